@@ -12,14 +12,18 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 
 	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg"
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
 	"github.com/deadsy/rvdbg/itf"
+	"github.com/deadsy/rvdbg/jtag"
+	"github.com/deadsy/rvdbg/server"
 	"github.com/deadsy/rvdbg/target"
-	"github.com/deadsy/rvdbg/target/gd32v"
-	"github.com/deadsy/rvdbg/target/maixgo"
-	"github.com/deadsy/rvdbg/target/redv"
-	"github.com/deadsy/rvdbg/target/wap"
+	"github.com/deadsy/rvdbg/util"
 	"github.com/deadsy/rvdbg/util/log"
 )
 
@@ -30,7 +34,50 @@ const MHz = 1000
 
 //-----------------------------------------------------------------------------
 
-func run(info *target.Info) error {
+// batch runs the command line and script commands.
+func batch(c *cli.CLI, tgt target.Target, cmds, script string) error {
+	b := target.NewBatch(c, tgt)
+	if cmds != "" {
+		err := b.Line(cmds)
+		if err != nil {
+			return err
+		}
+	}
+	if script != "" {
+		err := b.Script(script)
+		if err != nil {
+			return err
+		}
+	}
+	return b.Status()
+}
+
+// serve runs a JSON-RPC server for the target until interrupted.
+func serve(name string, jtagDriver jtag.Driver, address string) error {
+	t, err := rvdbg.New(name, jtagDriver)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+	s, err := server.New(t)
+	if err != nil {
+		return err
+	}
+	err = s.Listen(server.SplitAddress(address))
+	if err != nil {
+		return err
+	}
+	// stop the server on ctrl-c
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		s.Close()
+	}()
+	return s.Serve()
+}
+
+func run(info *target.Info, cmds, script, address string, format util.Format) error {
 
 	// create the debug interface
 	jtagDriver, err := itf.NewJtagDriver(info.DbgType, info.DbgSpeed)
@@ -39,24 +86,28 @@ func run(info *target.Info) error {
 	}
 	defer jtagDriver.Close()
 
-	// create the target
-	var tgt target.Target
-	switch info.Name {
-	case "wap":
-		tgt, err = wap.New(jtagDriver)
-	case "maixgo":
-		tgt, err = maixgo.New(jtagDriver)
-	case "gd32v":
-		tgt, err = gd32v.New(jtagDriver)
-	case "redv":
-		tgt, err = redv.New(jtagDriver)
+	// run the remote control server
+	if address != "" {
+		return serve(info.Name, jtagDriver, address)
 	}
+
+	// create the target
+	tgt, err := rvdbg.NewTarget(info.Name, jtagDriver)
 	if err != nil {
 		return err
 	}
+	tgt.SetFormat(format)
 
 	// create the cli
 	c := cli.NewCLI(tgt)
+
+	// run non-interactive commands
+	if cmds != "" || script != "" {
+		err := batch(c, tgt, cmds, script)
+		tgt.Shutdown()
+		return err
+	}
+
 	c.HistoryLoad(historyPath)
 	c.SetRoot(tgt.GetMenuRoot())
 
@@ -76,18 +127,80 @@ func run(info *target.Info) error {
 
 //-----------------------------------------------------------------------------
 
-func addTargets() {
-	target.Add(&gd32v.Info)
-	target.Add(&wap.Info)
-	target.Add(&maixgo.Info)
-	target.Add(&redv.Info)
+// options are the command line options.
+type options struct {
+	targetName    string // target name
+	interfaceName string // debug interface name
+	cmds          string // commands to run
+	script        string // script file to run
+	address       string // JSON-RPC server address
+	auth          string // debug module authentication key
+	json          bool   // JSON output format
 }
 
-//-----------------------------------------------------------------------------
+// newOptions defines the command line flags for the options.
+func newOptions(fs *flag.FlagSet) *options {
+	o := &options{}
+	fs.StringVar(&o.targetName, "t", "", "target name")
+	fs.StringVar(&o.interfaceName, "i", "", "debug interface name")
+	fs.StringVar(&o.cmds, "c", "", "run commands (\"<cmd>; <cmd>\") and exit")
+	fs.StringVar(&o.script, "x", "", "run commands from a script file and exit")
+	fs.BoolVar(&o.json, "json", false, "output command results as JSON objects")
+	fs.StringVar(&o.address, "s", "", "run a JSON-RPC server on [tcp:|unix:]<address> (E.g. localhost:4444)")
+	fs.StringVar(&o.auth, "auth", "", "authenticate with the debug module using key words (E.g. 0x12345678,0x9abcdef0)")
+	return o
+}
 
-func main() {
+// authKey returns the authentication key words for a comma separated key string.
+func authKey(s string) ([]uint32, error) {
+	key := []uint32{}
+	for _, x := range strings.Split(s, ",") {
+		k, err := strconv.ParseUint(strings.TrimSpace(x), 0, 32)
+		if err != nil {
+			return nil, fmt.Errorf("bad authentication key word \"%s\"", x)
+		}
+		key = append(key, uint32(k))
+	}
+	return key, nil
+}
 
-	addTargets()
+// format returns the output format for the options.
+func (o *options) format() util.Format {
+	if o.json {
+		return util.FormatJSON
+	}
+	return util.FormatText
+}
+
+// targetInfo returns the target information for the target and debug interface names.
+// The debug interface defaults to the target's interface.
+func targetInfo(targetName, interfaceName string) (*target.Info, error) {
+	if targetName == "" {
+		return nil, fmt.Errorf("use -t to specify a target name\n\ntargets:\n%s", target.List())
+	}
+	infoPtr := target.Lookup(targetName)
+	if infoPtr == nil {
+		return nil, fmt.Errorf("target \"%s\" not found\n\ntargets:\n%s", targetName, target.List())
+	}
+	// work out the debugger interface type
+	info := *infoPtr
+	if interfaceName == "" {
+		if info.DbgType == itf.TypeNone {
+			return nil, fmt.Errorf("use -i to specify an interface name\n\ndebug interfaces:\n%s", itf.List())
+		}
+		log.Info.Printf("using default debug interface: %s", info.DbgType)
+		return &info, nil
+	}
+	x := itf.Lookup(interfaceName)
+	if x == nil {
+		return nil, fmt.Errorf("debug interface \"%s\" not found\ndebug interfaces:\n%s", interfaceName, itf.List())
+	}
+	log.Info.Printf("using debug interface: %s", x.Type)
+	info.DbgType = x.Type
+	return &info, nil
+}
+
+func main() {
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
@@ -96,46 +209,31 @@ func main() {
 		fmt.Fprintf(os.Stderr, "\ntargets:\n%s\n", target.List())
 	}
 
-	targetName := flag.String("t", "", "target name")
-	interfaceName := flag.String("i", "", "debug interface name")
+	opt := newOptions(flag.CommandLine)
 	flag.Parse()
 
-	if *targetName == "" {
-		fmt.Fprintf(os.Stderr, "use -t to specify a target name\n")
-		fmt.Fprintf(os.Stderr, "\ntargets:\n%s\n", target.List())
-		os.Exit(1)
-	}
-
-	infoPtr := target.Lookup(*targetName)
-	if infoPtr == nil {
-		fmt.Fprintf(os.Stderr, "target \"%s\" not found\n", *targetName)
-		fmt.Fprintf(os.Stderr, "\ntargets:\n%s\n", target.List())
+	info, err := targetInfo(opt.targetName, opt.interfaceName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
 	}
 
-	// work out the debugger interface type
-	info := *infoPtr
-	if *interfaceName == "" {
-		if info.DbgType == itf.TypeNone {
-			fmt.Fprintf(os.Stderr, "use -i to specify an interface name\n")
-			fmt.Fprintf(os.Stderr, "\ndebug interfaces:\n%s\n", itf.List())
+	if opt.auth != "" {
+		key, err := authKey(opt.auth)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
 			os.Exit(1)
 		}
-		log.Info.Printf("using default debug interface: %s", info.DbgType)
-	} else {
-		x := itf.Lookup(*interfaceName)
-		if x == nil {
-			fmt.Fprintf(os.Stderr, "debug interface \"%s\" not found\n", *interfaceName)
-			fmt.Fprintf(os.Stderr, "debug interfaces:\n%s\n", itf.List())
-			os.Exit(1)
-		}
-		log.Info.Printf("using debug interface: %s", x.Type)
-		info.DbgType = x.Type
+		rvdbg.SetAuthenticator(info.Name, rv.NewKeyAuth(key))
 	}
 
-	err := run(&info)
+	err = run(info, opt.cmds, opt.script, opt.address, opt.format())
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", err)
+		// pass along the firmware exit code
+		if ee, ok := err.(*util.ExitError); ok {
+			os.Exit(ee.Status())
+		}
 		os.Exit(1)
 	}
 	os.Exit(0)
@@ -0,0 +1,126 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Debugger Command Line Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package main
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/deadsy/rvdbg/itf"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+func Test_Options(t *testing.T) {
+	tests := []struct {
+		args   []string
+		opt    options
+		format util.Format
+		err    bool
+	}{
+		{nil, options{}, util.FormatText, false},
+		{[]string{"-t", "maixgo"}, options{targetName: "maixgo"}, util.FormatText, false},
+		{
+			[]string{"-t", "gd32v", "-i", "jlink", "-c", "halt; gpr", "-json"},
+			options{targetName: "gd32v", interfaceName: "jlink", cmds: "halt; gpr", json: true},
+			util.FormatJSON, false,
+		},
+		{[]string{"-t", "redv", "-x", "test.rvdbg"}, options{targetName: "redv", script: "test.rvdbg"}, util.FormatText, false},
+		{[]string{"-t", "redv", "-s", "unix:/tmp/rvdbg"}, options{targetName: "redv", address: "unix:/tmp/rvdbg"}, util.FormatText, false},
+		{[]string{"-t", "redv", "-auth", "0x1234,5"}, options{targetName: "redv", auth: "0x1234,5"}, util.FormatText, false},
+		{[]string{"-bad"}, options{}, util.FormatText, true},
+		{[]string{"-t"}, options{}, util.FormatText, true},
+	}
+	for _, v := range tests {
+		fs := flag.NewFlagSet("rvdbg", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		opt := newOptions(fs)
+		err := fs.Parse(v.args)
+		if (err != nil) != v.err {
+			t.Errorf("%q: error %v", v.args, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if *opt != v.opt {
+			t.Errorf("%q: options %+v, expected %+v", v.args, *opt, v.opt)
+		}
+		if opt.format() != v.format {
+			t.Errorf("%q: format %v, expected %v", v.args, opt.format(), v.format)
+		}
+	}
+}
+
+func Test_TargetInfo(t *testing.T) {
+	tests := []struct {
+		target, itf string
+		typ         itf.Type
+		err         string
+	}{
+		{"", "", itf.TypeNone, "use -t"},
+		{"bogus", "", itf.TypeNone, "target \"bogus\" not found"},
+		{"gd32v", "", itf.TypeNone, "use -i"},
+		{"gd32v", "bogus", itf.TypeNone, "debug interface \"bogus\" not found"},
+		{"gd32v", "jlink", itf.TypeJlink, ""},
+		{"maixgo", "", itf.TypeDapLink, ""},
+		{"maixgo", "jlink", itf.TypeJlink, ""},
+	}
+	for _, v := range tests {
+		info, err := targetInfo(v.target, v.itf)
+		if v.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), v.err) {
+				t.Errorf("%q %q: error %v, expected %q", v.target, v.itf, err, v.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q %q: error %v", v.target, v.itf, err)
+			continue
+		}
+		if info.Name != v.target || info.DbgType != v.typ {
+			t.Errorf("%q %q: target %s interface %v", v.target, v.itf, info.Name, info.DbgType)
+		}
+	}
+	// the target database is not modified by the interface selection
+	info, _ := targetInfo("maixgo", "")
+	if info.DbgType != itf.TypeDapLink {
+		t.Errorf("maixgo default interface is %v", info.DbgType)
+	}
+}
+
+func Test_AuthKey(t *testing.T) {
+	tests := []struct {
+		s   string
+		key []uint32
+		err bool
+	}{
+		{"0x12345678", []uint32{0x12345678}, false},
+		{"0x12345678, 0x9abcdef0,7", []uint32{0x12345678, 0x9abcdef0, 7}, false},
+		{"0x123456789", nil, true},
+		{"0x1234,", nil, true},
+		{"key", nil, true},
+	}
+	for _, v := range tests {
+		key, err := authKey(v.s)
+		if (err != nil) != v.err {
+			t.Errorf("%q: error %v", v.s, err)
+			continue
+		}
+		if !reflect.DeepEqual(key, v.key) && !v.err {
+			t.Errorf("%q: key %x, expected %x", v.s, key, v.key)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
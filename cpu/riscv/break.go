@@ -0,0 +1,266 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Hardware Breakpoints
+
+A breakpoint is an mcontrol (0.13) or mcontrol6 (1.0) trigger that matches
+instruction execution at an address in all privilege modes and enters debug
+mode. It is only writable from debug mode (dmode = 1).
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import (
+	"errors"
+	"fmt"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/elf"
+	"github.com/deadsy/rvdbg/expr"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+// mcontrol/mcontrol6 tdata1 bits
+const (
+	mcLoad        = 1 << 0
+	mcStore       = 1 << 1
+	mcExecute     = 1 << 2
+	mcU           = 1 << 3
+	mcS           = 1 << 4
+	mcM           = 1 << 6
+	mcActionMask  = 15 << 12
+	mcActionDebug = 1 << 12
+)
+
+// bpTdata1 returns the tdata1 value for a breakpoint trigger.
+func bpTdata1(typ, xlen uint) uint64 {
+	return uint64(typ)<<(xlen-4) | 1<<(xlen-5) | mcActionDebug | mcM | mcS | mcU | mcExecute
+}
+
+// isMatchTrigger returns true for an address/data match trigger.
+func isMatchTrigger(typ uint) bool {
+	return typ == rv.TriggerMcontrol || typ == rv.TriggerMcontrol6
+}
+
+// isBreakpoint returns true if a trigger is a breakpoint.
+func isBreakpoint(tdata1 uint64, xlen uint) bool {
+	typ := rv.TriggerType(uint(tdata1), xlen)
+	dmode := util.Bit(uint(tdata1), xlen-5)
+	return isMatchTrigger(typ) && dmode == 1 && tdata1&mcActionMask == mcActionDebug && tdata1&mcExecute != 0
+}
+
+// isFree returns true if a trigger can be used for a breakpoint.
+func isFree(tdata1 uint64, xlen uint) bool {
+	typ := rv.TriggerType(uint(tdata1), xlen)
+	if typ == rv.TriggerDisabled {
+		return true
+	}
+	return isMatchTrigger(typ) && tdata1&(mcLoad|mcStore|mcExecute) == 0
+}
+
+// setBreakpoint sets a breakpoint using a free trigger. It returns the trigger index.
+func setBreakpoint(dbg rv.Debug, addr uint) (int, error) {
+	hi := dbg.GetCurrentHart()
+	tv, err := triggerValues(dbg)
+	if err != nil {
+		return 0, err
+	}
+	tselect, err := dbg.RdCSR(rv.TSELECT, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer dbg.WrCSR(rv.TSELECT, 0, tselect)
+	for _, v := range tv {
+		if isBreakpoint(v.Tdata1, hi.MXLEN) && v.Tdata2 == uint64(addr) {
+			return 0, fmt.Errorf("trigger%d is already a breakpoint at 0x%x", v.Index, addr)
+		}
+	}
+	for _, v := range tv {
+		if !isFree(v.Tdata1, hi.MXLEN) {
+			continue
+		}
+		err := dbg.WrCSR(rv.TSELECT, 0, uint64(v.Index))
+		if err != nil {
+			return 0, err
+		}
+		// try the 1.0 trigger type first, the type reads back differently if it isn't supported
+		for _, typ := range []uint{rv.TriggerMcontrol6, rv.TriggerMcontrol} {
+			err := dbg.WrCSR(rv.TDATA1, 0, 0)
+			if err != nil {
+				return 0, err
+			}
+			err = dbg.WrCSR(rv.TDATA2, 0, uint64(addr))
+			if err != nil {
+				return 0, err
+			}
+			err = dbg.WrCSR(rv.TDATA1, 0, bpTdata1(typ, hi.MXLEN))
+			if err != nil {
+				return 0, err
+			}
+			x, err := dbg.RdCSR(rv.TDATA1, 0)
+			if err != nil {
+				return 0, err
+			}
+			if isBreakpoint(x, hi.MXLEN) {
+				return v.Index, nil
+			}
+		}
+		// restore the trigger
+		err = dbg.WrCSR(rv.TDATA1, 0, v.Tdata1)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return 0, errors.New("no free trigger for the breakpoint")
+}
+
+// clrBreakpoints clears breakpoints (n < 0 == all breakpoints).
+func clrBreakpoints(dbg rv.Debug, n int) error {
+	hi := dbg.GetCurrentHart()
+	tv, err := triggerValues(dbg)
+	if err != nil {
+		return err
+	}
+	tselect, err := dbg.RdCSR(rv.TSELECT, 0)
+	if err != nil {
+		return err
+	}
+	defer dbg.WrCSR(rv.TSELECT, 0, tselect)
+	found := false
+	for _, v := range tv {
+		if !isBreakpoint(v.Tdata1, hi.MXLEN) || (n >= 0 && v.Index != n) {
+			continue
+		}
+		found = true
+		err := dbg.WrCSR(rv.TSELECT, 0, uint64(v.Index))
+		if err != nil {
+			return err
+		}
+		err = dbg.WrCSR(rv.TDATA1, 0, 0)
+		if err != nil {
+			return err
+		}
+	}
+	if n >= 0 && !found {
+		return fmt.Errorf("trigger%d is not a breakpoint", n)
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+
+// breakpoint is a breakpoint trigger.
+type breakpoint struct {
+	Index  int    `json:"index"`
+	Addr   uint64 `json:"addr"`
+	Symbol string `json:"symbol,omitempty"`
+}
+
+// breakpoints returns the breakpoints of the current hart.
+func breakpoints(dbg rv.Debug) ([]*breakpoint, error) {
+	hi := dbg.GetCurrentHart()
+	tv, err := triggerValues(dbg)
+	if err != nil {
+		return nil, err
+	}
+	bp := []*breakpoint{}
+	for _, v := range tv {
+		if !isBreakpoint(v.Tdata1, hi.MXLEN) {
+			continue
+		}
+		b := &breakpoint{Index: v.Index, Addr: v.Tdata2}
+		if f := elf.Current(); f != nil {
+			b.Symbol = f.SymbolString(uint(v.Tdata2))
+		}
+		bp = append(bp, b)
+	}
+	return bp, nil
+}
+
+//-----------------------------------------------------------------------------
+
+// BreakHelp is help for the break command.
+var BreakHelp = []cli.Help{
+	{"<cr>", "display the breakpoints"},
+	{"<addr>", "set a breakpoint"},
+	{"clr <n|*>", "clear a breakpoint"},
+	{"  addr", "address (expression)"},
+	{"  n", "trigger number (decimal), * for all breakpoints"},
+}
+
+// CmdBreak displays, sets and clears hardware breakpoints on the current hart.
+var CmdBreak = cli.Leaf{
+	Descr: "hardware breakpoints",
+	F: func(c *cli.CLI, args []string) {
+		dbg := c.User.(target).GetRiscvDebug()
+		hi := dbg.GetCurrentHart()
+		if len(args) != 0 && args[0] == "clr" {
+			err := cli.CheckArgc(args, []int{2})
+			if err != nil {
+				util.PutError(c.User, err)
+				return
+			}
+			n := -1
+			if args[1] != "*" {
+				n, err = cli.IntArg(args[1], [2]int{0, maxTriggers - 1}, 10)
+				if err != nil {
+					util.PutError(c.User, err)
+					return
+				}
+			}
+			err = clrBreakpoints(dbg, n)
+			if err != nil {
+				util.PutError(c.User, err)
+			}
+			return
+		}
+		if len(args) != 0 {
+			maxAddr := uint((1 << dbg.GetAddressSize()) - 1)
+			addr, err := expr.UintArg(expr.GetContext(c.User), args[0], [2]uint{0, maxAddr})
+			if err != nil {
+				util.PutError(c.User, err)
+				return
+			}
+			err = cli.CheckArgc(args, []int{1})
+			if err != nil {
+				util.PutError(c.User, err)
+				return
+			}
+			n, err := setBreakpoint(dbg, addr)
+			if err != nil {
+				util.PutError(c.User, fmt.Errorf("unable to set breakpoint: %v", err))
+				return
+			}
+			c.User.Put(fmt.Sprintf("trigger%d: breakpoint at 0x%x\n", n, addr))
+			return
+		}
+		bp, err := breakpoints(dbg)
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("unable to read triggers: %v", err))
+			return
+		}
+		if util.IsJSON(c.User) {
+			for _, b := range bp {
+				util.PutJSON(c.User, b)
+			}
+			return
+		}
+		if len(bp) == 0 {
+			c.User.Put("no breakpoints\n")
+			return
+		}
+		fmtx := util.UintFormat(hi.MXLEN)
+		s := [][]string{}
+		for _, b := range bp {
+			s = append(s, []string{fmt.Sprintf("trigger%d", b.Index), fmt.Sprintf(fmtx, b.Addr), b.Symbol})
+		}
+		c.User.Put(fmt.Sprintf("%s\n", cli.TableString(s, []int{0, 0, 0}, 1)))
+	},
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,47 @@
+//-----------------------------------------------------------------------------
+/*
+
+Hardware Breakpoint Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import "testing"
+
+//-----------------------------------------------------------------------------
+
+func Test_Breakpoint(t *testing.T) {
+	tests := []struct {
+		xlen   uint
+		tdata1 uint64
+		bp     bool
+		free   bool
+	}{
+		{32, bpTdata1(2, 32), true, false},
+		{32, bpTdata1(6, 32), true, false},
+		{64, bpTdata1(2, 64), true, false},
+		{32, 0x20000000 | mcActionDebug | mcM | mcExecute, false, false}, // dmode == 0
+		{32, 0x28000000 | mcM | mcExecute, false, false},                 // action == breakpoint exception
+		{32, 0x28000000 | mcM | mcLoad, false, false},                    // load watchpoint
+		{32, 0x28000000, false, true},                                    // mcontrol, no match enabled
+		{64, 6 << 60, false, true},                                       // mcontrol6, no match enabled
+		{32, 0xf0000000, false, true},                                    // disabled
+		{32, 0x30000000, false, false},                                   // icount
+		{32, 0, false, false},                                            // none
+	}
+	for _, v := range tests {
+		if x := isBreakpoint(v.tdata1, v.xlen); x != v.bp {
+			t.Errorf("tdata1 0x%x: isBreakpoint %t, expected %t", v.tdata1, x, v.bp)
+		}
+		if x := isFree(v.tdata1, v.xlen); x != v.free {
+			t.Errorf("tdata1 0x%x: isFree %t, expected %t", v.tdata1, x, v.free)
+		}
+	}
+	if x := bpTdata1(2, 32); x != 0x2800105c {
+		t.Errorf("mcontrol breakpoint tdata1 0x%x, expected 0x2800105c", x)
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,254 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Stack Backtrace
+
+Unwind the stack of the current hart. The call frame information of the
+loaded ELF file is used when it covers the pc, otherwise the frame pointer
+(s0) chain is followed.
+
+Frame pointer layout (gcc -fno-omit-frame-pointer):
+
+fp - 1*XLEN/8: saved ra
+fp - 2*XLEN/8: saved fp
+
+A leaf function may only save the fp, in which case it is at fp - 1*XLEN/8.
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import (
+	"errors"
+	"fmt"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/elf"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+const maxFrames = 64          // maximum number of backtrace frames
+const maxFrameSize = 64 << 10 // maximum stack frame size for frame pointer checks
+const regRA, regSP, regFP = 1, 2, 8
+
+// btFrame is a backtrace stack frame.
+type btFrame struct {
+	N      int    `json:"frame"`
+	PC     uint64 `json:"pc"`
+	SP     uint64 `json:"sp"`
+	Symbol string `json:"symbol,omitempty"`
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Method string `json:"method,omitempty"` // how the caller frame was found (cfi/fp)
+}
+
+// unwinder is the state for a stack unwind.
+type unwinder struct {
+	dbg  rv.Debug
+	xlen uint
+	f    *elf.File // nil == no ELF file
+	pc   uint64
+	regs [32]uint64
+}
+
+// rdWord reads an XLEN-bit word from memory.
+func (u *unwinder) rdWord(addr uint64) (uint64, error) {
+	x, err := u.dbg.RdMem(u.xlen, uint(addr), 1)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(x[0]), nil
+}
+
+// stepCFI unwinds a frame using call frame information.
+// It returns false if there is no information for the pc.
+func (u *unwinder) stepCFI(n int) (bool, error) {
+	if u.f == nil || u.f.Frames() == nil {
+		return false, nil
+	}
+	// the return address is after the call
+	pc := u.pc
+	if n > 0 {
+		pc--
+	}
+	fr, err := u.f.Frames().Lookup(uint(pc))
+	if err != nil || fr == nil {
+		return false, err
+	}
+	if fr.CFAReg >= 32 {
+		return false, fmt.Errorf("bad cfa register %d", fr.CFAReg)
+	}
+	cfa := u.regs[fr.CFAReg] + uint64(fr.CFAOffset)
+	regs := u.regs
+	// no rule == the return address is unchanged
+	ra := uint64(0)
+	if fr.RA < 32 {
+		ra = u.regs[fr.RA]
+	}
+	for reg, rule := range fr.Regs {
+		var x uint64
+		switch rule.Kind {
+		case elf.RuleSameValue:
+			continue
+		case elf.RuleUndefined:
+			// an undefined return address is the end of the stack
+			if reg == fr.RA {
+				ra = 0
+			}
+			continue
+		case elf.RuleOffset:
+			x, err = u.rdWord(cfa + uint64(rule.Offset))
+			if err != nil {
+				return false, err
+			}
+		case elf.RuleValOffset:
+			x = cfa + uint64(rule.Offset)
+		case elf.RuleRegister:
+			if rule.Reg >= 32 {
+				continue
+			}
+			x = u.regs[rule.Reg]
+		}
+		if reg == fr.RA {
+			ra = x
+		}
+		if reg < 32 {
+			regs[reg] = x
+		}
+	}
+	regs[regSP] = cfa
+	u.regs = regs
+	u.pc = ra
+	return true, nil
+}
+
+// stepFP unwinds a frame using the frame pointer.
+func (u *unwinder) stepFP(n int) error {
+	fp := u.regs[regFP]
+	sp := u.regs[regSP]
+	bytes := uint64(u.xlen >> 3)
+	// the frame pointer is the 16 byte aligned cfa
+	if fp == 0 || fp&15 != 0 || fp < sp || fp-sp > maxFrameSize {
+		return errors.New("no valid frame pointer")
+	}
+	x, err := u.rdWord(fp - bytes)
+	if err != nil {
+		return err
+	}
+	if n == 0 && x&15 == 0 && x > fp && x-fp <= maxFrameSize {
+		// looks like a leaf function that only saved the fp
+		u.pc = u.regs[regRA]
+		u.regs[regFP] = x
+	} else {
+		u.pc = x
+		u.regs[regFP], err = u.rdWord(fp - 2*bytes)
+		if err != nil {
+			return err
+		}
+	}
+	u.regs[regSP] = fp
+	return nil
+}
+
+// backtrace unwinds the stack of the current hart.
+func backtrace(state *CmdState, dbg rv.Debug, f *elf.File) ([]*btFrame, error) {
+	hi := dbg.GetCurrentHart()
+	if hi.State != rv.Halted {
+		return nil, fmt.Errorf("hart%d is not halted", hi.ID)
+	}
+	u := &unwinder{dbg: dbg, xlen: hi.MXLEN, f: f}
+	// the hart or the selected rtos task
+	reg, err := state.rdGPRs(dbg)
+	if err != nil {
+		return nil, err
+	}
+	copy(u.regs[:], reg[:len(reg)-1])
+	u.pc = reg[len(reg)-1]
+
+	frames := []*btFrame{}
+	for n := 0; n < maxFrames; n++ {
+		fr := &btFrame{N: n, PC: u.pc, SP: u.regs[regSP]}
+		frames = append(frames, fr)
+		if f != nil {
+			// look up the call instruction for the caller frames
+			pc := uint(u.pc)
+			if n > 0 {
+				pc--
+			}
+			fr.Symbol = f.SymbolString(pc)
+			fr.File, fr.Line = f.LineAt(pc)
+		}
+		// unwind to the caller
+		pc, sp := u.pc, u.regs[regSP]
+		fr.Method = "cfi"
+		ok, err := u.stepCFI(n)
+		if err != nil {
+			return frames, err
+		}
+		if !ok {
+			fr.Method = "fp"
+			if u.stepFP(n) != nil {
+				// end of the frame pointer chain
+				fr.Method = ""
+				break
+			}
+		}
+		// stop at the end of the stack
+		newSP := u.regs[regSP]
+		if u.pc == 0 || newSP < sp || (newSP == sp && u.pc == pc) {
+			break
+		}
+	}
+	return frames, nil
+}
+
+//-----------------------------------------------------------------------------
+
+func btString(frames []*btFrame, xlen uint) string {
+	fmtx := util.UintFormat(xlen)
+	s := [][]string{}
+	for _, fr := range frames {
+		line := ""
+		if fr.Line != 0 {
+			line = fmt.Sprintf("%s:%d", fr.File, fr.Line)
+		}
+		s = append(s, []string{fmt.Sprintf("#%d", fr.N), fmt.Sprintf(fmtx, fr.PC), fr.Symbol, line})
+	}
+	return cli.TableString(s, []int{0, 0, 0, 0}, 1)
+}
+
+// BacktraceHelp is help for the bt command.
+var BacktraceHelp = []cli.Help{
+	{"<cr>", "backtrace the current hart (or the task selected with \"threads\")"},
+	{"", "the call frame information of the ELF file (see \"elf\") is used when available"},
+}
+
+// CmdBacktrace displays a stack backtrace for the current hart.
+var CmdBacktrace = cli.Leaf{
+	Descr: "stack backtrace",
+	F: func(c *cli.CLI, args []string) {
+		err := cli.CheckArgc(args, []int{0})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		dbg := c.User.(target).GetRiscvDebug()
+		frames, err := backtrace(c.User.(target).GetRiscvState(), dbg, elf.Current())
+		if util.IsJSON(c.User) {
+			for _, fr := range frames {
+				util.PutJSON(c.User, fr)
+			}
+		} else if len(frames) != 0 {
+			c.User.Put(fmt.Sprintf("%s\n", btString(frames, dbg.GetCurrentHart().MXLEN)))
+		}
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("unable to unwind: %v", err))
+		}
+	},
+}
+
+//-----------------------------------------------------------------------------
@@ -12,18 +12,23 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	cli "github.com/deadsy/go-cli"
 	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/expr"
+	"github.com/deadsy/rvdbg/jtag"
 	"github.com/deadsy/rvdbg/soc"
+	"github.com/deadsy/rvdbg/util"
 )
 
 //-----------------------------------------------------------------------------
 
-// target provides a method for getting the CPU debugger driver.
+// target provides methods for getting the CPU debugger driver and command state.
 type target interface {
 	GetRiscvDebug() rv.Debug
 	GetCSR() (*soc.Device, soc.Driver)
+	GetRiscvState() *CmdState
 }
 
 //-----------------------------------------------------------------------------
@@ -32,6 +37,8 @@ type target interface {
 // CsrHelp is help information for the "csr" command.
 var CsrHelp = []cli.Help{
 	{"[register]", "register (string) - register name (or *)"},
+	{"<register> = <val>", "write a register"},
+	{"  val", "register value (expression)"},
 	{"<cr>", "display all registers"},
 }
 
@@ -40,9 +47,18 @@ var CmdCSR = cli.Leaf{
 	Descr: "display control and status registers",
 	F: func(c *cli.CLI, args []string) {
 
+		if len(args) > 1 {
+			err := regWrite(c, args)
+			if err != nil {
+				util.PutError(c.User, err)
+				return
+			}
+			args = args[:1]
+		}
+
 		err := cli.CheckArgc(args, []int{0, 1})
 		if err != nil {
-			c.User.Put(fmt.Sprintf("%s\n", err))
+			util.PutError(c.User, err)
 			return
 		}
 
@@ -51,25 +67,55 @@ var CmdCSR = cli.Leaf{
 		p := csr.GetPeripheral("CSR")
 
 		if len(args) == 0 {
-			c.User.Put(fmt.Sprintf("%s\n", p.Display(drv, nil, false)))
+			p.Put(c.User, drv, nil, false)
 			return
 		}
 
 		if args[0] == "*" {
-			c.User.Put(fmt.Sprintf("%s\n", p.Display(drv, nil, true)))
+			p.Put(c.User, drv, nil, true)
 			return
 		}
 
 		r := p.GetRegister(args[0])
 		if r == nil {
-			c.User.Put(fmt.Sprintf("no register \"%s\" (run \"csr\" for the names)\n", args[0]))
+			util.PutError(c.User, fmt.Errorf("no register \"%s\" (run \"csr\" for the names)", args[0]))
 			return
 		}
 
-		c.User.Put(fmt.Sprintf("%s\n", p.Display(drv, r, true)))
+		p.Put(c.User, drv, r, true)
 	},
 }
 
+//-----------------------------------------------------------------------------
+// write a register
+
+// regWrite parses "<reg> = <val>" and writes a register of the current hart.
+func regWrite(c *cli.CLI, args []string) error {
+	s := strings.SplitN(strings.Join(args, " "), "=", 2)
+	if len(s) != 2 {
+		return errors.New("usage: <reg> = <val>")
+	}
+	if c.User.(target).GetRiscvState().thread != nil {
+		return errors.New("can't write registers in a task context, see \"threads\"")
+	}
+	ctx, ok := expr.GetContext(c.User).(*Context)
+	if !ok {
+		return errors.New("no register context")
+	}
+	dbg := c.User.(target).GetRiscvDebug()
+	err := dbg.HaltHart()
+	if err != nil {
+		return fmt.Errorf("unable to halt hart%d: %v", dbg.GetCurrentHart().ID, err)
+	}
+	name := strings.TrimSpace(s[0])
+	maxVal := uint((1 << dbg.GetCurrentHart().MXLEN) - 1)
+	val, err := expr.UintArg(ctx, strings.TrimSpace(s[1]), [2]uint{0, maxVal})
+	if err != nil {
+		return err
+	}
+	return ctx.SetRegister(name, val)
+}
+
 //-----------------------------------------------------------------------------
 // display general purpose register set
 
@@ -82,64 +128,100 @@ var abiXName = [32]string{
 
 var gprCache []uint64
 
+// gprValue is the value of a general purpose register.
+type gprValue struct {
+	Reg     string `json:"reg"`
+	ABI     string `json:"abi,omitempty"`
+	Value   uint64 `json:"value"`
+	Changed bool   `json:"changed"`
+}
+
+// gprValues returns the values of the general purpose registers and the pc.
+func gprValues(reg []uint64) []gprValue {
+	if gprCache == nil {
+		gprCache = reg
+	}
+	v := make([]gprValue, len(reg))
+	for i := range reg {
+		v[i] = gprValue{Value: reg[i], Changed: reg[i] != gprCache[i]}
+		if i == len(reg)-1 {
+			v[i].Reg = "pc"
+		} else {
+			v[i].Reg = fmt.Sprintf("x%d", i)
+			v[i].ABI = abiXName[i]
+		}
+	}
+	gprCache = reg
+	return v
+}
+
 func gprString(reg []uint64, xlen uint) string {
 	fmtx := "%08x"
 	if xlen == 64 {
 		fmtx = "%016x"
 	}
-	if gprCache == nil {
-		gprCache = reg
-	}
-	s := make([]string, len(reg))
-	for i := 0; i < len(reg); i++ {
+	v := gprValues(reg)
+	s := make([]string, len(v))
+	for i := range v {
 		delta := ""
-		if reg[i] != gprCache[i] {
+		if v[i].Changed {
 			delta = " *"
 		}
-		if i == len(reg)-1 {
-			s[i] = fmt.Sprintf("%-9s "+fmtx+"%s", "pc", reg[i], delta)
+		if v[i].ABI == "" {
+			s[i] = fmt.Sprintf("%-9s "+fmtx+"%s", v[i].Reg, v[i].Value, delta)
 		} else {
-			regStr := fmt.Sprintf("x%d", i)
 			valStr := "0"
-			if reg[i] != 0 {
-				valStr = fmt.Sprintf(fmtx, reg[i])
+			if v[i].Value != 0 {
+				valStr = fmt.Sprintf(fmtx, v[i].Value)
 			}
-			s[i] = fmt.Sprintf("%-4s %-4s %s%s", regStr, abiXName[i], valStr, delta)
+			s[i] = fmt.Sprintf("%-4s %-4s %s%s", v[i].Reg, v[i].ABI, valStr, delta)
 		}
 	}
-	gprCache = reg
 	return strings.Join(s, "\n")
 }
 
-// CmdGpr displays the general purpose registers.
+// GprHelp is help for the gpr command.
+var GprHelp = []cli.Help{
+	{"<cr>", "display the general purpose registers"},
+	{"<reg> = <val>", "write a general purpose register or the pc"},
+	{"  reg", "register name (E.g. x10, a0, pc)"},
+	{"  val", "register value (expression)"},
+}
+
+// CmdGpr displays and writes the general purpose registers.
 var CmdGpr = cli.Leaf{
-	Descr: "display general purpose registers",
+	Descr: "display/write general purpose registers",
 	F: func(c *cli.CLI, args []string) {
 		dbg := c.User.(target).GetRiscvDebug()
 		hi := dbg.GetCurrentHart()
+		if len(args) != 0 {
+			err := regWrite(c, args)
+			if err != nil {
+				util.PutError(c.User, err)
+			}
+			return
+		}
 		err := dbg.HaltHart()
 		if err != nil {
-			c.User.Put(fmt.Sprintf("unable to halt hart%d: %v\n", hi.ID, err))
+			util.PutError(c.User, fmt.Errorf("unable to halt hart%d: %v", hi.ID, err))
 			return
 		}
-		// slice of register values, +1 for the pc
-		reg := make([]uint64, hi.Nregs+1)
-		// read the GPRs
-		for i := 0; i < hi.Nregs; i++ {
-			var err error
-			reg[i], err = dbg.RdGPR(uint(i), 0)
-			if err != nil {
-				c.User.Put(fmt.Sprintf("unable to read gpr%d: %v\n", i, err))
-				return
-			}
-		}
-		// read the PC
-		pc, err := dbg.RdCSR(rv.DPC, 0)
+		// the hart or the selected rtos task
+		state := c.User.(target).GetRiscvState()
+		reg, err := state.rdGPRs(dbg)
 		if err != nil {
-			c.User.Put(fmt.Sprintf("unable to read pc: %v\n", err))
+			util.PutError(c.User, err)
 			return
 		}
-		reg[len(reg)-1] = pc
+		if util.IsJSON(c.User) {
+			for _, v := range gprValues(reg) {
+				util.PutJSON(c.User, &v)
+			}
+			return
+		}
+		if state.thread != nil {
+			c.User.Put(fmt.Sprintf("task context (tcb 0x%x), see \"threads\"\n", state.thread.tcb))
+		}
 		c.User.Put(fmt.Sprintf("%s\n", gprString(reg, hi.MXLEN)))
 	},
 }
@@ -156,42 +238,91 @@ var abiFName = [32]string{
 
 var fprCache []uint64
 
+// fprValue is the value of a floating point register.
+type fprValue struct {
+	Reg     string `json:"reg"`
+	ABI     string `json:"abi"`
+	Value   uint64 `json:"value"`
+	Float   string `json:"float"`
+	Changed bool   `json:"changed"`
+}
+
+// fprValues returns the values of the floating point registers.
+func fprValues(reg []uint64, flen uint) []fprValue {
+	if fprCache == nil {
+		fprCache = reg
+	}
+	v := make([]fprValue, len(reg))
+	for i := range reg {
+		v[i] = fprValue{
+			Reg:     fmt.Sprintf("f%d", i),
+			ABI:     abiFName[i],
+			Value:   reg[i],
+			Float:   fprFloat(reg[i], flen),
+			Changed: reg[i] != fprCache[i],
+		}
+	}
+	fprCache = reg
+	return v
+}
+
 func fprString(reg []uint64, flen uint) string {
 	fmtx := "%08x"
 	if flen == 64 {
 		fmtx = "%016x"
 	}
-	if fprCache == nil {
-		fprCache = reg
-	}
-	s := make([]string, len(reg))
-	for i := 0; i < len(reg); i++ {
+	v := fprValues(reg, flen)
+	s := make([][]string, len(v))
+	for i := range v {
 		delta := ""
-		if reg[i] != fprCache[i] {
-			delta = " *"
+		if v[i].Changed {
+			delta = "*"
 		}
-		regStr := fmt.Sprintf("f%d", i)
 		valStr := "0"
-		if reg[i] != 0 {
-			valStr = fmt.Sprintf(fmtx, reg[i])
+		if v[i].Value != 0 {
+			valStr = fmt.Sprintf(fmtx, v[i].Value)
 		}
-		s[i] = fmt.Sprintf("%-4s %-4s %s%s", regStr, abiFName[i], valStr, delta)
+		s[i] = []string{v[i].Reg, v[i].ABI, valStr, v[i].Float, delta}
 	}
-	fprCache = reg
-	return strings.Join(s, "\n")
+	return cli.TableString(s, []int{4, 4, 0, 0, 0}, 1)
 }
 
-// CmdFpr displays the floating point registers.
+// FprHelp is help for the fpr command.
+var FprHelp = []cli.Help{
+	{"<cr>", "display the floating point registers and fcsr"},
+	{"<reg> = <float> [f32|f64]", "write a floating point register"},
+	{"  reg", "register name (E.g. f10, fa0)"},
+	{"  float", "floating point value (E.g. 1.5, -2e-3, inf, nan)"},
+	{"  f32|f64", "value type, default is flen, f32 values are NaN-boxed when flen is 64"},
+}
+
+// CmdFpr displays and writes the floating point registers.
 var CmdFpr = cli.Leaf{
-	Descr: "display floating point registers",
+	Descr: "display/write floating point registers",
 	F: func(c *cli.CLI, args []string) {
 		dbg := c.User.(target).GetRiscvDebug()
 		hi := dbg.GetCurrentHart()
+		if hi.FLEN == 0 {
+			util.PutError(c.User, fmt.Errorf("hart%d has no floating point registers", hi.ID))
+			return
+		}
 		err := dbg.HaltHart()
 		if err != nil {
-			c.User.Put(fmt.Sprintf("unable to halt hart%d: %v\n", hi.ID, err))
+			util.PutError(c.User, fmt.Errorf("unable to halt hart%d: %v", hi.ID, err))
 			return
 		}
+		if len(args) != 0 {
+			reg, val, err := fprWrite(args, hi.FLEN)
+			if err != nil {
+				util.PutError(c.User, err)
+				return
+			}
+			err = dbg.WrFPR(reg, 0, val)
+			if err != nil {
+				util.PutError(c.User, fmt.Errorf("unable to write fpr%d: %v", reg, err))
+				return
+			}
+		}
 		// slice of register values
 		reg := make([]uint64, hi.Nregs)
 		// read the FPRs
@@ -199,49 +330,211 @@ var CmdFpr = cli.Leaf{
 			var err error
 			reg[i], err = dbg.RdFPR(uint(i), 0)
 			if err != nil {
-				c.User.Put(fmt.Sprintf("unable to read fpr%d: %v\n", i, err))
+				util.PutError(c.User, fmt.Errorf("unable to read fpr%d: %v", i, err))
 				return
 			}
 		}
+		fcsr, err := dbg.RdCSR(rv.FCSR, 0)
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("unable to read fcsr: %v", err))
+			return
+		}
+		if util.IsJSON(c.User) {
+			for _, v := range fprValues(reg, hi.FLEN) {
+				util.PutJSON(c.User, &v)
+			}
+			util.PutJSON(c.User, newFcsrValue(fcsr))
+			return
+		}
 		c.User.Put(fmt.Sprintf("%s\n", fprString(reg, hi.FLEN)))
+		c.User.Put(fmt.Sprintf("%s\n", fcsrString(fcsr)))
 	},
 }
 
 //-----------------------------------------------------------------------------
 
+// HaltHelp is help for the halt command.
+var HaltHelp = []cli.Help{
+	{"<cr>", "halt the current hart"},
+	{"all", "halt all harts in the hart group"},
+}
+
 // CmdHalt halts the current hart.
 var CmdHalt = cli.Leaf{
 	Descr: "halt the current hart",
 	F: func(c *cli.CLI, args []string) {
 		dbg := c.User.(target).GetRiscvDebug()
+		all, err := allArg(args)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		if all {
+			err := dbg.HaltAll()
+			if err != nil {
+				util.PutError(c.User, fmt.Errorf("unable to halt harts: %v", err))
+			}
+			return
+		}
 		hi := dbg.GetCurrentHart()
 		if hi.State == rv.Halted {
 			c.User.Put(fmt.Sprintf("hart%d already halted\n", hi.ID))
 			return
 		}
-		err := dbg.HaltHart()
+		err = dbg.HaltHart()
 		if err != nil {
-			c.User.Put(fmt.Sprintf("unable to halt hart%d: %v\n", hi.ID, err))
+			util.PutError(c.User, fmt.Errorf("unable to halt hart%d: %v", hi.ID, err))
 			return
 		}
 	},
 }
 
+// ResumeHelp is help for the resume command.
+var ResumeHelp = []cli.Help{
+	{"<cr>", "resume the current hart"},
+	{"all", "resume all harts in the hart group"},
+}
+
 // CmdResume resumes the current hart.
 var CmdResume = cli.Leaf{
 	Descr: "resume the current hart",
 	F: func(c *cli.CLI, args []string) {
 		dbg := c.User.(target).GetRiscvDebug()
+		all, err := allArg(args)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		// the saved task contexts are no longer valid
+		state := c.User.(target).GetRiscvState()
+		state.thread = nil
+		host := state.host
+		if all {
+			ids := dbg.GetHartGroup()
+			if host != nil {
+				// halt on ebreak for semihosting requests
+				err = groupEbreak(dbg, ids)
+				if err != nil {
+					util.PutError(c.User, fmt.Errorf("unable to enable semihosting: %v", err))
+					return
+				}
+			}
+			err := dbg.ResumeAll()
+			if err != nil {
+				util.PutError(c.User, fmt.Errorf("unable to resume harts: %v", err))
+				return
+			}
+			if host != nil {
+				semihostRun(c, dbg, ids)
+			}
+			return
+		}
 		hi := dbg.GetCurrentHart()
 		if hi.State == rv.Running {
 			c.User.Put(fmt.Sprintf("hart%d already running\n", hi.ID))
 			return
 		}
-		err := dbg.ResumeHart()
+		if host != nil {
+			// halt on ebreak for semihosting requests
+			err = setEbreak(dbg, true)
+			if err != nil {
+				util.PutError(c.User, fmt.Errorf("unable to enable semihosting: %v", err))
+				return
+			}
+		}
+		err = dbg.ResumeHart()
 		if err != nil {
-			c.User.Put(fmt.Sprintf("unable to resume hart%d: %v\n", hi.ID, err))
+			util.PutError(c.User, fmt.Errorf("unable to resume hart%d: %v", hi.ID, err))
 			return
 		}
+		if host != nil {
+			semihostRun(c, dbg, []int{hi.ID})
+		}
+	},
+}
+
+// allArg returns true if a halt/resume command is for all harts.
+func allArg(args []string) (bool, error) {
+	err := cli.CheckArgc(args, []int{0, 1})
+	if err != nil {
+		return false, err
+	}
+	if len(args) == 0 {
+		return false, nil
+	}
+	if args[0] != "all" {
+		return false, fmt.Errorf("bad argument \"%s\"", args[0])
+	}
+	return true, nil
+}
+
+//-----------------------------------------------------------------------------
+
+// jtagTarget provides a method for getting the JTAG device.
+type jtagTarget interface {
+	GetJtagDevice() *jtag.Device
+}
+
+const srstDelay = 100 * time.Millisecond
+
+// ResetHelp is help for the reset command.
+var ResetHelp = []cli.Help{
+	{"<cr>", "reset the system, harts run after reset"},
+	{"run", "reset the system, harts run after reset"},
+	{"halt", "reset the system, harts halt after reset"},
+	{"init", "reset the debug module, then reset the system and halt"},
+	{"system", "reset the system with the SRST line"},
+	{"hart [halt]", "reset the current hart, optionally halt after reset"},
+}
+
+// CmdReset resets the target.
+var CmdReset = cli.Leaf{
+	Descr: "reset control",
+	F: func(c *cli.CLI, args []string) {
+		err := cli.CheckArgc(args, []int{0, 1, 2})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		dbg := c.User.(target).GetRiscvDebug()
+		c.User.(target).GetRiscvState().thread = nil
+		mode := "run"
+		if len(args) != 0 {
+			mode = args[0]
+		}
+		if len(args) == 2 && (mode != "hart" || args[1] != "halt") {
+			util.PutError(c.User, fmt.Errorf("bad argument \"%s\"", args[1]))
+			return
+		}
+		switch mode {
+		case "run":
+			err = dbg.ResetSystem(false)
+		case "halt":
+			err = dbg.ResetSystem(true)
+		case "init":
+			err = dbg.ResetDebug()
+			if err == nil {
+				err = dbg.ResetSystem(true)
+			}
+		case "system":
+			jt, ok := c.User.(jtagTarget)
+			if !ok {
+				util.PutError(c.User, errors.New("target has no jtag device"))
+				return
+			}
+			err = jt.GetJtagDevice().SystemReset(srstDelay)
+			if err == nil {
+				// the debug module may also have been reset
+				err = dbg.ResetDebug()
+			}
+		case "hart":
+			err = dbg.ResetHart(len(args) == 2)
+		default:
+			err = fmt.Errorf("unknown reset \"%s\"", mode)
+		}
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("reset %s failed: %v", mode, err))
+		}
 	},
 }
 
@@ -251,18 +544,212 @@ var CmdResume = cli.Leaf{
 var HartHelp = []cli.Help{
 	{"<cr>", "display info for current hart"},
 	{"<id>", "select hart<id> as the current hart"},
+	{"group", "display the hart states and the hart group"},
+	{"group all", "set the hart group to all harts"},
+	{"group <id> ...", "set the harts in the hart group (halt/resume all)"},
+}
+
+// hartStatus is the state of a hart within the hart summary.
+type hartStatus struct {
+	ID      int          `json:"id"`
+	State   rv.HartState `json:"state"`
+	Group   bool         `json:"group"`
+	Current bool         `json:"current"`
+}
+
+// hartSummary outputs the state of all harts and the hart group.
+func hartSummary(c *cli.CLI, dbg rv.Debug) {
+	state, err := dbg.HartStates()
+	if err != nil {
+		util.PutError(c.User, fmt.Errorf("unable to get hart states: %v", err))
+		return
+	}
+	group := map[int]bool{}
+	for _, id := range dbg.GetHartGroup() {
+		group[id] = true
+	}
+	cur := dbg.GetCurrentHart().ID
+	s := [][]string{}
+	for i := range state {
+		hs := &hartStatus{i, state[i], group[i], i == cur}
+		if util.IsJSON(c.User) {
+			util.PutJSON(c.User, hs)
+			continue
+		}
+		s = append(s, []string{
+			fmt.Sprintf("hart%d", i),
+			state[i].String(),
+			[]string{"", "group"}[util.BoolToInt(hs.Group)],
+			[]string{"", "*"}[util.BoolToInt(hs.Current)],
+		})
+	}
+	if len(s) != 0 {
+		c.User.Put(fmt.Sprintf("%s\n", cli.TableString(s, []int{0, 0, 0, 0}, 1)))
+	}
+}
+
+// hartGroup sets or displays the hart group.
+func hartGroup(c *cli.CLI, dbg rv.Debug, args []string) {
+	if len(args) == 0 {
+		hartSummary(c, dbg)
+		return
+	}
+	ids := []int{}
+	if len(args) != 1 || args[0] != "all" {
+		for _, arg := range args {
+			id, err := cli.IntArg(arg, [2]int{0, dbg.GetHartCount() - 1}, 10)
+			if err != nil {
+				util.PutError(c.User, err)
+				return
+			}
+			ids = append(ids, id)
+		}
+	}
+	err := dbg.SetHartGroup(ids)
+	if err != nil {
+		util.PutError(c.User, err)
+	}
 }
 
+// CmdHart displays hart information and selects the current hart.
 var CmdHart = cli.Leaf{
 	Descr: "hart info/select",
 	F: func(c *cli.CLI, args []string) {
 		dbg := c.User.(target).GetRiscvDebug()
-		hi := dbg.GetCurrentHart()
 		if len(args) == 0 {
+			hi := dbg.GetCurrentHart()
+			if util.IsJSON(c.User) {
+				util.PutJSON(c.User, hi)
+				return
+			}
 			c.User.Put(fmt.Sprintf("%s\n", hi))
 			return
 		}
-		// TODO
+		if args[0] == "group" {
+			hartGroup(c, dbg, args[1:])
+			return
+		}
+		err := cli.CheckArgc(args, []int{1})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		id, err := cli.IntArg(args[0], [2]int{0, dbg.GetHartCount() - 1}, 10)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		c.User.(target).GetRiscvState().thread = nil
+		_, err = dbg.SetCurrentHart(id)
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("unable to select hart%d: %v", id, err))
+		}
+	},
+}
+
+//-----------------------------------------------------------------------------
+// triggers
+
+const maxTriggers = 64
+
+// triggerValue is the state of a trigger.
+type triggerValue struct {
+	Index  int              `json:"index"`
+	Type   string           `json:"type"`
+	Tdata1 uint64           `json:"tdata1"`
+	Tdata2 uint64           `json:"tdata2"`
+	Fields []soc.FieldValue `json:"fields"`
+}
+
+// triggerValues reads the triggers of the current hart.
+func triggerValues(dbg rv.Debug) ([]*triggerValue, error) {
+	hi := dbg.GetCurrentHart()
+	if hi.State != rv.Halted {
+		return nil, fmt.Errorf("hart%d is not halted", hi.ID)
+	}
+	tselect, err := dbg.RdCSR(rv.TSELECT, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer dbg.WrCSR(rv.TSELECT, 0, tselect)
+	tv := []*triggerValue{}
+	for i := 0; i < maxTriggers; i++ {
+		err := dbg.WrCSR(rv.TSELECT, 0, uint64(i))
+		if err != nil {
+			return nil, err
+		}
+		// tselect reads back a different value if the trigger doesn't exist
+		x, err := dbg.RdCSR(rv.TSELECT, 0)
+		if err != nil {
+			return nil, err
+		}
+		if x != uint64(i) {
+			break
+		}
+		tdata1, err := dbg.RdCSR(rv.TDATA1, 0)
+		if err != nil {
+			return nil, err
+		}
+		typ := rv.TriggerType(uint(tdata1), hi.MXLEN)
+		if typ == rv.TriggerNone {
+			break
+		}
+		tdata2, err := dbg.RdCSR(rv.TDATA2, 0)
+		if err != nil {
+			return nil, err
+		}
+		v := &triggerValue{
+			Index:  i,
+			Type:   rv.TriggerName(typ),
+			Tdata1: tdata1,
+			Tdata2: tdata2,
+		}
+		for _, f := range rv.TriggerFields(typ, hi.MXLEN) {
+			v.Fields = append(v.Fields, f.Value(uint(tdata1)))
+		}
+		tv = append(tv, v)
+	}
+	return tv, nil
+}
+
+// CmdTrigger displays the triggers of the current hart.
+var CmdTrigger = cli.Leaf{
+	Descr: "display triggers",
+	F: func(c *cli.CLI, args []string) {
+		err := cli.CheckArgc(args, []int{0})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		dbg := c.User.(target).GetRiscvDebug()
+		tv, err := triggerValues(dbg)
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("unable to read triggers: %v", err))
+			return
+		}
+		if util.IsJSON(c.User) {
+			for _, v := range tv {
+				util.PutJSON(c.User, v)
+			}
+			return
+		}
+		if len(tv) == 0 {
+			c.User.Put("no triggers\n")
+			return
+		}
+		s := [][]string{}
+		for _, v := range tv {
+			s = append(s, []string{
+				fmt.Sprintf("trigger%d", v.Index),
+				fmt.Sprintf(": tdata1 0x%x", v.Tdata1),
+				fmt.Sprintf("tdata2 0x%x", v.Tdata2),
+				v.Type,
+			})
+			for i := range v.Fields {
+				s = append(s, v.Fields[i].Display())
+			}
+		}
+		c.User.Put(fmt.Sprintf("%s\n", cli.TableString(s, []int{0, 0, 0, 0}, 1)))
 	},
 }
 
@@ -270,7 +757,7 @@ var CmdHart = cli.Leaf{
 
 var DisassembleHelp = []cli.Help{
 	{"<addr/name> [len]", "memory region"},
-	{"  addr", "address (hex), default is current pc"},
+	{"  addr", "address (expression), default is current pc"},
 	{"  name", "symbol name (string), see \"symbol\" command"},
 	{"  len", "length (hex), defaults to 0x100"},
 }
@@ -278,7 +765,7 @@ var DisassembleHelp = []cli.Help{
 const defSize = 0x80
 
 // disassembleArg converts disassemble arguments to an (address, n) tuple.
-func disassembleArg(dbg rv.Debug, args []string) (uint, int, error) {
+func disassembleArg(ctx expr.Context, dbg rv.Debug, args []string) (uint, int, error) {
 
 	err := cli.CheckArgc(args, []int{0, 1, 2})
 	if err != nil {
@@ -296,7 +783,7 @@ func disassembleArg(dbg rv.Debug, args []string) (uint, int, error) {
 
 	// get the address
 	maxAddr := uint((1 << dbg.GetAddressSize()) - 1)
-	addr, err := cli.UintArg(args[0], [2]uint{0, maxAddr}, 16)
+	addr, err := expr.UintArg(ctx, args[0], [2]uint{0, maxAddr})
 	if err != nil {
 		return 0, 0, err
 	}
@@ -320,7 +807,7 @@ func disassembleArg(dbg rv.Debug, args []string) (uint, int, error) {
 	}
 
 	// get the size
-	n, err := cli.UintArg(args[1], [2]uint{1, 0x100000000}, 16)
+	n, err := expr.UintArg(ctx, args[1], [2]uint{1, 0x100000000})
 	if err != nil {
 		return 0, 0, err
 	}
@@ -335,9 +822,9 @@ var CmdDisassemble = cli.Leaf{
 		dbg := c.User.(target).GetRiscvDebug()
 		hi := dbg.GetCurrentHart()
 		// get the arguments
-		addr, n, err := disassembleArg(dbg, args)
+		addr, n, err := disassembleArg(expr.GetContext(c.User), dbg, args)
 		if err != nil {
-			c.User.Put(fmt.Sprintf("%s\n", err))
+			util.PutError(c.User, err)
 			return
 		}
 		// disassemble
@@ -347,7 +834,7 @@ var CmdDisassemble = cli.Leaf{
 			// data read access, so we always read 2 x 16-bit values.
 			ins, err := dbg.RdMem(16, addr, 2)
 			if err != nil {
-				c.User.Put(fmt.Sprintf("unable to read memory at %x\n", addr))
+				util.PutError(c.User, fmt.Errorf("unable to read memory at %x", addr))
 				return
 			}
 			da := hi.ISA.Disassemble(addr, (ins[1]<<16)|ins[0])
@@ -358,6 +845,230 @@ var CmdDisassemble = cli.Leaf{
 	},
 }
 
+//-----------------------------------------------------------------------------
+// physical memory protection
+
+// pmpImplemented returns true if a PMP entry is implemented.
+// The pmpcfg/pmpaddr registers of an unimplemented entry are read-only zero,
+// so a zeroed entry is probed by writing all ones to pmpaddr (WARL).
+func pmpImplemented(dbg rv.Debug, i int, cfg uint8, addr uint64) (bool, error) {
+	if cfg != 0 || addr != 0 {
+		return true, nil
+	}
+	hi := dbg.GetCurrentHart()
+	reg := rv.PMPADDR0 + uint(i)
+	err := dbg.WrCSR(reg, hi.MXLEN, ^uint64(0))
+	if err != nil {
+		return false, err
+	}
+	x, err := dbg.RdCSR(reg, hi.MXLEN)
+	if err != nil {
+		return false, err
+	}
+	err = dbg.WrCSR(reg, hi.MXLEN, 0)
+	if err != nil {
+		return false, err
+	}
+	return x != 0, nil
+}
+
+// pmpEntries reads the implemented PMP entries of the current hart.
+// The lowest numbered entries are implemented first, so reading stops at the
+// first unimplemented entry. Before priv 1.12 only 16 entries were defined, so
+// a pmpaddr register that can't be accessed also ends the entries.
+func pmpEntries(dbg rv.Debug) ([]*rv.PmpEntry, error) {
+	hi := dbg.GetCurrentHart()
+	if hi.State != rv.Halted {
+		return nil, fmt.Errorf("hart%d is not halted", hi.ID)
+	}
+	pmpcfg := map[uint]uint64{}
+	cfg := []uint8{}
+	addr := []uint{}
+	for i := 0; i < rv.MaxPmpEntries; i++ {
+		a, err := dbg.RdCSR(rv.PMPADDR0+uint(i), hi.MXLEN)
+		if err != nil {
+			if i == 0 {
+				return nil, err
+			}
+			break
+		}
+		reg, shift := rv.PmpCfgReg(i, hi.MXLEN)
+		if _, ok := pmpcfg[reg]; !ok {
+			x, err := dbg.RdCSR(reg, hi.MXLEN)
+			if err != nil {
+				return nil, err
+			}
+			pmpcfg[reg] = x
+		}
+		c := uint8(pmpcfg[reg] >> shift)
+		ok, err := pmpImplemented(dbg, i, c, a)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		cfg = append(cfg, c)
+		addr = append(addr, uint(a))
+	}
+	return rv.PmpDecode(cfg, addr, hi.MXLEN), nil
+}
+
+// PmpHelp is help for the pmp command.
+var PmpHelp = []cli.Help{
+	{"<cr>", "display the pmp entries"},
+	{"check <addr> <r|w|x> <m|s|u>", "check an access against the pmp entries"},
+	{"  addr", "address (expression)"},
+}
+
+// pmpResult is the result of a pmp access check.
+type pmpResult struct {
+	Addr    uint         `json:"addr"`
+	Allowed bool         `json:"allowed"`
+	Entry   *rv.PmpEntry `json:"entry"`
+}
+
+// pmpCheck checks an access against the pmp entries.
+func pmpCheck(c *cli.CLI, entries []*rv.PmpEntry, args []string) {
+	err := cli.CheckArgc(args, []int{3})
+	if err != nil {
+		util.PutError(c.User, err)
+		return
+	}
+	addr, err := expr.UintArg(expr.GetContext(c.User), args[0], [2]uint{0, ^uint(0)})
+	if err != nil {
+		util.PutError(c.User, err)
+		return
+	}
+	if len(args[1]) != 1 || !strings.Contains("rwx", args[1]) {
+		util.PutError(c.User, fmt.Errorf("bad access type \"%s\"", args[1]))
+		return
+	}
+	if len(args[2]) != 1 || !strings.Contains("msu", args[2]) {
+		util.PutError(c.User, fmt.Errorf("bad privilege mode \"%s\"", args[2]))
+		return
+	}
+	ok, e := rv.PmpCheck(entries, addr, rune(args[1][0]), rune(args[2][0]))
+	result := []string{"denied", "allowed"}[util.BoolToInt(ok)]
+	if util.IsJSON(c.User) {
+		util.PutJSON(c.User, &pmpResult{addr, ok, e})
+		return
+	}
+	if e == nil {
+		c.User.Put(fmt.Sprintf("%s (no matching entry)\n", result))
+		return
+	}
+	c.User.Put(fmt.Sprintf("%s by pmp%d (%s %s %s)\n", result, e.Index, e.Mode, e.Perms(), e.Range()))
+}
+
+// CmdPmp displays the physical memory protection entries.
+var CmdPmp = cli.Leaf{
+	Descr: "physical memory protection",
+	F: func(c *cli.CLI, args []string) {
+		dbg := c.User.(target).GetRiscvDebug()
+		entries, err := pmpEntries(dbg)
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("unable to read pmp registers: %v", err))
+			return
+		}
+		if len(args) != 0 {
+			if args[0] != "check" {
+				util.PutError(c.User, fmt.Errorf("bad argument \"%s\"", args[0]))
+				return
+			}
+			pmpCheck(c, entries, args[1:])
+			return
+		}
+		// don't display unused entries at the end
+		n := len(entries)
+		for n > 0 && entries[n-1].Cfg == 0 && entries[n-1].Addr == 0 {
+			n--
+		}
+		if util.IsJSON(c.User) {
+			for _, e := range entries[:n] {
+				util.PutJSON(c.User, e)
+			}
+			return
+		}
+		if n == 0 {
+			c.User.Put("no pmp entries\n")
+			return
+		}
+		s := [][]string{}
+		for _, e := range entries[:n] {
+			s = append(s, []string{fmt.Sprintf("pmp%d", e.Index), e.Mode, e.Perms(), e.Range()})
+		}
+		c.User.Put(fmt.Sprintf("%s\n", cli.TableString(s, []int{0, 0, 0, 0}, 1)))
+	},
+}
+
+//-----------------------------------------------------------------------------
+// virtual to physical address translation
+
+// VtopHelp is help for the vtop command.
+var VtopHelp = []cli.Help{
+	{"<vaddr> [<r|w|x> <s|u>]", "translate, and optionally check an access"},
+	{"  vaddr", "virtual address (expression)"},
+}
+
+// CmdVtop translates a virtual address using the current satp value.
+var CmdVtop = cli.Leaf{
+	Descr: "virtual to physical address translation",
+	F: func(c *cli.CLI, args []string) {
+		err := cli.CheckArgc(args, []int{1, 3})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		if len(args) == 3 {
+			if len(args[1]) != 1 || !strings.Contains("rwx", args[1]) {
+				util.PutError(c.User, fmt.Errorf("bad access type \"%s\"", args[1]))
+				return
+			}
+			if len(args[2]) != 1 || !strings.Contains("su", args[2]) {
+				util.PutError(c.User, fmt.Errorf("bad privilege mode \"%s\"", args[2]))
+				return
+			}
+		}
+		dbg := c.User.(target).GetRiscvDebug()
+		maxAddr := uint((1 << dbg.GetAddressSize()) - 1)
+		vaddr, err := expr.UintArg(expr.GetContext(c.User), args[0], [2]uint{0, maxAddr})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		pw, err := dbg.Translate(vaddr)
+		if pw != nil {
+			if util.IsJSON(c.User) {
+				util.PutJSON(c.User, pw)
+			} else if err == nil || len(pw.PTE) != 0 {
+				// show the page table entries (up to any failure)
+				c.User.Put(fmt.Sprintf("%s\n", pw))
+			}
+		}
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("unable to translate 0x%x: %v", vaddr, err))
+			return
+		}
+		if len(args) == 3 {
+			mstatus, err := dbg.RdCSR(rv.MSTATUS, 0)
+			if err != nil {
+				util.PutError(c.User, fmt.Errorf("unable to read mstatus: %v", err))
+				return
+			}
+			access, mode := rune(args[1][0]), rune(args[2][0])
+			err = pw.CheckAccess(access, mode, mstatus)
+			if err != nil {
+				util.PutError(c.User, fmt.Errorf("%c-mode %c access faults: %v", mode, access, err))
+				return
+			}
+			if !util.IsJSON(c.User) {
+				c.User.Put(fmt.Sprintf("%c-mode %c access is allowed\n", mode, access))
+			}
+		}
+	},
+}
+
 //-----------------------------------------------------------------------------
 
 var cmdRiscvTest1 = cli.Leaf{
@@ -0,0 +1,151 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Expression Context
+
+This code implements the expr.Context interface.
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/soc"
+)
+
+//-----------------------------------------------------------------------------
+
+// Context is an expression context for a RISC-V target.
+type Context struct {
+	dbg rv.Debug    // risc-v debugger
+	dev *soc.Device // soc device
+}
+
+// NewContext returns an expression context for a RISC-V target.
+func NewContext(dbg rv.Debug, dev *soc.Device) *Context {
+	return &Context{
+		dbg: dbg,
+		dev: dev,
+	}
+}
+
+// regIndex returns the index of a register name within a name list.
+func regIndex(name, prefix string, names []string) (uint, bool) {
+	if strings.HasPrefix(name, prefix) {
+		n, err := strconv.ParseUint(name[len(prefix):], 10, 8)
+		if err == nil && n < 32 {
+			return uint(n), true
+		}
+	}
+	for i := range names {
+		if names[i] == name {
+			return uint(i), true
+		}
+	}
+	return 0, false
+}
+
+// regKind is the kind of a named register.
+type regKind int
+
+const (
+	regPC  regKind = iota // program counter
+	regGPR                // general purpose register
+	regFPR                // floating point register
+	regCSR                // control and status register
+)
+
+// lookup returns the kind and number of a register in the current hart.
+func (ctx *Context) lookup(name string) (regKind, uint, error) {
+	hi := ctx.dbg.GetCurrentHart()
+	if hi.State != rv.Halted {
+		return 0, 0, fmt.Errorf("hart%d is not halted", hi.ID)
+	}
+	if name == "pc" {
+		return regPC, rv.DPC, nil
+	}
+	if name == "fp" {
+		name = "s0"
+	}
+	if n, ok := regIndex(name, "x", abiXName[:]); ok {
+		return regGPR, n, nil
+	}
+	if hi.FLEN != 0 {
+		if n, ok := regIndex(name, "f", abiFName[:]); ok {
+			return regFPR, n, nil
+		}
+	}
+	if r := hi.CSR.GetPeripheral("CSR").GetRegister(name); r != nil {
+		return regCSR, r.Offset, nil
+	}
+	return 0, 0, fmt.Errorf("unknown register \"%s\"", name)
+}
+
+// Register returns the value of a register in the current hart.
+func (ctx *Context) Register(name string) (uint, error) {
+	kind, n, err := ctx.lookup(name)
+	if err != nil {
+		return 0, err
+	}
+	var val uint64
+	switch kind {
+	case regGPR:
+		val, err = ctx.dbg.RdGPR(n, 0)
+	case regFPR:
+		val, err = ctx.dbg.RdFPR(n, 0)
+	default:
+		val, err = ctx.dbg.RdCSR(n, 0)
+	}
+	return uint(val), err
+}
+
+// SetRegister sets the value of a register in the current hart.
+func (ctx *Context) SetRegister(name string, val uint) error {
+	kind, n, err := ctx.lookup(name)
+	if err != nil {
+		return err
+	}
+	switch kind {
+	case regGPR:
+		return ctx.dbg.WrGPR(n, 0, uint64(val))
+	case regFPR:
+		return ctx.dbg.WrFPR(n, 0, uint64(val))
+	}
+	return ctx.dbg.WrCSR(n, 0, uint64(val))
+}
+
+// Symbol returns the address of a peripheral or peripheral register.
+func (ctx *Context) Symbol(name string) (uint, error) {
+	x := strings.Split(name, ".")
+	switch len(x) {
+	case 1:
+		p := ctx.dev.GetPeripheral(x[0])
+		if p != nil {
+			return p.Addr, nil
+		}
+	case 2:
+		r, err := ctx.dev.GetPeripheralRegister(x[0], x[1])
+		if err != nil {
+			return 0, err
+		}
+		return ctx.dev.GetPeripheral(x[0]).Addr + r.Offset, nil
+	}
+	return 0, fmt.Errorf("unknown symbol \"%s\"", name)
+}
+
+// RdMem reads a width-bit memory value.
+func (ctx *Context) RdMem(width, addr uint) (uint, error) {
+	val, err := ctx.dbg.RdMem(width, addr, 1)
+	if err != nil {
+		return 0, err
+	}
+	return val[0], nil
+}
+
+//-----------------------------------------------------------------------------
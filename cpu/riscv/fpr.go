@@ -0,0 +1,143 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Floating Point Values
+
+Decode floating point register values as IEEE 754 single/double values.
+With FLEN == 64 a single value is NaN-boxed (the upper 32 bits are ones).
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+)
+
+//-----------------------------------------------------------------------------
+
+const nanBox = 0xffffffff00000000
+
+// ieeeFormat describes an IEEE 754 binary format.
+type ieeeFormat struct {
+	name string
+	bits int  // total bits
+	frac uint // fraction bits
+	exp  uint // exponent bits
+}
+
+var ieeeSingle = &ieeeFormat{"f32", 32, 23, 8}
+var ieeeDouble = &ieeeFormat{"f64", 64, 52, 11}
+
+// String returns the value of an IEEE floating point number.
+func (f *ieeeFormat) String(x uint64) string {
+	sign := (x >> (f.frac + f.exp)) & 1
+	exp := (x >> f.frac) & ((1 << f.exp) - 1)
+	frac := x & ((1 << f.frac) - 1)
+	switch {
+	case exp == (1<<f.exp)-1 && frac == 0:
+		return []string{"+Inf", "-Inf"}[sign]
+	case exp == (1<<f.exp)-1:
+		// the msb of the fraction is the quiet bit
+		if frac&(1<<(f.frac-1)) != 0 {
+			return "qNaN"
+		}
+		return "sNaN"
+	}
+	var val float64
+	if f.bits == 32 {
+		val = float64(math.Float32frombits(uint32(x)))
+	} else {
+		val = math.Float64frombits(x)
+	}
+	s := strconv.FormatFloat(val, 'g', -1, f.bits)
+	if exp == 0 && frac != 0 {
+		s += " (denormal)"
+	}
+	return s
+}
+
+// fprFloat returns the floating point value of an FPR.
+func fprFloat(x uint64, flen uint) string {
+	if flen == 32 {
+		return ieeeSingle.String(x)
+	}
+	if x&nanBox == nanBox {
+		return fmt.Sprintf("%s %s", ieeeSingle.name, ieeeSingle.String(x))
+	}
+	return fmt.Sprintf("%s %s", ieeeDouble.name, ieeeDouble.String(x))
+}
+
+// fcsrValue is the value of the floating point control and status register.
+type fcsrValue struct {
+	Fcsr   uint64 `json:"fcsr"`
+	Frm    string `json:"frm"`
+	Fflags string `json:"fflags"`
+}
+
+func newFcsrValue(x uint64) *fcsrValue {
+	return &fcsrValue{x, rv.FrmName(uint(x >> 5)), rv.FflagsName(uint(x))}
+}
+
+// fcsrString decodes the floating point control and status register.
+func fcsrString(x uint64) string {
+	v := newFcsrValue(x)
+	return fmt.Sprintf("fcsr %02x frm %s fflags %s", v.Fcsr, v.Frm, v.Fflags)
+}
+
+//-----------------------------------------------------------------------------
+
+// fprWrite parses "<reg> = <float> [f32|f64]" and returns the register
+// number and value.
+func fprWrite(args []string, flen uint) (uint, uint64, error) {
+	s := strings.SplitN(strings.Join(args, " "), "=", 2)
+	if len(s) != 2 {
+		return 0, 0, fmt.Errorf("usage: fpr <reg> = <float> [f32|f64]")
+	}
+	name := strings.TrimSpace(s[0])
+	reg, ok := regIndex(name, "f", abiFName[:])
+	if !ok {
+		return 0, 0, fmt.Errorf("unknown fpr \"%s\"", name)
+	}
+	val := strings.Fields(s[1])
+	if len(val) == 0 || len(val) > 2 {
+		return 0, 0, fmt.Errorf("usage: fpr <reg> = <float> [f32|f64]")
+	}
+	f := ieeeSingle
+	if flen == 64 {
+		f = ieeeDouble
+	}
+	if len(val) == 2 {
+		switch val[1] {
+		case "f32":
+			f = ieeeSingle
+		case "f64":
+			if flen != 64 {
+				return 0, 0, fmt.Errorf("flen is %d, f64 is not supported", flen)
+			}
+			f = ieeeDouble
+		default:
+			return 0, 0, fmt.Errorf("bad float type \"%s\", use f32 or f64", val[1])
+		}
+	}
+	x, err := strconv.ParseFloat(val[0], f.bits)
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad float value \"%s\"", val[0])
+	}
+	if f == ieeeDouble {
+		return reg, math.Float64bits(x), nil
+	}
+	bits := uint64(math.Float32bits(float32(x)))
+	if flen == 64 {
+		bits |= nanBox
+	}
+	return reg, bits, nil
+}
+
+//-----------------------------------------------------------------------------
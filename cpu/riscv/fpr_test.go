@@ -0,0 +1,129 @@
+//-----------------------------------------------------------------------------
+/*
+
+Floating Point Value Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import (
+	"strings"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+
+func Test_IeeeString(t *testing.T) {
+	tests := []struct {
+		f *ieeeFormat
+		x uint64
+		s string
+	}{
+		{ieeeSingle, 0x00000000, "0"},
+		{ieeeSingle, 0x80000000, "-0"},
+		{ieeeSingle, 0x3fc00000, "1.5"},
+		{ieeeSingle, 0xc0000000, "-2"},
+		{ieeeSingle, 0x7f800000, "+Inf"},
+		{ieeeSingle, 0xff800000, "-Inf"},
+		{ieeeSingle, 0x7fc00000, "qNaN"},
+		{ieeeSingle, 0xffc00001, "qNaN"},
+		{ieeeSingle, 0x7fa00000, "sNaN"},
+		{ieeeSingle, 0x7f800001, "sNaN"},
+		{ieeeSingle, 0x00000001, "1e-45 (denormal)"},
+		{ieeeSingle, 0x00800000, "1.1754944e-38"},
+		{ieeeDouble, 0x0000000000000000, "0"},
+		{ieeeDouble, 0x3ff8000000000000, "1.5"},
+		{ieeeDouble, 0xc000000000000000, "-2"},
+		{ieeeDouble, 0x7ff0000000000000, "+Inf"},
+		{ieeeDouble, 0xfff0000000000000, "-Inf"},
+		{ieeeDouble, 0x7ff8000000000000, "qNaN"},
+		{ieeeDouble, 0x7ff4000000000000, "sNaN"},
+		{ieeeDouble, 0x0000000000000001, "5e-324 (denormal)"},
+		{ieeeDouble, 0x8000000000000001, "-5e-324 (denormal)"},
+	}
+	for _, v := range tests {
+		s := v.f.String(v.x)
+		if s != v.s {
+			t.Errorf("%s 0x%x: \"%s\", expected \"%s\"", v.f.name, v.x, s, v.s)
+		}
+	}
+}
+
+func Test_FprFloat(t *testing.T) {
+	tests := []struct {
+		x    uint64
+		flen uint
+		s    string
+	}{
+		{0x3fc00000, 32, "1.5"},
+		{0x7f800000, 32, "+Inf"},
+		// nan-boxed single values
+		{0xffffffff3fc00000, 64, "f32 1.5"},
+		{0xffffffffff800000, 64, "f32 -Inf"},
+		{0xffffffff7fa00000, 64, "f32 sNaN"},
+		{0xffffffffffffffff, 64, "f32 qNaN"},
+		{0xffffffff00000001, 64, "f32 1e-45 (denormal)"},
+		// double values
+		{0x3ff8000000000000, 64, "f64 1.5"},
+		{0x000000003fc00000, 64, "f64 5.28426686e-315 (denormal)"},
+		{0x7ff0000000000000, 64, "f64 +Inf"},
+		{0xfff8000000000000, 64, "f64 qNaN"},
+		// not nan-boxed
+		{0xfffffffe3fc00000, 64, "f64 qNaN"},
+	}
+	for _, v := range tests {
+		s := fprFloat(v.x, v.flen)
+		if s != v.s {
+			t.Errorf("flen %d 0x%x: \"%s\", expected \"%s\"", v.flen, v.x, s, v.s)
+		}
+	}
+}
+
+func Test_FprWrite(t *testing.T) {
+	tests := []struct {
+		args string
+		flen uint
+		reg  uint
+		val  uint64
+		ok   bool
+	}{
+		{"fa0 = 1.5", 32, 10, 0x3fc00000, true},
+		{"f1 = -2", 32, 1, 0xc0000000, true},
+		{"ft0=inf", 32, 0, 0x7f800000, true},
+		{"f2 = 1e-45", 32, 2, 0x00000001, true},
+		{"f3 = 1.5 f32", 32, 3, 0x3fc00000, true},
+		{"fa0 = 1.5", 64, 10, 0x3ff8000000000000, true},
+		{"fa0 = -inf f64", 64, 10, 0xfff0000000000000, true},
+		{"fs11 = 5e-324", 64, 27, 0x0000000000000001, true},
+		// f32 values are nan-boxed with flen 64
+		{"fa0 = 1.5 f32", 64, 10, 0xffffffff3fc00000, true},
+		{"f31 = -inf f32", 64, 31, 0xffffffffff800000, true},
+		{"f4 = 1e-45 f32", 64, 4, 0xffffffff00000001, true},
+		// errors
+		{"fa0 = 1 f64", 32, 0, 0, false},
+		{"fa0 = 1 f16", 64, 0, 0, false},
+		{"fa0 = x", 64, 0, 0, false},
+		{"fa0 =", 64, 0, 0, false},
+		{"fa0 = 1 f32 f64", 64, 0, 0, false},
+		{"fa0 1.5", 64, 0, 0, false},
+		{"f32 = 1.5", 64, 0, 0, false},
+		{"a0 = 1.5", 64, 0, 0, false},
+	}
+	for _, v := range tests {
+		reg, val, err := fprWrite(strings.Fields(v.args), v.flen)
+		if (err == nil) != v.ok || reg != v.reg || val != v.val {
+			t.Errorf("flen %d \"%s\": fpr%d 0x%x %v, expected fpr%d 0x%x ok %t", v.flen, v.args, reg, val, err, v.reg, v.val, v.ok)
+		}
+	}
+	// nan values are quiet nans
+	for _, flen := range []uint{32, 64} {
+		_, val, err := fprWrite([]string{"fa0", "=", "nan"}, flen)
+		if err != nil || fprFloat(val, flen) != map[uint]string{32: "qNaN", 64: "f64 qNaN"}[flen] {
+			t.Errorf("flen %d nan: 0x%x %v", flen, val, err)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,54 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Memory Access Modes
+
+The mem.ModeDriver methods for a RISC-V memory driver. Target memory drivers
+embed MemModes to select physical, privilege mode or virtual memory access.
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import (
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/mem"
+)
+
+//-----------------------------------------------------------------------------
+
+// MemModes implements the mem.ModeDriver interface for a RISC-V debugger.
+type MemModes struct {
+	dbg rv.Debug
+}
+
+// NewMemModes returns the memory access modes for a RISC-V debugger.
+func NewMemModes(dbg rv.Debug) MemModes {
+	return MemModes{dbg: dbg}
+}
+
+// GetModes returns the memory access modes.
+func (m MemModes) GetModes() []mem.Mode {
+	modes := []mem.Mode{}
+	for _, x := range rv.MemModes() {
+		modes = append(modes, mem.Mode{Name: x.String(), Descr: x.Descr()})
+	}
+	return modes
+}
+
+// GetMode returns the current memory access mode.
+func (m MemModes) GetMode() string {
+	return m.dbg.GetMemoryMode().String()
+}
+
+// SetMode sets the memory access mode.
+func (m MemModes) SetMode(name string) error {
+	mode, err := rv.ParseMemMode(name)
+	if err != nil {
+		return err
+	}
+	return m.dbg.SetMemoryMode(mode)
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,419 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Hardware Performance Counters
+
+Program the mhpmevent CSRs, zero the counters and count the events while
+the hart runs for a time window. The counters are 64 bits, on RV32 they are read as high/low halves.
+
+Before priv 1.10 (mscounteren is implemented) the machine counters may be
+the read only counters at 0xf00, which are written through delta CSRs.
+These counters are not zeroed, "perf run" reports the counter increments.
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+const counterCycle = 0   // mcycle
+const counterInstret = 2 // minstret
+const maxCounter = 31    // mhpmcounter31
+const perfPoll = 10 * time.Millisecond
+
+// perfCounter is the value of a performance counter.
+type perfCounter struct {
+	N     uint   `json:"n"`
+	Name  string `json:"name"`
+	Event string `json:"event"`
+	Count uint64 `json:"count"`
+}
+
+// counterCSRs is the counter CSR layout of a hart.
+type counterCSRs struct {
+	lo, hi   uint // CSR address of mcycle and mcycleh
+	writable bool // the counters can be zeroed
+	inhibit  bool // mcountinhibit is implemented (priv 1.11)
+}
+
+// getCounterCSRs returns the counter CSR layout of the current hart.
+func getCounterCSRs(dbg rv.Debug) *counterCSRs {
+	cc := &counterCSRs{lo: rv.MCYCLE, hi: rv.MCYCLEH, writable: true}
+	// 0x320 is mucounteren before priv 1.11, mscounteren (0x321) is also implemented
+	if _, err := dbg.RdCSR(rv.MSCOUNTEREN, 0); err == nil {
+		if _, err := dbg.RdCSR(rv.MCYCLE, 0); err != nil {
+			// read only counters
+			cc.lo, cc.hi, cc.writable = rv.MCYCLERO, rv.MCYCLEROH, false
+		}
+		return cc
+	}
+	_, err := dbg.RdCSR(rv.MCOUNTINHIBIT, 0)
+	cc.inhibit = err == nil
+	return cc
+}
+
+// rdCounter reads a 64-bit counter.
+func rdCounter(dbg rv.Debug, cc *counterCSRs, n uint) (uint64, error) {
+	if dbg.GetCurrentHart().MXLEN == 64 {
+		return dbg.RdCSR(cc.lo+n, 0)
+	}
+	// re-read if the low half carries into the high half
+	for i := 0; i < 3; i++ {
+		h0, err := dbg.RdCSR(cc.hi+n, 0)
+		if err != nil {
+			return 0, err
+		}
+		lo, err := dbg.RdCSR(cc.lo+n, 0)
+		if err != nil {
+			return 0, err
+		}
+		h1, err := dbg.RdCSR(cc.hi+n, 0)
+		if err != nil {
+			return 0, err
+		}
+		if h0 == h1 {
+			return (h1 << 32) | lo, nil
+		}
+	}
+	return 0, fmt.Errorf("counter %d is changing too quickly", n)
+}
+
+// clrCounter zeroes a 64-bit counter.
+func clrCounter(dbg rv.Debug, cc *counterCSRs, n uint) error {
+	err := dbg.WrCSR(cc.lo+n, 0, 0)
+	if err != nil {
+		return err
+	}
+	if dbg.GetCurrentHart().MXLEN == 64 {
+		return nil
+	}
+	err = dbg.WrCSR(cc.hi+n, 0, 0)
+	if err != nil {
+		return err
+	}
+	// the low half may have carried into the high half
+	return dbg.WrCSR(cc.lo+n, 0, 0)
+}
+
+// hpmEvents returns the programmed mhpmevent values (counter number to event).
+// Reading stops at the first unimplemented event CSR.
+func hpmEvents(dbg rv.Debug) map[uint]uint64 {
+	events := map[uint]uint64{}
+	for n := uint(3); n <= maxCounter; n++ {
+		x, err := dbg.RdCSR(rv.MHPMEVENT3+n-3, 0)
+		if err != nil {
+			break
+		}
+		if x != 0 {
+			events[n] = x
+		}
+	}
+	return events
+}
+
+// perfCounters reads mcycle, minstret and the counters with a programmed event.
+func perfCounters(dbg rv.Debug, id *rv.HpmID, cc *counterCSRs) ([]*perfCounter, error) {
+	pc := []*perfCounter{
+		{N: counterCycle, Name: "mcycle", Event: "cycles"},
+		{N: counterInstret, Name: "minstret", Event: "instructions"},
+	}
+	events := hpmEvents(dbg)
+	for n := uint(3); n <= maxCounter; n++ {
+		if x, ok := events[n]; ok {
+			pc = append(pc, &perfCounter{N: n, Name: fmt.Sprintf("mhpmcounter%d", n), Event: rv.HpmEventName(id, x)})
+		}
+	}
+	for _, x := range pc {
+		var err error
+		x.Count, err = rdCounter(dbg, cc, x.N)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %s: %v", x.Name, err)
+		}
+	}
+	return pc, nil
+}
+
+// counterMask returns a bit mask of mcycle, minstret and the counters with a programmed event.
+func counterMask(dbg rv.Debug) uint64 {
+	mask := uint64(1<<counterCycle | 1<<counterInstret)
+	for n := range hpmEvents(dbg) {
+		mask |= 1 << n
+	}
+	return mask
+}
+
+// enableCounters enables mcycle, minstret and the counters with a programmed event.
+func enableCounters(dbg rv.Debug, cc *counterCSRs) {
+	// mcountinhibit is optional
+	if !cc.inhibit {
+		return
+	}
+	mask := counterMask(dbg)
+	x, err := dbg.RdCSR(rv.MCOUNTINHIBIT, 0)
+	if err == nil && x&mask != 0 {
+		dbg.WrCSR(rv.MCOUNTINHIBIT, 0, x&^mask)
+	}
+}
+
+// clrCounters zeroes and enables mcycle, minstret and the counters with a programmed event.
+func clrCounters(dbg rv.Debug, cc *counterCSRs) error {
+	if !cc.writable {
+		return fmt.Errorf("the counters at 0x%x are read only (before priv 1.10), use \"perf run\"", cc.lo)
+	}
+	mask := counterMask(dbg)
+	for n := uint(0); n <= maxCounter; n++ {
+		if mask&(1<<n) == 0 {
+			continue
+		}
+		err := clrCounter(dbg, cc, n)
+		if err != nil {
+			return fmt.Errorf("unable to clear counter %d: %v", n, err)
+		}
+	}
+	enableCounters(dbg, cc)
+	return nil
+}
+
+func perfString(pc []*perfCounter) string {
+	s := [][]string{}
+	var cycles, instret uint64
+	for _, x := range pc {
+		switch x.N {
+		case counterCycle:
+			cycles = x.Count
+		case counterInstret:
+			instret = x.Count
+		}
+	}
+	for _, x := range pc {
+		ratio := ""
+		switch {
+		case x.N == counterInstret && cycles != 0:
+			ratio = fmt.Sprintf("ipc %.3f", float64(instret)/float64(cycles))
+		case x.N == counterCycle && instret != 0:
+			ratio = fmt.Sprintf("cpi %.3f", float64(cycles)/float64(instret))
+		case x.N > counterInstret && instret != 0:
+			ratio = fmt.Sprintf("%.2f per 1k instructions", 1000*float64(x.Count)/float64(instret))
+		}
+		s = append(s, []string{x.Name, x.Event, fmt.Sprintf("%d", x.Count), ratio})
+	}
+	return cli.TableString(s, []int{0, 0, 0, 0}, 1)
+}
+
+func eventsString(events []rv.HpmEvent) string {
+	s := [][]string{}
+	for _, e := range events {
+		s = append(s, []string{e.Name, fmt.Sprintf("0x%x", e.Class|1<<e.Bit), e.Descr})
+	}
+	return cli.TableString(s, []int{0, 0, 0}, 1)
+}
+
+//-----------------------------------------------------------------------------
+
+// haltedDo runs a function with the current hart halted.
+// A running hart is halted and then resumed.
+func haltedDo(dbg rv.Debug, fn func() error) error {
+	if dbg.GetCurrentHart().State == rv.Halted {
+		return fn()
+	}
+	err := dbg.HaltHart()
+	if err != nil {
+		return err
+	}
+	err = fn()
+	if err != nil {
+		dbg.ResumeHart()
+		return err
+	}
+	return dbg.ResumeHart()
+}
+
+// perfRun runs the hart for a time window and reads the counter increments.
+// The counters are not zeroed, so read only counters are supported.
+func perfRun(c *cli.CLI, dbg rv.Debug, id *rv.HpmID, window time.Duration) ([]*perfCounter, error) {
+	hi := dbg.GetCurrentHart()
+	running := hi.State == rv.Running
+	if running {
+		err := dbg.HaltHart()
+		if err != nil {
+			return nil, err
+		}
+	}
+	cc := getCounterCSRs(dbg)
+	enableCounters(dbg, cc)
+	start, err := perfCounters(dbg, id, cc)
+	if err != nil {
+		return nil, err
+	}
+	c.User.(target).GetRiscvState().thread = nil
+	err = dbg.ResumeHart()
+	if err != nil {
+		return nil, err
+	}
+	c.User.Put(fmt.Sprintf("hart%d running for %s (ctrl-d to stop)\n", hi.ID, window))
+	end := time.Now().Add(window)
+	c.Loop(func() bool {
+		if time.Now().After(end) {
+			return true
+		}
+		time.Sleep(perfPoll)
+		return false
+	}, cli.KeycodeCtrlD)
+	err = dbg.HaltHart()
+	if err != nil {
+		return nil, err
+	}
+	pc, err := perfCounters(dbg, id, cc)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pc {
+		pc[i].Count -= start[i].Count
+	}
+	if running {
+		err = dbg.ResumeHart()
+	}
+	return pc, err
+}
+
+//-----------------------------------------------------------------------------
+
+// PerfHelp is help for the perf command.
+var PerfHelp = []cli.Help{
+	{"<cr>", "display the performance counters"},
+	{"events", "display the event names for the hart core (mvendorid, marchid)"},
+	{"set <n> <event>", "program mhpmevent<n>"},
+	{"  n", "counter number 3..31 (decimal)"},
+	{"  event", "event number, name or names of the same class joined with \"+\", 0 to disable"},
+	{"clear", "zero the performance counters"},
+	{"run <ms>", "run the hart for a time window and display the counter increments"},
+	{"  ms", "time window in milliseconds (decimal)"},
+}
+
+// CmdPerf configures and reads the hardware performance counters.
+var CmdPerf = cli.Leaf{
+	Descr: "performance counters",
+	F: func(c *cli.CLI, args []string) {
+		dbg := c.User.(target).GetRiscvDebug()
+		id := &rv.HpmID{MXLEN: dbg.GetCurrentHart().MXLEN}
+		err := haltedDo(dbg, func() error {
+			x, err := dbg.RdCSR(rv.MVENDORID, 0)
+			if err != nil {
+				return err
+			}
+			id.Mvendorid = uint(x)
+			x, err = dbg.RdCSR(rv.MARCHID, 0)
+			id.Marchid = uint(x)
+			return err
+		})
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("unable to read mvendorid/marchid: %v", err))
+			return
+		}
+		cmd := ""
+		if len(args) != 0 {
+			cmd = args[0]
+		}
+		var pc []*perfCounter
+		switch cmd {
+		case "":
+			err = haltedDo(dbg, func() error {
+				var err error
+				pc, err = perfCounters(dbg, id, getCounterCSRs(dbg))
+				return err
+			})
+		case "events":
+			events := rv.HpmEvents(id)
+			if events == nil {
+				util.PutError(c.User, fmt.Errorf("no event names for %s", id))
+				return
+			}
+			if util.IsJSON(c.User) {
+				for i := range events {
+					util.PutJSON(c.User, &events[i])
+				}
+				return
+			}
+			c.User.Put(fmt.Sprintf("%s\n", eventsString(events)))
+			return
+		case "set":
+			err = cli.CheckArgc(args, []int{3})
+			if err != nil {
+				break
+			}
+			var n int
+			n, err = cli.IntArg(args[1], [2]int{3, maxCounter}, 10)
+			if err != nil {
+				break
+			}
+			var x uint64
+			x, err = rv.HpmEventValue(id, args[2])
+			if err != nil {
+				break
+			}
+			err = haltedDo(dbg, func() error {
+				reg := uint(rv.MHPMEVENT3 + n - 3)
+				err := dbg.WrCSR(reg, 0, x)
+				if err != nil {
+					return err
+				}
+				// unimplemented event bits are read as zero
+				y, err := dbg.RdCSR(reg, 0)
+				if err != nil {
+					return err
+				}
+				if y != x {
+					return fmt.Errorf("mhpmevent%d is 0x%x, event 0x%x is not supported", n, y, x)
+				}
+				return nil
+			})
+		case "clear":
+			err = cli.CheckArgc(args, []int{1})
+			if err == nil {
+				err = haltedDo(dbg, func() error {
+					return clrCounters(dbg, getCounterCSRs(dbg))
+				})
+			}
+		case "run":
+			err = cli.CheckArgc(args, []int{2})
+			if err != nil {
+				break
+			}
+			var ms int
+			ms, err = cli.IntArg(args[1], [2]int{1, 3600 * 1000}, 10)
+			if err != nil {
+				break
+			}
+			pc, err = perfRun(c, dbg, id, time.Duration(ms)*time.Millisecond)
+		default:
+			err = errors.New("usage: perf [events|set <n> <event>|clear|run <ms>]")
+		}
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		if pc == nil {
+			return
+		}
+		if util.IsJSON(c.User) {
+			for _, x := range pc {
+				util.PutJSON(c.User, x)
+			}
+			return
+		}
+		c.User.Put(fmt.Sprintf("%s\n", perfString(pc)))
+	},
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,148 @@
+//-----------------------------------------------------------------------------
+/*
+
+Hardware Performance Counter Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+)
+
+//-----------------------------------------------------------------------------
+
+// csrDebug is a debugger with CSRs. Each CSR read returns the next value of a
+// sequence, the last value is repeated.
+type csrDebug struct {
+	rv.Debug
+	hi  rv.HartInfo
+	csr map[uint][]uint64
+	wr  map[uint]uint64
+}
+
+func (d *csrDebug) GetCurrentHart() *rv.HartInfo {
+	return &d.hi
+}
+
+func (d *csrDebug) RdCSR(reg, size uint) (uint64, error) {
+	x, ok := d.csr[reg]
+	if !ok {
+		return 0, fmt.Errorf("csr 0x%x is not implemented", reg)
+	}
+	if len(x) > 1 {
+		d.csr[reg] = x[1:]
+	}
+	return x[0], nil
+}
+
+func (d *csrDebug) WrCSR(reg, size uint, val uint64) error {
+	if _, ok := d.csr[reg]; !ok {
+		return fmt.Errorf("csr 0x%x is not implemented", reg)
+	}
+	d.wr[reg] = val
+	return nil
+}
+
+func newCSRDebug(xlen uint, csr map[uint][]uint64) *csrDebug {
+	return &csrDebug{hi: rv.HartInfo{MXLEN: xlen}, csr: csr, wr: map[uint]uint64{}}
+}
+
+func Test_RdCounter(t *testing.T) {
+	cc := &counterCSRs{lo: rv.MCYCLE, hi: rv.MCYCLEH, writable: true}
+	tests := []struct {
+		xlen uint
+		lo   []uint64
+		hi   []uint64
+		val  uint64
+		ok   bool
+	}{
+		{64, []uint64{0x123456789}, nil, 0x123456789, true},
+		{32, []uint64{0x89abcdef}, []uint64{0x12}, 0x1289abcdef, true},
+		// the low half carries into the high half between the reads
+		{32, []uint64{0xffffffff, 0x00000004}, []uint64{0x12, 0x13, 0x13, 0x13}, 0x1300000004, true},
+		// the high half keeps changing
+		{32, []uint64{0}, []uint64{1, 2, 3, 4, 5, 6, 7}, 0, false},
+	}
+	for i, v := range tests {
+		csr := map[uint][]uint64{rv.MCYCLE: v.lo}
+		if v.hi != nil {
+			csr[rv.MCYCLEH] = v.hi
+		}
+		val, err := rdCounter(newCSRDebug(v.xlen, csr), cc, 0)
+		if (err == nil) != v.ok || val != v.val {
+			t.Errorf("test %d: 0x%x %v, expected 0x%x ok %t", i, val, err, v.val, v.ok)
+		}
+	}
+}
+
+func Test_CounterCSRs(t *testing.T) {
+	// priv 1.11: mcountinhibit
+	dbg := newCSRDebug(32, map[uint][]uint64{
+		rv.MCOUNTINHIBIT: {5},
+		rv.MCYCLE:        {0},
+		rv.MCYCLEH:       {0},
+		rv.MINSTRET:      {0},
+		rv.MINSTRETH:     {0},
+		rv.MHPMEVENT3:    {0},
+	})
+	cc := getCounterCSRs(dbg)
+	if *cc != (counterCSRs{rv.MCYCLE, rv.MCYCLEH, true, true}) {
+		t.Errorf("priv 1.11: counters %+v", *cc)
+	}
+	err := clrCounters(dbg, cc)
+	if err != nil {
+		t.Errorf("priv 1.11: %v", err)
+	}
+	if x, ok := dbg.wr[rv.MCOUNTINHIBIT]; !ok || x != 0 {
+		t.Errorf("priv 1.11: mcountinhibit 0x%x %t, expected 0", x, ok)
+	}
+	// priv 1.9.x: writable counters, 0x320 is mucounteren
+	dbg = newCSRDebug(32, map[uint][]uint64{
+		rv.MCOUNTINHIBIT: {5},
+		rv.MSCOUNTEREN:   {0},
+		rv.MCYCLE:        {0},
+		rv.MCYCLEH:       {0},
+		rv.MINSTRET:      {0},
+		rv.MINSTRETH:     {0},
+	})
+	cc = getCounterCSRs(dbg)
+	if *cc != (counterCSRs{rv.MCYCLE, rv.MCYCLEH, true, false}) {
+		t.Errorf("priv 1.9.x: counters %+v", *cc)
+	}
+	err = clrCounters(dbg, cc)
+	if err != nil {
+		t.Errorf("priv 1.9.x: %v", err)
+	}
+	if _, ok := dbg.wr[rv.MCOUNTINHIBIT]; ok {
+		t.Error("priv 1.9.x: mucounteren was written")
+	}
+	// priv 1.9.x: read only counters at 0xf00
+	dbg = newCSRDebug(32, map[uint][]uint64{
+		rv.MCOUNTINHIBIT: {5},
+		rv.MSCOUNTEREN:   {0},
+		rv.MCYCLERO:      {7},
+		rv.MCYCLEROH:     {1},
+	})
+	cc = getCounterCSRs(dbg)
+	if *cc != (counterCSRs{rv.MCYCLERO, rv.MCYCLEROH, false, false}) {
+		t.Errorf("read only: counters %+v", *cc)
+	}
+	if clrCounters(dbg, cc) == nil {
+		t.Error("read only: expected an error when zeroing the counters")
+	}
+	if len(dbg.wr) != 0 {
+		t.Errorf("read only: csrs were written %v", dbg.wr)
+	}
+	val, err := rdCounter(dbg, cc, 0)
+	if err != nil || val != 0x100000007 {
+		t.Errorf("read only: mcycle 0x%x %v, expected 0x100000007", val, err)
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,177 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V PC Sampling Profiler
+
+Sample the pc of the current hart while it runs. A debugger that provides
+rv.PCSampler is used when available, otherwise each sample halts the hart,
+reads dpc and resumes the hart.
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/elf"
+	"github.com/deadsy/rvdbg/profile"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+const profileInterval = time.Millisecond // time between samples
+const profileTop = 20                    // number of histogram buckets to display
+const profileBucket = 64                 // default address bucket size
+
+const dcsrCauseHaltReq = 3 // dcsr.cause for a debugger halt request
+
+// samplePC samples the pc of the current hart.
+func samplePC(dbg rv.Debug) (pc uint64, err error) {
+	if ps, ok := dbg.(rv.PCSampler); ok {
+		return ps.SamplePC()
+	}
+	hi := dbg.GetCurrentHart()
+	err = dbg.HaltHart()
+	if err != nil {
+		return 0, err
+	}
+	dcsr, err := dbg.RdCSR(rv.DCSR, 0)
+	if err != nil {
+		dbg.ResumeHart()
+		return 0, err
+	}
+	// stop if the hart halted by itself (E.g. a breakpoint)
+	if (dcsr>>6)&7 != dcsrCauseHaltReq {
+		return 0, fmt.Errorf("hart%d is halted", hi.ID)
+	}
+	// always resume the hart, even if the dpc read fails
+	defer func() {
+		rerr := dbg.ResumeHart()
+		if err == nil {
+			err = rerr
+		}
+	}()
+	return dbg.RdCSR(rv.DPC, 0)
+}
+
+// profileArgs returns the options for the profile command.
+func profileArgs(args []string) (uint, string, error) {
+	bucket := uint(profileBucket)
+	file := ""
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			return 0, "", fmt.Errorf("no value for \"%s\"", args[i])
+		}
+		switch args[i] {
+		case "bucket":
+			n, err := cli.IntArg(args[i+1], [2]int{1, 1 << 20}, 16)
+			if err != nil {
+				return 0, "", err
+			}
+			bucket = uint(n)
+		case "pprof":
+			file = args[i+1]
+		default:
+			return 0, "", fmt.Errorf("bad argument \"%s\"", args[i])
+		}
+	}
+	return bucket, file, nil
+}
+
+//-----------------------------------------------------------------------------
+
+// ProfileHelp is help for the profile command.
+var ProfileHelp = []cli.Help{
+	{"<seconds> [bucket <size>] [pprof <file>]", "sample the pc of the current hart"},
+	{"  seconds", "sampling time (decimal)"},
+	{"  size", "address bucket size for pcs without a symbol (hex), default 0x40"},
+	{"  file", "write a pprof profile, see \"go tool pprof\""},
+}
+
+// CmdProfile samples the pc of the current hart and displays a histogram.
+var CmdProfile = cli.Leaf{
+	Descr: "pc sampling profiler",
+	F: func(c *cli.CLI, args []string) {
+		if len(args) == 0 {
+			util.PutError(c.User, errors.New("usage: profile <seconds> [bucket <size>] [pprof <file>]"))
+			return
+		}
+		secs, err := cli.IntArg(args[0], [2]int{1, 3600}, 10)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		bucket, file, err := profileArgs(args[1:])
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		dbg := c.User.(target).GetRiscvDebug()
+		hi := dbg.GetCurrentHart()
+		if hi.State != rv.Running {
+			util.PutError(c.User, fmt.Errorf("hart%d is not running", hi.ID))
+			return
+		}
+		c.User.Put(fmt.Sprintf("sampling hart%d for %ds (ctrl-d to stop)\n", hi.ID, secs))
+		p := profile.New()
+		end := p.Start.Add(time.Duration(secs) * time.Second)
+		c.Loop(func() bool {
+			if time.Now().After(end) {
+				return true
+			}
+			var pc uint64
+			pc, err = samplePC(dbg)
+			if err != nil {
+				return true
+			}
+			p.Add(uint(pc))
+			time.Sleep(profileInterval)
+			return false
+		}, cli.KeycodeCtrlD)
+		p.Stop()
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("sampling stopped: %v", err))
+		}
+		f := elf.Current()
+		if file != "" {
+			err := writePprof(p, f, file)
+			if err != nil {
+				util.PutError(c.User, err)
+			}
+		}
+		h := p.Histogram(f, bucket)
+		if util.IsJSON(c.User) {
+			for _, b := range h {
+				util.PutJSON(c.User, b)
+			}
+			return
+		}
+		rate := float64(p.Total) / p.Duration.Seconds()
+		c.User.Put(fmt.Sprintf("%d samples in %.1fs (%.0f/s)\n", p.Total, p.Duration.Seconds(), rate))
+		c.User.Put(fmt.Sprintf("%s\n", profile.HistogramString(h, p.Total, profileTop)))
+	},
+}
+
+// writePprof writes a pprof profile file.
+func writePprof(p *profile.Profile, f *elf.File, name string) error {
+	fh, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = p.WritePprof(fh, f)
+	if err != nil {
+		fh.Close()
+		return err
+	}
+	return fh.Close()
+}
+
+//-----------------------------------------------------------------------------
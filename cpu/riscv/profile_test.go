@@ -0,0 +1,80 @@
+//-----------------------------------------------------------------------------
+/*
+
+PC Sampling Profiler Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import (
+	"testing"
+
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/cpu/riscv/sim"
+	"github.com/deadsy/rvdbg/jtag"
+)
+
+//-----------------------------------------------------------------------------
+
+// haltDebug hides the optional debugger interfaces (E.g. rv.PCSampler).
+type haltDebug struct {
+	rv.Debug
+}
+
+// newSimDebug returns a debugger for a simulated debug module.
+func newSimDebug(t *testing.T) (rv.Debug, *sim.Target) {
+	cfg := &sim.Config{
+		IDCode:  0x1e200a6d,
+		Version: 2,
+		Harts:   1,
+		MXLEN:   32,
+		MISA:    0x40101105,
+	}
+	drv := sim.New(cfg)
+	ch, err := jtag.NewChain(drv, jtag.ChainInfo{{IRLength: 5, ID: cfg.IDCode, Name: "sim"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev, err := ch.GetDevice(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbg, err := NewDebug(dev, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dbg, drv
+}
+
+func Test_SamplePC(t *testing.T) {
+	dbg, drv := newSimDebug(t)
+	if _, ok := dbg.(rv.PCSampler); !ok {
+		t.Error("the 0.13 debugger is not a pc sampler")
+	}
+	h := drv.Hart(0)
+	for _, d := range []rv.Debug{dbg, &haltDebug{dbg}} {
+		h.Halted = false
+		h.CSR[rv.DPC] = 0x20000100
+		pc, err := samplePC(d)
+		if err != nil || pc != 0x20000100 {
+			t.Errorf("%T: pc 0x%x %v, expected 0x20000100", d, pc, err)
+		}
+		if h.Halted {
+			t.Errorf("%T: hart0 was not resumed", d)
+		}
+		// the hart halts at a breakpoint (dcsr.cause ebreak)
+		h.Halted = true
+		h.CSR[rv.DCSR] = h.CSR[rv.DCSR]&^(7<<6) | 1<<6
+		_, err = samplePC(d)
+		if err == nil {
+			t.Errorf("%T: expected an error for a halted hart", d)
+		}
+		if !h.Halted {
+			t.Errorf("%T: a halted hart was resumed", d)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
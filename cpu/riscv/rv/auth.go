@@ -0,0 +1,85 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Debug Module Authentication
+
+A debug module may require the debugger to authenticate before it can be
+used (dmstatus.authenticated == 0). The authentication protocol is not
+defined by the debug specification, so it is provided by an authenticator
+for the target. The authenticator exchanges 32-bit words with the debug
+module through the authdata register.
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv
+
+import (
+	"errors"
+)
+
+//-----------------------------------------------------------------------------
+
+// AuthData provides access to the authdata register of a debug module.
+// The debug module is polled until it is not busy (dmstatus.authbusy == 0).
+type AuthData interface {
+	RdAuthData() (uint32, error) // read the authdata register
+	WrAuthData(x uint32) error   // write the authdata register
+}
+
+// Authenticator authenticates the debugger with a debug module.
+type Authenticator interface {
+	Authenticate(dm AuthData) error
+}
+
+//-----------------------------------------------------------------------------
+
+// ChallengeResponse is an authenticator that reads a challenge from the
+// debug module and writes back a response computed by a target specific function.
+type ChallengeResponse struct {
+	Words    int                                        // number of challenge words
+	Response func(challenge []uint32) ([]uint32, error) // returns the response for a challenge
+}
+
+// NewChallengeResponse returns a challenge-response authenticator.
+func NewChallengeResponse(words int, response func(challenge []uint32) ([]uint32, error)) *ChallengeResponse {
+	return &ChallengeResponse{
+		Words:    words,
+		Response: response,
+	}
+}
+
+// NewKeyAuth returns an authenticator that writes a fixed key to the debug module (no challenge).
+func NewKeyAuth(key []uint32) *ChallengeResponse {
+	return NewChallengeResponse(0, func(challenge []uint32) ([]uint32, error) {
+		return key, nil
+	})
+}
+
+// Authenticate reads the challenge and writes the response.
+func (a *ChallengeResponse) Authenticate(dm AuthData) error {
+	if a.Response == nil {
+		return errors.New("no response function")
+	}
+	challenge := make([]uint32, a.Words)
+	for i := range challenge {
+		x, err := dm.RdAuthData()
+		if err != nil {
+			return err
+		}
+		challenge[i] = x
+	}
+	response, err := a.Response(challenge)
+	if err != nil {
+		return err
+	}
+	for _, x := range response {
+		err := dm.WrAuthData(x)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
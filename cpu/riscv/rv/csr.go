@@ -10,6 +10,7 @@ package rv
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/deadsy/rvdbg/soc"
 	"github.com/deadsy/rvdbg/util"
@@ -27,9 +28,26 @@ func (hi *HartInfo) NewCsr() *soc.Device {
 				Registers: []soc.Register{
 					// User CSRs 0x000 - 0x0ff (read/write)
 					{Offset: 0x000, Name: "ustatus"},
-					{Offset: 0x001, Name: "fflags"},
-					{Offset: 0x002, Name: "frm"},
-					{Offset: 0x003, Name: "fcsr"},
+					{Offset: 0x001,
+						Name:   "fflags",
+						Fields: fflagsFields(),
+					},
+					{Offset: 0x002,
+						Name: "frm",
+						Fields: []soc.Field{
+							{Name: "frm", Msb: 2, Lsb: 0, Fmt: FrmName},
+						},
+					},
+					{Offset: 0x003,
+						Name: "fcsr",
+						Fields: append([]soc.Field{
+							{Name: "frm", Msb: 7, Lsb: 5, Fmt: FrmName},
+						}, fflagsFields()...),
+					},
+					{Offset: 0x008, Name: "vstart"},
+					{Offset: 0x009, Name: "vxsat"},
+					{Offset: 0x00a, Name: "vxrm"},
+					{Offset: 0x00f, Name: "vcsr"},
 					{Offset: 0x004, Name: "uie"},
 					{Offset: 0x005, Name: "utvec"},
 					{Offset: 0x040, Name: "uscratch"},
@@ -70,6 +88,18 @@ func (hi *HartInfo) NewCsr() *soc.Device {
 					{Offset: 0xc1d, Name: "hpmcounter29"},
 					{Offset: 0xc1e, Name: "hpmcounter30"},
 					{Offset: 0xc1f, Name: "hpmcounter31"},
+					{Offset: 0xc20, Name: "vl"},
+					{Offset: 0xc21,
+						Name: "vtype",
+						Fields: []soc.Field{
+							{Name: "vill", Msb: hi.MXLEN - 1, Lsb: hi.MXLEN - 1},
+							{Name: "vma", Msb: 7, Lsb: 7},
+							{Name: "vta", Msb: 6, Lsb: 6},
+							{Name: "vsew", Msb: 5, Lsb: 3, Fmt: fmtVSEW},
+							{Name: "vlmul", Msb: 2, Lsb: 0, Fmt: fmtVLMUL},
+						},
+					},
+					{Offset: 0xc22, Name: "vlenb"},
 					// User CSRs 0xc80 - 0xcbf (read only)
 					{Offset: 0xc80, Name: "cycleh"},
 					{Offset: 0xc81, Name: "timeh"},
@@ -341,26 +371,10 @@ func (hi *HartInfo) NewCsr() *soc.Device {
 					{Offset: 0x7a1, Name: "tdata1"},
 					{Offset: 0x7a2, Name: "tdata2"},
 					{Offset: 0x7a3, Name: "tdata3"},
+					{Offset: 0x7a4, Name: "tinfo"},
+					{Offset: 0x7a5, Name: "tcontrol"},
 					// Machine Debug Mode Only CSRs 0x7b0 - 0x7bf (read/write)
-					{Offset: 0x7b0,
-						Name: "dcsr",
-						Fields: []soc.Field{
-							{Name: "xdebugver", Msb: 31, Lsb: 30},
-							{Name: "ndreset", Msb: 29, Lsb: 29},
-							{Name: "fullreset", Msb: 28, Lsb: 28},
-							{Name: "ebreakm", Msb: 15, Lsb: 15},
-							{Name: "ebreakh", Msb: 14, Lsb: 14},
-							{Name: "ebreaks", Msb: 13, Lsb: 13},
-							{Name: "ebreaku", Msb: 12, Lsb: 12},
-							{Name: "stopcycle", Msb: 10, Lsb: 10},
-							{Name: "stoptime", Msb: 9, Lsb: 9},
-							{Name: "cause", Msb: 8, Lsb: 6},
-							{Name: "debugint", Msb: 5, Lsb: 5},
-							{Name: "halt", Msb: 3, Lsb: 3},
-							{Name: "step", Msb: 2, Lsb: 2},
-							{Name: "prv", Msb: 1, Lsb: 0},
-						},
-					},
+					{Offset: 0x7b0, Name: "dcsr"},
 					{Offset: 0x7b1, Name: "dpc"},
 					{Offset: 0x7b2, Name: "dscratch"},
 					// Hypervisor CSRs 0x200 - 0x2ff (read/write)
@@ -390,6 +404,8 @@ func (hi *HartInfo) NewCsr() *soc.Device {
 		r = p.GetRegister("sstatus")
 		r.Fields = append(r.Fields, soc.Field{Name: "uxl", Msb: 33, Lsb: 32, Fmt: fmtMXL})
 		// delete RV32 only registers
+		p.RemoveRegister("pmpcfg1")
+		p.RemoveRegister("pmpcfg3")
 		p.RemoveRegister("mcycleh")
 		p.RemoveRegister("minstreth")
 		p.RemoveRegister("cycleh")
@@ -401,7 +417,16 @@ func (hi *HartInfo) NewCsr() *soc.Device {
 		}
 	}
 
-	// TODO differences to DCSR decode based on debugger version
+	// delete the vector CSRs
+	if hi.VLENB == 0 {
+		p := csr.GetPeripheral("CSR")
+		for _, name := range []string{"vstart", "vxsat", "vxrm", "vcsr", "vl", "vtype", "vlenb"} {
+			p.RemoveRegister(name)
+		}
+	}
+
+	// dcsr decode based on the debugger version
+	csr.GetPeripheral("CSR").GetRegister("dcsr").Fields = append([]soc.Field(nil), dcsrFields[hi.Version]...)
 
 	hi.CSR = csr
 	return csr
@@ -409,23 +434,120 @@ func (hi *HartInfo) NewCsr() *soc.Device {
 
 //-----------------------------------------------------------------------------
 
+// dcsr fields for each debugger version.
+var dcsrFields = map[DebugVersion][]soc.Field{
+	DebugVersion011: {
+		{Name: "xdebugver", Msb: 31, Lsb: 30},
+		{Name: "ndreset", Msb: 29, Lsb: 29},
+		{Name: "fullreset", Msb: 28, Lsb: 28},
+		{Name: "ebreakm", Msb: 15, Lsb: 15},
+		{Name: "ebreakh", Msb: 14, Lsb: 14},
+		{Name: "ebreaks", Msb: 13, Lsb: 13},
+		{Name: "ebreaku", Msb: 12, Lsb: 12},
+		{Name: "stopcycle", Msb: 10, Lsb: 10},
+		{Name: "stoptime", Msb: 9, Lsb: 9},
+		{Name: "cause", Msb: 8, Lsb: 6},
+		{Name: "debugint", Msb: 5, Lsb: 5},
+		{Name: "halt", Msb: 3, Lsb: 3},
+		{Name: "step", Msb: 2, Lsb: 2},
+		{Name: "prv", Msb: 1, Lsb: 0},
+	},
+	DebugVersion013: {
+		{Name: "xdebugver", Msb: 31, Lsb: 28},
+		{Name: "ebreakm", Msb: 15, Lsb: 15},
+		{Name: "ebreaks", Msb: 13, Lsb: 13},
+		{Name: "ebreaku", Msb: 12, Lsb: 12},
+		{Name: "stepie", Msb: 11, Lsb: 11},
+		{Name: "stopcount", Msb: 10, Lsb: 10},
+		{Name: "stoptime", Msb: 9, Lsb: 9},
+		{Name: "cause", Msb: 8, Lsb: 6, Enums: dcsrCause},
+		{Name: "mprven", Msb: 4, Lsb: 4},
+		{Name: "nmip", Msb: 3, Lsb: 3},
+		{Name: "step", Msb: 2, Lsb: 2},
+		{Name: "prv", Msb: 1, Lsb: 0},
+	},
+	DebugVersion10: {
+		{Name: "debugver", Msb: 31, Lsb: 28},
+		{Name: "extcause", Msb: 26, Lsb: 24},
+		{Name: "cetrig", Msb: 19, Lsb: 19},
+		{Name: "ebreakvs", Msb: 17, Lsb: 17},
+		{Name: "ebreakvu", Msb: 16, Lsb: 16},
+		{Name: "ebreakm", Msb: 15, Lsb: 15},
+		{Name: "ebreaks", Msb: 13, Lsb: 13},
+		{Name: "ebreaku", Msb: 12, Lsb: 12},
+		{Name: "stepie", Msb: 11, Lsb: 11},
+		{Name: "stopcount", Msb: 10, Lsb: 10},
+		{Name: "stoptime", Msb: 9, Lsb: 9},
+		{Name: "cause", Msb: 8, Lsb: 6, Enums: dcsrCause},
+		{Name: "v", Msb: 5, Lsb: 5},
+		{Name: "mprven", Msb: 4, Lsb: 4},
+		{Name: "nmip", Msb: 3, Lsb: 3},
+		{Name: "step", Msb: 2, Lsb: 2},
+		{Name: "prv", Msb: 1, Lsb: 0},
+	},
+}
+
+// dcsrCause is the reason for entering debug mode (0.13/1.0).
+var dcsrCause = soc.Enum{
+	1: "ebreak",
+	2: "trigger",
+	3: "haltreq",
+	4: "step",
+	5: "resethaltreq",
+	6: "group",
+	7: "other",
+}
+
+//-----------------------------------------------------------------------------
+
 // CSR register addresses.
 const (
-	FFLAGS    = 0x001
-	FRM       = 0x002
-	FCSR      = 0x003
-	SSCRATCH  = 0x140
-	MSTATUS   = 0x300
-	MISA      = 0x301
-	MSCRATCH  = 0x340
-	DCSR      = 0x7b0
-	DPC       = 0x7b1
-	DSCRATCH0 = 0x7b2
-	DSCRATCH1 = 0x7b3
-	MVENDORID = 0xf11
-	MARCHID   = 0xf12
-	MIMPID    = 0xf13
-	MHARTID   = 0xf14
+	FFLAGS        = 0x001
+	FRM           = 0x002
+	FCSR          = 0x003
+	VSTART        = 0x008
+	VXSAT         = 0x009
+	VXRM          = 0x00a
+	VCSR          = 0x00f
+	SSTATUS       = 0x100
+	SSCRATCH      = 0x140
+	SEPC          = 0x141
+	SCAUSE        = 0x142
+	STVAL         = 0x143
+	SATP          = 0x180
+	MSTATUS       = 0x300
+	MISA          = 0x301
+	MCOUNTINHIBIT = 0x320 // mucounteren before priv 1.11
+	MSCOUNTEREN   = 0x321 // priv 1.9.1 only
+	MHPMEVENT3    = 0x323
+	MSCRATCH      = 0x340
+	MEPC          = 0x341
+	MCAUSE        = 0x342
+	MTVAL         = 0x343
+	TSELECT       = 0x7a0
+	TDATA1        = 0x7a1
+	TDATA2        = 0x7a2
+	TDATA3        = 0x7a3
+	TINFO         = 0x7a4
+	DCSR          = 0x7b0
+	DPC           = 0x7b1
+	DSCRATCH0     = 0x7b2
+	DSCRATCH1     = 0x7b3
+	MCYCLE        = 0xb00
+	MINSTRET      = 0xb02
+	MHPMCOUNTER3  = 0xb03
+	MCYCLEH       = 0xb80
+	MINSTRETH     = 0xb82
+	MHPMCOUNTER3H = 0xb83
+	VL            = 0xc20
+	VTYPE         = 0xc21
+	VLENB         = 0xc22
+	MCYCLERO      = 0xf00 // read only mcycle before priv 1.10
+	MCYCLEROH     = 0xf80 // read only mcycleh before priv 1.10
+	MVENDORID     = 0xf11
+	MARCHID       = 0xf12
+	MIMPID        = 0xf13
+	MHARTID       = 0xf14
 )
 
 // CSR address modes.
@@ -471,6 +593,72 @@ func CheckExtMISA(misa uint, ext rune) bool {
 	return (misa & (1 << n)) != 0
 }
 
+//-----------------------------------------------------------------------------
+// FCSR
+
+// fflagsFields returns the floating point exception flag fields.
+func fflagsFields() []soc.Field {
+	return []soc.Field{
+		{Name: "nv", Msb: 4, Lsb: 4},
+		{Name: "dz", Msb: 3, Lsb: 3},
+		{Name: "of", Msb: 2, Lsb: 2},
+		{Name: "uf", Msb: 1, Lsb: 1},
+		{Name: "nx", Msb: 0, Lsb: 0},
+	}
+}
+
+// FrmName returns the name of a floating point rounding mode.
+func FrmName(x uint) string {
+	return []string{"rne", "rtz", "rdn", "rup", "rmm", "reserved", "reserved", "dyn"}[x&7]
+}
+
+// FflagsName returns the names of the set floating point exception flags.
+func FflagsName(x uint) string {
+	s := []string{}
+	for i, name := range []string{"nv", "dz", "of", "uf", "nx"} {
+		if x&(1<<(4-uint(i))) != 0 {
+			s = append(s, name)
+		}
+	}
+	if len(s) == 0 {
+		return "none"
+	}
+	return strings.Join(s, ",")
+}
+
+//-----------------------------------------------------------------------------
+// VTYPE
+
+// MstatusVS is the mstatus.vs (vector context status) field.
+const MstatusVS = (3 << 9)
+
+// VectorOn sets mstatus.vs so the vector CSRs and instructions can be used.
+// It returns the original mstatus value.
+func VectorOn(dbg Debug) (uint64, error) {
+	mstatus, err := dbg.RdCSR(MSTATUS, 0)
+	if err != nil {
+		return 0, err
+	}
+	if mstatus&MstatusVS == 0 {
+		err = dbg.WrCSR(MSTATUS, 0, mstatus|MstatusVS)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return mstatus, nil
+}
+
+func fmtVSEW(x uint) string {
+	if x > 3 {
+		return "reserved"
+	}
+	return fmt.Sprintf("e%d", 8<<x)
+}
+
+func fmtVLMUL(x uint) string {
+	return []string{"m1", "m2", "m4", "m8", "reserved", "mf8", "mf4", "mf2"}[x]
+}
+
 //-----------------------------------------------------------------------------
 
 // GetCSRSize returns the CSR register bit size.
@@ -486,6 +674,11 @@ func GetCSRSize(reg uint, hi *HartInfo) uint {
 		return 0
 	case DPC:
 		return hi.DXLEN
+	case VSTART, VXSAT, VXRM, VCSR, VL, VTYPE, VLENB:
+		if hi.VLENB != 0 {
+			return hi.MXLEN
+		}
+		return 0
 	}
 	// normal
 	switch reg & modeMask {
@@ -0,0 +1,62 @@
+//-----------------------------------------------------------------------------
+/*
+
+CSR Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv
+
+import "testing"
+
+//-----------------------------------------------------------------------------
+
+func Test_FflagsName(t *testing.T) {
+	tests := []struct {
+		x    uint
+		name string
+	}{
+		{0, "none"},
+		{0x10, "nv"},
+		{0x08, "dz"},
+		{0x04, "of"},
+		{0x02, "uf"},
+		{0x01, "nx"},
+		{0x05, "of,nx"},
+		{0x1f, "nv,dz,of,uf,nx"},
+		{0xe0, "none"}, // frm bits are ignored
+		{0xe3, "uf,nx"},
+	}
+	for _, v := range tests {
+		name := FflagsName(v.x)
+		if name != v.name {
+			t.Errorf("0x%x: \"%s\", expected \"%s\"", v.x, name, v.name)
+		}
+	}
+}
+
+func Test_FrmName(t *testing.T) {
+	tests := []struct {
+		x    uint
+		name string
+	}{
+		{0, "rne"},
+		{1, "rtz"},
+		{2, "rdn"},
+		{3, "rup"},
+		{4, "rmm"},
+		{5, "reserved"},
+		{6, "reserved"},
+		{7, "dyn"},
+		{0xf, "dyn"}, // upper bits are ignored
+	}
+	for _, v := range tests {
+		name := FrmName(v.x)
+		if name != v.name {
+			t.Errorf("%d: \"%s\", expected \"%s\"", v.x, name, v.name)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
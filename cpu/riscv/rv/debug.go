@@ -40,21 +40,69 @@ func (s HartState) String() string {
 	return "unknown"
 }
 
+// MarshalText returns the hart state name (E.g. for JSON output).
+func (s HartState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText sets the hart state from its name (E.g. for a JSON-RPC client).
+func (s *HartState) UnmarshalText(text []byte) error {
+	*s = Unknown
+	for k, v := range stateName {
+		if v == string(text) {
+			*s = k
+		}
+	}
+	return nil
+}
+
+// DebugVersion is the version of the RISC-V debug specification.
+type DebugVersion int
+
+// DebugVersion values.
+const (
+	DebugVersion011 DebugVersion = iota // 0.11
+	DebugVersion013                     // 0.13
+	DebugVersion10                      // 1.0
+)
+
+func (v DebugVersion) String() string {
+	return [3]string{"0.11", "0.13", "1.0"}[v]
+}
+
+// MarshalText returns the debug version string (E.g. for JSON output).
+func (v DebugVersion) MarshalText() ([]byte, error) {
+	return []byte(v.String()), nil
+}
+
+// UnmarshalText sets the debug version from its string (E.g. for a JSON-RPC client).
+func (v *DebugVersion) UnmarshalText(text []byte) error {
+	for i := DebugVersion011; i <= DebugVersion10; i++ {
+		if i.String() == string(text) {
+			*v = i
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown debug version \"%s\"", text)
+}
+
 // HartInfo stores generic hart information.
 type HartInfo struct {
-	ID      int         // hart identifier
-	State   HartState   // hart state
-	Nregs   int         // number of GPRs (normally 32, 16 for rv32e)
-	MXLEN   uint        // machine XLEN
-	SXLEN   uint        // supervisor XLEN (0 == no S-mode)
-	UXLEN   uint        // user XLEN (0 == no U-mode)
-	HXLEN   uint        // hypervisor XLEN (0 == no H-mode)
-	DXLEN   uint        // debug XLEN
-	FLEN    uint        // foating point register width (0 == no floating point)
-	MISA    uint        // MISA value
-	MHARTID uint        // MHARTID value
-	CSR     *soc.Device // CSR registers/fields
-	ISA     *rvda.ISA   // ISA for the disassembler
+	ID      int          `json:"id"`      // hart identifier
+	State   HartState    `json:"state"`   // hart state
+	Version DebugVersion `json:"version"` // debug specification version
+	Nregs   int          `json:"nregs"`   // number of GPRs (normally 32, 16 for rv32e)
+	MXLEN   uint         `json:"mxlen"`   // machine XLEN
+	SXLEN   uint         `json:"sxlen"`   // supervisor XLEN (0 == no S-mode)
+	UXLEN   uint         `json:"uxlen"`   // user XLEN (0 == no U-mode)
+	HXLEN   uint         `json:"hxlen"`   // hypervisor XLEN (0 == no H-mode)
+	DXLEN   uint         `json:"dxlen"`   // debug XLEN
+	FLEN    uint         `json:"flen"`    // foating point register width (0 == no floating point)
+	VLENB   uint         `json:"vlenb"`   // vector register length in bytes (0 == no vector extension)
+	MISA    uint         `json:"misa"`    // MISA value
+	MHARTID uint         `json:"mhartid"` // MHARTID value
+	CSR     *soc.Device  `json:"-"`       // CSR registers/fields
+	ISA     *rvda.ISA    `json:"-"`       // ISA for the disassembler
 }
 
 func xlenString(n uint, msg string) string {
@@ -67,6 +115,7 @@ func xlenString(n uint, msg string) string {
 func (hi *HartInfo) String() string {
 	s := make([][]string, 0)
 	s = append(s, []string{fmt.Sprintf("hart%d", hi.ID), fmt.Sprintf("%s", hi.State)})
+	s = append(s, []string{"debug", hi.Version.String()})
 	s = append(s, []string{"mhartid", fmt.Sprintf("%d", hi.MHARTID)})
 	s = append(s, []string{"nregs", fmt.Sprintf("%d", hi.Nregs)})
 	s = append(s, []string{"mxlen", fmt.Sprintf("%d", hi.MXLEN)})
@@ -74,6 +123,7 @@ func (hi *HartInfo) String() string {
 	s = append(s, []string{"uxlen", xlenString(hi.UXLEN, "u-mode")})
 	s = append(s, []string{"hxlen", xlenString(hi.HXLEN, "h-mode")})
 	s = append(s, []string{"flen", xlenString(hi.FLEN, "floating point")})
+	s = append(s, []string{"vlen", xlenString(hi.VLENB*8, "vector")})
 	s = append(s, []string{"dxlen", fmt.Sprintf("%d", hi.DXLEN)})
 	return cli.TableString(s, []int{0, 0}, 1)
 }
@@ -90,6 +140,16 @@ type Debug interface {
 	SetCurrentHart(id int) (*HartInfo, error) // set the current hart
 	HaltHart() error                          // halt the current hart
 	ResumeHart() error                        // resume the current hart
+	// hart group control
+	SetHartGroup(ids []int) error     // set the harts that are halted/resumed together
+	GetHartGroup() []int              // get the harts that are halted/resumed together
+	HaltAll() error                   // halt all harts in the hart group
+	ResumeAll() error                 // resume all harts in the hart group
+	HartStates() ([]HartState, error) // get the state of all harts
+	// reset
+	ResetSystem(halt bool) error // reset the system, optionally halting the harts
+	ResetHart(halt bool) error   // reset the current hart, optionally halting it
+	ResetDebug() error           // reset the debug module
 	// registers
 	RdGPR(reg, size uint) (uint64, error)   // read general purpose register
 	RdFPR(reg, size uint) (uint64, error)   // read floating point register
@@ -97,13 +157,24 @@ type Debug interface {
 	WrGPR(reg, size uint, val uint64) error // write general purpose register
 	WrFPR(reg, size uint, val uint64) error // write floating point register
 	WrCSR(reg, size uint, val uint64) error // write control and status register
+	RdVPR(reg uint) ([]byte, error)         // read vector register (VLENB bytes, element 0 first)
+	WrVPR(reg uint, val []byte) error       // write vector register
 	// memory
 	GetAddressSize() uint                      // get address size in bits
 	RdMem(width, addr, n uint) ([]uint, error) // read width-bit memory buffer
 	WrMem(width, addr uint, val []uint) error  // write width-bit memory buffer
+	SetMemoryMode(mode MemMode) error          // set the memory access mode
+	GetMemoryMode() MemMode                    // get the memory access mode
+	Translate(vaddr uint) (*PageWalk, error)   // translate a virtual address using satp
 	// test
 	Test1() string
 	Test2() string
 }
 
+// PCSampler is an optional interface for a debugger that can sample the pc
+// of a running hart with fewer debug operations than HaltHart/RdCSR/ResumeHart.
+type PCSampler interface {
+	SamplePC() (uint64, error) // sample the pc of the current hart, error if it is halted
+}
+
 //-----------------------------------------------------------------------------
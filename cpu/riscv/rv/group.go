@@ -0,0 +1,72 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Hart Groups
+
+The hart group is the set of harts that are halted/resumed together.
+It is shared by the debug module implementations.
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv
+
+import "fmt"
+
+//-----------------------------------------------------------------------------
+
+// AllHarts returns the ids of all n harts.
+func AllHarts(n int) []int {
+	ids := make([]int, n)
+	for i := range ids {
+		ids[i] = i
+	}
+	return ids
+}
+
+// HartGroup is a hart group. The zero value is a group of all harts.
+type HartGroup struct {
+	ids []int
+}
+
+// Set sets the harts in the group (n harts, empty == all harts).
+func (g *HartGroup) Set(ids []int, n int) error {
+	for _, id := range ids {
+		if id < 0 || id >= n {
+			return fmt.Errorf("hart id %d is out of range", id)
+		}
+	}
+	if len(ids) == 0 {
+		ids = AllHarts(n)
+	}
+	g.ids = ids
+	return nil
+}
+
+// Get returns the harts in the group (n harts).
+func (g *HartGroup) Get(n int) []int {
+	if g.ids == nil {
+		return AllHarts(n)
+	}
+	return g.ids
+}
+
+// GroupSequential selects each hart in the hart group in turn and runs a function.
+// The current hart is restored afterwards.
+func GroupSequential(dbg Debug, fn func() error) error {
+	id := dbg.GetCurrentHart().ID
+	defer dbg.SetCurrentHart(id)
+	for _, i := range dbg.GetHartGroup() {
+		_, err := dbg.SetCurrentHart(i)
+		if err != nil {
+			return err
+		}
+		err = fn()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,163 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Hardware Performance Monitor Events
+
+The mhpmevent encoding is core specific. The event tables are selected
+with mvendorid and marchid. Open source architecture ids are registered
+with mvendorid 0, commercial architecture ids have the marchid msb set.
+
+SiFive/Rocket: mhpmevent[7:0] is the event class, the upper bits are a mask
+of events within the class. The counter increments when any of the masked
+events occur.
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+//-----------------------------------------------------------------------------
+
+// HpmEvent is a performance monitor event.
+type HpmEvent struct {
+	Name  string `json:"name"`
+	Descr string `json:"descr"`
+	Class uint   `json:"class"`
+	Bit   uint   `json:"bit"`
+}
+
+// sifiveEvents are the SiFive/Rocket core events.
+var sifiveEvents = []HpmEvent{
+	// instruction commit events
+	{"exception", "exception taken", 0, 8},
+	{"load", "integer load instruction retired", 0, 9},
+	{"store", "integer store instruction retired", 0, 10},
+	{"amo", "atomic memory operation retired", 0, 11},
+	{"system", "system instruction retired", 0, 12},
+	{"arith", "integer arithmetic instruction retired", 0, 13},
+	{"branch", "conditional branch retired", 0, 14},
+	{"jal", "jal instruction retired", 0, 15},
+	{"jalr", "jalr instruction retired", 0, 16},
+	{"mul", "integer multiplication instruction retired", 0, 17},
+	{"div", "integer division instruction retired", 0, 18},
+	// microarchitectural events
+	{"load-use", "load-use interlock", 1, 8},
+	{"long-latency", "long-latency interlock", 1, 9},
+	{"csr-read", "csr read interlock", 1, 10},
+	{"icache-busy", "instruction cache/itim busy", 1, 11},
+	{"dcache-busy", "data cache/dtim busy", 1, 12},
+	{"branch-miss", "branch direction misprediction", 1, 13},
+	{"target-miss", "branch/jump target misprediction", 1, 14},
+	{"csr-flush", "pipeline flush from csr write", 1, 15},
+	{"other-flush", "pipeline flush from other event", 1, 16},
+	{"mul-interlock", "integer multiplication interlock", 1, 17},
+	// memory system events
+	{"icache-miss", "instruction cache miss", 2, 8},
+	{"dcache-miss", "data cache miss or memory-mapped i/o access", 2, 9},
+	{"dcache-wb", "data cache writeback", 2, 10},
+	{"itlb-miss", "instruction tlb miss", 2, 11},
+	{"dtlb-miss", "data tlb miss", 2, 12},
+}
+
+// HpmID identifies the core for the event tables.
+type HpmID struct {
+	Mvendorid uint // mvendorid value
+	Marchid   uint // marchid value
+	MXLEN     uint // machine XLEN (marchid length)
+}
+
+// commercial returns true for a commercial architecture id (msb set).
+func (id *HpmID) commercial() bool {
+	return id.MXLEN != 0 && (id.Marchid>>(id.MXLEN-1))&1 != 0
+}
+
+func (id *HpmID) String() string {
+	return fmt.Sprintf("mvendorid 0x%x marchid 0x%x", id.Mvendorid, id.Marchid)
+}
+
+// hpmTable is the event table for a set of cores.
+type hpmTable struct {
+	mvendorid uint
+	marchid   uint // 0 == any commercial architecture id of the vendor
+	events    []HpmEvent
+}
+
+var hpmTables = []hpmTable{
+	{0, 1, sifiveEvents},     // rocket (open source architecture id 1)
+	{0x489, 0, sifiveEvents}, // sifive cores (E.g. e31, u54, u74)
+}
+
+// HpmEvents returns the performance monitor events for a core (nil == unknown).
+func HpmEvents(id *HpmID) []HpmEvent {
+	for _, t := range hpmTables {
+		if t.mvendorid != id.Mvendorid {
+			continue
+		}
+		if t.marchid == id.Marchid || (t.marchid == 0 && id.commercial()) {
+			return t.events
+		}
+	}
+	return nil
+}
+
+// HpmEventValue returns the mhpmevent value for an event string.
+// The event string is a number or event names joined with "+" (E.g. "load+store").
+func HpmEventValue(id *HpmID, s string) (uint64, error) {
+	if x, err := strconv.ParseUint(s, 0, 64); err == nil {
+		return x, nil
+	}
+	events := HpmEvents(id)
+	if events == nil {
+		return 0, fmt.Errorf("no event names for %s, use a number", id)
+	}
+	var val uint64
+	class := -1
+	for _, name := range strings.Split(s, "+") {
+		var e *HpmEvent
+		for i := range events {
+			if events[i].Name == name {
+				e = &events[i]
+				break
+			}
+		}
+		if e == nil {
+			return 0, fmt.Errorf("unknown event \"%s\"", name)
+		}
+		if class >= 0 && uint(class) != e.Class {
+			return 0, fmt.Errorf("event \"%s\" is not in event class %d", name, class)
+		}
+		class = int(e.Class)
+		val |= uint64(e.Class) | (1 << e.Bit)
+	}
+	return val, nil
+}
+
+// HpmEventName returns the event names for an mhpmevent value.
+func HpmEventName(id *HpmID, val uint64) string {
+	if val == 0 {
+		return "none"
+	}
+	names := []string{}
+	for _, e := range HpmEvents(id) {
+		if val&0xff == uint64(e.Class) && val&(1<<e.Bit) != 0 {
+			names = append(names, e.Name)
+			val &= ^uint64(1 << e.Bit)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("0x%x", val)
+	}
+	if val > 0xff {
+		// unknown mask bits
+		names = append(names, fmt.Sprintf("0x%x", val&^0xff))
+	}
+	return strings.Join(names, "+")
+}
+
+//-----------------------------------------------------------------------------
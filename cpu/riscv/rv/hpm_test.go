@@ -0,0 +1,99 @@
+//-----------------------------------------------------------------------------
+/*
+
+Hardware Performance Monitor Event Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv
+
+import "testing"
+
+//-----------------------------------------------------------------------------
+
+var fe310 = &HpmID{Mvendorid: 0x489, Marchid: 0x80000007, MXLEN: 32}
+var fu540 = &HpmID{Mvendorid: 0x489, Marchid: 0x8000000000000007, MXLEN: 64}
+var rocket = &HpmID{Mvendorid: 0, Marchid: 1, MXLEN: 64}
+
+func Test_HpmEvents(t *testing.T) {
+	tests := []struct {
+		id    *HpmID
+		known bool
+	}{
+		{fe310, true},
+		{fu540, true},
+		{rocket, true},
+		// unregistered open source core
+		{&HpmID{Mvendorid: 0, Marchid: 0, MXLEN: 64}, false},
+		{&HpmID{Mvendorid: 0, Marchid: 0x8000000000000000, MXLEN: 64}, false},
+		// sifive open source architecture id
+		{&HpmID{Mvendorid: 0x489, Marchid: 7, MXLEN: 32}, false},
+		{&HpmID{Mvendorid: 0x31e, Marchid: 0x80000007, MXLEN: 32}, false},
+	}
+	for _, v := range tests {
+		events := HpmEvents(v.id)
+		if (events != nil) != v.known {
+			t.Errorf("%s: event table %t, expected %t", v.id, events != nil, v.known)
+		}
+	}
+}
+
+func Test_HpmEventValue(t *testing.T) {
+	tests := []struct {
+		id  *HpmID
+		s   string
+		val uint64
+		ok  bool
+	}{
+		{fe310, "0x4200", 0x4200, true},
+		{fe310, "0", 0, true},
+		{fe310, "load", 0x200, true},
+		{fe310, "load+store", 0x600, true},
+		{fu540, "icache-miss+dcache-miss", 0x302, true},
+		{rocket, "branch-miss", 0x2001, true},
+		{fe310, "load+icache-miss", 0, false}, // different classes
+		{fe310, "bogus", 0, false},
+		{fe310, "load+", 0, false},
+		{&HpmID{MXLEN: 32}, "load", 0, false}, // no event names
+		{&HpmID{MXLEN: 32}, "0x200", 0x200, true},
+	}
+	for _, v := range tests {
+		val, err := HpmEventValue(v.id, v.s)
+		if (err == nil) != v.ok || val != v.val {
+			t.Errorf("%s \"%s\": 0x%x %v, expected 0x%x ok %t", v.id, v.s, val, err, v.val, v.ok)
+		}
+	}
+}
+
+func Test_HpmEventName(t *testing.T) {
+	tests := []struct {
+		id   *HpmID
+		val  uint64
+		name string
+	}{
+		{fe310, 0, "none"},
+		{fe310, 0x200, "load"},
+		{fe310, 0x600, "load+store"},
+		{fu540, 0x302, "icache-miss+dcache-miss"},
+		{fe310, 0x80200, "load+0x80000"}, // unknown mask bit
+		{fe310, 0x1, "0x1"},              // no events in the class
+		{fe310, 0x7, "0x7"},              // unknown class
+		{&HpmID{MXLEN: 32}, 0x200, "0x200"},
+	}
+	for _, v := range tests {
+		name := HpmEventName(v.id, v.val)
+		if name != v.name {
+			t.Errorf("%s 0x%x: \"%s\", expected \"%s\"", v.id, v.val, name, v.name)
+		}
+	}
+	// names and values round trip
+	for _, e := range HpmEvents(fe310) {
+		val, err := HpmEventValue(fe310, e.Name)
+		if err != nil || HpmEventName(fe310, val) != e.Name {
+			t.Errorf("%s: 0x%x %v does not round trip", e.Name, val, err)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -13,31 +13,35 @@ import "github.com/deadsy/rvdbg/util"
 //-----------------------------------------------------------------------------
 
 const (
-	opcodeLB      = 0x00000003 // lb
-	opcodeLH      = 0x00001003 // lh
-	opcodeLW      = 0x00002003 // lw
-	opcodeLWU     = 0x00006003 // lwu
-	opcodeLD      = 0x00003003 // ld
-	opcodeSB      = 0x00000023 // sb
-	opcodeSH      = 0x00001023 // sh
-	opcodeSW      = 0x00002023 // sw
-	opcodeSD      = 0x00003023 // sd
-	opcodeJAL     = 0x0000006f // jal
-	opcodeXORI    = 0x00004013 // xori
-	opcodeSRLI    = 0x00005013 // srli
-	opcodeADDI    = 0x00000013 // addi
-	opcodeEBREAK  = 0x00100073 // ebreak
-	opcodeCSRRW   = 0x00001073 // csrrw
-	opcodeCSRRS   = 0x00002073 // csrrs
-	opcodeCSRRSI  = 0x00006073 // csrrsi
-	opcodeFMV_X_W = 0xe0000053 // fmv.x.w
-	opcodeFMV_W_X = 0xf0000053 // fmv.w.x
-	opcodeFMV_D_X = 0xf2000053 // fmv.d.x
-	opcodeFMV_X_D = 0xe2000053 // fmv.x.d
-	opcodeFLD     = 0x00003007 // fld
-	opcodeFSD     = 0x00003027 // fsd
-	opcodeFLW     = 0x00002007 // flw
-	opcodeFSW     = 0x00002027 // fsw
+	opcodeLB             = 0x00000003 // lb
+	opcodeLH             = 0x00001003 // lh
+	opcodeLW             = 0x00002003 // lw
+	opcodeLWU            = 0x00006003 // lwu
+	opcodeLD             = 0x00003003 // ld
+	opcodeSB             = 0x00000023 // sb
+	opcodeSH             = 0x00001023 // sh
+	opcodeSW             = 0x00002023 // sw
+	opcodeSD             = 0x00003023 // sd
+	opcodeJAL            = 0x0000006f // jal
+	opcodeXORI           = 0x00004013 // xori
+	opcodeSRLI           = 0x00005013 // srli
+	opcodeADDI           = 0x00000013 // addi
+	opcodeEBREAK         = 0x00100073 // ebreak
+	opcodeCSRRW          = 0x00001073 // csrrw
+	opcodeCSRRS          = 0x00002073 // csrrs
+	opcodeCSRRSI         = 0x00006073 // csrrsi
+	opcodeFMV_X_W        = 0xe0000053 // fmv.x.w
+	opcodeFMV_W_X        = 0xf0000053 // fmv.w.x
+	opcodeFMV_D_X        = 0xf2000053 // fmv.d.x
+	opcodeFMV_X_D        = 0xe2000053 // fmv.x.d
+	opcodeFLD            = 0x00003007 // fld
+	opcodeFSD            = 0x00003027 // fsd
+	opcodeFLW            = 0x00002007 // flw
+	opcodeFSW            = 0x00002027 // fsw
+	opcodeVSETVLI        = 0x00007057 // vsetvli
+	opcodeVSETVL         = 0x80007057 // vsetvl
+	opcodeVMV_X_S        = 0x42002057 // vmv.x.s
+	opcodeVSLIDE1DOWN_VX = 0x3e006057 // vslide1down.vx
 )
 
 //-----------------------------------------------------------------------------
@@ -154,4 +158,24 @@ func InsFLW(rd, ofs, rs1 uint) uint32 {
 	return uint32((util.Bits(ofs, 11, 0) << 20) | (rs1 << 15) | (rd << 7) | opcodeFLW)
 }
 
+// InsVSETVLI returns "vsetvli rd, rs1, vtypei"
+func InsVSETVLI(rd, rs1, vtypei uint) uint32 {
+	return uint32((util.Bits(vtypei, 10, 0) << 20) | (rs1 << 15) | (rd << 7) | opcodeVSETVLI)
+}
+
+// InsVSETVL returns "vsetvl rd, rs1, rs2"
+func InsVSETVL(rd, rs1, rs2 uint) uint32 {
+	return uint32((rs2 << 20) | (rs1 << 15) | (rd << 7) | opcodeVSETVL)
+}
+
+// InsVMV_X_S returns "vmv.x.s rd, vs2"
+func InsVMV_X_S(rd, vs2 uint) uint32 {
+	return uint32((vs2 << 20) | (rd << 7) | opcodeVMV_X_S)
+}
+
+// InsVSLIDE1DOWN_VX returns "vslide1down.vx vd, vs2, rs1"
+func InsVSLIDE1DOWN_VX(vd, vs2, rs1 uint) uint32 {
+	return uint32((vs2 << 20) | (rs1 << 15) | (vd << 7) | opcodeVSLIDE1DOWN_VX)
+}
+
 //-----------------------------------------------------------------------------
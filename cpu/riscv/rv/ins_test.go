@@ -0,0 +1,38 @@
+//-----------------------------------------------------------------------------
+/*
+
+Instruction Encoding Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv
+
+import "testing"
+
+//-----------------------------------------------------------------------------
+
+func Test_VectorIns(t *testing.T) {
+	tests := []struct {
+		name string
+		ins  uint32
+		op   uint32
+	}{
+		{"vsetvli s0, zero, e32, m1, ta, ma", InsVSETVLI(RegS0, RegZero, 0xd0), 0x0d007457},
+		{"vsetvli t0, a0, e32, m1, ta, ma", InsVSETVLI(RegT0, RegA0, 0xd0), 0x0d0572d7},
+		{"vsetvli s0, zero, e64, m1, ta, ma", InsVSETVLI(RegS0, RegZero, 0xd8), 0x0d807457},
+		{"vsetvl zero, s0, s1", InsVSETVL(RegZero, RegS0, RegS1), 0x80947057},
+		{"vsetvl t0, a0, a1", InsVSETVL(RegT0, RegA0, RegA1), 0x80b572d7},
+		{"vmv.x.s a0, v8", InsVMV_X_S(RegA0, 8), 0x42802557},
+		{"vmv.x.s s0, v3", InsVMV_X_S(RegS0, 3), 0x42302457},
+		{"vslide1down.vx v3, v3, s0", InsVSLIDE1DOWN_VX(3, 3, RegS0), 0x3e3461d7},
+		{"vslide1down.vx v31, v31, s0", InsVSLIDE1DOWN_VX(31, 31, RegS0), 0x3ff46fd7},
+	}
+	for _, v := range tests {
+		if v.ins != v.op {
+			t.Errorf("%s: 0x%08x, expected 0x%08x", v.name, v.ins, v.op)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
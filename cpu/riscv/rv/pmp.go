@@ -0,0 +1,179 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Physical Memory Protection
+
+Decode the pmpcfg/pmpaddr registers and check an access against them.
+
+RV32: pmpcfg0..15 each have 4 entries.
+RV64: pmpcfg0,2,4..14 each have 8 entries (the odd pmpcfg registers don't exist).
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv
+
+import (
+	"fmt"
+)
+
+//-----------------------------------------------------------------------------
+
+// PMP CSR addresses.
+const (
+	PMPCFG0  = 0x3a0
+	PMPADDR0 = 0x3b0
+)
+
+// MaxPmpEntries is the maximum number of PMP entries.
+const MaxPmpEntries = 64
+
+// PMP configuration bits.
+const (
+	pmpR = 1 << 0 // read
+	pmpW = 1 << 1 // write
+	pmpX = 1 << 2 // execute
+	pmpL = 1 << 7 // locked
+)
+
+// PMP address matching modes (pmpcfg.A).
+const (
+	pmpOff   = 0 // null region (disabled)
+	pmpTor   = 1 // top of range
+	pmpNa4   = 2 // naturally aligned four-byte region
+	pmpNapot = 3 // naturally aligned power-of-two region
+)
+
+var pmpModeName = [4]string{"OFF", "TOR", "NA4", "NAPOT"}
+
+// PmpCfgReg returns the pmpcfg CSR and byte shift for a PMP entry.
+func PmpCfgReg(i int, xlen uint) (uint, uint) {
+	if xlen == 64 {
+		// 8 entries per even numbered pmpcfg register
+		return PMPCFG0 + uint(i>>3)*2, uint(i&7) * 8
+	}
+	// 4 entries per pmpcfg register
+	return PMPCFG0 + uint(i>>2), uint(i&3) * 8
+}
+
+//-----------------------------------------------------------------------------
+
+// PmpEntry is a decoded PMP entry.
+type PmpEntry struct {
+	Index int    `json:"index"`
+	Cfg   uint   `json:"cfg"`   // pmpcfg byte
+	Addr  uint   `json:"addr"`  // pmpaddr value
+	Mode  string `json:"mode"`  // OFF, TOR, NA4, NAPOT
+	R     bool   `json:"r"`     // read
+	W     bool   `json:"w"`     // write
+	X     bool   `json:"x"`     // execute
+	L     bool   `json:"l"`     // locked
+	Start uint   `json:"start"` // start of the address range
+	End   uint   `json:"end"`   // end of the address range (exclusive)
+}
+
+// matches returns true if an address is within the entry address range.
+func (e *PmpEntry) matches(addr uint) bool {
+	return e.Mode != pmpModeName[pmpOff] && addr >= e.Start && addr < e.End
+}
+
+// Perms returns the permissions string for the entry.
+func (e *PmpEntry) Perms() string {
+	s := []rune("l---")
+	if !e.L {
+		s[0] = '-'
+	}
+	if e.R {
+		s[1] = 'r'
+	}
+	if e.W {
+		s[2] = 'w'
+	}
+	if e.X {
+		s[3] = 'x'
+	}
+	return string(s)
+}
+
+// Range returns the address range string for the entry.
+func (e *PmpEntry) Range() string {
+	if e.Mode == pmpModeName[pmpOff] {
+		return ""
+	}
+	if e.Start >= e.End {
+		return "empty"
+	}
+	return fmt.Sprintf("0x%x-0x%x", e.Start, e.End-1)
+}
+
+// PmpDecode decodes the PMP entries from the pmpcfg bytes and pmpaddr values.
+func PmpDecode(cfg []uint8, addr []uint, xlen uint) []*PmpEntry {
+	// pmpaddr has address bits 33:2 (rv32) or 55:2 (rv64)
+	abits := map[uint]uint{32: 32, 64: 54}[xlen]
+	amask := (uint(1) << abits) - 1
+	entries := make([]*PmpEntry, len(addr))
+	for i := range addr {
+		a := addr[i] & amask
+		e := &PmpEntry{
+			Index: i,
+			Cfg:   uint(cfg[i]),
+			Addr:  a,
+			Mode:  pmpModeName[(cfg[i]>>3)&3],
+			R:     cfg[i]&pmpR != 0,
+			W:     cfg[i]&pmpW != 0,
+			X:     cfg[i]&pmpX != 0,
+			L:     cfg[i]&pmpL != 0,
+		}
+		switch (cfg[i] >> 3) & 3 {
+		case pmpTor:
+			if i > 0 {
+				e.Start = (addr[i-1] & amask) << 2
+			}
+			e.End = a << 2
+		case pmpNa4:
+			e.Start = a << 2
+			e.End = e.Start + 4
+		case pmpNapot:
+			// the number of trailing ones gives the region size
+			n := uint(0)
+			for n < abits && a&(1<<n) != 0 {
+				n++
+			}
+			e.Start = (a &^ ((1 << n) - 1)) << 2
+			e.End = e.Start + (1 << (n + 3))
+		}
+		entries[i] = e
+	}
+	return entries
+}
+
+//-----------------------------------------------------------------------------
+
+// PmpCheck checks a memory access against the PMP entries.
+// The access type is 'r', 'w' or 'x'. The privilege mode is 'm', 's' or 'u'.
+// It returns the access result and the matching entry (nil == no match).
+func PmpCheck(entries []*PmpEntry, addr uint, access, mode rune) (bool, *PmpEntry) {
+	// the lowest numbered matching entry determines the result
+	for _, e := range entries {
+		if !e.matches(addr) {
+			continue
+		}
+		// unlocked entries don't apply to machine mode
+		if mode == 'm' && !e.L {
+			return true, e
+		}
+		switch access {
+		case 'r':
+			return e.R, e
+		case 'w':
+			return e.W, e
+		case 'x':
+			return e.X, e
+		}
+		return false, e
+	}
+	// no match: machine mode succeeds, s/u mode fails if pmp is implemented
+	return mode == 'm' || len(entries) == 0, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,155 @@
+//-----------------------------------------------------------------------------
+/*
+
+PMP Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv
+
+import "testing"
+
+//-----------------------------------------------------------------------------
+
+func Test_PmpCfgReg(t *testing.T) {
+	tests := []struct {
+		i     int
+		xlen  uint
+		reg   uint
+		shift uint
+	}{
+		{0, 32, PMPCFG0, 0},
+		{3, 32, PMPCFG0, 24},
+		{4, 32, PMPCFG0 + 1, 0},
+		{63, 32, PMPCFG0 + 15, 24},
+		{0, 64, PMPCFG0, 0},
+		{7, 64, PMPCFG0, 56},
+		{8, 64, PMPCFG0 + 2, 0},
+		{63, 64, PMPCFG0 + 14, 56},
+	}
+	for _, v := range tests {
+		reg, shift := PmpCfgReg(v.i, v.xlen)
+		if reg != v.reg || shift != v.shift {
+			t.Errorf("rv%d entry %d: pmpcfg 0x%x shift %d, expected 0x%x shift %d", v.xlen, v.i, reg, shift, v.reg, v.shift)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+const (
+	cfgTor   = pmpTor << 3
+	cfgNa4   = pmpNa4 << 3
+	cfgNapot = pmpNapot << 3
+)
+
+func Test_PmpDecode(t *testing.T) {
+	tests := []struct {
+		xlen       uint
+		cfg        []uint8
+		addr       []uint
+		mode       string
+		start, end uint // decoded range of the last entry
+	}{
+		// TOR, entry 0 starts at 0
+		{32, []uint8{cfgTor}, []uint{0x1000 >> 2}, "TOR", 0, 0x1000},
+		// TOR, start from the previous pmpaddr
+		{32, []uint8{0, cfgTor}, []uint{0x1000 >> 2, 0x3000 >> 2}, "TOR", 0x1000, 0x3000},
+		// NA4
+		{32, []uint8{cfgNa4}, []uint{0x2004 >> 2}, "NA4", 0x2004, 0x2008},
+		// NAPOT 8 bytes (no trailing ones)
+		{32, []uint8{cfgNapot}, []uint{0x2000 >> 2}, "NAPOT", 0x2000, 0x2008},
+		// NAPOT 4KiB
+		{32, []uint8{cfgNapot}, []uint{(0x80000000 | 0x7ff) >> 2}, "NAPOT", 0x80000000, 0x80001000},
+		{32, []uint8{cfgNapot}, []uint{0x200001ff}, "NAPOT", 0x80000000, 0x80001000},
+		// NAPOT rv64 above 4GiB
+		{64, []uint8{cfgNapot}, []uint{(0x100000000 >> 2) | 0xffff}, "NAPOT", 0x100000000, 0x100080000},
+		// rv32 pmpaddr holds address bits 33:2
+		{32, []uint8{cfgNa4}, []uint{0x80000000}, "NA4", 0x200000000, 0x200000004},
+		// OFF
+		{32, []uint8{0}, []uint{0x1000}, "OFF", 0, 0},
+	}
+	for i, v := range tests {
+		entries := PmpDecode(v.cfg, v.addr, v.xlen)
+		if len(entries) != len(v.addr) {
+			t.Fatalf("test %d: %d entries, expected %d", i, len(entries), len(v.addr))
+		}
+		e := entries[len(entries)-1]
+		if e.Mode != v.mode || e.Start != v.start || e.End != v.end {
+			t.Errorf("test %d: %s 0x%x-0x%x, expected %s 0x%x-0x%x", i, e.Mode, e.Start, e.End, v.mode, v.start, v.end)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_PmpCheck(t *testing.T) {
+	// pmpcfg bytes as they are packed into the pmpcfg registers
+	cfg := []uint8{
+		cfgNa4 | pmpR,               // 0: 0x1000-0x1003 r
+		cfgNapot | pmpR | pmpW,      // 1: 0x1000-0x1fff rw
+		0,                           // 2: off, 0x4000 is the base of entry 3
+		cfgTor | pmpX,               // 3: 0x4000-0x7fff x
+		cfgNapot | pmpL,             // 4: 0x8000-0x8fff locked, no access
+		cfgTor | pmpL | pmpR | pmpW, // 5: 0x87fc-0x9fff locked rw
+	}
+	addr := []uint{
+		0x1000 >> 2,
+		(0x1000 | 0x7ff) >> 2,
+		0x4000 >> 2,
+		0x8000 >> 2,
+		(0x8000 | 0x7ff) >> 2,
+		0xa000 >> 2,
+	}
+	entries := PmpDecode(cfg, addr, 32)
+
+	tests := []struct {
+		addr         uint
+		access, mode rune
+		ok           bool
+		index        int // -1 == no match
+	}{
+		// the lowest numbered entry has priority
+		{0x1000, 'r', 'u', true, 0},
+		{0x1000, 'w', 'u', false, 0},
+		{0x1004, 'w', 'u', true, 1},
+		{0x1fff, 'r', 's', true, 1},
+		{0x1fff, 'x', 's', false, 1},
+		// TOR
+		{0x4000, 'x', 'u', true, 3},
+		{0x7fff, 'x', 'u', true, 3},
+		{0x7fff, 'r', 'u', false, 3},
+		{0x3fff, 'x', 'u', false, -1},
+		// unlocked entries don't apply to m-mode
+		{0x4000, 'r', 'm', true, 3},
+		// locked entries apply to m-mode
+		{0x8000, 'r', 'm', false, 4},
+		{0x9000, 'w', 'm', true, 5},
+		{0x9000, 'x', 'm', false, 5},
+		// entry 5 starts at pmpaddr4, entry 4 has priority for the overlap
+		{0x8ffc, 'w', 'm', false, 4},
+		// no match: m-mode succeeds, s/u-mode fails
+		{0x10000, 'r', 'm', true, -1},
+		{0x10000, 'r', 's', false, -1},
+		{0x10000, 'x', 'u', false, -1},
+	}
+	for _, v := range tests {
+		ok, e := PmpCheck(entries, v.addr, v.access, v.mode)
+		index := -1
+		if e != nil {
+			index = e.Index
+		}
+		if ok != v.ok || index != v.index {
+			t.Errorf("0x%x %c %c: %t by entry %d, expected %t by entry %d", v.addr, v.access, v.mode, ok, index, v.ok, v.index)
+		}
+	}
+
+	// no pmp entries: all accesses succeed
+	ok, e := PmpCheck(nil, 0x1000, 'w', 'u')
+	if !ok || e != nil {
+		t.Errorf("no pmp entries: %t %v, expected true <nil>", ok, e)
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,114 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Trap Causes
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv
+
+//-----------------------------------------------------------------------------
+
+// Exception codes (xcause with interrupt == 0).
+const (
+	ExInsMisaligned   = 0
+	ExInsAccessFault  = 1
+	ExIllegalIns      = 2
+	ExBreakpoint      = 3
+	ExLoadMisaligned  = 4
+	ExLoadAccessFault = 5
+	ExAmoMisaligned   = 6
+	ExAmoAccessFault  = 7
+	ExEcallU          = 8
+	ExEcallS          = 9
+	ExEcallVS         = 10
+	ExEcallM          = 11
+	ExInsPageFault    = 12
+	ExLoadPageFault   = 13
+	ExAmoPageFault    = 15
+)
+
+var exceptionName = map[uint]string{
+	ExInsMisaligned:   "instruction address misaligned",
+	ExInsAccessFault:  "instruction access fault",
+	ExIllegalIns:      "illegal instruction",
+	ExBreakpoint:      "breakpoint",
+	ExLoadMisaligned:  "load address misaligned",
+	ExLoadAccessFault: "load access fault",
+	ExAmoMisaligned:   "store/amo address misaligned",
+	ExAmoAccessFault:  "store/amo access fault",
+	ExEcallU:          "environment call from u-mode",
+	ExEcallS:          "environment call from s-mode",
+	ExEcallVS:         "environment call from vs-mode",
+	ExEcallM:          "environment call from m-mode",
+	ExInsPageFault:    "instruction page fault",
+	ExLoadPageFault:   "load page fault",
+	ExAmoPageFault:    "store/amo page fault",
+	18:                "software check",
+	19:                "hardware error",
+	20:                "instruction guest-page fault",
+	21:                "load guest-page fault",
+	22:                "virtual instruction",
+	23:                "store/amo guest-page fault",
+}
+
+var interruptName = map[uint]string{
+	0:  "user software interrupt",
+	1:  "supervisor software interrupt",
+	2:  "virtual supervisor software interrupt",
+	3:  "machine software interrupt",
+	4:  "user timer interrupt",
+	5:  "supervisor timer interrupt",
+	6:  "virtual supervisor timer interrupt",
+	7:  "machine timer interrupt",
+	8:  "user external interrupt",
+	9:  "supervisor external interrupt",
+	10: "virtual supervisor external interrupt",
+	11: "machine external interrupt",
+	12: "supervisor guest external interrupt",
+	13: "counter overflow interrupt",
+}
+
+// CauseName returns the name of a trap cause.
+func CauseName(interrupt bool, code uint) string {
+	names := exceptionName
+	if interrupt {
+		names = interruptName
+		if code >= 16 {
+			return "platform interrupt"
+		}
+	}
+	if s, ok := names[code]; ok {
+		return s
+	}
+	return "reserved"
+}
+
+// DecodeCause returns the interrupt bit and exception code of an xcause value.
+func DecodeCause(cause uint64, xlen uint) (bool, uint) {
+	interrupt := (cause>>(xlen-1))&1 != 0
+	code := uint(cause & ((1 << (xlen - 1)) - 1))
+	return interrupt, code
+}
+
+// TvalInfo returns the meaning of the xtval value for an exception.
+func TvalInfo(code uint) string {
+	switch code {
+	case ExInsMisaligned, ExInsAccessFault, ExLoadMisaligned, ExLoadAccessFault,
+		ExAmoMisaligned, ExAmoAccessFault, ExInsPageFault, ExLoadPageFault, ExAmoPageFault:
+		return "faulting address"
+	case ExIllegalIns:
+		return "instruction encoding"
+	case ExBreakpoint:
+		return "breakpoint address"
+	}
+	return ""
+}
+
+// PrivName returns the name of a privilege mode (E.g. mstatus.mpp).
+func PrivName(prv uint) string {
+	return [4]string{"u-mode", "s-mode", "reserved", "m-mode"}[prv&3]
+}
+
+//-----------------------------------------------------------------------------
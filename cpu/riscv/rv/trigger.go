@@ -0,0 +1,163 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Trigger Module
+
+Decode the tdata1 register for the trigger types of debug specification 0.13
+and 1.0 (mcontrol, icount, itrigger, etrigger, mcontrol6).
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv
+
+import (
+	"sort"
+
+	"github.com/deadsy/rvdbg/soc"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+// Trigger types (tdata1.type).
+const (
+	TriggerNone      = 0  // no trigger at this tselect
+	TriggerLegacy    = 1  // legacy address match trigger
+	TriggerMcontrol  = 2  // address/data match trigger
+	TriggerIcount    = 3  // instruction count trigger
+	TriggerItrigger  = 4  // interrupt trigger
+	TriggerEtrigger  = 5  // exception trigger
+	TriggerMcontrol6 = 6  // address/data match trigger (1.0)
+	TriggerTmexttrig = 7  // trace module external trigger
+	TriggerDisabled  = 15 // trigger exists but is disabled
+)
+
+var triggerName = map[uint]string{
+	TriggerNone:      "none",
+	TriggerLegacy:    "legacy",
+	TriggerMcontrol:  "mcontrol",
+	TriggerIcount:    "icount",
+	TriggerItrigger:  "itrigger",
+	TriggerEtrigger:  "etrigger",
+	TriggerMcontrol6: "mcontrol6",
+	TriggerTmexttrig: "tmexttrigger",
+	TriggerDisabled:  "disabled",
+}
+
+// TriggerName returns the name of a trigger type.
+func TriggerName(typ uint) string {
+	if s, ok := triggerName[typ]; ok {
+		return s
+	}
+	return "unknown"
+}
+
+// TriggerType returns the trigger type from a tdata1 value.
+func TriggerType(tdata1 uint, xlen uint) uint {
+	return util.Bits(tdata1, xlen-1, xlen-4)
+}
+
+//-----------------------------------------------------------------------------
+
+var triggerAction = soc.Enum{
+	0: "breakpoint",
+	1: "debug mode",
+	2: "trace on",
+	3: "trace off",
+	4: "trace notify",
+	8: "external0",
+	9: "external1",
+}
+
+var triggerMatch = soc.Enum{
+	0:  "equal",
+	1:  "napot",
+	2:  "ge",
+	3:  "lt",
+	4:  "mask low",
+	5:  "mask high",
+	8:  "not equal",
+	9:  "not napot",
+	12: "not mask low",
+	13: "not mask high",
+}
+
+// TriggerFields returns the tdata1 bit fields for a trigger type.
+func TriggerFields(typ uint, xlen uint) []soc.Field {
+	fs := []soc.Field{
+		{Name: "type", Msb: xlen - 1, Lsb: xlen - 4, Fmt: TriggerName},
+		{Name: "dmode", Msb: xlen - 5, Lsb: xlen - 5},
+	}
+	switch typ {
+	case TriggerMcontrol:
+		fs = append(fs, []soc.Field{
+			{Name: "maskmax", Msb: xlen - 6, Lsb: xlen - 11},
+			{Name: "hit", Msb: 20, Lsb: 20},
+			{Name: "select", Msb: 19, Lsb: 19},
+			{Name: "timing", Msb: 18, Lsb: 18},
+			{Name: "sizelo", Msb: 17, Lsb: 16},
+			{Name: "action", Msb: 15, Lsb: 12, Enums: triggerAction},
+			{Name: "chain", Msb: 11, Lsb: 11},
+			{Name: "match", Msb: 10, Lsb: 7, Enums: triggerMatch},
+			{Name: "m", Msb: 6, Lsb: 6},
+			{Name: "s", Msb: 4, Lsb: 4},
+			{Name: "u", Msb: 3, Lsb: 3},
+			{Name: "execute", Msb: 2, Lsb: 2},
+			{Name: "store", Msb: 1, Lsb: 1},
+			{Name: "load", Msb: 0, Lsb: 0},
+		}...)
+		if xlen == 64 {
+			fs = append(fs, soc.Field{Name: "sizehi", Msb: 22, Lsb: 21})
+		}
+	case TriggerMcontrol6:
+		fs = append(fs, []soc.Field{
+			{Name: "uncertain", Msb: 26, Lsb: 26},
+			{Name: "hit1", Msb: 25, Lsb: 25},
+			{Name: "vs", Msb: 24, Lsb: 24},
+			{Name: "vu", Msb: 23, Lsb: 23},
+			{Name: "hit0", Msb: 22, Lsb: 22},
+			{Name: "select", Msb: 21, Lsb: 21},
+			{Name: "size", Msb: 18, Lsb: 16},
+			{Name: "action", Msb: 15, Lsb: 12, Enums: triggerAction},
+			{Name: "chain", Msb: 11, Lsb: 11},
+			{Name: "match", Msb: 10, Lsb: 7, Enums: triggerMatch},
+			{Name: "m", Msb: 6, Lsb: 6},
+			{Name: "uncertainen", Msb: 5, Lsb: 5},
+			{Name: "s", Msb: 4, Lsb: 4},
+			{Name: "u", Msb: 3, Lsb: 3},
+			{Name: "execute", Msb: 2, Lsb: 2},
+			{Name: "store", Msb: 1, Lsb: 1},
+			{Name: "load", Msb: 0, Lsb: 0},
+		}...)
+	case TriggerIcount:
+		fs = append(fs, []soc.Field{
+			{Name: "vs", Msb: 26, Lsb: 26},
+			{Name: "vu", Msb: 25, Lsb: 25},
+			{Name: "hit", Msb: 24, Lsb: 24},
+			{Name: "count", Msb: 23, Lsb: 10},
+			{Name: "m", Msb: 9, Lsb: 9},
+			{Name: "pending", Msb: 8, Lsb: 8},
+			{Name: "s", Msb: 7, Lsb: 7},
+			{Name: "u", Msb: 6, Lsb: 6},
+			{Name: "action", Msb: 5, Lsb: 0, Enums: triggerAction},
+		}...)
+	case TriggerItrigger, TriggerEtrigger:
+		fs = append(fs, []soc.Field{
+			{Name: "hit", Msb: xlen - 6, Lsb: xlen - 6},
+			{Name: "vs", Msb: 12, Lsb: 12},
+			{Name: "vu", Msb: 11, Lsb: 11},
+			{Name: "m", Msb: 9, Lsb: 9},
+			{Name: "s", Msb: 7, Lsb: 7},
+			{Name: "u", Msb: 6, Lsb: 6},
+			{Name: "action", Msb: 5, Lsb: 0, Enums: triggerAction},
+		}...)
+		if typ == TriggerItrigger {
+			fs = append(fs, soc.Field{Name: "nmi", Msb: 10, Lsb: 10})
+		}
+	}
+	sort.Sort(soc.FieldSet(fs))
+	return fs
+}
+
+//-----------------------------------------------------------------------------
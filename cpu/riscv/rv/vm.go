@@ -0,0 +1,334 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Virtual Memory
+
+Memory access modes and a debugger side page table walker for the
+Sv32/Sv39/Sv48/Sv57 translation schemes. The walker uses physical memory
+reads, so virtual memory can be accessed without changing the hart state.
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv
+
+import (
+	"errors"
+	"fmt"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+// MemMode is the memory access mode for the debugger.
+type MemMode int
+
+// MemMode values.
+const (
+	MemPhysical   MemMode = iota // physical address, machine mode
+	MemSupervisor                // supervisor mode (mstatus.mprv)
+	MemUser                      // user mode (mstatus.mprv)
+	MemVirtual                   // virtual address, translated by the debugger using satp
+)
+
+var memModeName = [4]string{"m", "s", "u", "virt"}
+
+var memModeDescr = [4]string{
+	"physical address, machine mode",
+	"supervisor mode (mstatus.mprv)",
+	"user mode (mstatus.mprv)",
+	"virtual address, translated by the debugger using satp",
+}
+
+func (m MemMode) String() string {
+	return memModeName[m]
+}
+
+// Descr returns the description of the memory access mode.
+func (m MemMode) Descr() string {
+	return memModeDescr[m]
+}
+
+// MemModes returns all memory access modes.
+func MemModes() []MemMode {
+	return []MemMode{MemPhysical, MemSupervisor, MemUser, MemVirtual}
+}
+
+// ParseMemMode returns the memory access mode for a name.
+func ParseMemMode(s string) (MemMode, error) {
+	for i, name := range memModeName {
+		if s == name {
+			return MemMode(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown memory mode \"%s\"", s)
+}
+
+//-----------------------------------------------------------------------------
+
+// RdMemFunc reads n x width-bit values from physical memory.
+type RdMemFunc func(width, addr, n uint) ([]uint, error)
+
+// WrMemFunc writes width-bit values to physical memory.
+type WrMemFunc func(width, addr uint, val []uint) error
+
+const pageShift = 12
+const pageSize = 1 << pageShift
+
+// vmScheme is a virtual memory translation scheme.
+type vmScheme struct {
+	name    string
+	levels  int  // page table levels
+	pteSize uint // bytes per page table entry
+	vpnBits uint // bits per virtual page number
+	ppnBits uint // bits in the physical page number of a pte
+	vaBits  uint // bits in a virtual address
+}
+
+var sv32 = &vmScheme{"sv32", 2, 4, 10, 22, 32}
+var sv39 = &vmScheme{"sv39", 3, 8, 9, 44, 39}
+var sv48 = &vmScheme{"sv48", 4, 8, 9, 44, 48}
+var sv57 = &vmScheme{"sv57", 5, 8, 9, 44, 57}
+
+// satpScheme returns the translation scheme and root page table address for a satp value.
+// A nil scheme is bare (no translation).
+func satpScheme(satp uint64, xlen uint) (*vmScheme, uint, error) {
+	if xlen == 32 {
+		ppn := util.Bits(uint(satp), 21, 0)
+		if util.Bit(uint(satp), 31) == 0 {
+			return nil, 0, nil
+		}
+		return sv32, ppn << pageShift, nil
+	}
+	ppn := uint(satp & ((1 << 44) - 1))
+	switch satp >> 60 {
+	case 0:
+		return nil, 0, nil
+	case 8:
+		return sv39, ppn << pageShift, nil
+	case 9:
+		return sv48, ppn << pageShift, nil
+	case 10:
+		return sv57, ppn << pageShift, nil
+	}
+	return nil, 0, fmt.Errorf("unknown satp.mode %d", satp>>60)
+}
+
+//-----------------------------------------------------------------------------
+
+// PTE flags.
+const (
+	pteV = 1 << 0 // valid
+	pteR = 1 << 1 // readable
+	pteW = 1 << 2 // writeable
+	pteX = 1 << 3 // executable
+	pteU = 1 << 4 // user mode accessible
+	pteA = 1 << 6 // accessed
+	pteD = 1 << 7 // dirty
+)
+
+// mstatus/sstatus bits for page access checks.
+const (
+	statusSUM = 1 << 18 // permit supervisor user memory access
+	statusMXR = 1 << 19 // make executable readable
+)
+
+// pteFlags returns a string for the flags of a page table entry.
+func pteFlags(pte uint) string {
+	s := []rune{}
+	for i, c := range "DAGUXWRV" {
+		if pte&(1<<uint(7-i)) != 0 {
+			s = append(s, c)
+		} else {
+			s = append(s, '-')
+		}
+	}
+	return string(s)
+}
+
+// PageEntry is a page table entry read during a page table walk.
+type PageEntry struct {
+	Level int    `json:"level"`
+	Addr  uint   `json:"addr"`
+	Value uint   `json:"value"`
+	Flags string `json:"flags"`
+}
+
+// PageWalk is the result of a page table walk.
+type PageWalk struct {
+	Mode     string      `json:"mode"`
+	VAddr    uint        `json:"vaddr"`
+	PAddr    uint        `json:"paddr"`
+	PageSize uint        `json:"pagesize"`
+	PTE      []PageEntry `json:"pte"`
+}
+
+func (pw *PageWalk) String() string {
+	s := [][]string{}
+	for _, e := range pw.PTE {
+		s = append(s, []string{
+			fmt.Sprintf("level %d", e.Level),
+			fmt.Sprintf("pte 0x%x", e.Addr),
+			fmt.Sprintf("= 0x%x", e.Value),
+			e.Flags,
+		})
+	}
+	if pw.PageSize != 0 {
+		s = append(s, []string{pw.Mode, fmt.Sprintf("0x%x", pw.VAddr), fmt.Sprintf("-> 0x%x", pw.PAddr), fmt.Sprintf("%s page", util.MemSize(pw.PageSize))})
+	}
+	return cli.TableString(s, []int{0, 0, 0, 0}, 1)
+}
+
+// Translate walks the page tables to translate a virtual address to a physical address.
+// The page walk is returned with a translation error, so the failing entry can be shown.
+func Translate(satp uint64, xlen, vaddr uint, rd RdMemFunc) (*PageWalk, error) {
+	vm, a, err := satpScheme(satp, xlen)
+	if err != nil {
+		return nil, err
+	}
+	if vm == nil {
+		return &PageWalk{Mode: "bare", VAddr: vaddr, PAddr: vaddr, PageSize: pageSize}, nil
+	}
+	pw := &PageWalk{Mode: vm.name, VAddr: vaddr}
+	// rv64: the upper address bits must match the msb of the virtual address
+	if xlen == 64 {
+		hi := int64(vaddr) >> (vm.vaBits - 1)
+		if hi != 0 && hi != -1 {
+			return pw, fmt.Errorf("0x%x is not a canonical %s address", vaddr, vm.name)
+		}
+	}
+	for i := vm.levels - 1; i >= 0; i-- {
+		shift := pageShift + uint(i)*vm.vpnBits
+		vpn := (vaddr >> shift) & ((1 << vm.vpnBits) - 1)
+		pteAddr := a + vpn*vm.pteSize
+		x, err := rd(vm.pteSize*8, pteAddr, 1)
+		if err != nil {
+			return pw, err
+		}
+		pte := x[0]
+		pw.PTE = append(pw.PTE, PageEntry{i, pteAddr, pte, pteFlags(pte)})
+		if pte&pteV == 0 || (pte&pteR == 0 && pte&pteW != 0) {
+			return pw, fmt.Errorf("invalid pte at level %d", i)
+		}
+		ppn := (pte >> 10) & ((1 << vm.ppnBits) - 1)
+		if pte&(pteR|pteX) == 0 {
+			// pointer to the next level
+			a = ppn << pageShift
+			continue
+		}
+		// leaf pte, superpages must be aligned
+		size := uint(1) << shift
+		if (ppn<<pageShift)&(size-1) != 0 {
+			return pw, fmt.Errorf("misaligned superpage at level %d", i)
+		}
+		pw.PageSize = size
+		pw.PAddr = (ppn << pageShift) | (vaddr & (size - 1))
+		return pw, nil
+	}
+	return pw, errors.New("no leaf pte")
+}
+
+// CheckAccess checks an access against the leaf pte of a page walk.
+// The access type is 'r', 'w' or 'x'. The privilege mode is 's' or 'u'.
+// The status value (mstatus/sstatus) provides the SUM and MXR bits.
+// A clear A/D bit is a page fault, as it is without hardware A/D updates.
+func (pw *PageWalk) CheckAccess(access, mode rune, status uint64) error {
+	if pw.Mode == "bare" {
+		return nil
+	}
+	if pw.PageSize == 0 || len(pw.PTE) == 0 {
+		return errors.New("no leaf pte")
+	}
+	pte := pw.PTE[len(pw.PTE)-1].Value
+	if pte&pteU != 0 {
+		if mode == 's' && access == 'x' {
+			return errors.New("supervisor execute from a user page")
+		}
+		if mode == 's' && status&statusSUM == 0 {
+			return errors.New("supervisor access to a user page (sum is clear)")
+		}
+	} else if mode == 'u' {
+		return errors.New("user access to a supervisor page")
+	}
+	switch access {
+	case 'r':
+		if pte&pteR == 0 && (pte&pteX == 0 || status&statusMXR == 0) {
+			return errors.New("page is not readable")
+		}
+	case 'w':
+		if pte&pteW == 0 {
+			return errors.New("page is not writeable")
+		}
+	case 'x':
+		if pte&pteX == 0 {
+			return errors.New("page is not executable")
+		}
+	}
+	if pte&pteA == 0 {
+		return errors.New("pte.a is clear")
+	}
+	if access == 'w' && pte&pteD == 0 {
+		return errors.New("pte.d is clear")
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+
+// VirtRdMem reads n x width-bit values from virtual memory.
+func VirtRdMem(satp uint64, xlen, width, addr, n uint, rd RdMemFunc) ([]uint, error) {
+	buf := make([]uint, 0, n)
+	bytes := width >> 3
+	for n > 0 {
+		pw, err := Translate(satp, xlen, addr, rd)
+		if err != nil {
+			return nil, fmt.Errorf("unable to translate 0x%x: %v", addr, err)
+		}
+		// read to the end of the page
+		k := (pageSize - (addr & (pageSize - 1))) / bytes
+		if k == 0 {
+			k = 1
+		}
+		if k > n {
+			k = n
+		}
+		x, err := rd(width, pw.PAddr, k)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, x...)
+		addr += k * bytes
+		n -= k
+	}
+	return buf, nil
+}
+
+// VirtWrMem writes width-bit values to virtual memory.
+func VirtWrMem(satp uint64, xlen, width, addr uint, val []uint, rd RdMemFunc, wr WrMemFunc) error {
+	bytes := width >> 3
+	for len(val) > 0 {
+		pw, err := Translate(satp, xlen, addr, rd)
+		if err != nil {
+			return fmt.Errorf("unable to translate 0x%x: %v", addr, err)
+		}
+		// write to the end of the page
+		k := (pageSize - (addr & (pageSize - 1))) / bytes
+		if k == 0 {
+			k = 1
+		}
+		if k > uint(len(val)) {
+			k = uint(len(val))
+		}
+		err = wr(width, pw.PAddr, val[:k])
+		if err != nil {
+			return err
+		}
+		addr += k * bytes
+		val = val[k:]
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
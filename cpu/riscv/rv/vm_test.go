@@ -0,0 +1,210 @@
+//-----------------------------------------------------------------------------
+/*
+
+Virtual Memory Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv
+
+import (
+	"fmt"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+
+// testMem is a physical memory of width-bit values (keyed by address).
+type testMem map[uint]uint
+
+func (m testMem) rd(width, addr, n uint) ([]uint, error) {
+	if addr&((width>>3)-1) != 0 {
+		return nil, fmt.Errorf("misaligned read at 0x%x", addr)
+	}
+	x := make([]uint, n)
+	for i := range x {
+		x[i] = m[addr+uint(i)*(width>>3)]
+	}
+	return x, nil
+}
+
+func (m testMem) wr(width, addr uint, val []uint) error {
+	for i := range val {
+		m[addr+uint(i)*(width>>3)] = val[i]
+	}
+	return nil
+}
+
+// pte returns a page table entry for a physical address.
+func pte(paddr, flags uint) uint {
+	return (paddr>>pageShift)<<10 | flags
+}
+
+const pteLeaf = pteV | pteR | pteW | pteX | pteA | pteD
+
+//-----------------------------------------------------------------------------
+
+// sv32Mem returns the page tables for the Sv32 tests (root at 0x10000).
+func sv32Mem() testMem {
+	return testMem{
+		// 0x00400000: 4KiB pages
+		0x10004: pte(0x11000, pteV),
+		0x11004: pte(0x80000000, pteLeaf),
+		0x11008: pte(0x90000000, pteLeaf),
+		// 0x00800000: 4MiB superpage
+		0x10008: pte(0x80400000, pteLeaf),
+		// 0x00c00000: misaligned superpage
+		0x1000c: pte(0x80401000, pteLeaf),
+		// 0x01000000: invalid
+		0x10010: pte(0x80800000, pteLeaf&^pteV),
+		// 0x01400000: write without read
+		0x10014: pte(0x80800000, pteV|pteW),
+		// 0x01800000: pointer at level 0
+		0x10018: pte(0x12000, pteV),
+		0x12000: pte(0x13000, pteV),
+	}
+}
+
+// sv39Mem returns the page tables for the Sv39 tests (root at 0x20000).
+func sv39Mem() testMem {
+	return testMem{
+		// 0x40000000: 1GiB superpage
+		0x20008: pte(0x80000000, pteLeaf),
+		// 0x80000000: 2MiB superpages
+		0x20010: pte(0x21000, pteV),
+		0x21018: pte(0x80200000, pteLeaf),
+		0x21020: pte(0x80201000, pteLeaf),
+		// 0xffffffc000000000: 1GiB superpage at the top of the address space
+		0x20800: pte(0xc0000000, pteLeaf),
+	}
+}
+
+const satpSv32 = 1<<31 | 0x10
+const satpSv39 = 8<<60 | 0x20
+
+func Test_Translate(t *testing.T) {
+	tests := []struct {
+		satp     uint64
+		xlen     uint
+		vaddr    uint
+		paddr    uint
+		pageSize uint
+		err      bool
+	}{
+		// bare
+		{0, 32, 0x1234, 0x1234, pageSize, false},
+		{0, 64, 0x1234, 0x1234, pageSize, false},
+		// sv32
+		{satpSv32, 32, 0x00401234, 0x80000234, 4 << 10, false},
+		{satpSv32, 32, 0x00402ffc, 0x90000ffc, 4 << 10, false},
+		{satpSv32, 32, 0x00912345, 0x80512345, 4 << 20, false},
+		{satpSv32, 32, 0x00c00000, 0, 0, true},
+		{satpSv32, 32, 0x01000000, 0, 0, true},
+		{satpSv32, 32, 0x01400000, 0, 0, true},
+		{satpSv32, 32, 0x01800000, 0, 0, true},
+		{satpSv32, 32, 0x00403000, 0, 0, true},
+		// sv39
+		{satpSv39, 64, 0x40000123, 0x80000123, 1 << 30, false},
+		{satpSv39, 64, 0x80612345, 0x80212345, 2 << 20, false},
+		{satpSv39, 64, 0x80800000, 0, 0, true},
+		{satpSv39, 64, 0xffffffc000000010, 0xc0000010, 1 << 30, false},
+		// not canonical
+		{satpSv39, 64, 1 << 40, 0, 0, true},
+		// unknown satp.mode
+		{3 << 60, 64, 0, 0, 0, true},
+	}
+	for _, v := range tests {
+		m := sv32Mem()
+		if v.xlen == 64 {
+			m = sv39Mem()
+		}
+		pw, err := Translate(v.satp, v.xlen, v.vaddr, m.rd)
+		if v.err {
+			if err == nil {
+				t.Errorf("0x%x: expected an error, got 0x%x", v.vaddr, pw.PAddr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("0x%x: unexpected error %v", v.vaddr, err)
+			continue
+		}
+		if pw.PAddr != v.paddr || pw.PageSize != v.pageSize {
+			t.Errorf("0x%x: 0x%x (page size 0x%x), expected 0x%x (page size 0x%x)", v.vaddr, pw.PAddr, pw.PageSize, v.paddr, v.pageSize)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_CheckAccess(t *testing.T) {
+	tests := []struct {
+		pte          uint
+		access, mode rune
+		status       uint64
+		ok           bool
+	}{
+		{pteV | pteR | pteW | pteA | pteD, 'w', 's', 0, true},
+		{pteV | pteR | pteA, 'w', 's', 0, false},
+		{pteV | pteR | pteA, 'x', 's', 0, false},
+		// A/D
+		{pteV | pteR | pteW | pteA, 'r', 's', 0, true},
+		{pteV | pteR | pteW | pteA, 'w', 's', 0, false},
+		{pteV | pteR | pteW | pteD, 'r', 's', 0, false},
+		// U/SUM
+		{pteV | pteR | pteU | pteA, 'r', 'u', 0, true},
+		{pteV | pteR | pteA, 'r', 'u', 0, false},
+		{pteV | pteR | pteU | pteA, 'r', 's', 0, false},
+		{pteV | pteR | pteU | pteA, 'r', 's', statusSUM, true},
+		{pteV | pteX | pteU | pteA, 'x', 's', statusSUM, false},
+		{pteV | pteX | pteU | pteA, 'x', 'u', 0, true},
+		// MXR
+		{pteV | pteX | pteA, 'r', 's', 0, false},
+		{pteV | pteX | pteA, 'r', 's', statusMXR, true},
+		{pteV | pteX | pteU | pteA, 'r', 'u', statusMXR, true},
+	}
+	for _, v := range tests {
+		pw := &PageWalk{Mode: "sv39", PageSize: pageSize, PTE: []PageEntry{{Value: v.pte, Flags: pteFlags(v.pte)}}}
+		err := pw.CheckAccess(v.access, v.mode, v.status)
+		if (err == nil) != v.ok {
+			t.Errorf("%s %c-mode %c status 0x%x: %v, expected ok %t", pteFlags(v.pte), v.mode, v.access, v.status, err, v.ok)
+		}
+	}
+	// bare mode has no checks
+	pw := &PageWalk{Mode: "bare", PageSize: pageSize}
+	if err := pw.CheckAccess('w', 'u', 0); err != nil {
+		t.Errorf("bare: %v", err)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_VirtMem(t *testing.T) {
+	m := sv32Mem()
+	m[0x80000ffc] = 0x11111111
+	m[0x90000000] = 0x22222222
+	// read across a page boundary
+	x, err := VirtRdMem(satpSv32, 32, 32, 0x00401ffc, 2, m.rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x[0] != 0x11111111 || x[1] != 0x22222222 {
+		t.Errorf("read 0x%x 0x%x", x[0], x[1])
+	}
+	// write across a page boundary
+	err = VirtWrMem(satpSv32, 32, 32, 0x00401ffc, []uint{0x33333333, 0x44444444}, m.rd, m.wr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m[0x80000ffc] != 0x33333333 || m[0x90000000] != 0x44444444 {
+		t.Errorf("wrote 0x%x 0x%x", m[0x80000ffc], m[0x90000000])
+	}
+	// read into an unmapped page
+	_, err = VirtRdMem(satpSv32, 32, 32, 0x00402ffc, 2, m.rd)
+	if err == nil {
+		t.Error("expected an error reading an unmapped page")
+	}
+}
+
+//-----------------------------------------------------------------------------
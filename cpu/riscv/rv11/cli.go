@@ -15,6 +15,7 @@ import (
 
 	cli "github.com/deadsy/go-cli"
 	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/util"
 )
 
 //-----------------------------------------------------------------------------
@@ -40,7 +41,7 @@ var cmdDbus = cli.Leaf{
 		dbg := c.User.(target).GetRiscvDebug().(*Debug)
 		dump, err := dbg.dbusDump()
 		if err != nil {
-			c.User.Put(fmt.Sprintf("unable to get dbus registers: %v\n", err))
+			util.PutError(c.User, fmt.Errorf("unable to get dbus registers: %v", err))
 		}
 		c.User.Put(fmt.Sprintf("%s\n", dump))
 	},
@@ -0,0 +1,63 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Debugger 0.11
+
+Hart Group Functions
+
+The 0.11 debug module has no hart array mask, so the harts in the hart
+group are halted and resumed in turn.
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv11
+
+import (
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+// SetHartGroup sets the harts that are halted/resumed together.
+func (dbg *Debug) SetHartGroup(ids []int) error {
+	return dbg.group.Set(ids, len(dbg.hart))
+}
+
+// GetHartGroup returns the harts that are halted/resumed together.
+func (dbg *Debug) GetHartGroup() []int {
+	return dbg.group.Get(len(dbg.hart))
+}
+
+// HaltAll halts all harts in the hart group.
+func (dbg *Debug) HaltAll() error {
+	return rv.GroupSequential(dbg, dbg.HaltHart)
+}
+
+// ResumeAll resumes all harts in the hart group.
+func (dbg *Debug) ResumeAll() error {
+	return rv.GroupSequential(dbg, dbg.ResumeHart)
+}
+
+// HartStates returns the current state of all harts.
+// Each hart is selected in turn to read its halt notification.
+func (dbg *Debug) HartStates() ([]rv.HartState, error) {
+	state := make([]rv.HartState, len(dbg.hart))
+	defer dbg.selectHart(dbg.hartid)
+	for i := range dbg.hart {
+		err := dbg.selectHart(i)
+		if err != nil {
+			return nil, err
+		}
+		halted, err := dbg.isHalted()
+		if err != nil {
+			return nil, err
+		}
+		state[i] = []rv.HartState{rv.Running, rv.Halted}[util.BoolToInt(halted)]
+		dbg.hart[i].info.State = state[i]
+	}
+	return state, nil
+}
+
+//-----------------------------------------------------------------------------
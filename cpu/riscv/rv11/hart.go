@@ -25,8 +25,13 @@ import (
 // halt a hart
 
 // isHalted returns true if the currently selected hart is halted.
+// The halt notification (dmcontrol.haltnot) is set by the hart in debug mode.
 func (dbg *Debug) isHalted() (bool, error) {
-	return false, errors.New("TODO")
+	x, err := dbg.rdDbus(dmcontrol)
+	if err != nil {
+		return false, err
+	}
+	return x&haltNotification != 0, nil
 }
 
 // halt the current hart, return true if it was already halted.
@@ -45,7 +50,8 @@ func (dbg *Debug) halt() (bool, error) {
 
 // isRunning returns true if the currently selected hart is running.
 func (dbg *Debug) isRunning() (bool, error) {
-	return false, errors.New("TODO")
+	halted, err := dbg.isHalted()
+	return !halted, err
 }
 
 // resume the current hart, return true if it was already running.
@@ -421,6 +427,7 @@ func (dbg *Debug) newHart(id int) *hartInfo {
 		dbg: dbg,
 	}
 	hi.info.ID = id
+	hi.info.Version = rv.DebugVersion011
 	hi.info.Nregs = 32
 	return hi
 }
@@ -405,6 +405,13 @@ func (dbg *Debug) RdMem(width, addr, n uint) ([]uint, error) {
 	if width == 64 && hi.info.MXLEN < 64 {
 		return nil, fmt.Errorf("%d-bit memory reads are not supported", width)
 	}
+	if dbg.memMode == rv.MemVirtual {
+		satp, err := dbg.RdCSR(rv.SATP, hi.info.MXLEN)
+		if err != nil {
+			return nil, err
+		}
+		return rv.VirtRdMem(satp, hi.info.MXLEN, width, addr, n, dbg.rdPhys)
+	}
 	return hi.rdMem(dbg, width, addr, n)
 }
 
@@ -417,7 +424,49 @@ func (dbg *Debug) WrMem(width, addr uint, val []uint) error {
 	if width == 64 && hi.info.MXLEN < 64 {
 		return fmt.Errorf("%d-bit memory writes are not supported", width)
 	}
+	if dbg.memMode == rv.MemVirtual {
+		satp, err := dbg.RdCSR(rv.SATP, hi.info.MXLEN)
+		if err != nil {
+			return err
+		}
+		return rv.VirtWrMem(satp, hi.info.MXLEN, width, addr, val, dbg.rdPhys, dbg.wrPhys)
+	}
 	return hi.wrMem(dbg, width, addr, val)
 }
 
+// rdPhys reads n x width-bit values from physical memory.
+func (dbg *Debug) rdPhys(width, addr, n uint) ([]uint, error) {
+	return dbg.hart[dbg.hartid].rdMem(dbg, width, addr, n)
+}
+
+// wrPhys writes width-bit values to physical memory.
+func (dbg *Debug) wrPhys(width, addr uint, val []uint) error {
+	return dbg.hart[dbg.hartid].wrMem(dbg, width, addr, val)
+}
+
+// SetMemoryMode sets the memory access mode.
+// Supervisor/user mode access (mstatus.mprv) is not supported for 0.11.
+func (dbg *Debug) SetMemoryMode(mode rv.MemMode) error {
+	if mode == rv.MemSupervisor || mode == rv.MemUser {
+		return fmt.Errorf("memory mode \"%s\" is not supported for 0.11", mode)
+	}
+	dbg.memMode = mode
+	return nil
+}
+
+// GetMemoryMode returns the memory access mode.
+func (dbg *Debug) GetMemoryMode() rv.MemMode {
+	return dbg.memMode
+}
+
+// Translate translates a virtual address using the current satp value.
+func (dbg *Debug) Translate(vaddr uint) (*rv.PageWalk, error) {
+	hi := dbg.hart[dbg.hartid]
+	satp, err := dbg.RdCSR(rv.SATP, hi.info.MXLEN)
+	if err != nil {
+		return nil, err
+	}
+	return rv.Translate(satp, hi.info.MXLEN, vaddr, dbg.rdPhys)
+}
+
 //-----------------------------------------------------------------------------
@@ -9,6 +9,7 @@ RISC-V Debugger 0.11 Register Operations
 package rv11
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/deadsy/rvdbg/cpu/riscv/rv"
@@ -95,4 +96,16 @@ func (dbg *Debug) WrFPR(reg, size uint, val uint64) error {
 	return hi.wrFPR(dbg, reg, size, val)
 }
 
+// vector registers
+
+// RdVPR reads a vector register.
+func (dbg *Debug) RdVPR(reg uint) ([]byte, error) {
+	return nil, errors.New("vector registers are not supported by the 0.11 debugger")
+}
+
+// WrVPR writes a vector register.
+func (dbg *Debug) WrVPR(reg uint, val []byte) error {
+	return errors.New("vector registers are not supported by the 0.11 debugger")
+}
+
 //-----------------------------------------------------------------------------
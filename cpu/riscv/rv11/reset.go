@@ -0,0 +1,65 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Debugger 0.11
+
+Reset Functions
+
+Resets are requested with the dcsr.ndreset and dcsr.fullreset bits, so the
+halt on reset bit (dcsr.halt) can be set with the same write.
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv11
+
+import (
+	"errors"
+
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+const dcsrNdreset = (1 << 29)
+const dcsrFullreset = (1 << 28)
+const dcsrHalt = (1 << 3)
+
+// reset writes dcsr with a reset bit and the halt-on-reset state.
+func (dbg *Debug) reset(bit uint64, halt bool) error {
+	dcsr, err := dbg.RdCSR(rv.DCSR, 0)
+	if err != nil {
+		return err
+	}
+	dcsr &= ^uint64(dcsrHalt | dcsrNdreset | dcsrFullreset)
+	if halt {
+		dcsr |= dcsrHalt
+	}
+	// the hart is reset by the write, so a write error is expected
+	dbg.WrCSR(rv.DCSR, 0, dcsr|bit)
+	// resync the debug ram cache
+	err = dbg.cache.reset()
+	if err != nil {
+		return err
+	}
+	dbg.hart[dbg.hartid].info.State = []rv.HartState{rv.Running, rv.Halted}[util.BoolToInt(halt)]
+	return nil
+}
+
+// ResetSystem resets the system (dcsr.ndreset) and optionally halts the current hart.
+func (dbg *Debug) ResetSystem(halt bool) error {
+	return dbg.reset(dcsrNdreset, halt)
+}
+
+// ResetHart resets the current hart (dcsr.fullreset) and optionally halts it.
+func (dbg *Debug) ResetHart(halt bool) error {
+	return dbg.reset(dcsrFullreset, halt)
+}
+
+// ResetDebug resets the debug module.
+func (dbg *Debug) ResetDebug() error {
+	return errors.New("debug module reset is not supported for 0.11")
+}
+
+//-----------------------------------------------------------------------------
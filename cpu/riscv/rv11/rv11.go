@@ -34,17 +34,19 @@ const irDbus = 0x11 // debug bus access
 // Debug is a RISC-V 0.11 debugger. It implements the rv.Debug interface.
 type Debug struct {
 	dev          *jtag.Device
-	dbusDevice   *soc.Device // dbus device for decode/display
-	cache        *ramCache   // cache of debug ram words
-	hart         []*hartInfo // implemented harts
-	hartid       int         // currently selected hart
-	ir           uint        // cache of ir value
-	irlen        int         // IR length
-	drDbusLength int         // DR length for dbus
-	abits        uint        // address bits in dtmcontrol
-	idle         uint        // idle value in dtmcontrol
-	dramsize     uint        // number of debug ram words implemented
-	dbusops      uint        // running count of total dbus operations
+	dbusDevice   *soc.Device  // dbus device for decode/display
+	cache        *ramCache    // cache of debug ram words
+	hart         []*hartInfo  // implemented harts
+	hartid       int          // currently selected hart
+	ir           uint         // cache of ir value
+	irlen        int          // IR length
+	drDbusLength int          // DR length for dbus
+	abits        uint         // address bits in dtmcontrol
+	idle         uint         // idle value in dtmcontrol
+	dramsize     uint         // number of debug ram words implemented
+	dbusops      uint         // running count of total dbus operations
+	group        rv.HartGroup // hart group for halt/resume all
+	memMode      rv.MemMode   // memory access mode
 }
 
 func (dbg *Debug) String() string {
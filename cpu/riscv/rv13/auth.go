@@ -0,0 +1,102 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Debugger 0.13
+
+Debug Module Authentication
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv13
+
+import (
+	"errors"
+	"time"
+
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/util/log"
+)
+
+//-----------------------------------------------------------------------------
+
+const authenticated = (1 << 7)
+const authbusy = (1 << 6)
+
+const authTimeout = 100 * time.Millisecond
+
+// authData provides authdata register access for an authenticator.
+type authData struct {
+	dbg *Debug
+}
+
+// authWait waits for the debug module to be ready for an authdata access.
+func (ad *authData) authWait() error {
+	t := time.Now().Add(authTimeout)
+	for t.After(time.Now()) {
+		busy, err := ad.dbg.checkStatus(authbusy)
+		if err != nil {
+			return err
+		}
+		if !busy {
+			return nil
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+	return errors.New("authbusy timeout")
+}
+
+// RdAuthData reads the authdata register.
+func (ad *authData) RdAuthData() (uint32, error) {
+	err := ad.authWait()
+	if err != nil {
+		return 0, err
+	}
+	return ad.dbg.rdDmi(authdata)
+}
+
+// WrAuthData writes the authdata register.
+func (ad *authData) WrAuthData(x uint32) error {
+	err := ad.authWait()
+	if err != nil {
+		return err
+	}
+	return ad.dbg.wrDmi(authdata, x)
+}
+
+//-----------------------------------------------------------------------------
+
+// authenticate authenticates the debugger with the debug module (if needed).
+func (dbg *Debug) authenticate(auth rv.Authenticator) error {
+	ok, err := dbg.checkStatus(authenticated)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+	if auth == nil {
+		return errors.New("debugger is not authenticated (no authenticator for target)")
+	}
+	log.Info.Printf("authenticating debugger")
+	ad := &authData{dbg}
+	err = auth.Authenticate(ad)
+	if err != nil {
+		return err
+	}
+	// the debug module may be busy checking the response
+	err = ad.authWait()
+	if err != nil {
+		return err
+	}
+	ok, err = dbg.checkStatus(authenticated)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.New("debugger authentication failed")
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
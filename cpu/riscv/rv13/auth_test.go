@@ -0,0 +1,76 @@
+//-----------------------------------------------------------------------------
+/*
+
+Authentication Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv13
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/cpu/riscv/sim"
+)
+
+//-----------------------------------------------------------------------------
+
+const authKey = 0x5a5aa5a5
+
+// xorResponse returns the response for a challenge.
+func xorResponse(challenge []uint32) ([]uint32, error) {
+	response := make([]uint32, len(challenge))
+	for i := range challenge {
+		response[i] = challenge[i] ^ authKey
+	}
+	return response, nil
+}
+
+func Test_Authenticate(t *testing.T) {
+	challenge := []uint32{0x01234567, 0x89abcdef}
+	tests := []struct {
+		challenge []uint32
+		response  []uint32
+		auth      rv.Authenticator
+		err       string
+	}{
+		// challenge-response, the debugger waits for authbusy between the words
+		{challenge, []uint32{0x01234567 ^ authKey, 0x89abcdef ^ authKey}, rv.NewChallengeResponse(2, xorResponse), ""},
+		{challenge, []uint32{0x01234567, 0x89abcdef}, rv.NewChallengeResponse(2, xorResponse), "debugger authentication failed"},
+		// fixed key
+		{nil, []uint32{authKey}, rv.NewKeyAuth([]uint32{authKey}), ""},
+		{nil, []uint32{authKey}, rv.NewKeyAuth([]uint32{0}), "debugger authentication failed"},
+		{nil, []uint32{authKey}, nil, "debugger is not authenticated"},
+		// no authentication
+		{nil, nil, nil, ""},
+	}
+	for i, v := range tests {
+		cfg := simConfig(2)
+		cfg.Challenge = v.challenge
+		cfg.Response = v.response
+		drv := sim.New(cfg)
+		dbg, err := New(simDevice(t, drv, cfg.IDCode), v.auth)
+		if v.err != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), v.err) {
+				t.Errorf("test %d: error %v, expected %q", i, err, v.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: %v", i, err)
+			continue
+		}
+		if !drv.Authenticated() {
+			t.Errorf("test %d: debugger is not authenticated", i)
+		}
+		// the harts are enumerated after authentication
+		if len(dbg.hart) != 2 {
+			t.Errorf("test %d: %d harts found, expected 2", i, len(dbg.hart))
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
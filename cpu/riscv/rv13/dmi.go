@@ -141,9 +141,12 @@ func newDMI() *soc.Device {
 							{Name: "resumereq", Msb: 30, Lsb: 30},
 							{Name: "hartreset", Msb: 29, Lsb: 29},
 							{Name: "ackhavereset", Msb: 28, Lsb: 28},
+							{Name: "ackunavail", Msb: 27, Lsb: 27},
 							{Name: "hasel", Msb: 26, Lsb: 26},
 							{Name: "hartsello", Msb: 25, Lsb: 16},
 							{Name: "hartselhi", Msb: 15, Lsb: 6},
+							{Name: "setkeepalive", Msb: 5, Lsb: 5},
+							{Name: "clrkeepalive", Msb: 4, Lsb: 4},
 							{Name: "setresethaltreq", Msb: 3, Lsb: 3},
 							{Name: "clrresethaltreq", Msb: 2, Lsb: 2},
 							{Name: "ndmreset", Msb: 1, Lsb: 1},
@@ -154,6 +157,8 @@ func newDMI() *soc.Device {
 						Name:  "dmstatus",
 						Descr: "debug module status",
 						Fields: []soc.Field{
+							{Name: "ndmresetpending", Msb: 24, Lsb: 24},
+							{Name: "stickyunavail", Msb: 23, Lsb: 23},
 							{Name: "impebreak", Msb: 22, Lsb: 22},
 							{Name: "allhavereset", Msb: 19, Lsb: 19},
 							{Name: "anyhavereset", Msb: 18, Lsb: 18},
@@ -192,6 +197,7 @@ func newDMI() *soc.Device {
 						Fields: []soc.Field{
 							{Name: "progbufsize", Msb: 28, Lsb: 24},
 							{Name: "busy", Msb: 12, Lsb: 12},
+							{Name: "relaxedpriv", Msb: 11, Lsb: 11},
 							{Name: "cmderr", Msb: 10, Lsb: 8},
 							{Name: "datacount", Msb: 3, Lsb: 0},
 						},
@@ -229,6 +235,7 @@ func newDMI() *soc.Device {
 const haltreq = (1 << 31)
 const resumereq = (1 << 30)
 const ackhavereset = (1 << 28)
+const ackunavail = (1 << 27)
 const hartsello = ((1 << 10) - 1) << 16
 const hartselhi = ((1 << 10) - 1) << 6
 const ndmreset = (1 << 1)
@@ -283,6 +290,7 @@ func (dbg *Debug) selectHart(id int) error {
 //-----------------------------------------------------------------------------
 // DM status
 
+const ndmresetpending = (1 << 24)
 const anyhavereset = (1 << 18)
 const allresumeack = (1 << 17)
 const anyresumeack = (1 << 16)
@@ -404,6 +412,7 @@ const (
 )
 
 const errClear = (7 << 8 /*cmderr*/)
+const relaxedpriv = (1 << 11)
 
 func (ce cmdErr) String() string {
 	return [8]string{
@@ -426,7 +435,12 @@ func (cs cmdStatus) getError() cmdErr {
 // cmdErrorClr resets a command error.
 func (dbg *Debug) cmdErrorClr() error {
 	// write all-ones to the cmderr field.
-	return dbg.wrDmi(abstractcs, errClear)
+	// abstractcs.relaxedpriv (1.0) is writeable, so keep the current setting.
+	x := uint32(errClear)
+	if dbg.relaxedpriv {
+		x |= relaxedpriv
+	}
+	return dbg.wrDmi(abstractcs, x)
 }
 
 // regCSR returns the abstract register number for a control and status register.
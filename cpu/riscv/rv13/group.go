@@ -0,0 +1,247 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Debugger 0.13
+
+Hart Group Functions
+
+The harts in the hart group are halted and resumed together. If the debug
+module implements the hart array mask (hasel/hawindow) this is done with a
+single halt/resume request, otherwise each hart is halted/resumed in turn.
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv13
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/util"
+	"github.com/deadsy/rvdbg/util/log"
+)
+
+//-----------------------------------------------------------------------------
+
+const hasel = (1 << 26)
+
+// haWindows returns the hart array window masks for a set of harts.
+func haWindows(ids []int) map[uint]uint32 {
+	w := map[uint]uint32{}
+	for _, id := range ids {
+		w[uint(id>>5)] |= 1 << uint(id&31)
+	}
+	return w
+}
+
+// wrHartArray writes the hart array mask for a set of harts.
+// It returns false if the mask was not accepted.
+func (dbg *Debug) wrHartArray(ids []int) (bool, error) {
+	n := (len(dbg.hart) + 31) >> 5
+	w := haWindows(ids)
+	for i := uint(0); i < uint(n); i++ {
+		err := dbg.wrDmi(hawindowsel, uint32(i))
+		if err != nil {
+			return false, err
+		}
+		err = dbg.wrDmi(hawindow, w[i])
+		if err != nil {
+			return false, err
+		}
+		x, err := dbg.rdDmi(hawindow)
+		if err != nil {
+			return false, err
+		}
+		if x != w[i] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// probeHartArray works out if the hart array mask is supported.
+func (dbg *Debug) probeHartArray() error {
+	dbg.hasel = false
+	if len(dbg.hart) < 2 {
+		return nil
+	}
+	err := dbg.setDmi(dmcontrol, hasel)
+	if err != nil {
+		return err
+	}
+	x, err := dbg.rdDmi(dmcontrol)
+	if err != nil {
+		return err
+	}
+	err = dbg.clrDmi(dmcontrol, hasel)
+	if err != nil {
+		return err
+	}
+	if x&hasel != 0 {
+		// check the mask can select all harts
+		ok, err := dbg.wrHartArray(rv.AllHarts(len(dbg.hart)))
+		if err != nil {
+			return err
+		}
+		dbg.hasel = ok
+	}
+	log.Info.Printf("hart array mask %t", dbg.hasel)
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+
+// SetHartGroup sets the harts that are halted/resumed together.
+func (dbg *Debug) SetHartGroup(ids []int) error {
+	return dbg.group.Set(ids, len(dbg.hart))
+}
+
+// GetHartGroup returns the harts that are halted/resumed together.
+func (dbg *Debug) GetHartGroup() []int {
+	return dbg.group.Get(len(dbg.hart))
+}
+
+// groupRequest makes a halt/resume request for a set of harts using the hart array mask.
+func (dbg *Debug) groupRequest(ids []int, req, flag uint32, to time.Duration) error {
+	ok, err := dbg.wrHartArray(ids)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("hart array mask not accepted for harts %v", ids)
+	}
+	// hartsel is always selected, so use a hart from the set
+	x, err := dbg.rdDmi(dmcontrol)
+	if err != nil {
+		return err
+	}
+	x = setHartSelect(x, ids[0]) | hasel
+	err = dbg.wrDmi(dmcontrol, x|req)
+	if err != nil {
+		return err
+	}
+	// wait for all selected harts
+	t := time.Now().Add(to)
+	done := false
+	for t.After(time.Now()) {
+		done, err = dbg.checkStatus(flag)
+		if err != nil {
+			return err
+		}
+		if done {
+			break
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+	// clear the request while the harts are still selected
+	err = dbg.wrDmi(dmcontrol, x)
+	if err != nil {
+		return err
+	}
+	// restore the current hart
+	err = dbg.wrDmi(dmcontrol, setHartSelect(x&^hasel, dbg.hartid))
+	if err != nil {
+		return err
+	}
+	if !done {
+		return fmt.Errorf("harts %v did not respond", ids)
+	}
+	return nil
+}
+
+// setGroupState sets the state of all harts in the group.
+func (dbg *Debug) setGroupState(state rv.HartState) {
+	for _, id := range dbg.GetHartGroup() {
+		dbg.hart[id].info.State = state
+	}
+}
+
+// HaltAll halts all harts in the hart group.
+func (dbg *Debug) HaltAll() error {
+	if !dbg.hasel {
+		return rv.GroupSequential(dbg, dbg.HaltHart)
+	}
+	err := dbg.groupRequest(dbg.GetHartGroup(), haltreq, allhalted, haltTimeout)
+	if err != nil {
+		return err
+	}
+	dbg.setGroupState(rv.Halted)
+	return nil
+}
+
+// ResumeAll resumes all harts in the hart group.
+func (dbg *Debug) ResumeAll() error {
+	if !dbg.hasel {
+		return rv.GroupSequential(dbg, dbg.ResumeHart)
+	}
+	// resumeack is only set for harts that were halted
+	state, err := dbg.HartStates()
+	if err != nil {
+		return err
+	}
+	ids := []int{}
+	for _, id := range dbg.GetHartGroup() {
+		if state[id] == rv.Halted {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) != 0 {
+		err = dbg.groupRequest(ids, resumereq, allresumeack, resumeTimeout)
+		if err != nil {
+			return err
+		}
+	}
+	dbg.setGroupState(rv.Running)
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+
+// HartStates returns the current state of all harts.
+// haltsum0/haltsum1 are used when there are multiple harts.
+func (dbg *Debug) HartStates() ([]rv.HartState, error) {
+	n := len(dbg.hart)
+	state := make([]rv.HartState, n)
+	if n == 1 {
+		halted, err := dbg.isHalted()
+		if err != nil {
+			return nil, err
+		}
+		state[0] = []rv.HartState{rv.Running, rv.Halted}[util.BoolToInt(halted)]
+		dbg.hart[0].info.State = state[0]
+		return state, nil
+	}
+	// haltsum1 has a bit for each group of 32 harts
+	groups := uint32(1)
+	if n > 32 {
+		x, err := dbg.rdDmi(haltsum1)
+		if err != nil {
+			return nil, err
+		}
+		groups = x
+	}
+	defer dbg.selectHart(dbg.hartid)
+	for i := 0; i < n; i += 32 {
+		var sum uint32
+		if groups&(1<<uint(i>>5)) != 0 {
+			// haltsum0 is for the 32 harts containing hartsel
+			err := dbg.selectHart(i)
+			if err != nil {
+				return nil, err
+			}
+			sum, err = dbg.rdDmi(haltsum0)
+			if err != nil {
+				return nil, err
+			}
+		}
+		for j := i; j < n && j < i+32; j++ {
+			state[j] = []rv.HartState{rv.Running, rv.Halted}[util.Bit(uint(sum), uint(j-i))]
+			dbg.hart[j].info.State = state[j]
+		}
+	}
+	return state, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,78 @@
+//-----------------------------------------------------------------------------
+/*
+
+Hart Group Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv13
+
+import (
+	"testing"
+
+	"github.com/deadsy/rvdbg/cpu/riscv/sim"
+)
+
+//-----------------------------------------------------------------------------
+
+// checkGroup checks the state of the harts after a group halt/resume.
+func checkGroup(t *testing.T, name string, drv *sim.Target, halted []bool) {
+	for i := range halted {
+		h := drv.Hart(i)
+		if h.Halted != halted[i] {
+			t.Errorf("%s: hart%d halted %t, expected %t", name, i, h.Halted, halted[i])
+		}
+		if h.HaltReq {
+			t.Errorf("%s: hart%d halt request was not cleared", name, i)
+		}
+	}
+	if drv.HartSelect() != 1 {
+		t.Errorf("%s: hart%d is selected, expected hart1", name, drv.HartSelect())
+	}
+}
+
+func Test_Group(t *testing.T) {
+	for _, hartArray := range []bool{false, true} {
+		cfg := simConfig(4)
+		cfg.HartArray = hartArray
+		dbg, drv := newSimDebug(t, cfg)
+		if dbg.hasel != hartArray {
+			t.Fatalf("hart array mask %t, expected %t", dbg.hasel, hartArray)
+		}
+		err := dbg.SetHartGroup([]int{0, 2, 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the current hart is not in the group
+		_, err = dbg.SetCurrentHart(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = dbg.HaltAll()
+		if err != nil {
+			t.Errorf("HaltAll: %v", err)
+		}
+		checkGroup(t, "HaltAll", drv, []bool{true, false, true, true})
+
+		err = dbg.ResumeAll()
+		if err != nil {
+			t.Errorf("ResumeAll: %v", err)
+		}
+		checkGroup(t, "ResumeAll", drv, []bool{false, false, false, false})
+
+		// hart2 halts (E.g. at a breakpoint), the rest of the group is running
+		drv.Hart(2).Halted = true
+		err = dbg.ResumeAll()
+		if err != nil {
+			t.Errorf("ResumeAll: %v", err)
+		}
+		checkGroup(t, "ResumeAll", drv, []bool{false, false, false, false})
+		if !drv.Hart(2).ResumeAck {
+			t.Error("ResumeAll: hart2 was not resumed")
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -123,6 +123,30 @@ func (dbg *Debug) resume() (bool, error) {
 	return false, nil
 }
 
+//-----------------------------------------------------------------------------
+// pc sampling
+
+// SamplePC samples the pc of the current hart (rv.PCSampler).
+// The hart is halted, dpc is read and the hart is resumed. A hart that was
+// already halted (E.g. at a breakpoint) is left halted.
+func (dbg *Debug) SamplePC() (uint64, error) {
+	halted, err := dbg.halt()
+	if err != nil {
+		return 0, err
+	}
+	if halted {
+		dbg.hart[dbg.hartid].info.State = rv.Halted
+		return 0, fmt.Errorf("hart%d is halted", dbg.hartid)
+	}
+	pc, err := dbg.RdCSR(rv.DPC, 0)
+	// always resume the hart, even if the dpc read fails
+	_, rerr := dbg.resume()
+	if err != nil {
+		return 0, err
+	}
+	return pc, rerr
+}
+
 //-----------------------------------------------------------------------------
 // access probing- setup pointers to access functions
 
@@ -398,6 +422,16 @@ func (hi *hartInfo) examine() error {
 		log.Error.Printf("hart%d: misa has 128-bit floating point but FLEN < 128", hi.info.ID)
 	}
 
+	// get the VLENB value
+	if rv.CheckExtMISA(hi.info.MISA, 'v') {
+		hi.info.VLENB, err = dbg.getVLENB(hi)
+		if err != nil {
+			log.Error.Printf("hart%d: misa has vector extension but %v", hi.info.ID, err)
+		} else {
+			log.Info.Printf("hart%d: VLENB %d", hi.info.ID, hi.info.VLENB)
+		}
+	}
+
 	// get the hart id per the CSR
 	mhartid, err := dbg.RdCSR(rv.MHARTID, 0)
 	if err != nil {
@@ -449,6 +483,7 @@ func (dbg *Debug) newHart(id int) *hartInfo {
 		dbg: dbg,
 	}
 	hi.info.ID = id
+	hi.info.Version = dbg.version
 	hi.info.Nregs = 32
 	return hi
 }
@@ -0,0 +1,51 @@
+//-----------------------------------------------------------------------------
+/*
+
+Hart Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv13
+
+import (
+	"testing"
+
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+)
+
+//-----------------------------------------------------------------------------
+
+func Test_SamplePC(t *testing.T) {
+	dbg, drv := newSimDebug(t, simConfig(2))
+	_, err := dbg.SetCurrentHart(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := drv.Hart(1)
+	if h.Halted {
+		t.Fatal("hart1 is halted")
+	}
+	h.CSR[rv.DPC] = 0x80001234
+	pc, err := dbg.SamplePC()
+	if err != nil || pc != 0x80001234 {
+		t.Errorf("pc 0x%x %v, expected 0x80001234", pc, err)
+	}
+	if h.Halted || h.HaltReq || !h.ResumeAck {
+		t.Errorf("hart1 halted %t haltreq %t resumeack %t after a sample", h.Halted, h.HaltReq, h.ResumeAck)
+	}
+	// the hart halts by itself (E.g. at a breakpoint)
+	h.Halted = true
+	_, err = dbg.SamplePC()
+	if err == nil {
+		t.Error("expected an error for a halted hart")
+	}
+	if !h.Halted || dbg.GetCurrentHart().State != rv.Halted {
+		t.Error("a halted hart was resumed")
+	}
+	if drv.Hart(0).Halted {
+		t.Error("hart0 was halted")
+	}
+}
+
+//-----------------------------------------------------------------------------
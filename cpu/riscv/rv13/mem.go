@@ -4,13 +4,21 @@
 RISC-V Debugger 0.13 Memory Operations
 Implements the mem.Driver interface methods.
 
+Memory is accessed with the program buffer. For supervisor/user mode access
+mstatus.mprv is set so the loads/stores use the privilege mode in mstatus.mpp
+(and the satp translation for that mode). For virtual access the debugger
+walks the page tables and then makes a physical access.
+
 */
 //-----------------------------------------------------------------------------
 
 package rv13
 
 import (
+	"errors"
 	"fmt"
+
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
 )
 
 //-----------------------------------------------------------------------------
@@ -29,6 +37,22 @@ func (dbg *Debug) RdMem(width, addr, n uint) ([]uint, error) {
 	if width == 64 && hi.info.MXLEN < 64 {
 		return nil, fmt.Errorf("%d-bit memory reads are not supported", width)
 	}
+	switch dbg.memMode {
+	case rv.MemSupervisor, rv.MemUser:
+		var buf []uint
+		err := dbg.mprvAccess(func() error {
+			var err error
+			buf, err = hi.rdMem(dbg, width, addr, n)
+			return err
+		})
+		return buf, err
+	case rv.MemVirtual:
+		satp, err := dbg.RdCSR(rv.SATP, hi.info.MXLEN)
+		if err != nil {
+			return nil, err
+		}
+		return rv.VirtRdMem(satp, hi.info.MXLEN, width, addr, n, dbg.rdPhys)
+	}
 	return hi.rdMem(dbg, width, addr, n)
 }
 
@@ -41,7 +65,105 @@ func (dbg *Debug) WrMem(width, addr uint, val []uint) error {
 	if width == 64 && hi.info.MXLEN < 64 {
 		return fmt.Errorf("%d-bit memory writes are not supported", width)
 	}
+	switch dbg.memMode {
+	case rv.MemSupervisor, rv.MemUser:
+		return dbg.mprvAccess(func() error {
+			return hi.wrMem(dbg, width, addr, val)
+		})
+	case rv.MemVirtual:
+		satp, err := dbg.RdCSR(rv.SATP, hi.info.MXLEN)
+		if err != nil {
+			return err
+		}
+		return rv.VirtWrMem(satp, hi.info.MXLEN, width, addr, val, dbg.rdPhys, dbg.wrPhys)
+	}
 	return hi.wrMem(dbg, width, addr, val)
 }
 
+// rdPhys reads n x width-bit values from physical memory.
+func (dbg *Debug) rdPhys(width, addr, n uint) ([]uint, error) {
+	return dbg.hart[dbg.hartid].rdMem(dbg, width, addr, n)
+}
+
+// wrPhys writes width-bit values to physical memory.
+func (dbg *Debug) wrPhys(width, addr uint, val []uint) error {
+	return dbg.hart[dbg.hartid].wrMem(dbg, width, addr, val)
+}
+
+//-----------------------------------------------------------------------------
+// privilege mode access
+
+const mstatusMPRV = (1 << 17)
+const mstatusMPP = (3 << 11)
+const dcsrMprven = (1 << 4)
+
+// mprvAccess runs a memory access function with mstatus.mprv set, so the
+// program buffer loads/stores use the privilege mode of the memory mode.
+func (dbg *Debug) mprvAccess(fn func() error) error {
+	prv := map[rv.MemMode]uint64{rv.MemSupervisor: 1, rv.MemUser: 0}[dbg.memMode]
+	// dcsr.mprven enables mstatus.mprv in debug mode
+	dcsr, err := dbg.RdCSR(rv.DCSR, 0)
+	if err != nil {
+		return err
+	}
+	if dcsr&dcsrMprven == 0 {
+		err := dbg.WrCSR(rv.DCSR, 0, dcsr|dcsrMprven)
+		if err != nil {
+			return err
+		}
+		defer dbg.WrCSR(rv.DCSR, 0, dcsr)
+		x, err := dbg.RdCSR(rv.DCSR, 0)
+		if err != nil {
+			return err
+		}
+		if x&dcsrMprven == 0 {
+			return errors.New("dcsr.mprven is not supported")
+		}
+	}
+	mstatus, err := dbg.RdCSR(rv.MSTATUS, 0)
+	if err != nil {
+		return err
+	}
+	err = dbg.WrCSR(rv.MSTATUS, 0, (mstatus & ^uint64(mstatusMPP))|mstatusMPRV|(prv<<11))
+	if err != nil {
+		return err
+	}
+	defer dbg.WrCSR(rv.MSTATUS, 0, mstatus)
+	return fn()
+}
+
+//-----------------------------------------------------------------------------
+
+// SetMemoryMode sets the memory access mode.
+func (dbg *Debug) SetMemoryMode(mode rv.MemMode) error {
+	hi := dbg.hart[dbg.hartid]
+	switch mode {
+	case rv.MemSupervisor:
+		if !rv.CheckExtMISA(hi.info.MISA, 's') {
+			return errors.New("hart has no supervisor mode")
+		}
+	case rv.MemUser:
+		if !rv.CheckExtMISA(hi.info.MISA, 'u') {
+			return errors.New("hart has no user mode")
+		}
+	}
+	dbg.memMode = mode
+	return nil
+}
+
+// GetMemoryMode returns the memory access mode.
+func (dbg *Debug) GetMemoryMode() rv.MemMode {
+	return dbg.memMode
+}
+
+// Translate translates a virtual address using the current satp value.
+func (dbg *Debug) Translate(vaddr uint) (*rv.PageWalk, error) {
+	hi := dbg.hart[dbg.hartid]
+	satp, err := dbg.RdCSR(rv.SATP, hi.info.MXLEN)
+	if err != nil {
+		return nil, err
+	}
+	return rv.Translate(satp, hi.info.MXLEN, vaddr, dbg.rdPhys)
+}
+
 //-----------------------------------------------------------------------------
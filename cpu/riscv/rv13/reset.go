@@ -0,0 +1,248 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Debugger 0.13
+
+Reset Functions
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv13
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+const hartreset = (1 << 29)
+const setresethaltreq = (1 << 3)
+const clrresethaltreq = (1 << 2)
+
+const allhavereset = (1 << 19)
+const hasresethaltreq = (1 << 5)
+
+const resetTimeout = 100 * time.Millisecond
+
+// dmctrl returns a dmcontrol value that selects the current hart.
+func (dbg *Debug) dmctrl(bits uint32) uint32 {
+	return setHartSelect(dmactive|bits, dbg.hartid)
+}
+
+// haltOnReset requests that the current hart halts after reset.
+// setresethaltreq is used if it is implemented, otherwise haltreq is set and
+// held through the reset. haltreq reads as 0, so a dmcontrol read/modify/write
+// would clear it. The returned bits must be held in all dmcontrol writes until
+// the hart has halted.
+func (dbg *Debug) haltOnReset() (uint32, error) {
+	hasReq, err := dbg.checkStatus(hasresethaltreq)
+	if err != nil {
+		return 0, err
+	}
+	if hasReq {
+		return 0, dbg.wrDmi(dmcontrol, dbg.dmctrl(setresethaltreq))
+	}
+	return haltreq, dbg.wrDmi(dmcontrol, dbg.dmctrl(haltreq))
+}
+
+// clrHaltOnReset clears the halt after reset request for the current hart.
+func (dbg *Debug) clrHaltOnReset(hold uint32) error {
+	if hold&haltreq != 0 {
+		return dbg.wrDmi(dmcontrol, dbg.dmctrl(0))
+	}
+	return dbg.wrDmi(dmcontrol, dbg.dmctrl(clrresethaltreq))
+}
+
+// waitNdmReset waits for the system reset to complete (1.0 dmstatus.ndmresetpending).
+func (dbg *Debug) waitNdmReset() error {
+	if dbg.version < rv.DebugVersion10 {
+		return nil
+	}
+	t := time.Now().Add(resetTimeout)
+	for t.After(time.Now()) {
+		pending, err := dbg.checkStatus(ndmresetpending)
+		if err != nil {
+			return err
+		}
+		if !pending {
+			return nil
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+	return errors.New("ndmreset is still pending")
+}
+
+// waitReset waits for the current hart to come out of reset.
+// The hart is selected with the held dmcontrol bits (see haltOnReset).
+func (dbg *Debug) waitReset(halt bool, hold uint32) error {
+	err := dbg.wrDmi(dmcontrol, dbg.dmctrl(hold))
+	if err != nil {
+		return err
+	}
+	flag := uint32(allrunning)
+	if halt {
+		flag = allhalted
+	}
+	t := time.Now().Add(resetTimeout)
+	done := false
+	for t.After(time.Now()) {
+		x, err := dbg.rdDmi(dmstatus)
+		if err != nil {
+			return err
+		}
+		if x&allhavereset != 0 && x&flag != 0 {
+			done = true
+			break
+		}
+		time.Sleep(1 * time.Millisecond)
+	}
+	if halt {
+		// the hart has halted (or timed out), drop the halt request
+		err := dbg.clrHaltOnReset(hold)
+		if err != nil {
+			return err
+		}
+	}
+	// acknowledge the reset
+	// 1.0: harts may be unavailable during reset, so also clear stickyunavail
+	ack := uint32(ackhavereset)
+	if dbg.version >= rv.DebugVersion10 {
+		ack |= ackunavail
+	}
+	err = dbg.wrDmi(dmcontrol, dbg.dmctrl(ack))
+	if err != nil {
+		return err
+	}
+	if !done {
+		return fmt.Errorf("hart%d did not %s after reset", dbg.hartid, []string{"run", "halt"}[util.BoolToInt(halt)])
+	}
+	dbg.hart[dbg.hartid].info.State = []rv.HartState{rv.Running, rv.Halted}[util.BoolToInt(halt)]
+	return nil
+}
+
+// forEachHartID runs a function for each hart without selecting it.
+// The function must select the hart with dbg.dmctrl.
+func (dbg *Debug) forEachHartID(fn func() error) error {
+	id := dbg.hartid
+	defer func() { dbg.hartid = id }()
+	for i := range dbg.hart {
+		dbg.hartid = i
+		err := fn()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// forEachHart runs a function for each hart and then restores the current hart.
+func (dbg *Debug) forEachHart(fn func() error) error {
+	id := dbg.hartid
+	for i := range dbg.hart {
+		_, err := dbg.SetCurrentHart(i)
+		if err != nil {
+			return err
+		}
+		err = fn()
+		if err != nil {
+			dbg.SetCurrentHart(id)
+			return err
+		}
+	}
+	_, err := dbg.SetCurrentHart(id)
+	return err
+}
+
+//-----------------------------------------------------------------------------
+
+// ResetSystem resets the system (ndmreset) and optionally halts the harts.
+// dmcontrol is written with explicit values until the harts have come out of reset.
+func (dbg *Debug) ResetSystem(halt bool) error {
+	var hold uint32
+	if halt {
+		err := dbg.forEachHartID(func() error {
+			var err error
+			hold, err = dbg.haltOnReset()
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	// pulse ndmreset
+	err := dbg.wrDmi(dmcontrol, dbg.dmctrl(hold|ndmreset))
+	if err != nil {
+		return err
+	}
+	err = dbg.wrDmi(dmcontrol, dbg.dmctrl(hold))
+	if err != nil {
+		return err
+	}
+	err = dbg.waitNdmReset()
+	if err != nil {
+		return err
+	}
+	err = dbg.forEachHartID(func() error { return dbg.waitReset(halt, hold) })
+	if err != nil {
+		return err
+	}
+	// select the current hart
+	return dbg.wrDmi(dmcontrol, dbg.dmctrl(0))
+}
+
+// ResetHart resets the current hart (hartreset) and optionally halts it.
+// dmcontrol is written with explicit values until the hart has come out of reset.
+func (dbg *Debug) ResetHart(halt bool) error {
+	var hold uint32
+	if halt {
+		var err error
+		hold, err = dbg.haltOnReset()
+		if err != nil {
+			return err
+		}
+	}
+	err := dbg.wrDmi(dmcontrol, dbg.dmctrl(hold|hartreset))
+	if err != nil {
+		return err
+	}
+	// hartreset is optional, check the bit was set
+	x, err := dbg.rdDmi(dmcontrol)
+	if err != nil {
+		return err
+	}
+	err = dbg.wrDmi(dmcontrol, dbg.dmctrl(hold))
+	if err != nil {
+		return err
+	}
+	if x&hartreset == 0 {
+		if halt {
+			dbg.clrHaltOnReset(hold)
+		}
+		return errors.New("hartreset is not supported")
+	}
+	return dbg.waitReset(halt, hold)
+}
+
+// ResetDebug resets the debug module and updates the hart states.
+func (dbg *Debug) ResetDebug() error {
+	err := dbg.dmActivePulse()
+	if err != nil {
+		return err
+	}
+	return dbg.forEachHart(func() error {
+		halted, err := dbg.isHalted()
+		if err != nil {
+			return err
+		}
+		dbg.hart[dbg.hartid].info.State = []rv.HartState{rv.Running, rv.Halted}[util.BoolToInt(halted)]
+		return nil
+	})
+}
+
+//-----------------------------------------------------------------------------
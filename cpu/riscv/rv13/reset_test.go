@@ -0,0 +1,107 @@
+//-----------------------------------------------------------------------------
+/*
+
+Reset Tests
+
+The tests use a simulated debug module.
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv13
+
+import (
+	"testing"
+
+	"github.com/deadsy/rvdbg/cpu/riscv/sim"
+	"github.com/deadsy/rvdbg/jtag"
+)
+
+//-----------------------------------------------------------------------------
+
+// simDevice returns the jtag device for a simulated debug module.
+func simDevice(t *testing.T, drv *sim.Target, id jtag.IDCode) *jtag.Device {
+	ch, err := jtag.NewChain(drv, jtag.ChainInfo{{IRLength: 5, ID: id, Name: "sim"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dev, err := ch.GetDevice(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dev
+}
+
+// newSimDebug returns a debugger for a simulated debug module.
+func newSimDebug(t *testing.T, cfg *sim.Config) (*Debug, *sim.Target) {
+	drv := sim.New(cfg)
+	dbg, err := New(simDevice(t, drv, cfg.IDCode), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dbg, drv
+}
+
+// simConfig returns the configuration for a simulated RV32 debug module.
+func simConfig(nharts int) *sim.Config {
+	return &sim.Config{
+		IDCode:  0x1e200a6d,
+		Version: 2,
+		Harts:   nharts,
+		MXLEN:   32,
+		MISA:    0x40101105,
+	}
+}
+
+func checkHarts(t *testing.T, name string, drv *sim.Target, n int, halted bool) {
+	for i := 0; i < n; i++ {
+		h := drv.Hart(i)
+		if h.InReset || h.Halted != halted {
+			t.Errorf("%s: hart%d halted %t (in reset %t), expected halted %t", name, i, h.Halted, h.InReset, halted)
+		}
+		if h.HaltReq || h.ResetHaltReq {
+			t.Errorf("%s: hart%d halt request was not cleared", name, i)
+		}
+		if h.HaveReset {
+			t.Errorf("%s: hart%d havereset was not acknowledged", name, i)
+		}
+	}
+}
+
+func Test_Reset(t *testing.T) {
+	for _, hasReq := range []bool{false, true} {
+		cfg := simConfig(2)
+		cfg.ResetHaltReq = hasReq
+		dbg, drv := newSimDebug(t, cfg)
+		_, err := dbg.SetCurrentHart(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = dbg.ResetSystem(true)
+		if err != nil {
+			t.Errorf("ResetSystem(true): %v", err)
+		}
+		checkHarts(t, "ResetSystem(true)", drv, 2, true)
+		if drv.HartSelect() != 1 {
+			t.Errorf("hart%d is selected after reset, expected hart1", drv.HartSelect())
+		}
+
+		err = dbg.ResetSystem(false)
+		if err != nil {
+			t.Errorf("ResetSystem(false): %v", err)
+		}
+		checkHarts(t, "ResetSystem(false)", drv, 2, false)
+
+		err = dbg.ResetHart(true)
+		if err != nil {
+			t.Errorf("ResetHart(true): %v", err)
+		}
+		h0, h1 := drv.Hart(0), drv.Hart(1)
+		if !h1.Halted || h1.HaltReq || h0.Halted {
+			t.Errorf("ResetHart(true): bad hart state %+v %+v", h0, h1)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
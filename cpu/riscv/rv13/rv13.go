@@ -1,7 +1,10 @@
 //-----------------------------------------------------------------------------
 /*
 
-RISC-V Debugger 0.13 Functions
+RISC-V Debugger 0.13/1.0 Functions
+
+The 0.13 and 1.0 debug specifications use the same debug transport module
+(dtmcs.version == 1), and are distinguished by dmstatus.version.
 
 */
 //-----------------------------------------------------------------------------
@@ -31,41 +34,49 @@ const irDmi = 0x11 // debug module interface access
 
 //-----------------------------------------------------------------------------
 
-// Debug is a RISC-V 0.13 debugger. It implements the rv.Debug interface.
+// Debug is a RISC-V 0.13/1.0 debugger. It implements the rv.Debug interface.
 type Debug struct {
+	version         rv.DebugVersion // debug specification version
 	dev             *jtag.Device
-	dmiDevice       *soc.Device // dmi device for decode/display
-	hart            []*hartInfo // implemented harts
-	hartid          int         // currently selected hart
-	ir              uint        // cache of ir value
-	irlen           int         // IR length
-	drDmiLength     int         // DR length for dmi
-	abits           uint        // address bits in dtmcs
-	idle            uint        // idle value in dtmcs
-	progbufsize     uint        // number of progbuf words implemented
-	datacount       uint        // number of data words implemented
-	autoexecprogbuf bool        // can we autoexec on progbufX access?
-	autoexecdata    bool        // can we autoexec on dataX access?
-	sbasize         uint        // width of system bus address (0 = no access)
-	hartsellen      uint        // hart select length 0..20
-	impebreak       uint        // implicit ebreak in progbuf
+	dmiDevice       *soc.Device  // dmi device for decode/display
+	hart            []*hartInfo  // implemented harts
+	hartid          int          // currently selected hart
+	ir              uint         // cache of ir value
+	irlen           int          // IR length
+	drDmiLength     int          // DR length for dmi
+	abits           uint         // address bits in dtmcs
+	idle            uint         // idle value in dtmcs
+	progbufsize     uint         // number of progbuf words implemented
+	datacount       uint         // number of data words implemented
+	autoexecprogbuf bool         // can we autoexec on progbufX access?
+	autoexecdata    bool         // can we autoexec on dataX access?
+	sbasize         uint         // width of system bus address (0 = no access)
+	hartsellen      uint         // hart select length 0..20
+	impebreak       uint         // implicit ebreak in progbuf
+	relaxedpriv     bool         // abstract commands have relaxed privilege checks
+	hasel           bool         // hart array mask is supported
+	group           rv.HartGroup // hart group for halt/resume all
+	memMode         rv.MemMode   // memory access mode
 }
 
 func (dbg *Debug) String() string {
 	s := [][]string{}
-	s = append(s, []string{"version", "0.13"})
+	s = append(s, []string{"version", dbg.version.String()})
 	s = append(s, []string{"idle cycles", fmt.Sprintf("%d", dbg.idle)})
 	s = append(s, []string{"sbasize", fmt.Sprintf("%d bits", dbg.sbasize)})
 	s = append(s, []string{"progbufsize", fmt.Sprintf("%d words", dbg.progbufsize)})
 	s = append(s, []string{"datacount", fmt.Sprintf("%d words", dbg.datacount)})
 	s = append(s, []string{"autoexecprogbuf", fmt.Sprintf("%t", dbg.autoexecprogbuf)})
 	s = append(s, []string{"autoexecdata", fmt.Sprintf("%t", dbg.autoexecdata)})
+	if dbg.version >= rv.DebugVersion10 {
+		s = append(s, []string{"relaxedpriv", fmt.Sprintf("%t", dbg.relaxedpriv)})
+	}
 	return cli.TableString(s, []int{0, 0}, 1)
 }
 
-// New returns a RISC-V 0.13 debugger.
-func New(dev *jtag.Device) (*Debug, error) {
-	log.Info.Printf("0.13 debug module")
+// New returns a RISC-V 0.13/1.0 debugger.
+// The authenticator is used if the debug module requires authentication (nil == none).
+func New(dev *jtag.Device, auth rv.Authenticator) (*Debug, error) {
 	dbg := &Debug{
 		dev:       dev,
 		irlen:     dev.GetIRLength(),
@@ -109,6 +120,35 @@ func New(dev *jtag.Device) (*Debug, error) {
 		return nil, err
 	}
 
+	// check dmstatus fields
+	x, err := dbg.rdDmi(dmstatus)
+	if err != nil {
+		return nil, err
+	}
+	// check version
+	switch util.Bits(uint(x), 3, 0) {
+	case 2:
+		dbg.version = rv.DebugVersion013
+	case 3:
+		dbg.version = rv.DebugVersion10
+	default:
+		return nil, errors.New("unknown dmstatus version")
+	}
+	log.Info.Printf("%s debug module", dbg.version)
+
+	// check authentication (the debug module ignores other accesses until we are authenticated)
+	err = dbg.authenticate(auth)
+	if err != nil {
+		return nil, err
+	}
+
+	// implicit ebreak after progbuf
+	x, err = dbg.rdDmi(dmstatus)
+	if err != nil {
+		return nil, err
+	}
+	dbg.impebreak = util.Bit(uint(x), 22)
+
 	// write all-ones to hartsel
 	err = dbg.selectHart((1 << 20) - 1)
 	if err != nil {
@@ -116,7 +156,7 @@ func New(dev *jtag.Device) (*Debug, error) {
 	}
 
 	// read back dmcontrol
-	x, err := dbg.rdDmi(dmcontrol)
+	x, err = dbg.rdDmi(dmcontrol)
 	if err != nil {
 		return nil, err
 	}
@@ -132,22 +172,6 @@ func New(dev *jtag.Device) (*Debug, error) {
 	}
 	log.Info.Printf("hartsellen %d", dbg.hartsellen)
 
-	// check dmstatus fields
-	x, err = dbg.rdDmi(dmstatus)
-	if err != nil {
-		return nil, err
-	}
-	// check version
-	if util.Bits(uint(x), 3, 0) != 2 {
-		return nil, errors.New("unknown dmstatus version")
-	}
-	// check authentication
-	if util.Bit(uint(x), 7) != 1 {
-		return nil, errors.New("debugger is not authenticated")
-	}
-	// implicit ebreak after progbuf
-	dbg.impebreak = util.Bit(uint(x), 22)
-
 	// work out the system bus address size
 	x, err = dbg.rdDmi(sbcs)
 	if err != nil {
@@ -193,6 +217,20 @@ func New(dev *jtag.Device) (*Debug, error) {
 	log.Info.Printf("progbufsize %d impebreak %d autoexecprogbuf %t", dbg.progbufsize, dbg.impebreak, dbg.autoexecprogbuf)
 	log.Info.Printf("datacount %d autoexecdata %t", dbg.datacount, dbg.autoexecdata)
 
+	// 1.0: relax the privilege checks for abstract commands (if supported)
+	if dbg.version >= rv.DebugVersion10 {
+		err = dbg.setDmi(abstractcs, relaxedpriv)
+		if err != nil {
+			return nil, err
+		}
+		x, err = dbg.rdDmi(abstractcs)
+		if err != nil {
+			return nil, err
+		}
+		dbg.relaxedpriv = x&relaxedpriv != 0
+		log.Info.Printf("relaxedpriv %t", dbg.relaxedpriv)
+	}
+
 	// clear any pending command errors
 	err = dbg.cmdErrorClr()
 	if err != nil {
@@ -233,6 +271,12 @@ func New(dev *jtag.Device) (*Debug, error) {
 		}
 	}
 
+	// can we halt/resume multiple harts at once?
+	err = dbg.probeHartArray()
+	if err != nil {
+		return nil, err
+	}
+
 	return dbg, nil
 }
 
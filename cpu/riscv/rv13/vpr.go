@@ -0,0 +1,174 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Debugger 0.13 Vector Register Operations
+
+The vector registers are accessed one XLEN-bit element at a time with
+program buffer sequences. The element width is set to XLEN with vl = VLMAX.
+
+read: vmv.x.s s0, vN; vslide1down.vx vN, vN, s0
+write: vslide1down.vx vN, vN, s0
+
+Each step rotates the register down by one element, so after VLMAX steps
+a read leaves the register unchanged and a write has shifted in all the
+new elements. s0, s1, vl, vtype, vstart and mstatus are restored afterwards.
+
+*/
+//-----------------------------------------------------------------------------
+
+package rv13
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+)
+
+//-----------------------------------------------------------------------------
+
+// getVLENB returns the vector register length in bytes for the current hart.
+func (dbg *Debug) getVLENB(hi *hartInfo) (uint, error) {
+	if !rv.CheckExtMISA(hi.info.MISA, 'v') {
+		return 0, errors.New("no vector extension")
+	}
+	mstatus, err := rv.VectorOn(dbg)
+	if err != nil {
+		return 0, err
+	}
+	defer dbg.WrCSR(rv.MSTATUS, 0, mstatus)
+	vlenb, err := dbg.RdCSR(rv.VLENB, hi.info.MXLEN)
+	if err != nil {
+		return 0, err
+	}
+	return uint(vlenb), nil
+}
+
+// vectorAccess runs a function with vl = VLMAX and SEW = XLEN.
+// The function is passed the number of elements.
+func (dbg *Debug) vectorAccess(write bool, fn func(n uint) error) error {
+	hi := dbg.hart[dbg.hartid]
+	xlen := hi.info.MXLEN
+	if hi.info.VLENB == 0 {
+		return errors.New("no vector extension")
+	}
+	// save state
+	s0, err := dbg.RdGPR(rv.RegS0, 0)
+	if err != nil {
+		return err
+	}
+	s1, err := dbg.RdGPR(rv.RegS1, 0)
+	if err != nil {
+		return err
+	}
+	mstatus, err := rv.VectorOn(dbg)
+	if err != nil {
+		return err
+	}
+	vstart, err := dbg.RdCSR(rv.VSTART, 0)
+	if err != nil {
+		return err
+	}
+	vl, err := dbg.RdCSR(rv.VL, 0)
+	if err != nil {
+		return err
+	}
+	vtype, err := dbg.RdCSR(rv.VTYPE, 0)
+	if err != nil {
+		return err
+	}
+	// vsetvli s0, zero, e<xlen>, m1, ta, ma
+	sew := map[uint]uint{32: 2, 64: 3}[xlen]
+	pb := dbg.newProgramBuffer(2)
+	pb[0] = rv.InsVSETVLI(rv.RegS0, rv.RegZero, (1<<7)|(1<<6)|(sew<<3))
+	err = dbg.WrCSR(rv.VSTART, 0, 0)
+	if err == nil {
+		var vlmax uint64
+		vlmax, err = dbg.pbRead(xlen, pb)
+		if err == nil && uint(vlmax) != hi.info.VLENB*8/xlen {
+			err = fmt.Errorf("vlmax is %d, expected %d", vlmax, hi.info.VLENB*8/xlen)
+		}
+	}
+	if err == nil {
+		err = fn(uint(hi.info.VLENB * 8 / xlen))
+	}
+	// restore state: vsetvl zero, s0, s1
+	errs := []error{err}
+	errs = append(errs, dbg.WrGPR(rv.RegS1, 0, vtype))
+	pb[0] = rv.InsVSETVL(rv.RegZero, rv.RegS0, rv.RegS1)
+	errs = append(errs, dbg.pbWrite(xlen, vl, pb))
+	errs = append(errs, dbg.WrCSR(rv.VSTART, 0, vstart))
+	if write && mstatus&rv.MstatusVS != 0 {
+		// the vector state is dirty
+		mstatus |= rv.MstatusVS
+	}
+	errs = append(errs, dbg.WrCSR(rv.MSTATUS, 0, mstatus))
+	errs = append(errs, dbg.WrGPR(rv.RegS0, 0, s0))
+	errs = append(errs, dbg.WrGPR(rv.RegS1, 0, s1))
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+
+// RdVPR reads a vector register.
+func (dbg *Debug) RdVPR(reg uint) ([]byte, error) {
+	hi := dbg.hart[dbg.hartid]
+	if reg >= 32 {
+		return nil, fmt.Errorf("vpr%d is invalid", reg)
+	}
+	xlen := hi.info.MXLEN
+	buf := []byte{}
+	err := dbg.vectorAccess(false, func(n uint) error {
+		pb := dbg.newProgramBuffer(3)
+		pb[0] = rv.InsVMV_X_S(rv.RegS0, reg)
+		pb[1] = rv.InsVSLIDE1DOWN_VX(reg, reg, rv.RegS0)
+		for i := uint(0); i < n; i++ {
+			x, err := dbg.pbRead(xlen, pb)
+			if err != nil {
+				return err
+			}
+			for j := uint(0); j < xlen; j += 8 {
+				buf = append(buf, byte(x>>j))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// WrVPR writes a vector register.
+func (dbg *Debug) WrVPR(reg uint, val []byte) error {
+	hi := dbg.hart[dbg.hartid]
+	if reg >= 32 {
+		return fmt.Errorf("vpr%d is invalid", reg)
+	}
+	if uint(len(val)) != hi.info.VLENB {
+		return fmt.Errorf("vpr%d is %d bytes", reg, hi.info.VLENB)
+	}
+	xlen := hi.info.MXLEN
+	return dbg.vectorAccess(true, func(n uint) error {
+		pb := dbg.newProgramBuffer(2)
+		pb[0] = rv.InsVSLIDE1DOWN_VX(reg, reg, rv.RegS0)
+		for i := uint(0); i < n; i++ {
+			var x uint64
+			for j := uint(0); j < xlen; j += 8 {
+				x |= uint64(val[i*(xlen>>3)+(j>>3)]) << j
+			}
+			err := dbg.pbWrite(xlen, x, pb)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//-----------------------------------------------------------------------------
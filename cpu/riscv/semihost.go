@@ -0,0 +1,295 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Semihosting
+
+A semihosting request is an ebreak within a fixed instruction sequence:
+
+slli x0, x0, 0x1f
+ebreak
+srai x0, x0, 7
+
+The operation is in a0 and the parameter is in a1. The result is returned in a0.
+The dcsr.ebreakm/s/u bits are set so the ebreak halts the hart. The request
+is serviced and the hart is resumed after the ebreak.
+
+The CLI owns stdin, so console input is queued with "semihosting input".
+A console read without queued input leaves the hart halted on the ebreak,
+and the request is serviced again when the hart is resumed.
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/semihost"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+// semihosting instruction sequence
+const (
+	insSlli   = 0x01f01013 // slli x0, x0, 0x1f
+	insEbreak = 0x00100073 // ebreak
+	insSrai   = 0x40705013 // srai x0, x0, 7
+)
+
+const dcsrEbreak = (1 << 15) | (1 << 13) | (1 << 12) // dcsr.ebreakm/s/u
+const dcsrCauseEbreak = 1                            // dcsr.cause for an ebreak
+const regA0, regA1 = 10, 11
+const semihostPoll = 5 * time.Millisecond
+
+// userWriter writes console output to the user.
+type userWriter struct {
+	ui cli.USER
+}
+
+func (w *userWriter) Write(buf []byte) (int, error) {
+	w.ui.Put(string(buf))
+	return len(buf), nil
+}
+
+//-----------------------------------------------------------------------------
+
+// setEbreak sets/clears the dcsr bits that make ebreak halt the hart.
+func setEbreak(dbg rv.Debug, on bool) error {
+	dcsr, err := dbg.RdCSR(rv.DCSR, 0)
+	if err != nil {
+		return err
+	}
+	if on {
+		dcsr |= dcsrEbreak
+	} else {
+		dcsr &= ^uint64(dcsrEbreak)
+	}
+	return dbg.WrCSR(rv.DCSR, 0, dcsr)
+}
+
+// groupEbreak sets the dcsr ebreak bits on the halted harts of a hart group.
+// The current hart is restored.
+func groupEbreak(dbg rv.Debug, ids []int) error {
+	cur := dbg.GetCurrentHart().ID
+	state, err := dbg.HartStates()
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if state[id] != rv.Halted {
+			continue
+		}
+		_, err = dbg.SetCurrentHart(id)
+		if err == nil {
+			err = setEbreak(dbg, true)
+		}
+		if err != nil {
+			break
+		}
+	}
+	_, xerr := dbg.SetCurrentHart(cur)
+	if err != nil {
+		return err
+	}
+	return xerr
+}
+
+// isSemihosting returns true (and the pc) if the hart halted on a semihosting request.
+func isSemihosting(dbg rv.Debug) (bool, uint64, error) {
+	pc, err := dbg.RdCSR(rv.DPC, 0)
+	if err != nil {
+		return false, 0, err
+	}
+	dcsr, err := dbg.RdCSR(rv.DCSR, 0)
+	if err != nil {
+		return false, 0, err
+	}
+	if (dcsr>>6)&7 != dcsrCauseEbreak {
+		return false, pc, nil
+	}
+	// the pc may be 16-bit aligned
+	x, err := dbg.RdMem(16, uint(pc-4), 6)
+	if err != nil {
+		return false, pc, err
+	}
+	ok := (x[1]<<16)|x[0] == insSlli && (x[3]<<16)|x[2] == insEbreak && (x[5]<<16)|x[4] == insSrai
+	return ok, pc, nil
+}
+
+// semihostRequest services a semihosting request and steps over the ebreak.
+func semihostRequest(host *semihost.Host, dbg rv.Debug, pc uint64) error {
+	op, err := dbg.RdGPR(regA0, 0)
+	if err != nil {
+		return err
+	}
+	arg, err := dbg.RdGPR(regA1, 0)
+	if err != nil {
+		return err
+	}
+	xlen := dbg.GetCurrentHart().MXLEN
+	result, err := host.Call(dbg, xlen, uint(op), uint(arg))
+	if err == semihost.ErrNoInput {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", semihost.OpName(uint(op)), err)
+	}
+	err = dbg.WrGPR(regA0, 0, uint64(result))
+	if err != nil {
+		return err
+	}
+	return dbg.WrCSR(rv.DPC, 0, pc+4)
+}
+
+// hartsString returns a description of a set of harts.
+func hartsString(ids []int) string {
+	if len(ids) == 1 {
+		return fmt.Sprintf("hart%d", ids[0])
+	}
+	return fmt.Sprintf("harts %v", ids)
+}
+
+// semihostRun services semihosting requests from a set of harts until a hart halts
+// for another reason, the firmware exits, or the user stops waiting.
+// A hart that stops the run is left as the current hart.
+func semihostRun(c *cli.CLI, dbg rv.Debug, ids []int) {
+	s := c.User.(target).GetRiscvState()
+	c.User.Put(fmt.Sprintf("%s running with semihosting (ctrl-d to stop waiting)\n", hartsString(ids)))
+	cur := dbg.GetCurrentHart().ID
+	stop := -1 // the hart that stopped the run
+	var err error
+	c.Loop(func() bool {
+		var state []rv.HartState
+		state, err = dbg.HartStates()
+		if err != nil {
+			return true
+		}
+		id := -1
+		for _, i := range ids {
+			if state[i] == rv.Halted {
+				id = i
+				break
+			}
+		}
+		if id < 0 {
+			time.Sleep(semihostPoll)
+			return false
+		}
+		_, err = dbg.SetCurrentHart(id)
+		if err != nil {
+			return true
+		}
+		stop = id
+		var ok bool
+		var pc uint64
+		ok, pc, err = isSemihosting(dbg)
+		if err != nil {
+			return true
+		}
+		if !ok {
+			c.User.Put(fmt.Sprintf("hart%d halted at 0x%x\n", id, pc))
+			return true
+		}
+		err = semihostRequest(s.host, dbg, pc)
+		if err == semihost.ErrNoInput {
+			c.User.Put(fmt.Sprintf("hart%d is waiting for console input (semihosting input <text>, then resume)\n", id))
+			err = nil
+			return true
+		}
+		if err != nil {
+			return true
+		}
+		if exited, _ := s.host.Exited(); exited {
+			return true
+		}
+		err = dbg.ResumeHart()
+		if err != nil {
+			return true
+		}
+		stop = -1
+		return false
+	}, cli.KeycodeCtrlD)
+	if stop < 0 {
+		// no hart stopped the run, go back to the original hart
+		_, xerr := dbg.SetCurrentHart(cur)
+		if err == nil {
+			err = xerr
+		}
+	}
+	if err != nil {
+		util.PutError(c.User, fmt.Errorf("semihosting: %v", err))
+		return
+	}
+	if exited, code := s.host.Exited(); exited {
+		// start again with a new host state
+		s.host.Close()
+		s.host = semihost.NewHost(&userWriter{c.User})
+		if code != 0 {
+			util.PutError(c.User, &util.ExitError{Code: code})
+			return
+		}
+		c.User.Put(fmt.Sprintf("hart%d exited (code 0)\n", stop))
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// SemihostingHelp is help for the semihosting command.
+var SemihostingHelp = []cli.Help{
+	{"<cr>", "display the semihosting state"},
+	{"on", "enable semihosting (serviced while \"resume\" waits for the hart)"},
+	{"off", "disable semihosting"},
+	{"input <text>", "queue a line of console input for the firmware"},
+}
+
+// CmdSemihosting enables/disables semihosting.
+var CmdSemihosting = cli.Leaf{
+	Descr: "semihosting control",
+	F: func(c *cli.CLI, args []string) {
+		if len(args) > 1 && args[0] != "input" {
+			util.PutError(c.User, errors.New("usage: semihosting on|off|input <text>"))
+			return
+		}
+		s := c.User.(target).GetRiscvState()
+		if len(args) == 0 {
+			c.User.Put(fmt.Sprintf("semihosting is %s\n", []string{"off", "on"}[util.BoolToInt(s.host != nil)]))
+			return
+		}
+		dbg := c.User.(target).GetRiscvDebug()
+		switch args[0] {
+		case "on":
+			if s.host == nil {
+				s.host = semihost.NewHost(&userWriter{c.User})
+			}
+		case "off":
+			if s.host != nil {
+				s.host.Close()
+				s.host = nil
+			}
+			// ebreak goes back to the trap handler
+			if dbg.GetCurrentHart().State == rv.Halted {
+				err := setEbreak(dbg, false)
+				if err != nil {
+					util.PutError(c.User, err)
+				}
+			}
+		case "input":
+			if s.host == nil {
+				util.PutError(c.User, errors.New("semihosting is off"))
+				return
+			}
+			s.host.Input([]byte(strings.Join(args[1:], " ") + "\n"))
+		default:
+			util.PutError(c.User, errors.New("usage: semihosting on|off|input <text>"))
+		}
+	},
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,517 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Debug Target Simulator
+
+Debug Module Functions
+
+*/
+//-----------------------------------------------------------------------------
+
+package sim
+
+//-----------------------------------------------------------------------------
+
+// debug module registers
+const (
+	data0        = 0x04
+	dmcontrol    = 0x10
+	dmstatus     = 0x11
+	hartinfo     = 0x12
+	haltsum1     = 0x13
+	hawindowsel  = 0x14
+	hawindow     = 0x15
+	abstractcs   = 0x16
+	command      = 0x17
+	abstractauto = 0x18
+	progbuf0     = 0x20
+	authdata     = 0x30
+	haltsum0     = 0x40
+)
+
+const datacount = 2
+const progbufsize = 8
+
+// dmcontrol bits
+const (
+	haltreq         = 1 << 31
+	resumereq       = 1 << 30
+	hartreset       = 1 << 29
+	ackhavereset    = 1 << 28
+	hasel           = 1 << 26
+	hartsello       = 0x3ff << 16
+	hartselhi       = 0x3ff << 6
+	setresethaltreq = 1 << 3
+	clrresethaltreq = 1 << 2
+	ndmreset        = 1 << 1
+	dmactive        = 1 << 0
+)
+
+// dmstatus bits
+const (
+	allhavereset    = 1 << 19
+	anyhavereset    = 1 << 18
+	allresumeack    = 1 << 17
+	anyresumeack    = 1 << 16
+	allnonexistent  = 1 << 15
+	anynonexistent  = 1 << 14
+	allrunning      = 1 << 11
+	anyrunning      = 1 << 10
+	allhalted       = 1 << 9
+	anyhalted       = 1 << 8
+	authenticated   = 1 << 7
+	authbusy        = 1 << 6
+	hasresethaltreq = 1 << 5
+)
+
+// abstractcs bits
+const relaxedpriv = 1 << 11
+
+// command errors
+const (
+	errOk           = 0
+	errNotSupported = 2
+	errException    = 3
+	errHaltResume   = 4
+)
+
+// authbusy is set for this many dmstatus reads after an authdata write
+const authBusyReads = 2
+
+type debugModule struct {
+	dmactive      bool
+	hartsel       int
+	hartsellen    uint
+	hasel         bool
+	hawindowsel   uint
+	hawindow      map[uint]uint32
+	ndmreset      bool
+	hartreset     bool
+	cmderr        uint
+	relaxedpriv   bool
+	command       uint32
+	abstractauto  uint32
+	data          [datacount]uint32
+	progbuf       [progbufsize]uint32
+	authenticated bool
+	authBusy      int      // dmstatus reads with authbusy set
+	authRd        int      // challenge words read
+	authWr        []uint32 // response words written
+}
+
+//-----------------------------------------------------------------------------
+
+// getHartSelect gets the hart select value from a dmcontrol value.
+func getHartSelect(x uint32) int {
+	return int(((x & hartselhi) >> 6 << 10) | ((x & hartsello) >> 16))
+}
+
+// setHartSelect sets the hart select value in a dmcontrol value.
+func setHartSelect(x uint32, id int) uint32 {
+	return x | ((uint32(id>>10) << 6) & hartselhi) | ((uint32(id) << 16) & hartsello)
+}
+
+// selected returns the harts selected by hartsel and the hart array mask.
+func (t *Target) selected() []*Hart {
+	sel := []*Hart{}
+	for i, h := range t.hart {
+		if i == t.dm.hartsel || (t.dm.hasel && t.dm.hawindow[uint(i>>5)]&(1<<uint(i&31)) != 0) {
+			sel = append(sel, h)
+		}
+	}
+	return sel
+}
+
+// current returns the hart selected by hartsel (nil == nonexistent).
+func (t *Target) current() *Hart {
+	if t.dm.hartsel >= len(t.hart) {
+		return nil
+	}
+	return t.hart[t.dm.hartsel]
+}
+
+//-----------------------------------------------------------------------------
+// dmcontrol
+
+func (t *Target) wrDmcontrol(x uint32) {
+	dm := &t.dm
+	dm.dmactive = x&dmactive != 0
+	if !dm.dmactive {
+		// reset the debug module
+		t.dm = debugModule{
+			hartsellen:    dm.hartsellen,
+			authenticated: dm.authenticated,
+		}
+		for _, h := range t.hart {
+			h.HaltReq = false
+		}
+		return
+	}
+	if !dm.authenticated {
+		return
+	}
+	dm.hartsel = getHartSelect(x) & ((1 << dm.hartsellen) - 1)
+	dm.hasel = t.cfg.HartArray && x&hasel != 0
+	sel := t.selected()
+	for _, h := range sel {
+		h.HaltReq = x&haltreq != 0
+		if t.cfg.ResetHaltReq {
+			if x&setresethaltreq != 0 {
+				h.ResetHaltReq = true
+			}
+			if x&clrresethaltreq != 0 {
+				h.ResetHaltReq = false
+			}
+		}
+		if x&ackhavereset != 0 {
+			h.HaveReset = false
+		}
+		if x&resumereq != 0 && x&haltreq == 0 {
+			h.resume()
+		}
+	}
+	// system reset
+	ndm := x&ndmreset != 0
+	if ndm {
+		for _, h := range t.hart {
+			h.InReset = true
+			h.Halted = false
+			h.HaveReset = true
+		}
+	} else if dm.ndmreset {
+		for _, h := range t.hart {
+			h.leaveReset()
+		}
+	}
+	dm.ndmreset = ndm
+	// hart reset
+	hr := x&hartreset != 0
+	for _, h := range sel {
+		if hr {
+			h.InReset = true
+			h.Halted = false
+			h.HaveReset = true
+		} else if dm.hartreset {
+			h.leaveReset()
+		}
+	}
+	dm.hartreset = hr
+	// halt requests for running harts
+	for _, h := range sel {
+		if h.HaltReq && !h.InReset && !h.Halted {
+			h.halt(causeHaltReq)
+		}
+	}
+}
+
+func (t *Target) rdDmcontrol() uint32 {
+	dm := &t.dm
+	x := uint32(0)
+	if dm.dmactive {
+		x |= dmactive
+	}
+	if !dm.authenticated {
+		return x
+	}
+	x = setHartSelect(x, dm.hartsel)
+	if dm.hasel {
+		x |= hasel
+	}
+	if dm.ndmreset {
+		x |= ndmreset
+	}
+	if dm.hartreset {
+		x |= hartreset
+	}
+	return x
+}
+
+//-----------------------------------------------------------------------------
+// dmstatus
+
+func (t *Target) rdDmstatus() uint32 {
+	dm := &t.dm
+	x := uint32(t.cfg.Version)
+	if dm.authBusy > 0 {
+		dm.authBusy--
+		return x | authbusy
+	}
+	if !dm.authenticated {
+		return x
+	}
+	x |= authenticated
+	if t.cfg.ResetHaltReq {
+		x |= hasresethaltreq
+	}
+	if t.current() == nil {
+		x |= anynonexistent
+	}
+	sel := t.selected()
+	if len(sel) == 0 {
+		return x | allnonexistent
+	}
+	all := uint32(allhalted | allrunning | allresumeack | allhavereset)
+	for _, h := range sel {
+		var f uint32
+		if h.Halted {
+			f |= anyhalted
+		} else if !h.InReset {
+			f |= anyrunning
+		}
+		if h.ResumeAck {
+			f |= anyresumeack
+		}
+		if h.HaveReset {
+			f |= anyhavereset
+		}
+		x |= f
+		// the all flag is 1 bit above the any flag
+		all &= f << 1
+	}
+	return x | all
+}
+
+//-----------------------------------------------------------------------------
+// halt summaries
+
+func (t *Target) rdHaltsum0() uint32 {
+	base := t.dm.hartsel &^ 31
+	x := uint32(0)
+	for i := 0; i < 32 && base+i < len(t.hart); i++ {
+		if t.hart[base+i].Halted {
+			x |= 1 << uint(i)
+		}
+	}
+	return x
+}
+
+func (t *Target) rdHaltsum1() uint32 {
+	base := t.dm.hartsel &^ 1023
+	x := uint32(0)
+	for i := 0; i < 1024 && base+i < len(t.hart); i++ {
+		if t.hart[base+i].Halted {
+			x |= 1 << uint(i>>5)
+		}
+	}
+	return x
+}
+
+//-----------------------------------------------------------------------------
+// abstract commands
+
+func (t *Target) rdAbstractcs() uint32 {
+	x := uint32(progbufsize<<24) | uint32(t.dm.cmderr<<8) | datacount
+	if t.dm.relaxedpriv {
+		x |= relaxedpriv
+	}
+	return x
+}
+
+func (t *Target) wrAbstractcs(x uint32) {
+	// cmderr is write 1 to clear
+	t.dm.cmderr &^= uint(x>>8) & 7
+	if t.cfg.Version >= 3 {
+		t.dm.relaxedpriv = x&relaxedpriv != 0
+	}
+}
+
+// execute runs the current abstract command.
+func (t *Target) execute() {
+	dm := &t.dm
+	if dm.cmderr != errOk {
+		// commands are not started until cmderr is cleared
+		return
+	}
+	dm.cmderr = t.access(dm.command)
+}
+
+// access runs an access register command. It returns the command error.
+func (t *Target) access(x uint32) uint {
+	if x>>24 != 0 {
+		// only access register commands are supported
+		return errNotSupported
+	}
+	h := t.current()
+	if h == nil || !h.Halted {
+		return errHaltResume
+	}
+	size := uint(8) << ((x >> 20) & 7)
+	if x&(1<<17) != 0 {
+		// transfer
+		reg := uint(x & 0xffff)
+		val, n, ok := h.rdReg(reg)
+		if !ok && reg < 0x1000 {
+			return errException
+		}
+		if !ok || (size != 32 && size != 64) || size > n {
+			return errNotSupported
+		}
+		if x&(1<<16) != 0 {
+			// write
+			val = uint64(t.dm.data[0])
+			if size == 64 {
+				val |= uint64(t.dm.data[1]) << 32
+			}
+			h.wrReg(reg, val)
+		} else {
+			t.dm.data[0] = uint32(val)
+			if size == 64 {
+				t.dm.data[1] = uint32(val >> 32)
+			}
+		}
+	}
+	if x&(1<<18) != 0 {
+		// postexec
+		if !t.exec(h) {
+			return errException
+		}
+	}
+	return errOk
+}
+
+// autoexec runs the abstract command after a data/progbuf register access (if enabled).
+func (t *Target) autoexec(addr uint) {
+	switch {
+	case addr >= data0 && addr < data0+datacount:
+		if t.dm.abstractauto&(1<<(addr-data0)) != 0 {
+			t.execute()
+		}
+	case addr >= progbuf0 && addr < progbuf0+progbufsize:
+		if t.dm.abstractauto&(1<<(16+addr-progbuf0)) != 0 {
+			t.execute()
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// authentication
+
+func (t *Target) rdAuthdata() uint32 {
+	dm := &t.dm
+	if len(t.cfg.Challenge) == 0 {
+		return 0
+	}
+	x := t.cfg.Challenge[dm.authRd%len(t.cfg.Challenge)]
+	dm.authRd++
+	return x
+}
+
+func (t *Target) wrAuthdata(x uint32) {
+	dm := &t.dm
+	if dm.authBusy > 0 || dm.authenticated {
+		// writes while busy are lost
+		return
+	}
+	dm.authBusy = authBusyReads
+	dm.authWr = append(dm.authWr, x)
+	if len(dm.authWr) < len(t.cfg.Response) {
+		return
+	}
+	dm.authenticated = true
+	for i, v := range t.cfg.Response {
+		if dm.authWr[i] != v {
+			dm.authenticated = false
+		}
+	}
+	dm.authWr = nil
+}
+
+//-----------------------------------------------------------------------------
+// dmi register access
+
+// rdDmi reads a debug module register.
+func (t *Target) rdDmi(addr uint) uint32 {
+	dm := &t.dm
+	switch addr {
+	case dmstatus:
+		return t.rdDmstatus()
+	case dmcontrol:
+		return t.rdDmcontrol()
+	case authdata:
+		return t.rdAuthdata()
+	}
+	if !dm.authenticated {
+		return 0
+	}
+	switch {
+	case addr >= data0 && addr < data0+datacount:
+		x := dm.data[addr-data0]
+		t.autoexec(addr)
+		return x
+	case addr >= progbuf0 && addr < progbuf0+progbufsize:
+		x := dm.progbuf[addr-progbuf0]
+		t.autoexec(addr)
+		return x
+	}
+	switch addr {
+	case haltsum0:
+		return t.rdHaltsum0()
+	case haltsum1:
+		return t.rdHaltsum1()
+	case hawindowsel:
+		return uint32(dm.hawindowsel)
+	case hawindow:
+		return dm.hawindow[dm.hawindowsel]
+	case abstractcs:
+		return t.rdAbstractcs()
+	case command:
+		return dm.command
+	case abstractauto:
+		return dm.abstractauto
+	}
+	return 0
+}
+
+// wrDmi writes a debug module register.
+func (t *Target) wrDmi(addr uint, x uint32) {
+	dm := &t.dm
+	switch addr {
+	case dmcontrol:
+		t.wrDmcontrol(x)
+		return
+	case authdata:
+		t.wrAuthdata(x)
+		return
+	}
+	if !dm.authenticated {
+		return
+	}
+	switch {
+	case addr >= data0 && addr < data0+datacount:
+		dm.data[addr-data0] = x
+		t.autoexec(addr)
+		return
+	case addr >= progbuf0 && addr < progbuf0+progbufsize:
+		dm.progbuf[addr-progbuf0] = x
+		t.autoexec(addr)
+		return
+	}
+	switch addr {
+	case hawindowsel:
+		if t.cfg.HartArray {
+			dm.hawindowsel = uint(x) & 31
+		}
+	case hawindow:
+		if t.cfg.HartArray {
+			// only the bits for existing harts are writeable
+			base := int(dm.hawindowsel << 5)
+			var mask uint32
+			for i := 0; i < 32 && base+i < len(t.hart); i++ {
+				mask |= 1 << uint(i)
+			}
+			if dm.hawindow == nil {
+				dm.hawindow = make(map[uint]uint32)
+			}
+			dm.hawindow[dm.hawindowsel] = x & mask
+		}
+	case abstractcs:
+		t.wrAbstractcs(x)
+	case command:
+		dm.command = x
+		t.execute()
+	case abstractauto:
+		dm.abstractauto = x & (((1<<progbufsize)-1)<<16 | ((1 << datacount) - 1))
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,238 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Debug Target Simulator
+
+Hart and Program Buffer Functions
+
+*/
+//-----------------------------------------------------------------------------
+
+package sim
+
+import (
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+)
+
+//-----------------------------------------------------------------------------
+
+const dcsrStep = 1 << 2
+
+// dcsr.cause values
+const (
+	causeHaltReq      = 3 // halt request
+	causeStep         = 4 // single step
+	causeResetHaltReq = 5 // halt after reset request
+)
+
+// Hart is a simulated hart.
+type Hart struct {
+	HaltReq      bool            // halt request
+	ResetHaltReq bool            // halt after reset request
+	Halted       bool            // the hart is halted
+	InReset      bool            // the hart is in reset
+	HaveReset    bool            // the hart has been reset (not yet acknowledged)
+	ResumeAck    bool            // the hart has resumed
+	GPR          [32]uint64      // general purpose registers
+	FPR          [32]uint64      // floating point registers
+	CSR          map[uint]uint64 // implemented control and status registers
+	xlen         uint            // register length
+	flen         uint            // floating point register length (0 == none)
+}
+
+func newHart(id int, xlen uint, misa uint64) *Hart {
+	h := &Hart{
+		HaveReset: true,
+		xlen:      xlen,
+		CSR: map[uint]uint64{
+			rv.MSTATUS:       0,
+			rv.MISA:          misa,
+			rv.TSELECT:       0,
+			rv.DCSR:          (4 << 28) | 3, // xdebugver 4, prv m
+			rv.DPC:           0,
+			rv.DSCRATCH0:     0,
+			rv.DSCRATCH0 + 1: 0,
+			rv.MHARTID:       uint64(id),
+		},
+	}
+	if misa&(1<<('f'-'a')) != 0 {
+		h.flen = 32
+	}
+	if misa&(1<<('d'-'a')) != 0 {
+		h.flen = 64
+	}
+	return h
+}
+
+// mask masks a value to the register length.
+func (h *Hart) mask(val uint64) uint64 {
+	if h.xlen == 32 {
+		return uint64(uint32(val))
+	}
+	return val
+}
+
+// setGPR sets a general purpose register.
+func (h *Hart) setGPR(i uint, val uint64) {
+	if i != 0 {
+		h.GPR[i] = h.mask(val)
+	}
+}
+
+// halt halts a hart and sets dcsr.cause.
+func (h *Hart) halt(cause uint64) {
+	h.Halted = true
+	h.CSR[rv.DCSR] = h.CSR[rv.DCSR]&^(7<<6) | cause<<6
+}
+
+// leaveReset takes a hart out of reset.
+func (h *Hart) leaveReset() {
+	h.InReset = false
+	h.Halted = false
+	if h.ResetHaltReq {
+		h.halt(causeResetHaltReq)
+	} else if h.HaltReq {
+		h.halt(causeHaltReq)
+	}
+}
+
+// resume resumes a halted hart.
+func (h *Hart) resume() {
+	h.ResumeAck = false
+	if !h.Halted || h.InReset {
+		return
+	}
+	h.Halted = false
+	h.ResumeAck = true
+	// single step: halt after the next instruction
+	if h.CSR[rv.DCSR]&dcsrStep != 0 {
+		h.CSR[rv.DPC] = h.mask(h.CSR[rv.DPC] + 4)
+		h.halt(causeStep)
+	}
+}
+
+//-----------------------------------------------------------------------------
+// abstract register access
+
+// rdReg reads a register by abstract register number.
+// It returns the value and register length (0 == no register).
+func (h *Hart) rdReg(reg uint) (uint64, uint, bool) {
+	switch {
+	case reg < 0x1000:
+		val, ok := h.CSR[reg]
+		return val, h.xlen, ok
+	case reg < 0x1020:
+		return h.GPR[reg-0x1000], h.xlen, true
+	case reg < 0x1040:
+		return h.FPR[reg-0x1020], h.flen, h.flen != 0
+	}
+	return 0, 0, false
+}
+
+// wrReg writes a register by abstract register number.
+func (h *Hart) wrReg(reg uint, val uint64) {
+	switch {
+	case reg < 0x1000:
+		h.CSR[reg] = h.mask(val)
+	case reg < 0x1020:
+		h.setGPR(reg-0x1000, val)
+	case reg < 0x1040:
+		h.FPR[reg-0x1020] = val
+	}
+}
+
+//-----------------------------------------------------------------------------
+// program buffer execution
+
+// signExtend sign extends an n-bit value.
+func signExtend(val uint64, n uint) uint64 {
+	s := 64 - n
+	return uint64(int64(val<<s) >> s)
+}
+
+// exec runs the program buffer on a hart. It returns false on an exception.
+func (t *Target) exec(h *Hart) bool {
+	for _, ins := range t.dm.progbuf {
+		if ins == rv.InsEBREAK() {
+			return true
+		}
+		if !t.step(h, ins) {
+			return false
+		}
+	}
+	// no ebreak at the end of the program buffer
+	return false
+}
+
+// step executes an instruction on a hart. It returns false on an exception.
+func (t *Target) step(h *Hart, ins uint32) bool {
+	rd := uint(ins>>7) & 31
+	funct3 := (ins >> 12) & 7
+	rs1 := uint(ins>>15) & 31
+	rs2 := uint(ins>>20) & 31
+	immI := signExtend(uint64(ins>>20), 12)
+	switch ins & 0x7f {
+	case 0x03:
+		// load
+		n := []int{1, 2, 4, 8, 1, 2, 4, 0}[funct3]
+		if n == 0 || uint(n*8) > h.xlen {
+			return false
+		}
+		val := t.load(h.GPR[rs1]+immI, n)
+		if funct3 < 4 {
+			val = signExtend(val, uint(n*8))
+		}
+		h.setGPR(rd, val)
+	case 0x23:
+		// store
+		n := []int{1, 2, 4, 8, 0, 0, 0, 0}[funct3]
+		if n == 0 || uint(n*8) > h.xlen {
+			return false
+		}
+		immS := signExtend(uint64(((ins>>25)<<5)|((ins>>7)&31)), 12)
+		t.store(h.mask(h.GPR[rs1]+immS), n, h.GPR[rs2])
+	case 0x13:
+		// integer register-immediate
+		x := h.GPR[rs1]
+		switch funct3 {
+		case 0: // addi
+			h.setGPR(rd, x+immI)
+		case 4: // xori
+			h.setGPR(rd, x^immI)
+		case 5: // srli
+			h.setGPR(rd, x>>(immI&uint64(h.xlen-1)))
+		case 6: // ori
+			h.setGPR(rd, x|immI)
+		case 7: // andi
+			h.setGPR(rd, x&immI)
+		default:
+			return false
+		}
+	case 0x73:
+		// csr access
+		csr := uint(ins >> 20)
+		old, ok := h.CSR[csr]
+		if !ok || funct3 == 0 || funct3 == 4 {
+			return false
+		}
+		src := h.GPR[rs1]
+		if funct3 >= 5 {
+			// immediate
+			src = uint64(rs1)
+		}
+		switch funct3 & 3 {
+		case 1: // csrrw
+			h.CSR[csr] = h.mask(src)
+		case 2: // csrrs
+			h.CSR[csr] = old | src
+		case 3: // csrrc
+			h.CSR[csr] = old &^ src
+		}
+		h.setGPR(rd, old)
+	default:
+		return false
+	}
+	return true
+}
+
+//-----------------------------------------------------------------------------
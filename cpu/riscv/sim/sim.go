@@ -0,0 +1,231 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Debug Target Simulator
+
+A simulated JTAG driver for a RISC-V 0.13/1.0 debug module, so the debugger
+can be tested without hardware. It models the debug transport module, the
+debug module registers (halt/resume/reset requests, the hart array mask,
+authentication, abstract register commands and the program buffer) and a set
+of harts sharing a sparse byte addressed memory.
+
+The program buffer runs the load, store, immediate and CSR instructions used
+by the debugger.
+
+*/
+//-----------------------------------------------------------------------------
+
+package sim
+
+import (
+	"math/bits"
+	"time"
+
+	"github.com/deadsy/rvdbg/bitstr"
+	"github.com/deadsy/rvdbg/jtag"
+)
+
+//-----------------------------------------------------------------------------
+
+// Config is the configuration of a simulated target.
+type Config struct {
+	IDCode       jtag.IDCode // jtag id code
+	Version      uint        // dmstatus.version (2 = 0.13, 3 = 1.0)
+	Harts        int         // number of harts
+	MXLEN        uint        // register length (32 or 64)
+	MISA         uint64      // misa value (f/d set the floating point register length)
+	HartArray    bool        // the hart array mask (hasel/hawindow) is implemented
+	ResetHaltReq bool        // setresethaltreq/clrresethaltreq are implemented
+	Challenge    []uint32    // authdata challenge words
+	Response     []uint32    // authdata response words (nil == no authentication)
+}
+
+// Target is a simulated target. It implements the jtag.Driver interface.
+type Target struct {
+	cfg  Config
+	ir   uint            // instruction register
+	dmi  uint64          // dmi capture value
+	dm   debugModule     // debug module state
+	hart []*Hart         // harts
+	mem  map[uint64]byte // memory
+}
+
+// New returns a simulated target.
+func New(cfg *Config) *Target {
+	t := &Target{
+		cfg: *cfg,
+		ir:  irIDCode,
+		mem: make(map[uint64]byte),
+	}
+	for i := 0; i < cfg.Harts; i++ {
+		t.hart = append(t.hart, newHart(i, cfg.MXLEN, cfg.MISA))
+	}
+	t.dm.hartsellen = uint(bits.Len(uint(cfg.Harts - 1)))
+	t.dm.authenticated = cfg.Response == nil
+	return t
+}
+
+// Hart returns a simulated hart.
+func (t *Target) Hart(id int) *Hart {
+	return t.hart[id]
+}
+
+// HartSelect returns the dmcontrol hartsel value.
+func (t *Target) HartSelect() int {
+	return t.dm.hartsel
+}
+
+// Authenticated returns true if the debugger has authenticated.
+func (t *Target) Authenticated() bool {
+	return t.dm.authenticated
+}
+
+// RdMem reads n bytes of memory.
+func (t *Target) RdMem(addr uint64, n int) []byte {
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = t.mem[addr+uint64(i)]
+	}
+	return buf
+}
+
+// WrMem writes bytes to memory.
+func (t *Target) WrMem(addr uint64, buf []byte) {
+	for i, b := range buf {
+		t.mem[addr+uint64(i)] = b
+	}
+}
+
+// load reads a little endian n byte value from memory.
+func (t *Target) load(addr uint64, n int) uint64 {
+	var val uint64
+	for i := n - 1; i >= 0; i-- {
+		val = (val << 8) | uint64(t.mem[addr+uint64(i)])
+	}
+	return val
+}
+
+// store writes a little endian n byte value to memory.
+func (t *Target) store(addr uint64, n int, val uint64) {
+	for i := 0; i < n; i++ {
+		t.mem[addr+uint64(i)] = byte(val >> uint(8*i))
+	}
+}
+
+//-----------------------------------------------------------------------------
+// jtag tap with a debug transport module
+
+const irLength = 5
+const irIDCode = 0x01
+const irDtmcs = 0x10
+const irDmi = 0x11
+
+const abits = 7
+const drDtmcsLength = 32
+const drDmiLength = 34 + abits
+
+// dmi operations
+const opRd = 1
+const opWr = 2
+const opMask = 3
+
+// toBits returns the bits of a bit string, first bit first.
+func toBits(b *bitstr.BitString) []byte {
+	s := b.String()
+	bits := make([]byte, len(s))
+	for i := range s {
+		bits[len(s)-1-i] = s[i] - '0'
+	}
+	return bits
+}
+
+// fromBits returns a bit string for bits, first bit first.
+func fromBits(bits []byte) *bitstr.BitString {
+	s := make([]byte, len(bits))
+	for i := range bits {
+		s[len(bits)-1-i] = '0' + bits[i]
+	}
+	return bitstr.FromString(string(s))
+}
+
+// shift shifts tdi through an n-bit register with a capture value.
+// It returns tdo and the final register value.
+func shift(tdi *bitstr.BitString, capture uint64, n int) (*bitstr.BitString, uint64) {
+	reg := make([]byte, n)
+	for i := range reg {
+		reg[i] = byte(capture>>uint(i)) & 1
+	}
+	in := toBits(tdi)
+	out := make([]byte, len(in))
+	for i := range in {
+		out[i] = reg[0]
+		reg = append(reg[1:], in[i])
+	}
+	var val uint64
+	for i := range reg {
+		val |= uint64(reg[i]) << uint(i)
+	}
+	return fromBits(out), val
+}
+
+// TestReset pulses the ~TRST line.
+func (t *Target) TestReset(delay time.Duration) error {
+	return nil
+}
+
+// SystemReset pulses the ~SRST line.
+func (t *Target) SystemReset(delay time.Duration) error {
+	return nil
+}
+
+// GetState returns the state of a powered (3.3V) target that is not held in reset.
+func (t *Target) GetState() (*jtag.State, error) {
+	return &jtag.State{TargetVoltage: 3300, Srst: true}, nil
+}
+
+// Close closes the driver.
+func (t *Target) Close() error {
+	return nil
+}
+
+// TapReset resets the TAP state machine.
+func (t *Target) TapReset() error {
+	t.ir = irIDCode
+	return nil
+}
+
+// ScanIR scans bits through the instruction register.
+func (t *Target) ScanIR(tdi *bitstr.BitString, needTdo bool) (*bitstr.BitString, error) {
+	tdo, val := shift(tdi, 1, irLength)
+	t.ir = uint(val)
+	return tdo, nil
+}
+
+// ScanDR scans bits through the data register selected by the instruction register.
+func (t *Target) ScanDR(tdi *bitstr.BitString, idle uint, needTdo bool) (*bitstr.BitString, error) {
+	switch t.ir {
+	case irIDCode:
+		tdo, _ := shift(tdi, uint64(t.cfg.IDCode), 32)
+		return tdo, nil
+	case irDtmcs:
+		tdo, _ := shift(tdi, 1|(abits<<4), drDtmcsLength)
+		return tdo, nil
+	case irDmi:
+		tdo, val := shift(tdi, t.dmi, drDmiLength)
+		addr := uint(val >> 34)
+		data := uint32(val >> 2)
+		switch val & opMask {
+		case opRd:
+			t.dmi = uint64(addr)<<34 | uint64(t.rdDmi(addr))<<2
+		case opWr:
+			t.wrDmi(addr, data)
+			t.dmi = uint64(addr)<<34 | uint64(data)<<2
+		}
+		return tdo, nil
+	}
+	// bypass
+	tdo, _ := shift(tdi, 0, 1)
+	return tdo, nil
+}
+
+//-----------------------------------------------------------------------------
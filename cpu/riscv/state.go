@@ -0,0 +1,32 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Debugger State
+
+The command state for a RISC-V target (E.g. semihosting, the selected rtos task) is kept in CmdState,
+which is embedded in the target.
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import (
+	"github.com/deadsy/rvdbg/semihost"
+)
+
+//-----------------------------------------------------------------------------
+
+// CmdState stores the RISC-V command state. It is embedded in a target.
+type CmdState struct {
+	host   *semihost.Host // semihosting state (nil == semihosting is off)
+	thread *threadContext // selected rtos task context (nil == the current hart)
+	frame  threadFrame    // rtos task context frame layout
+}
+
+// GetRiscvState returns the RISC-V command state.
+func (s *CmdState) GetRiscvState() *CmdState {
+	return s
+}
+
+//-----------------------------------------------------------------------------
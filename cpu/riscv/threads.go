@@ -0,0 +1,280 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V RTOS Threads
+
+Display the FreeRTOS tasks and select the register context of a task. The
+gpr and bt commands use the selected context until the hart is resumed.
+
+The registers of a task that is not running are read from the context frame
+saved on the task stack by the FreeRTOS RISC-V port (portasmSAVE_CONTEXT).
+The frame is in XLEN-bit words:
+
+0: mepc
+1: x1
+2..28: x5..x31
+29: mstatus (pre V11), xCriticalNesting (V11+)
+30: mstatus (V11+)
+
+The frame size (portCONTEXT_SIZE) is 30 words before V11 and 31 words from
+V11. V11 is detected with the xCriticalNesting symbol of the ELF file.
+A chip specific port (portasmADDITIONAL_CONTEXT_SIZE) saves additional words
+below the frame, so the frame is at pxTopOfStack plus the additional words.
+The frame layout can be set with "threads frame".
+
+The frame is popped when the task runs, so sp is pxTopOfStack plus the
+additional and frame words. gp and tp are not saved, they are the same for
+all tasks.
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import (
+	"errors"
+	"fmt"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/elf"
+	"github.com/deadsy/rvdbg/rtos"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+// FreeRTOS context frame sizes (portCONTEXT_SIZE)
+const (
+	ctxWordsV10 = 30 // pre V11: mstatus
+	ctxWordsV11 = 31 // V11+: xCriticalNesting, mstatus
+)
+
+// threadContext is the register context of an RTOS task.
+type threadContext struct {
+	tcb  uint
+	regs []uint64 // general purpose registers and the pc
+}
+
+// threadFrame is the layout of the FreeRTOS context frame.
+type threadFrame struct {
+	set   bool // the layout was set by the user (false == detect the kernel version)
+	words uint // words in the frame (portCONTEXT_SIZE)
+	extra uint // additional words below the frame (portasmADDITIONAL_CONTEXT_SIZE)
+}
+
+// frameLayout returns the context frame layout for the kernel.
+func (s *CmdState) frameLayout(syms rtos.Symbols) threadFrame {
+	if s.frame.set {
+		return s.frame
+	}
+	words := uint(ctxWordsV10)
+	if syms != nil && syms.Lookup("xCriticalNesting") != nil {
+		words = ctxWordsV11
+	}
+	return threadFrame{words: words}
+}
+
+func (f threadFrame) String() string {
+	mode := "set"
+	if !f.set {
+		mode = "from the kernel version"
+	}
+	return fmt.Sprintf("context frame %d words, %d additional words (%s)", f.words, f.extra, mode)
+}
+
+// rdGPRs returns the general purpose registers and the pc (last) of the
+// selected register context.
+func (s *CmdState) rdGPRs(dbg rv.Debug) ([]uint64, error) {
+	if s.thread != nil {
+		return append([]uint64{}, s.thread.regs...), nil
+	}
+	hi := dbg.GetCurrentHart()
+	// slice of register values, +1 for the pc
+	reg := make([]uint64, hi.Nregs+1)
+	for i := 0; i < hi.Nregs; i++ {
+		var err error
+		reg[i], err = dbg.RdGPR(uint(i), 0)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read gpr%d: %v", i, err)
+		}
+	}
+	pc, err := dbg.RdCSR(rv.DPC, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read pc: %v", err)
+	}
+	reg[len(reg)-1] = pc
+	return reg, nil
+}
+
+// taskContext reads the saved register context of a task that is not running.
+func taskContext(dbg rv.Debug, t *rtos.Task, layout threadFrame) (*threadContext, error) {
+	hi := dbg.GetCurrentHart()
+	n := hi.MXLEN >> 3
+	frame, err := dbg.RdMem(hi.MXLEN, t.TopOfStack+layout.extra*n, layout.words)
+	if err != nil {
+		return nil, err
+	}
+	reg := make([]uint64, hi.Nregs+1)
+	reg[1] = uint64(frame[1])
+	reg[2] = uint64(t.TopOfStack + (layout.extra+layout.words)*n)
+	for i := 3; i < 5; i++ {
+		reg[i], err = dbg.RdGPR(uint(i), 0)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read gpr%d: %v", i, err)
+		}
+	}
+	for i := 5; i < hi.Nregs; i++ {
+		reg[i] = uint64(frame[i-3])
+	}
+	reg[len(reg)-1] = uint64(frame[0])
+	return &threadContext{t.TCB, reg}, nil
+}
+
+//-----------------------------------------------------------------------------
+
+func threadsString(tasks []*rtos.Task, xlen uint, sel *threadContext) string {
+	fmtx := util.UintFormat(xlen)
+	s := [][]string{}
+	for i, t := range tasks {
+		selected := ""
+		if sel != nil && sel.tcb == t.TCB {
+			selected = "*"
+		}
+		s = append(s, []string{
+			fmt.Sprintf("%d", i),
+			t.Name,
+			t.State.String(),
+			fmt.Sprintf("prio %d", t.Priority),
+			fmt.Sprintf("free %d", t.StackFree),
+			fmt.Sprintf("tcb "+fmtx, t.TCB),
+			selected,
+		})
+	}
+	return cli.TableString(s, []int{0, 0, 0, 0, 0, 0, 0}, 1)
+}
+
+// ThreadsHelp is help for the threads command.
+var ThreadsHelp = []cli.Help{
+	{"<cr>", "display the FreeRTOS tasks (name, state, priority, free stack bytes)"},
+	{"<n/name>", "select the register context of a task for \"gpr\" and \"bt\""},
+	{"hart", "select the register context of the current hart"},
+	{"frame [auto|<words> [extra]]", "display/set the task context frame layout"},
+	{"  words", "words in the frame (portCONTEXT_SIZE), 30 pre V11, 31 V11+"},
+	{"  extra", "additional words below the frame (portasmADDITIONAL_CONTEXT_SIZE)"},
+	{"", "the kernel symbols are found with the ELF file (see \"elf\")"},
+}
+
+// CmdThreads displays the RTOS tasks and selects a task register context.
+var CmdThreads = cli.Leaf{
+	Descr: "rtos thread info/select",
+	F: func(c *cli.CLI, args []string) {
+		if len(args) >= 1 && args[0] == "frame" {
+			threadsFrame(c, args[1:])
+			return
+		}
+		err := cli.CheckArgc(args, []int{0, 1})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		dbg := c.User.(target).GetRiscvDebug()
+		state := c.User.(target).GetRiscvState()
+		hi := dbg.GetCurrentHart()
+		if len(args) == 1 && args[0] == "hart" {
+			state.thread = nil
+			return
+		}
+		var syms rtos.Symbols
+		if f := elf.Current(); f != nil {
+			syms = f
+		}
+		tasks, err := rtos.FreeRTOSTasks(dbg, syms, hi.MXLEN)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		if len(args) == 0 {
+			if util.IsJSON(c.User) {
+				for _, t := range tasks {
+					util.PutJSON(c.User, t)
+				}
+				return
+			}
+			c.User.Put(fmt.Sprintf("%s\n", threadsString(tasks, hi.MXLEN, state.thread)))
+			return
+		}
+		// select a task by index or name
+		var t *rtos.Task
+		for i := range tasks {
+			if args[0] == tasks[i].Name || args[0] == fmt.Sprintf("%d", i) {
+				t = tasks[i]
+				break
+			}
+		}
+		if t == nil {
+			util.PutError(c.User, fmt.Errorf("no task \"%s\"", args[0]))
+			return
+		}
+		if t.State == rtos.Running {
+			// the registers are in the hart
+			state.thread = nil
+			return
+		}
+		if hi.State != rv.Halted {
+			util.PutError(c.User, fmt.Errorf("hart%d is not halted", hi.ID))
+			return
+		}
+		ctx, err := taskContext(dbg, t, state.frameLayout(syms))
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("unable to read the context of \"%s\": %v", t.Name, err))
+			return
+		}
+		state.thread = ctx
+		c.User.Put(fmt.Sprintf("task \"%s\" (%s) pc "+util.UintFormat(hi.MXLEN)+"\n", t.Name, t.State, ctx.regs[len(ctx.regs)-1]))
+	},
+}
+
+//-----------------------------------------------------------------------------
+
+// threadsFrame displays/sets the task context frame layout.
+func threadsFrame(c *cli.CLI, args []string) {
+	state := c.User.(target).GetRiscvState()
+	err := cli.CheckArgc(args, []int{0, 1, 2})
+	if err != nil {
+		util.PutError(c.User, err)
+		return
+	}
+	if len(args) == 0 {
+		var syms rtos.Symbols
+		if f := elf.Current(); f != nil {
+			syms = f
+		}
+		c.User.Put(fmt.Sprintf("%s\n", state.frameLayout(syms)))
+		return
+	}
+	if args[0] == "auto" {
+		if len(args) != 1 {
+			util.PutError(c.User, errors.New("usage: threads frame auto"))
+			return
+		}
+		state.frame = threadFrame{}
+		return
+	}
+	words, err := cli.IntArg(args[0], [2]int{ctxWordsV10, 256}, 10)
+	if err != nil {
+		util.PutError(c.User, err)
+		return
+	}
+	extra := 0
+	if len(args) == 2 {
+		extra, err = cli.IntArg(args[1], [2]int{0, 256}, 10)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+	}
+	state.frame = threadFrame{true, uint(words), uint(extra)}
+}
+
+//-----------------------------------------------------------------------------
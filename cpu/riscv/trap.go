@@ -0,0 +1,215 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Trap Diagnosis
+
+Decode the machine/supervisor trap CSRs (xcause, xepc, xtval, xstatus) and
+point out the usual causes of a firmware fault.
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import (
+	"fmt"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/elf"
+	"github.com/deadsy/rvdbg/soc"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+// socTarget provides a method for getting the SoC device.
+type socTarget interface {
+	GetSoC() (*soc.Device, soc.Driver)
+}
+
+// trapCSR are the CSRs for a trap privilege mode.
+type trapCSR struct {
+	cause, epc, tval, status uint
+	ppShift, ppMask          uint // xstatus.xpp
+	pieShift                 uint // xstatus.xpie
+}
+
+var trapCSRs = map[string]*trapCSR{
+	"m": {rv.MCAUSE, rv.MEPC, rv.MTVAL, rv.MSTATUS, 11, 3, 7},
+	"s": {rv.SCAUSE, rv.SEPC, rv.STVAL, rv.SSTATUS, 8, 1, 5},
+}
+
+// trapInfo is the decoded trap state.
+type trapInfo struct {
+	Mode      string   `json:"mode"`
+	Cause     uint64   `json:"cause"`
+	Interrupt bool     `json:"interrupt"`
+	Code      uint     `json:"code"`
+	Name      string   `json:"name"`
+	EPC       uint64   `json:"epc"`
+	Ins       string   `json:"ins,omitempty"`
+	Symbol    string   `json:"symbol,omitempty"`
+	Tval      uint64   `json:"tval"`
+	TvalInfo  string   `json:"tvalinfo,omitempty"`
+	PP        string   `json:"pp"`
+	PIE       uint     `json:"pie"`
+	Notes     []string `json:"notes,omitempty"`
+}
+
+// rdTrap reads and decodes the trap CSRs for a privilege mode.
+func rdTrap(dbg rv.Debug, dev *soc.Device, mode string) (*trapInfo, error) {
+	hi := dbg.GetCurrentHart()
+	if hi.State != rv.Halted {
+		return nil, fmt.Errorf("hart%d is not halted", hi.ID)
+	}
+	csr := trapCSRs[mode]
+	ti := &trapInfo{Mode: mode}
+	var status uint64
+	for _, x := range []struct {
+		reg uint
+		val *uint64
+	}{
+		{csr.cause, &ti.Cause},
+		{csr.epc, &ti.EPC},
+		{csr.tval, &ti.Tval},
+		{csr.status, &status},
+	} {
+		var err error
+		*x.val, err = dbg.RdCSR(x.reg, hi.MXLEN)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ti.Interrupt, ti.Code = rv.DecodeCause(ti.Cause, hi.MXLEN)
+	ti.Name = rv.CauseName(ti.Interrupt, ti.Code)
+	ti.PP = rv.PrivName(uint(status>>csr.ppShift) & csr.ppMask)
+	ti.PIE = uint(status>>csr.pieShift) & 1
+	// function+offset for xepc (the raw address is displayed without an elf file)
+	if f := elf.Current(); f != nil {
+		ti.Symbol = f.SymbolString(uint(ti.EPC))
+	}
+
+	// disassemble the instruction at xepc
+	var ins uint
+	x, err := dbg.RdMem(16, uint(ti.EPC), 2)
+	if err == nil {
+		ins = (x[1] << 16) | x[0]
+		ti.Ins = hi.ISA.Disassemble(uint(ti.EPC), ins).String()
+	}
+
+	if ti.Interrupt {
+		// platform interrupts may be named by the soc
+		if dev != nil {
+			for _, irq := range dev.Interrupts {
+				if irq.IRQ == ti.Code {
+					ti.Name = fmt.Sprintf("%s (%s)", ti.Name, irq.Name)
+				}
+			}
+		}
+		return ti, nil
+	}
+
+	ti.TvalInfo = rv.TvalInfo(ti.Code)
+	addr := uint(ti.Tval)
+
+	// look for the usual suspects
+	switch ti.Code {
+	case rv.ExInsMisaligned:
+		ti.note("jump/branch target 0x%x is misaligned", addr)
+	case rv.ExLoadMisaligned, rv.ExAmoMisaligned:
+		ti.note("data address 0x%x is misaligned", addr)
+	case rv.ExInsAccessFault, rv.ExLoadAccessFault, rv.ExAmoAccessFault:
+		if dev != nil {
+			if dev.GetPeripheralAt(addr) != nil {
+				ti.note("0x%x is %s (is the peripheral enabled?)", addr, dev.Symbol(addr))
+			} else {
+				ti.note("0x%x is not in a known peripheral region", addr)
+			}
+		}
+		if ti.Code == rv.ExInsAccessFault && ti.EPC == ti.Tval {
+			ti.note("execution from 0x%x, check the jump/return address", addr)
+		}
+	case rv.ExIllegalIns:
+		if ti.Tval != 0 {
+			ti.note("illegal instruction encoding 0x%08x", ti.Tval)
+		} else if ti.Ins != "" {
+			ti.note("xtval is 0, instruction at xepc is 0x%08x", ins)
+		}
+		if ins == 0 || ins == 0xffffffff {
+			ti.note("erased or uninitialised memory at 0x%x", ti.EPC)
+		}
+	case rv.ExInsPageFault, rv.ExLoadPageFault, rv.ExAmoPageFault:
+		ti.note("page fault at 0x%x, see \"vtop 0x%x\"", addr, addr)
+	case rv.ExBreakpoint:
+		ti.note("ebreak or trigger without a debugger attached")
+	}
+	return ti, nil
+}
+
+// note adds a diagnosis note to the trap information.
+func (ti *trapInfo) note(format string, a ...interface{}) {
+	ti.Notes = append(ti.Notes, fmt.Sprintf(format, a...))
+}
+
+func (ti *trapInfo) String() string {
+	s := [][]string{}
+	kind := []string{"exception", "interrupt"}[util.BoolToInt(ti.Interrupt)]
+	s = append(s, []string{ti.Mode + "cause", fmt.Sprintf("0x%x", ti.Cause), fmt.Sprintf("%s %d: %s", kind, ti.Code, ti.Name)})
+	epc := ti.Ins
+	if ti.Symbol != "" {
+		epc = fmt.Sprintf("%s (%s)", epc, ti.Symbol)
+	}
+	s = append(s, []string{ti.Mode + "epc", fmt.Sprintf("0x%x", ti.EPC), epc})
+	s = append(s, []string{ti.Mode + "tval", fmt.Sprintf("0x%x", ti.Tval), ti.TvalInfo})
+	s = append(s, []string{ti.Mode + "status", fmt.Sprintf("%spp", ti.Mode), ti.PP})
+	s = append(s, []string{"", fmt.Sprintf("%spie", ti.Mode), fmt.Sprintf("%d", ti.PIE)})
+	for _, n := range ti.Notes {
+		s = append(s, []string{"note", n, ""})
+	}
+	return cli.TableString(s, []int{0, 0, 0}, 1)
+}
+
+//-----------------------------------------------------------------------------
+
+// TrapHelp is help for the trap command.
+var TrapHelp = []cli.Help{
+	{"<cr>", "diagnose a machine mode trap (mcause/mepc/mtval/mstatus)"},
+	{"s", "diagnose a supervisor mode trap (scause/sepc/stval/sstatus)"},
+}
+
+// CmdTrap decodes the trap CSRs.
+var CmdTrap = cli.Leaf{
+	Descr: "trap diagnosis",
+	F: func(c *cli.CLI, args []string) {
+		err := cli.CheckArgc(args, []int{0, 1})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		mode := "m"
+		if len(args) == 1 {
+			mode = args[0]
+		}
+		if _, ok := trapCSRs[mode]; !ok {
+			util.PutError(c.User, fmt.Errorf("bad privilege mode \"%s\"", mode))
+			return
+		}
+		var dev *soc.Device
+		if t, ok := c.User.(socTarget); ok {
+			dev, _ = t.GetSoC()
+		}
+		ti, err := rdTrap(c.User.(target).GetRiscvDebug(), dev, mode)
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("unable to read trap registers: %v", err))
+			return
+		}
+		if util.IsJSON(c.User) {
+			util.PutJSON(c.User, ti)
+			return
+		}
+		c.User.Put(fmt.Sprintf("%s\n", ti))
+	},
+}
+
+//-----------------------------------------------------------------------------
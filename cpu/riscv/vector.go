@@ -0,0 +1,254 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Vector Registers
+
+Display and write the vector registers (v0..v31) as elements of a
+given width (e8, e16, e32, e64). Element 0 is displayed first.
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+// vregValue is a vector register split into elements.
+type vregValue struct {
+	Name     string   `json:"name"`
+	Sew      uint     `json:"sew"`
+	Elements []uint64 `json:"elements"`
+}
+
+// vectorState reads vl and vtype. mstatus.vs is enabled for the access.
+func vectorState(dbg rv.Debug) (uint64, uint64, error) {
+	mstatus, err := rv.VectorOn(dbg)
+	if err != nil {
+		return 0, 0, err
+	}
+	if mstatus&rv.MstatusVS == 0 {
+		defer dbg.WrCSR(rv.MSTATUS, 0, mstatus)
+	}
+	vl, err := dbg.RdCSR(rv.VL, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	vtype, err := dbg.RdCSR(rv.VTYPE, 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	return vl, vtype, nil
+}
+
+// vtypeSew returns the element width selected by vtype (0 == invalid vtype).
+func vtypeSew(vtype uint64, xlen uint) uint {
+	if vtype&(1<<(xlen-1)) != 0 {
+		// vill
+		return 0
+	}
+	vsew := (vtype >> 3) & 7
+	if vsew > 3 {
+		return 0
+	}
+	return 8 << vsew
+}
+
+// sewArg converts an element width argument (E.g. "e32") to bits.
+func sewArg(s string) (uint, error) {
+	switch s {
+	case "e8":
+		return 8, nil
+	case "e16":
+		return 16, nil
+	case "e32":
+		return 32, nil
+	case "e64":
+		return 64, nil
+	}
+	return 0, fmt.Errorf("bad element width \"%s\", use e8, e16, e32 or e64", s)
+}
+
+// vregArg converts a vector register argument (E.g. "v3") to a register number.
+func vregArg(s string) (uint, bool) {
+	if !strings.HasPrefix(s, "v") {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(s[1:], 10, 8)
+	if err != nil || n >= 32 {
+		return 0, false
+	}
+	return uint(n), true
+}
+
+// vregElements splits a vector register into little endian elements.
+func vregElements(buf []byte, sew uint) []uint64 {
+	n := sew >> 3
+	x := make([]uint64, len(buf)/int(n))
+	for i := range x {
+		for j := uint(0); j < n; j++ {
+			x[i] |= uint64(buf[uint(i)*n+j]) << (8 * j)
+		}
+	}
+	return x
+}
+
+// vregBytes sets the elements of a vector register.
+func vregBytes(buf []byte, sew uint, x []uint64) {
+	n := sew >> 3
+	for i := range x {
+		for j := uint(0); j < n; j++ {
+			buf[uint(i)*n+j] = byte(x[i] >> (8 * j))
+		}
+	}
+}
+
+func vregsString(regs []*vregValue) string {
+	s := make([][]string, len(regs))
+	for i, r := range regs {
+		fmtx := fmt.Sprintf("%%0%dx", r.Sew>>2)
+		e := make([]string, len(r.Elements))
+		for j, x := range r.Elements {
+			e[j] = fmt.Sprintf(fmtx, x)
+		}
+		s[i] = []string{r.Name, strings.Join(e, " ")}
+	}
+	return cli.TableString(s, []int{4, 0}, 1)
+}
+
+//-----------------------------------------------------------------------------
+
+// VregsHelp is help for the vregs command.
+var VregsHelp = []cli.Help{
+	{"[eN]", "display all vector registers"},
+	{"v<n> [eN]", "display a vector register"},
+	{"v<n> eN <val> ...", "write elements of a vector register, starting at element 0"},
+	{"  n", "vector register 0..31"},
+	{"  eN", "element width e8, e16, e32 or e64, default is vtype.vsew"},
+	{"  val", "element value (hex)"},
+}
+
+// CmdVregs displays and writes the vector registers.
+var CmdVregs = cli.Leaf{
+	Descr: "display/write vector registers",
+	F: func(c *cli.CLI, args []string) {
+		dbg := c.User.(target).GetRiscvDebug()
+		hi := dbg.GetCurrentHart()
+		if hi.VLENB == 0 {
+			util.PutError(c.User, fmt.Errorf("hart%d has no vector extension", hi.ID))
+			return
+		}
+		// register selection
+		regs := []uint{}
+		if len(args) != 0 {
+			if n, ok := vregArg(args[0]); ok {
+				regs = append(regs, n)
+				args = args[1:]
+			}
+		}
+		if len(regs) == 0 {
+			if len(args) > 1 {
+				util.PutError(c.User, errors.New("usage: vregs [v<n>] [eN] [<val> ...]"))
+				return
+			}
+			for i := uint(0); i < 32; i++ {
+				regs = append(regs, i)
+			}
+		}
+		// element width
+		var sew uint
+		if len(args) != 0 {
+			var err error
+			sew, err = sewArg(args[0])
+			if err != nil {
+				util.PutError(c.User, err)
+				return
+			}
+			args = args[1:]
+		}
+		// element values
+		if len(args) != 0 {
+			n := hi.VLENB * 8 / sew
+			if uint(len(args)) > n {
+				util.PutError(c.User, fmt.Errorf("v%d has %d e%d elements", regs[0], n, sew))
+				return
+			}
+		}
+		vals := make([]uint64, len(args))
+		for i := range args {
+			var err error
+			vals[i], err = strconv.ParseUint(strings.TrimPrefix(args[i], "0x"), 16, int(sew))
+			if err != nil {
+				util.PutError(c.User, fmt.Errorf("bad e%d element value \"%s\"", sew, args[i]))
+				return
+			}
+		}
+		err := dbg.HaltHart()
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("unable to halt hart%d: %v", hi.ID, err))
+			return
+		}
+		vl, vtype, err := vectorState(dbg)
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("unable to read vl/vtype: %v", err))
+			return
+		}
+		if sew == 0 {
+			sew = vtypeSew(vtype, hi.MXLEN)
+			if sew == 0 {
+				sew = 8
+			}
+		}
+		// write
+		if len(vals) != 0 {
+			buf, err := dbg.RdVPR(regs[0])
+			if err != nil {
+				util.PutError(c.User, fmt.Errorf("unable to read v%d: %v", regs[0], err))
+				return
+			}
+			x := vregElements(buf, sew)
+			copy(x, vals)
+			vregBytes(buf, sew, x)
+			err = dbg.WrVPR(regs[0], buf)
+			if err != nil {
+				util.PutError(c.User, fmt.Errorf("unable to write v%d: %v", regs[0], err))
+				return
+			}
+		}
+		// display
+		vr := make([]*vregValue, len(regs))
+		for i, n := range regs {
+			buf, err := dbg.RdVPR(n)
+			if err != nil {
+				util.PutError(c.User, fmt.Errorf("unable to read v%d: %v", n, err))
+				return
+			}
+			vr[i] = &vregValue{
+				Name:     fmt.Sprintf("v%d", n),
+				Sew:      sew,
+				Elements: vregElements(buf, sew),
+			}
+		}
+		if util.IsJSON(c.User) {
+			for _, x := range vr {
+				util.PutJSON(c.User, x)
+			}
+			return
+		}
+		fmtx := util.UintFormat(hi.MXLEN)
+		c.User.Put(fmt.Sprintf("vlen %d vl %d vtype "+fmtx+"\n", hi.VLENB*8, vl, vtype))
+		c.User.Put(fmt.Sprintf("%s\n", vregsString(vr)))
+	},
+}
+
+//-----------------------------------------------------------------------------
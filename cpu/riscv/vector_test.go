@@ -0,0 +1,56 @@
+//-----------------------------------------------------------------------------
+/*
+
+Vector Register Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package riscv
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+
+func Test_VregElements(t *testing.T) {
+	buf := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	tests := []struct {
+		sew uint
+		x   []uint64
+	}{
+		{8, []uint64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}},
+		{16, []uint64{0x0201, 0x0403, 0x0605, 0x0807, 0x0a09, 0x0c0b, 0x0e0d, 0x100f}},
+		{32, []uint64{0x04030201, 0x08070605, 0x0c0b0a09, 0x100f0e0d}},
+		{64, []uint64{0x0807060504030201, 0x100f0e0d0c0b0a09}},
+	}
+	for _, v := range tests {
+		x := vregElements(buf, v.sew)
+		if !reflect.DeepEqual(x, v.x) {
+			t.Errorf("e%d: elements %x, expected %x", v.sew, x, v.x)
+		}
+		// round trip
+		b := make([]byte, len(buf))
+		vregBytes(b, v.sew, x)
+		if !bytes.Equal(b, buf) {
+			t.Errorf("e%d: bytes %x, expected %x", v.sew, b, buf)
+		}
+	}
+	// a partial write only changes the leading elements
+	b := append([]byte{}, buf...)
+	vregBytes(b, 32, []uint64{0xdeadbeef})
+	x := vregElements(b, 32)
+	if !reflect.DeepEqual(x, []uint64{0xdeadbeef, 0x08070605, 0x0c0b0a09, 0x100f0e0d}) {
+		t.Errorf("partial write: elements %x", x)
+	}
+	// elements are truncated to the element width
+	vregBytes(b, 8, []uint64{0x1ff})
+	if b[0] != 0xff || b[1] != 0xbe {
+		t.Errorf("truncated write: bytes %x", b[:2])
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,559 @@
+//-----------------------------------------------------------------------------
+/*
+
+DWARF Call Frame Information
+
+Parse the CIE/FDE records in .debug_frame or .eh_frame and run the call
+frame instructions to get the unwind rules for a pc. DWARF expressions are
+not supported.
+
+*/
+//-----------------------------------------------------------------------------
+
+package elf
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+//-----------------------------------------------------------------------------
+
+// RuleKind is the type of a register unwind rule.
+type RuleKind int
+
+// RuleKind values.
+const (
+	RuleSameValue RuleKind = iota // register is unchanged
+	RuleUndefined                 // register value is not recoverable
+	RuleOffset                    // register is saved at cfa+offset
+	RuleValOffset                 // register value is cfa+offset
+	RuleRegister                  // register is saved in another register
+)
+
+// Rule is a register unwind rule.
+type Rule struct {
+	Kind   RuleKind
+	Reg    uint  // RuleRegister
+	Offset int64 // RuleOffset, RuleValOffset
+}
+
+// Frame is the unwind information for a pc.
+type Frame struct {
+	CFAReg    uint          // the canonical frame address is reg+offset
+	CFAOffset int64         // offset from the cfa register
+	RA        uint          // return address column
+	Regs      map[uint]Rule // register rules (no rule == same value)
+}
+
+//-----------------------------------------------------------------------------
+
+// cie is a common information entry.
+type cie struct {
+	codeAlign uint64 // code alignment factor
+	dataAlign int64  // data alignment factor
+	ra        uint   // return address column
+	fdeEnc    byte   // fde pointer encoding (.eh_frame)
+	aug       bool   // augmentation data is present
+	ins       []byte // initial instructions
+}
+
+// fde is a frame description entry.
+type fde struct {
+	cie        *cie
+	begin, end uint   // pc range
+	ins        []byte // instructions
+}
+
+// FrameTable is the call frame information for an ELF file.
+type FrameTable struct {
+	fdes    []*fde // sorted by pc
+	order   binary.ByteOrder
+	ptrSize uint
+}
+
+//-----------------------------------------------------------------------------
+
+// reader reads values from a section.
+type reader struct {
+	data  []byte
+	ofs   int
+	order binary.ByteOrder
+	err   error
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return make([]byte, n)
+	}
+	if n < 0 || r.ofs+n > len(r.data) {
+		r.err = errors.New("unexpected end of section")
+		return make([]byte, n)
+	}
+	b := r.data[r.ofs : r.ofs+n]
+	r.ofs += n
+	return b
+}
+
+func (r *reader) u8() uint8   { return r.bytes(1)[0] }
+func (r *reader) u16() uint16 { return r.order.Uint16(r.bytes(2)) }
+func (r *reader) u32() uint32 { return r.order.Uint32(r.bytes(4)) }
+func (r *reader) u64() uint64 { return r.order.Uint64(r.bytes(8)) }
+
+// addr reads an address of ptrSize bytes.
+func (r *reader) addr(ptrSize uint) uint {
+	if ptrSize == 8 {
+		return uint(r.u64())
+	}
+	return uint(r.u32())
+}
+
+func (r *reader) uleb() uint64 {
+	var x uint64
+	for shift := uint(0); ; shift += 7 {
+		b := r.u8()
+		x |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 || r.err != nil {
+			return x
+		}
+	}
+}
+
+func (r *reader) sleb() int64 {
+	var x int64
+	shift := uint(0)
+	for {
+		b := r.u8()
+		x |= int64(b&0x7f) << shift
+		shift += 7
+		if b&0x80 == 0 || r.err != nil {
+			if shift < 64 && b&0x40 != 0 {
+				x |= -1 << shift
+			}
+			return x
+		}
+	}
+}
+
+func (r *reader) cstring() string {
+	start := r.ofs
+	for r.err == nil && r.u8() != 0 {
+	}
+	if r.err != nil {
+		return ""
+	}
+	return string(r.data[start : r.ofs-1])
+}
+
+//-----------------------------------------------------------------------------
+
+// pointer encodings (.eh_frame)
+const (
+	pePtr     = 0x00
+	peUleb128 = 0x01
+	peUdata2  = 0x02
+	peUdata4  = 0x03
+	peUdata8  = 0x04
+	peSleb128 = 0x09
+	peSdata2  = 0x0a
+	peSdata4  = 0x0b
+	peSdata8  = 0x0c
+	pePcrel   = 0x10
+	peOmit    = 0xff
+)
+
+// frameParser parses a call frame section.
+type frameParser struct {
+	r       *reader
+	addr    uint // section address
+	eh      bool // .eh_frame format
+	ptrSize uint // address size in bytes
+	cies    map[int]*cie
+}
+
+// pointer reads an encoded pointer.
+func (p *frameParser) pointer(enc byte) uint {
+	r := p.r
+	if !p.eh {
+		enc = pePtr
+	}
+	if enc == peOmit {
+		return 0
+	}
+	pc := p.addr + uint(r.ofs)
+	var x uint
+	switch enc & 0x0f {
+	case pePtr:
+		x = r.addr(p.ptrSize)
+	case peUleb128:
+		x = uint(r.uleb())
+	case peUdata2:
+		x = uint(r.u16())
+	case peUdata4:
+		x = uint(r.u32())
+	case peUdata8:
+		x = uint(r.u64())
+	case peSleb128:
+		x = uint(r.sleb())
+	case peSdata2:
+		x = uint(int16(r.u16()))
+	case peSdata4:
+		x = uint(int32(r.u32()))
+	case peSdata8:
+		x = uint(r.u64())
+	default:
+		r.err = fmt.Errorf("unsupported pointer encoding 0x%02x", enc)
+	}
+	switch enc & 0x70 {
+	case 0:
+	case pePcrel:
+		x += pc
+	default:
+		r.err = fmt.Errorf("unsupported pointer encoding 0x%02x", enc)
+	}
+	if p.ptrSize == 4 {
+		x &= 0xffffffff
+	}
+	return x
+}
+
+// header reads the length and id of an entry. It returns the entry end, and the offset of the id.
+func (p *frameParser) header() (end, idOfs int, id uint64) {
+	r := p.r
+	length := uint64(r.u32())
+	is64 := length == 0xffffffff
+	if is64 {
+		length = r.u64()
+	}
+	end = r.ofs + int(length)
+	idOfs = r.ofs
+	if length == 0 {
+		return
+	}
+	if is64 {
+		id = r.u64()
+		if !p.eh && id == 0xffffffffffffffff {
+			id = 0xffffffff
+		}
+	} else {
+		id = uint64(r.u32())
+	}
+	return
+}
+
+// isCIE returns true if the entry id is for a cie.
+func (p *frameParser) isCIE(id uint64) bool {
+	if p.eh {
+		return id == 0
+	}
+	return id == 0xffffffff
+}
+
+// cie returns the cie at an offset.
+func (p *frameParser) cie(ofs int) (*cie, error) {
+	if c, ok := p.cies[ofs]; ok {
+		return c, nil
+	}
+	r := p.r
+	if ofs < 0 || ofs >= len(r.data) {
+		return nil, fmt.Errorf("bad cie offset 0x%x", ofs)
+	}
+	save := r.ofs
+	defer func() { r.ofs = save }()
+	r.ofs = ofs
+
+	end, _, id := p.header()
+	if r.err == nil && !p.isCIE(id) {
+		return nil, fmt.Errorf("no cie at offset 0x%x", ofs)
+	}
+	c := &cie{fdeEnc: pePtr}
+	version := r.u8()
+	aug := r.cstring()
+	if version >= 4 {
+		r.u8() // address_size
+		r.u8() // segment_size
+	}
+	c.codeAlign = r.uleb()
+	c.dataAlign = r.sleb()
+	if version == 1 {
+		c.ra = uint(r.u8())
+	} else {
+		c.ra = uint(r.uleb())
+	}
+	if aug != "" {
+		if aug[0] != 'z' {
+			return nil, fmt.Errorf("unsupported cie augmentation \"%s\"", aug)
+		}
+		c.aug = true
+		n := r.uleb()
+		augEnd := r.ofs + int(n)
+		for _, a := range aug[1:] {
+			switch a {
+			case 'R':
+				c.fdeEnc = r.u8()
+			case 'P':
+				p.pointer(r.u8())
+			case 'L':
+				r.u8()
+			}
+		}
+		r.ofs = augEnd
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if r.ofs > end {
+		return nil, fmt.Errorf("bad cie at offset 0x%x", ofs)
+	}
+	c.ins = r.data[r.ofs:end]
+	p.cies[ofs] = c
+	return c, nil
+}
+
+// NewFrameTable returns the call frame information for a .debug_frame or .eh_frame section.
+func NewFrameTable(data []byte, addr uint, eh bool, order binary.ByteOrder, ptrSize uint) (*FrameTable, error) {
+	p := &frameParser{
+		r:       &reader{data: data, order: order},
+		addr:    addr,
+		eh:      eh,
+		ptrSize: ptrSize,
+		cies:    map[int]*cie{},
+	}
+	r := p.r
+	ft := &FrameTable{order: order, ptrSize: ptrSize}
+	for r.ofs < len(data) {
+		start := r.ofs
+		end, idOfs, id := p.header()
+		if r.err != nil {
+			return nil, r.err
+		}
+		if end == idOfs {
+			// zero length terminator (.eh_frame)
+			if eh {
+				break
+			}
+			continue
+		}
+		if end > len(data) {
+			return nil, fmt.Errorf("bad entry length at offset 0x%x", start)
+		}
+		if p.isCIE(id) {
+			_, err := p.cie(start)
+			if err != nil {
+				return nil, err
+			}
+			r.ofs = end
+			continue
+		}
+		// the cie pointer is relative to the id field for .eh_frame
+		cieOfs := int(id)
+		if eh {
+			cieOfs = idOfs - int(id)
+		}
+		c, err := p.cie(cieOfs)
+		if err != nil {
+			return nil, err
+		}
+		f := &fde{cie: c}
+		f.begin = p.pointer(c.fdeEnc)
+		f.end = f.begin + p.pointer(c.fdeEnc&0x0f)
+		if c.aug {
+			n := r.uleb()
+			r.ofs += int(n)
+		}
+		if r.err != nil {
+			return nil, r.err
+		}
+		if r.ofs > end {
+			return nil, fmt.Errorf("bad fde at offset 0x%x", start)
+		}
+		f.ins = data[r.ofs:end]
+		if f.begin != f.end {
+			ft.fdes = append(ft.fdes, f)
+		}
+		r.ofs = end
+	}
+	sort.Slice(ft.fdes, func(i, j int) bool {
+		return ft.fdes[i].begin < ft.fdes[j].begin
+	})
+	return ft, nil
+}
+
+//-----------------------------------------------------------------------------
+
+// call frame instructions
+const (
+	cfaAdvanceLoc        = 0x40 // high 2 bits
+	cfaOffset            = 0x80 // high 2 bits
+	cfaRestore           = 0xc0 // high 2 bits
+	cfaNop               = 0x00
+	cfaSetLoc            = 0x01
+	cfaAdvanceLoc1       = 0x02
+	cfaAdvanceLoc2       = 0x03
+	cfaAdvanceLoc4       = 0x04
+	cfaOffsetExtended    = 0x05
+	cfaRestoreExtended   = 0x06
+	cfaUndefined         = 0x07
+	cfaSameValue         = 0x08
+	cfaRegister          = 0x09
+	cfaRememberState     = 0x0a
+	cfaRestoreState      = 0x0b
+	cfaDefCfa            = 0x0c
+	cfaDefCfaRegister    = 0x0d
+	cfaDefCfaOffset      = 0x0e
+	cfaDefCfaExpression  = 0x0f
+	cfaExpression        = 0x10
+	cfaOffsetExtendedSf  = 0x11
+	cfaDefCfaSf          = 0x12
+	cfaDefCfaOffsetSf    = 0x13
+	cfaValOffset         = 0x14
+	cfaValOffsetSf       = 0x15
+	cfaValExpression     = 0x16
+	cfaGnuArgsSize       = 0x2e
+	cfaGnuNegOffsetExtSf = 0x2f
+)
+
+// frameState is the state for running call frame instructions.
+type frameState struct {
+	cfaReg uint
+	cfaOfs int64
+	regs   map[uint]Rule
+}
+
+func (s *frameState) copy() *frameState {
+	x := &frameState{s.cfaReg, s.cfaOfs, map[uint]Rule{}}
+	for k, v := range s.regs {
+		x.regs[k] = v
+	}
+	return x
+}
+
+// execute runs call frame instructions up to the pc.
+func (ft *FrameTable) execute(c *cie, ins []byte, loc, pc uint, s, initial *frameState) error {
+	r := &reader{data: ins, order: ft.order}
+	stack := []*frameState{}
+	// advance the location, stop when past the pc
+	advance := func(delta uint64) bool {
+		loc += uint(delta * c.codeAlign)
+		return loc > pc
+	}
+	for r.ofs < len(ins) && r.err == nil {
+		op := r.u8()
+		switch op & 0xc0 {
+		case cfaAdvanceLoc:
+			if advance(uint64(op & 0x3f)) {
+				return nil
+			}
+			continue
+		case cfaOffset:
+			s.regs[uint(op&0x3f)] = Rule{Kind: RuleOffset, Offset: int64(r.uleb()) * c.dataAlign}
+			continue
+		case cfaRestore:
+			s.regs[uint(op&0x3f)] = initial.regs[uint(op&0x3f)]
+			continue
+		}
+		switch op {
+		case cfaNop:
+		case cfaSetLoc:
+			loc = r.addr(ft.ptrSize)
+			if loc > pc {
+				return nil
+			}
+		case cfaAdvanceLoc1:
+			if advance(uint64(r.u8())) {
+				return nil
+			}
+		case cfaAdvanceLoc2:
+			if advance(uint64(r.u16())) {
+				return nil
+			}
+		case cfaAdvanceLoc4:
+			if advance(uint64(r.u32())) {
+				return nil
+			}
+		case cfaOffsetExtended:
+			reg := uint(r.uleb())
+			s.regs[reg] = Rule{Kind: RuleOffset, Offset: int64(r.uleb()) * c.dataAlign}
+		case cfaOffsetExtendedSf:
+			reg := uint(r.uleb())
+			s.regs[reg] = Rule{Kind: RuleOffset, Offset: r.sleb() * c.dataAlign}
+		case cfaGnuNegOffsetExtSf:
+			reg := uint(r.uleb())
+			s.regs[reg] = Rule{Kind: RuleOffset, Offset: -int64(r.uleb()) * c.dataAlign}
+		case cfaValOffset:
+			reg := uint(r.uleb())
+			s.regs[reg] = Rule{Kind: RuleValOffset, Offset: int64(r.uleb()) * c.dataAlign}
+		case cfaValOffsetSf:
+			reg := uint(r.uleb())
+			s.regs[reg] = Rule{Kind: RuleValOffset, Offset: r.sleb() * c.dataAlign}
+		case cfaRestoreExtended:
+			reg := uint(r.uleb())
+			s.regs[reg] = initial.regs[reg]
+		case cfaUndefined:
+			s.regs[uint(r.uleb())] = Rule{Kind: RuleUndefined}
+		case cfaSameValue:
+			s.regs[uint(r.uleb())] = Rule{Kind: RuleSameValue}
+		case cfaRegister:
+			reg := uint(r.uleb())
+			s.regs[reg] = Rule{Kind: RuleRegister, Reg: uint(r.uleb())}
+		case cfaRememberState:
+			stack = append(stack, s.copy())
+		case cfaRestoreState:
+			if len(stack) == 0 {
+				return errors.New("cfa state stack underflow")
+			}
+			*s = *stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+		case cfaDefCfa:
+			s.cfaReg = uint(r.uleb())
+			s.cfaOfs = int64(r.uleb())
+		case cfaDefCfaSf:
+			s.cfaReg = uint(r.uleb())
+			s.cfaOfs = r.sleb() * c.dataAlign
+		case cfaDefCfaRegister:
+			s.cfaReg = uint(r.uleb())
+		case cfaDefCfaOffset:
+			s.cfaOfs = int64(r.uleb())
+		case cfaDefCfaOffsetSf:
+			s.cfaOfs = r.sleb() * c.dataAlign
+		case cfaGnuArgsSize:
+			r.uleb()
+		case cfaDefCfaExpression, cfaExpression, cfaValExpression:
+			return errors.New("dwarf expressions are not supported")
+		default:
+			return fmt.Errorf("unknown call frame instruction 0x%02x", op)
+		}
+	}
+	return r.err
+}
+
+// Lookup returns the unwind information for a pc (nil == no information).
+func (ft *FrameTable) Lookup(pc uint) (*Frame, error) {
+	i := sort.Search(len(ft.fdes), func(i int) bool {
+		return ft.fdes[i].begin > pc
+	}) - 1
+	if i < 0 || pc >= ft.fdes[i].end {
+		return nil, nil
+	}
+	f := ft.fdes[i]
+	s := &frameState{regs: map[uint]Rule{}}
+	err := ft.execute(f.cie, f.cie.ins, f.begin, pc, s, s)
+	if err != nil {
+		return nil, err
+	}
+	initial := s.copy()
+	err = ft.execute(f.cie, f.ins, f.begin, pc, s, initial)
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{
+		CFAReg:    s.cfaReg,
+		CFAOffset: s.cfaOfs,
+		RA:        f.cie.ra,
+		Regs:      s.regs,
+	}, nil
+}
+
+//-----------------------------------------------------------------------------
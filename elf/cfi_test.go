@@ -0,0 +1,75 @@
+//-----------------------------------------------------------------------------
+/*
+
+Call Frame Information Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package elf
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+
+// debugFrame is a .debug_frame section for a function at 0x1000.
+var debugFrame = []byte{
+	// cie
+	12, 0, 0, 0, // length
+	0xff, 0xff, 0xff, 0xff, // cie id
+	1,          // version
+	0,          // augmentation ""
+	1,          // code alignment
+	0x7c,       // data alignment (-4)
+	1,          // return address column (ra)
+	0x0c, 2, 0, // def_cfa sp, 0
+	// fde
+	22, 0, 0, 0, // length
+	0, 0, 0, 0, // cie pointer
+	0x00, 0x10, 0, 0, // initial location 0x1000
+	0x20, 0, 0, 0, // address range 0x20
+	0x42,       // advance_loc 2
+	0x0e, 0x10, // def_cfa_offset 16
+	0x81, 0x01, // offset ra, cfa-4
+	0x88, 0x02, // offset s0, cfa-8
+	0x44,       // advance_loc 4
+	0x0d, 0x08, // def_cfa_register s0
+}
+
+func Test_Lookup(t *testing.T) {
+	ft, err := NewFrameTable(debugFrame, 0, false, binary.LittleEndian, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := map[uint]Rule{
+		1: {Kind: RuleOffset, Offset: -4},
+		8: {Kind: RuleOffset, Offset: -8},
+	}
+	test := []struct {
+		pc    uint
+		frame *Frame
+	}{
+		{0x0ffe, nil},
+		{0x1000, &Frame{2, 0, 1, map[uint]Rule{}}},
+		{0x1001, &Frame{2, 0, 1, map[uint]Rule{}}},
+		{0x1002, &Frame{2, 16, 1, saved}},
+		{0x1006, &Frame{8, 16, 1, saved}},
+		{0x101f, &Frame{8, 16, 1, saved}},
+		{0x1020, nil},
+	}
+	for _, v := range test {
+		fr, err := ft.Lookup(v.pc)
+		if err != nil {
+			t.Fatalf("pc 0x%x: %v", v.pc, err)
+		}
+		if !reflect.DeepEqual(fr, v.frame) {
+			t.Errorf("pc 0x%x: expected %+v, got %+v", v.pc, v.frame, fr)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,68 @@
+//-----------------------------------------------------------------------------
+/*
+
+ELF File Menu Items
+
+*/
+//-----------------------------------------------------------------------------
+
+package elf
+
+import (
+	"errors"
+	"fmt"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+func (fi *fileInfo) String() string {
+	yn := []string{"no", "yes"}
+	s := [][]string{}
+	s = append(s, []string{"file", fi.Name})
+	s = append(s, []string{"class", fmt.Sprintf("elf%d", fi.Class)})
+	s = append(s, []string{"entry", fmt.Sprintf("0x%x", fi.Entry)})
+	s = append(s, []string{"symbols", fmt.Sprintf("%d", fi.Symbols)})
+	s = append(s, []string{"dwarf", yn[util.BoolToInt(fi.Dwarf)]})
+	s = append(s, []string{"frames", fmt.Sprintf("%d", fi.Frames)})
+	return cli.TableString(s, []int{0, 0}, 1)
+}
+
+// ElfHelp is help for the elf command.
+var ElfHelp = []cli.Help{
+	{"<cr>", "display the loaded ELF file"},
+	{"<file>", "load an ELF file (symbols, line numbers, call frame information)"},
+}
+
+// CmdElf loads an ELF file.
+var CmdElf = cli.Leaf{
+	Descr: "load an ELF file",
+	F: func(c *cli.CLI, args []string) {
+		err := cli.CheckArgc(args, []int{0, 1})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		if len(args) == 1 {
+			f, err := Load(args[0])
+			if err != nil {
+				util.PutError(c.User, err)
+				return
+			}
+			current = f
+		}
+		if current == nil {
+			util.PutError(c.User, errors.New("no ELF file loaded"))
+			return
+		}
+		if util.IsJSON(c.User) {
+			util.PutJSON(c.User, current.info())
+			return
+		}
+		c.User.Put(fmt.Sprintf("%s\n", current.info()))
+	},
+}
+
+//-----------------------------------------------------------------------------
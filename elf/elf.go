@@ -0,0 +1,246 @@
+//-----------------------------------------------------------------------------
+/*
+
+ELF Files
+
+Load an ELF file for the target firmware. The symbol table, DWARF line
+information and call frame information (.debug_frame/.eh_frame) are used
+to add source level detail to the debugger output.
+
+*/
+//-----------------------------------------------------------------------------
+
+package elf
+
+import (
+	"debug/dwarf"
+	goelf "debug/elf"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+//-----------------------------------------------------------------------------
+
+// Symbol is a function or object symbol.
+type Symbol struct {
+	Name string `json:"name"`
+	Addr uint   `json:"addr"`
+	Size uint   `json:"size"`
+	Func bool   `json:"func"`
+}
+
+// File is a loaded ELF file.
+type File struct {
+	Name    string      // file name
+	Class   uint        // 32 or 64 bits
+	Machine string      // target machine
+	Entry   uint        // entry point address
+	symbols []*Symbol   // sorted by address
+	dwarf   *dwarf.Data // DWARF debug information (nil == none)
+	frames  *FrameTable // call frame information (nil == none)
+}
+
+// Load loads an ELF file.
+func Load(name string) (*File, error) {
+	f, err := goelf.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ef := &File{
+		Name:    name,
+		Class:   map[goelf.Class]uint{goelf.ELFCLASS32: 32, goelf.ELFCLASS64: 64}[f.Class],
+		Machine: f.Machine.String(),
+		Entry:   uint(f.Entry),
+	}
+	if f.Machine != goelf.EM_RISCV {
+		return nil, fmt.Errorf("%s is not a RISC-V ELF file (%s)", filepath.Base(name), ef.Machine)
+	}
+
+	// symbols
+	syms, err := f.Symbols()
+	if err != nil && err != goelf.ErrNoSymbols {
+		return nil, err
+	}
+	for _, s := range syms {
+		typ := goelf.ST_TYPE(s.Info)
+		if s.Name == "" || s.Section == goelf.SHN_UNDEF || (typ != goelf.STT_FUNC && typ != goelf.STT_OBJECT) {
+			continue
+		}
+		ef.symbols = append(ef.symbols, &Symbol{s.Name, uint(s.Value), uint(s.Size), typ == goelf.STT_FUNC})
+	}
+	sort.SliceStable(ef.symbols, func(i, j int) bool {
+		return ef.symbols[i].Addr < ef.symbols[j].Addr
+	})
+
+	// debug information
+	ef.dwarf, _ = f.DWARF()
+
+	// call frame information, .debug_frame is preferred to .eh_frame
+	for _, name := range []string{".debug_frame", ".eh_frame"} {
+		s := f.Section(name)
+		if s == nil {
+			continue
+		}
+		data, err := s.Data()
+		if err != nil {
+			return nil, err
+		}
+		ef.frames, err = NewFrameTable(data, uint(s.Addr), name == ".eh_frame", f.ByteOrder, ef.Class>>3)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		break
+	}
+
+	return ef, nil
+}
+
+//-----------------------------------------------------------------------------
+
+// Lookup returns the named symbol (nil == not found).
+func (f *File) Lookup(name string) *Symbol {
+	for _, s := range f.symbols {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// SymbolAt returns the symbol containing an address and the offset into it (nil == not found).
+func (f *File) SymbolAt(addr uint) (*Symbol, uint) {
+	i := sort.Search(len(f.symbols), func(i int) bool {
+		return f.symbols[i].Addr > addr
+	})
+	// search back for a symbol containing the address
+	for i--; i >= 0; i-- {
+		s := f.symbols[i]
+		if addr == s.Addr || addr < s.Addr+s.Size {
+			return s, addr - s.Addr
+		}
+		if s.Size != 0 {
+			break
+		}
+	}
+	return nil, 0
+}
+
+// SymbolString returns a "symbol+offset" string for an address ("" == no symbol).
+func (f *File) SymbolString(addr uint) string {
+	s, ofs := f.SymbolAt(addr)
+	if s == nil {
+		return ""
+	}
+	if ofs == 0 {
+		return s.Name
+	}
+	return fmt.Sprintf("%s+0x%x", s.Name, ofs)
+}
+
+// LineAt returns the source file and line number for an address (line 0 == not found).
+func (f *File) LineAt(addr uint) (string, int) {
+	if f.dwarf == nil {
+		return "", 0
+	}
+	cu, err := f.dwarf.Reader().SeekPC(uint64(addr))
+	if err != nil {
+		return "", 0
+	}
+	lr, err := f.dwarf.LineReader(cu)
+	if err != nil || lr == nil {
+		return "", 0
+	}
+	var le dwarf.LineEntry
+	err = lr.SeekPC(uint64(addr), &le)
+	if err != nil || le.File == nil {
+		return "", 0
+	}
+	return le.File.Name, le.Line
+}
+
+// Field is a struct member.
+type Field struct {
+	Offset uint // byte offset within the struct
+	Size   uint // size in bytes
+}
+
+// StructFields returns the members of a named struct type from the DWARF information.
+func (f *File) StructFields(name string) (map[string]Field, error) {
+	if f.dwarf == nil {
+		return nil, errors.New("no dwarf information")
+	}
+	r := f.dwarf.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			break
+		}
+		if e.Tag != dwarf.TagStructType || e.Val(dwarf.AttrName) != name || e.Val(dwarf.AttrDeclaration) != nil {
+			continue
+		}
+		t, err := f.dwarf.Type(e.Offset)
+		if err != nil {
+			return nil, err
+		}
+		st, ok := t.(*dwarf.StructType)
+		if !ok {
+			break
+		}
+		fields := map[string]Field{}
+		for _, x := range st.Field {
+			fields[x.Name] = Field{uint(x.ByteOffset), uint(x.Type.Size())}
+		}
+		return fields, nil
+	}
+	return nil, fmt.Errorf("no struct \"%s\" in the dwarf information", name)
+}
+
+// Frames returns the call frame information (nil == none).
+func (f *File) Frames() *FrameTable {
+	return f.frames
+}
+
+//-----------------------------------------------------------------------------
+
+// fileInfo is the summary information for a loaded ELF file.
+type fileInfo struct {
+	Name    string `json:"name"`
+	Class   uint   `json:"class"`
+	Entry   uint   `json:"entry"`
+	Symbols int    `json:"symbols"`
+	Dwarf   bool   `json:"dwarf"`
+	Frames  int    `json:"frames"`
+}
+
+func (f *File) info() *fileInfo {
+	fi := &fileInfo{
+		Name:    f.Name,
+		Class:   f.Class,
+		Entry:   f.Entry,
+		Symbols: len(f.symbols),
+		Dwarf:   f.dwarf != nil,
+	}
+	if f.frames != nil {
+		fi.Frames = len(f.frames.fdes)
+	}
+	return fi
+}
+
+//-----------------------------------------------------------------------------
+
+// current is the loaded ELF file.
+var current *File
+
+// Current returns the loaded ELF file (nil == no file loaded).
+func Current() *File {
+	return current
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,337 @@
+//-----------------------------------------------------------------------------
+/*
+
+Expression Evaluation
+
+Evaluate integer expressions used as command arguments.
+
+Numbers are hexadecimal with an optional 0x prefix.
+A name is a symbol unless it starts with a digit, so a symbol that looks like
+a hex number (E.g. ADC0, FEED) is found before the number. Use 0FEED or 0xFEED
+for the number.
+$name is the value of a register (E.g. $pc, $sp, $a0, $mstatus).
+name or name.name is the address of a symbol (E.g. a peripheral register).
+*(uint32*)x reads a memory value (uint8, uint16, uint32, uint64), *x is 32-bit.
+
+Operators (lowest to highest precedence): |, &, << >>, + -, * /, unary - ~ *
+
+*/
+//-----------------------------------------------------------------------------
+
+package expr
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	cli "github.com/deadsy/go-cli"
+)
+
+//-----------------------------------------------------------------------------
+
+// Context provides the values for names used within an expression.
+type Context interface {
+	Register(name string) (uint, error)   // return the value of a register
+	Symbol(name string) (uint, error)     // return the address of a symbol
+	RdMem(width, addr uint) (uint, error) // read a width-bit memory value
+}
+
+// Target provides a method for getting the expression context.
+type Target interface {
+	GetExprContext() Context
+}
+
+// GetContext returns the expression context for an object (or nil).
+func GetContext(x interface{}) Context {
+	if t, ok := x.(Target); ok {
+		return t.GetExprContext()
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+// lexer
+
+type tokenType int
+
+const (
+	tokEnd    tokenType = iota // end of input
+	tokNumber                  // number
+	tokReg                     // $register
+	tokSymbol                  // symbol
+	tokOp                      // operator
+	tokCast                    // (uintN*)
+)
+
+type token struct {
+	kind tokenType
+	s    string // token string
+	val  uint   // number value, or cast width
+}
+
+var castWidth = map[string]uint{"uint8": 8, "uint16": 16, "uint32": 32, "uint64": 64}
+
+func isNameChar(c byte) bool {
+	return c == '_' || c == '.' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// lex converts an expression string to a list of tokens.
+func lex(s string) ([]token, error) {
+	t := []token{}
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '<' || c == '>':
+			if i+1 >= len(s) || s[i+1] != c {
+				return nil, fmt.Errorf("bad operator at \"%s\"", s[i:])
+			}
+			t = append(t, token{kind: tokOp, s: s[i : i+2]})
+			i += 2
+		case strings.IndexByte("+-*/&|~)", c) >= 0:
+			t = append(t, token{kind: tokOp, s: s[i : i+1]})
+			i++
+		case c == '(':
+			// is this a cast?
+			j := strings.IndexByte(s[i:], ')')
+			if j > 0 {
+				x := strings.Replace(s[i+1:i+j], " ", "", -1)
+				if strings.HasSuffix(x, "*") {
+					if w, ok := castWidth[strings.TrimSuffix(x, "*")]; ok {
+						t = append(t, token{kind: tokCast, s: s[i : i+j+1], val: w})
+						i += j + 1
+						break
+					}
+				}
+			}
+			t = append(t, token{kind: tokOp, s: "("})
+			i++
+		case c == '$':
+			j := i + 1
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, errors.New("no register name after $")
+			}
+			t = append(t, token{kind: tokReg, s: s[i+1 : j]})
+			i = j
+		case isNameChar(c):
+			j := i
+			for j < len(s) && isNameChar(s[j]) {
+				j++
+			}
+			name := s[i:j]
+			if c >= '0' && c <= '9' {
+				// a number starts with a digit (or 0x)
+				x := strings.TrimPrefix(strings.TrimPrefix(name, "0x"), "0X")
+				val, err := strconv.ParseUint(x, 16, 64)
+				if err != nil {
+					return nil, fmt.Errorf("bad number \"%s\"", name)
+				}
+				t = append(t, token{kind: tokNumber, s: name, val: uint(val)})
+			} else {
+				t = append(t, token{kind: tokSymbol, s: name})
+			}
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected character '%c'", c)
+		}
+	}
+	return append(t, token{kind: tokEnd}), nil
+}
+
+//-----------------------------------------------------------------------------
+// parser
+
+type parser struct {
+	ctx    Context
+	tokens []token
+	idx    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.idx]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.idx]
+	if t.kind != tokEnd {
+		p.idx++
+	}
+	return t
+}
+
+// isOp returns true if the next token is one of the operators.
+func (p *parser) isOp(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.s == op {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+// binary operator precedence levels, lowest first
+var levels = [][]string{
+	{"|"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/"},
+}
+
+func (p *parser) binary(level int) (uint, error) {
+	if level == len(levels) {
+		return p.unary()
+	}
+	x, err := p.binary(level + 1)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op, ok := p.isOp(levels[level]...)
+		if !ok {
+			return x, nil
+		}
+		p.next()
+		y, err := p.binary(level + 1)
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "|":
+			x |= y
+		case "&":
+			x &= y
+		case "<<":
+			x <<= y
+		case ">>":
+			x >>= y
+		case "+":
+			x += y
+		case "-":
+			x -= y
+		case "*":
+			x *= y
+		case "/":
+			if y == 0 {
+				return 0, errors.New("divide by zero")
+			}
+			x /= y
+		}
+	}
+}
+
+func (p *parser) unary() (uint, error) {
+	t := p.peek()
+	if t.kind == tokOp {
+		switch t.s {
+		case "-", "~":
+			p.next()
+			x, err := p.unary()
+			if err != nil {
+				return 0, err
+			}
+			if t.s == "-" {
+				return -x, nil
+			}
+			return ^x, nil
+		case "*":
+			p.next()
+			width := uint(32)
+			if p.peek().kind == tokCast {
+				width = p.next().val
+			}
+			addr, err := p.unary()
+			if err != nil {
+				return 0, err
+			}
+			return p.ctx.RdMem(width, addr)
+		}
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (uint, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return t.val, nil
+	case tokReg:
+		return p.ctx.Register(t.s)
+	case tokSymbol:
+		x, err := p.ctx.Symbol(t.s)
+		if err != nil {
+			// not a symbol, is it a hex number without a leading digit?
+			if val, err := strconv.ParseUint(t.s, 16, 64); err == nil {
+				return uint(val), nil
+			}
+		}
+		return x, err
+	case tokCast:
+		return 0, fmt.Errorf("cast \"%s\" without dereference", t.s)
+	case tokOp:
+		if t.s == "(" {
+			x, err := p.binary(0)
+			if err != nil {
+				return 0, err
+			}
+			if _, ok := p.isOp(")"); !ok {
+				return 0, errors.New("missing )")
+			}
+			p.next()
+			return x, nil
+		}
+		return 0, fmt.Errorf("unexpected \"%s\"", t.s)
+	}
+	return 0, errors.New("unexpected end of expression")
+}
+
+//-----------------------------------------------------------------------------
+
+// Eval evaluates an expression string.
+func Eval(ctx Context, s string) (uint, error) {
+	tokens, err := lex(s)
+	if err != nil {
+		return 0, err
+	}
+	p := &parser{
+		ctx:    ctx,
+		tokens: tokens,
+	}
+	x, err := p.binary(0)
+	if err != nil {
+		return 0, err
+	}
+	if t := p.peek(); t.kind != tokEnd {
+		return 0, fmt.Errorf("unexpected \"%s\"", t.s)
+	}
+	return x, nil
+}
+
+// UintArg converts an expression argument to a uint value within the limits.
+// Without a context the argument must be a hex number.
+func UintArg(ctx Context, arg string, limits [2]uint) (uint, error) {
+	if ctx == nil {
+		return cli.UintArg(arg, limits, 16)
+	}
+	x, err := Eval(ctx, arg)
+	if err != nil {
+		return 0, fmt.Errorf("\"%s\": %s", arg, err)
+	}
+	if x < limits[0] || x > limits[1] {
+		return 0, fmt.Errorf("%x is out of range (%x..%x)", x, limits[0], limits[1])
+	}
+	return x, nil
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,94 @@
+//-----------------------------------------------------------------------------
+/*
+
+Expression Evaluation Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package expr
+
+import (
+	"fmt"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+
+type testContext struct{}
+
+func (ctx *testContext) Register(name string) (uint, error) {
+	if name == "sp" {
+		return 0x80001000, nil
+	}
+	return 0, fmt.Errorf("no register %s", name)
+}
+
+func (ctx *testContext) Symbol(name string) (uint, error) {
+	switch name {
+	case "uart0.ctrl":
+		return 0x38000008, nil
+	case "ADC0", "feed":
+		return 0x40012400, nil
+	}
+	return 0, fmt.Errorf("no symbol %s", name)
+}
+
+func (ctx *testContext) RdMem(width, addr uint) (uint, error) {
+	return (addr + 1) & ((1 << width) - 1), nil
+}
+
+func Test_Eval(t *testing.T) {
+	tests := []struct {
+		s   string
+		val uint
+		err bool
+	}{
+		{"10", 0x10, false},
+		{"0x10", 0x10, false},
+		{"1+2*3", 7, false},
+		{"(1+2)*3", 9, false},
+		{"1<<4|1", 0x11, false},
+		{"ff&0f", 0xf, false},
+		{"100/10-1", 0xf, false},
+		{"-1+2", 1, false},
+		{"~0&ff", 0xff, false},
+		{"$sp+0x10", 0x80001010, false},
+		{"uart0.ctrl", 0x38000008, false},
+		{"ADC0", 0x40012400, false},
+		{"ADC0+4", 0x40012404, false},
+		{"feed", 0x40012400, false},
+		{"0feed", 0xfeed, false},
+		{"0xfeed", 0xfeed, false},
+		{"fee", 0xfee, false},
+		{"xyz", 0, true},
+		{"*(uint8*)0xff", 0, false},
+		{"*(uint32*)($sp-1)", 0x80001000, false},
+		{"*10", 0x11, false},
+		{"$xyz", 0, true},
+		{"1/0", 0, true},
+		{"(1+2", 0, true},
+		{"1 2", 0, true},
+		{"1g", 0, true},
+		{"", 0, true},
+	}
+	ctx := &testContext{}
+	for _, v := range tests {
+		val, err := Eval(ctx, v.s)
+		if v.err {
+			if err == nil {
+				t.Errorf("%q: expected an error", v.s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error %s", v.s, err)
+			continue
+		}
+		if val != v.val {
+			t.Errorf("%q: expected %x, got %x", v.s, v.val, val)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -26,6 +26,7 @@ type Driver interface {
 	GetSectors() []*mem.Region            // return the set of flash sectors
 	Erase(r *mem.Region) error            // erase a flash sector
 	EraseAll() error                      // erase all of the flash
+	Write(addr uint, buf []byte) error    // write a buffer to erased flash
 }
 
 // target provides a method for getting the Flash driver.
@@ -87,7 +88,7 @@ var cmdErase = cli.Leaf{
 			c.User.Put("erase all: ")
 			err := drv.EraseAll()
 			if err != nil {
-				c.User.Put(fmt.Sprintf("%s\n", err))
+				util.PutError(c.User, err)
 			} else {
 				c.User.Put("done\n")
 			}
@@ -96,7 +97,7 @@ var cmdErase = cli.Leaf{
 		// get the memory region
 		r, err := mem.RegionArg(drv, args)
 		if err != nil {
-			c.User.Put(fmt.Sprintf("%s\n", err))
+			util.PutError(c.User, err)
 			return
 		}
 		// build a list of the flash sectors to be erased
@@ -122,6 +123,9 @@ var cmdErase = cli.Leaf{
 		done := c.Loop(func() bool { return eraseLoop(es) }, cli.KeycodeCtrlD)
 		es.progress.Erase()
 		status := []string{"abort", "done"}[util.BoolToInt(done)]
+		if !done || len(es.errors) != 0 {
+			util.SetError(c.User, fmt.Errorf("erase %s (%d errors)", status, len(es.errors)))
+		}
 		c.User.Put(fmt.Sprintf("%s (%d errors)\n", status, len(es.errors)))
 	},
 }
@@ -130,6 +134,12 @@ var cmdInfo = cli.Leaf{
 	Descr: "display flash info",
 	F: func(c *cli.CLI, args []string) {
 		drv := c.User.(target).GetFlashDriver()
+		if util.IsJSON(c.User) {
+			for _, r := range drv.GetSectors() {
+				util.PutJSON(c.User, r.Info())
+			}
+			return
+		}
 		s := [][]string{}
 		for _, r := range drv.GetSectors() {
 			s = append(s, r.ColString())
@@ -12,6 +12,7 @@ import (
 	"fmt"
 
 	"github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/util"
 )
 
 //-----------------------------------------------------------------------------
@@ -53,12 +54,12 @@ var cmdClr = cli.Leaf{
 		drv := c.User.(target).GetGpioDriver()
 		port, bit, err := gpioArg(drv, args)
 		if err != nil {
-			c.User.Put(fmt.Sprintf("%s\n", err))
+			util.PutError(c.User, err)
 			return
 		}
 		err = drv.Clr(port, bit)
 		if err != nil {
-			c.User.Put(fmt.Sprintf("%s\n", err))
+			util.PutError(c.User, err)
 		}
 	},
 }
@@ -69,12 +70,12 @@ var cmdSet = cli.Leaf{
 		drv := c.User.(target).GetGpioDriver()
 		port, bit, err := gpioArg(drv, args)
 		if err != nil {
-			c.User.Put(fmt.Sprintf("%s\n", err))
+			util.PutError(c.User, err)
 			return
 		}
 		err = drv.Set(port, bit)
 		if err != nil {
-			c.User.Put(fmt.Sprintf("%s\n", err))
+			util.PutError(c.User, err)
 		}
 	},
 }
@@ -166,6 +166,29 @@ func (ch *Chain) String() string {
 	return strings.Join(s, "\n")
 }
 
+// DeviceState is the state of a device on the JTAG chain.
+type DeviceState struct {
+	Index  int    `json:"index"`
+	Name   string `json:"name"`
+	IRLen  int    `json:"irlen"`
+	IDCode uint   `json:"idcode"`
+}
+
+// ChainState is the state of the JTAG chain.
+type ChainState struct {
+	IRLen   int           `json:"irlen"`
+	Devices []DeviceState `json:"devices"`
+}
+
+// State returns the state of the JTAG chain.
+func (ch *Chain) State() *ChainState {
+	cs := &ChainState{IRLen: ch.irlen}
+	for _, d := range ch.dev {
+		cs.Devices = append(cs.Devices, DeviceState{d.idx, d.name, d.irlen, uint(d.idcode)})
+	}
+	return cs
+}
+
 // readIDcodes returns a slice of idcodes for the JTAG chain.
 func (ch *Chain) readIDCodes() ([]uint, error) {
 	// a TAP reset leaves the idcodes in the DR chain
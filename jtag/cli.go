@@ -12,6 +12,7 @@ import (
 	"fmt"
 
 	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/util"
 )
 
 //-----------------------------------------------------------------------------
@@ -27,6 +28,10 @@ var cmdJtagChain = cli.Leaf{
 	Descr: "display jtag chain state",
 	F: func(c *cli.CLI, args []string) {
 		chain := c.User.(target).GetJtagDevice().chain
+		if util.IsJSON(c.User) {
+			util.PutJSON(c.User, chain.State())
+			return
+		}
 		c.User.Put(fmt.Sprintf("%s\n", chain))
 	},
 }
@@ -11,6 +11,7 @@ package jtag
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/deadsy/rvdbg/bitstr"
 )
@@ -51,6 +52,11 @@ func (dev *Device) String() string {
 	return fmt.Sprintf("device %d: %s irlen %d %s", dev.idx, dev.name, dev.irlen, dev.idcode)
 }
 
+// SystemReset pulses the system reset line (SRST) of the JTAG driver.
+func (dev *Device) SystemReset(delay time.Duration) error {
+	return dev.drv.SystemReset(delay)
+}
+
 // WrIR writes to IR for a device
 func (dev *Device) WrIR(wr *bitstr.BitString) error {
 	// place other devices into bypass mode (IR = all 1's)
@@ -9,12 +9,17 @@ Memory Menu Items
 package mem
 
 import (
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
+	"os"
+	"strings"
 	"time"
 
 	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/expr"
 	"github.com/deadsy/rvdbg/util"
 )
 
@@ -22,7 +27,7 @@ import (
 
 var helpMemRegion = []cli.Help{
 	{"<addr/name> [len]", "memory region"},
-	{"  addr", "address (hex), default is 0"},
+	{"  addr", "address (expression), default is 0"},
 	{"  name", "region name (string), see \"map\" command"},
 	{"  len", "length (hex), defaults to region size or 0x100"},
 }
@@ -66,7 +71,7 @@ func display(c *cli.CLI, args []string, width uint) {
 	drv := c.User.(target).GetMemoryDriver()
 	r, err := RegionArg(drv, args)
 	if err != nil {
-		c.User.Put(fmt.Sprintf("%s\n", err))
+		util.PutError(c.User, err)
 		return
 	}
 	// read from memory, write to the display
@@ -77,7 +82,7 @@ func display(c *cli.CLI, args []string, width uint) {
 	}
 	c.Loop(func() bool { return copyLoop(cs) }, cli.KeycodeCtrlD)
 	if cs.err != nil {
-		c.User.Put(fmt.Sprintf("%s\n", cs.err))
+		util.PutError(c.User, cs.err)
 	}
 }
 
@@ -226,12 +231,12 @@ var cmdToFile = cli.Leaf{
 		// process the arguments
 		err := cli.CheckArgc(args, []int{2, 3})
 		if err != nil {
-			c.User.Put(fmt.Sprintf("%s\n", err))
+			util.PutError(c.User, err)
 			return
 		}
 		name, addr, n, err := fileRegionArg(drv, args)
 		if err != nil {
-			c.User.Put(fmt.Sprintf("%s\n", err))
+			util.PutError(c.User, err)
 			return
 		}
 		// round down address to 32-bit byte boundary
@@ -248,7 +253,7 @@ var cmdToFile = cli.Leaf{
 		rd := newMemReader(drv, addr, n, width)
 		wr, err := newFileWriter(name, width)
 		if err != nil {
-			c.User.Put(fmt.Sprintf("unable to open %s (%s)\n", name, err))
+			util.PutError(c.User, fmt.Errorf("unable to open %s (%s)", name, err))
 			return
 		}
 		cs := &copyState{
@@ -266,17 +271,419 @@ var cmdToFile = cli.Leaf{
 
 		// report result
 		if !done {
-			c.User.Put("abort\n")
+			util.PutError(c.User, errors.New("abort"))
 			return
 		}
 		if cs.err != nil {
-			c.User.Put(fmt.Sprintf("error (%s)\n", cs.err))
+			util.PutError(c.User, fmt.Errorf("error (%s)", cs.err))
 			return
 		}
 		c.User.Put("done\n")
 	},
 }
 
+//-----------------------------------------------------------------------------
+// file to memory
+
+var helpMemFromFile = []cli.Help{
+	{"<filename> <addr/name> [len]", "read from file, write to memory"},
+	{"  filename", "filename (string)"},
+	{"  addr", "address (hex)"},
+	{"  name", "region name (string), see \"map\" command"},
+	{"  len", "length (hex), defaults to file size (limited to region size)"},
+}
+
+var cmdFromFile = cli.Leaf{
+	Descr: "read from file, write to memory",
+	F: func(c *cli.CLI, args []string) {
+		drv := c.User.(target).GetMemoryDriver()
+		// process the arguments
+		err := cli.CheckArgc(args, []int{2, 3})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		name, addr, n, err := fileRegionArg(drv, args)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		size := uint(info.Size())
+		if len(args) == 2 && drv.LookupSymbol(args[1]) == nil {
+			// no length or region size given, use the file size
+			n = size
+		}
+		n = min(n, size)
+		if n == 0 {
+			c.User.Put("nothing to write\n")
+			return
+		}
+		// use 32-bit access if we are aligned, otherwise 8-bit
+		var width uint = 8
+		if (addr|n)&3 == 0 {
+			width = 32
+		}
+
+		// read from file, write to memory
+		const readSize = 1024
+		rd, err := newFileReader(name, width)
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("unable to open %s (%s)", name, err))
+			return
+		}
+		rd.setLimit(n)
+		cs := &copyState{
+			rd:       rd,
+			wr:       newMemWriter(drv, addr, width),
+			size:     readSize,
+			progress: util.NewProgress(c.User, rd.totalReads(readSize)),
+		}
+		c.User.Put(fmt.Sprintf("writing memory (ctrl-d to abort): "))
+		cs.progress.Update(0)
+		done := c.Loop(func() bool { return copyLoop(cs) }, cli.KeycodeCtrlD)
+		cs.progress.Erase()
+		rd.Close()
+
+		// report result
+		if !done {
+			util.PutError(c.User, errors.New("abort"))
+			return
+		}
+		if cs.err != nil {
+			util.PutError(c.User, fmt.Errorf("error (%s)", cs.err))
+			return
+		}
+		c.User.Put("done\n")
+
+		// read back from memory and compare with the file
+		rd, err = newFileReader(name, width)
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("unable to open %s (%s)", name, err))
+			return
+		}
+		rd.setLimit(n)
+		mr := newMemReader(drv, addr, n, width)
+		wr := newCmpWriter(rd, addr, width)
+		cs = &copyState{
+			rd:       mr,
+			wr:       wr,
+			size:     readSize,
+			progress: util.NewProgress(c.User, mr.totalReads(readSize)),
+		}
+		c.User.Put(fmt.Sprintf("verifying (ctrl-d to abort): "))
+		cs.progress.Update(0)
+		done = c.Loop(func() bool { return copyLoop(cs) }, cli.KeycodeCtrlD)
+		cs.progress.Erase()
+		rd.Close()
+
+		// report result
+		if !done {
+			util.PutError(c.User, errors.New("abort"))
+			return
+		}
+		if cs.err != nil {
+			util.PutError(c.User, fmt.Errorf("error (%s)", cs.err))
+			return
+		}
+		if wr.count != 0 {
+			util.SetError(c.User, errors.New("verify failed"))
+			c.User.Put(fmt.Sprintf("failed\n%s\n", wr.String(drv.GetAddressSize())))
+			return
+		}
+		c.User.Put("passed\n")
+	},
+}
+
+//-----------------------------------------------------------------------------
+// memory search, fill and compare
+
+// regionPrefixArg converts leading "<addr> <len>" or "<name>" arguments to a memory region.
+// It returns the region and the remaining arguments.
+func regionPrefixArg(drv Driver, args []string) (*Region, []string, error) {
+	if len(args) == 0 {
+		return nil, nil, errors.New("no memory region")
+	}
+	// is the first argument a region name?
+	r := drv.LookupSymbol(args[0])
+	if r != nil {
+		return r, args[1:], nil
+	}
+	if len(args) < 2 {
+		return nil, nil, errors.New("need an address and length")
+	}
+	r, err := RegionArg(drv, args[0:2])
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, args[2:], nil
+}
+
+// copyProgress runs a copy loop with a progress indicator.
+// It returns true if the copy completed without error.
+func copyProgress(c *cli.CLI, msg string, cs *copyState, total int) bool {
+	cs.progress = util.NewProgress(c.User, total)
+	c.User.Put(fmt.Sprintf("%s (ctrl-d to abort): ", msg))
+	cs.progress.Update(0)
+	done := c.Loop(func() bool { return copyLoop(cs) }, cli.KeycodeCtrlD)
+	cs.progress.Erase()
+	if !done {
+		util.PutError(c.User, errors.New("abort"))
+		return false
+	}
+	if cs.err != nil {
+		util.PutError(c.User, fmt.Errorf("error (%s)", cs.err))
+		return false
+	}
+	c.User.Put("done\n")
+	return true
+}
+
+var patternWidth = map[string]uint{"h": 16, "w": 32, "d": 64}
+
+// patternArg converts pattern and mask arguments to (pattern, mask, align) values.
+func patternArg(args []string) ([]uint8, []uint8, uint, error) {
+	err := cli.CheckArgc(args, []int{1, 2})
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	kind, val := "w", args[0]
+	if i := strings.Index(args[0], ":"); i >= 0 {
+		kind, val = args[0][:i], args[0][i+1:]
+	}
+	var pattern, mask []uint8
+	var align uint = 1
+	switch kind {
+	case "s":
+		if len(args) == 2 {
+			return nil, nil, 0, errors.New("a string pattern can't be masked")
+		}
+		pattern = []uint8(val)
+	case "b":
+		pattern, err = hex.DecodeString(val)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("bad byte string \"%s\"", val)
+		}
+		if len(args) == 2 {
+			mask, err = hex.DecodeString(args[1])
+			if err != nil || len(mask) != len(pattern) {
+				return nil, nil, 0, fmt.Errorf("bad byte mask \"%s\"", args[1])
+			}
+		}
+	case "h", "w", "d":
+		width := patternWidth[kind]
+		maxVal := uint((1 << width) - 1)
+		x, err := cli.UintArg(val, [2]uint{0, maxVal}, 16)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		pattern = util.ConvertToUint8(width, []uint{x})
+		if len(args) == 2 {
+			m, err := cli.UintArg(args[1], [2]uint{0, maxVal}, 16)
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			mask = util.ConvertToUint8(width, []uint{m})
+		}
+		align = width >> 3
+	default:
+		return nil, nil, 0, fmt.Errorf("unknown pattern type \"%s\"", kind)
+	}
+	if len(pattern) == 0 {
+		return nil, nil, 0, errors.New("empty pattern")
+	}
+	if mask == nil {
+		mask = make([]uint8, len(pattern))
+		for i := range mask {
+			mask[i] = 0xff
+		}
+	}
+	for i := range pattern {
+		pattern[i] &= mask[i]
+	}
+	return pattern, mask, align, nil
+}
+
+var helpMemFind = []cli.Help{
+	{"<addr len/name> <pattern> [mask]", "find a pattern in memory"},
+	{"  addr", "address (hex)"},
+	{"  len", "length (hex)"},
+	{"  name", "region name (string), see \"map\" command"},
+	{"  pattern", "b:<hex bytes>, h:/w:/d:<16/32/64-bit value>, s:<string>"},
+	{"", "no prefix is a 32-bit value"},
+	{"  mask", "mask (hex), same type as pattern, not for strings"},
+}
+
+var cmdFind = cli.Leaf{
+	Descr: "find a pattern in memory",
+	F: func(c *cli.CLI, args []string) {
+		drv := c.User.(target).GetMemoryDriver()
+		// process the arguments
+		r, args, err := regionPrefixArg(drv, args)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		pattern, mask, align, err := patternArg(args)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		// round down address to 32-bit byte boundary
+		addr := r.addr & ^uint(3)
+		// round up n to an integral multiple of 4 bytes
+		n := (r.addr + r.size - addr + 3) & ^uint(3)
+		// read from memory, write to the pattern finder
+		// the pattern finder only matches within the requested bytes
+		const readSize = 1024
+		const width = 32
+		rd := newMemReader(drv, addr, n, width)
+		wr := newFindWriter(addr, width, pattern, mask, align, r.addr, r.addr+r.size)
+		cs := &copyState{
+			rd:   rd,
+			wr:   wr,
+			size: readSize,
+		}
+		if !copyProgress(c, "searching memory", cs, rd.totalReads(readSize)) {
+			return
+		}
+		c.User.Put(fmt.Sprintf("%s\n", wr.String(drv.GetAddressSize())))
+	},
+}
+
+var helpMemFill = []cli.Help{
+	{"<addr len/name> <value> [width]", "fill memory with a value"},
+	{"  addr", "address (hex)"},
+	{"  len", "length (hex)"},
+	{"  name", "region name (string), see \"map\" command"},
+	{"  value", "fill value (hex)"},
+	{"  width", "8, 16, 32 (default) or 64 bits"},
+}
+
+var cmdFill = cli.Leaf{
+	Descr: "fill memory with a value",
+	F: func(c *cli.CLI, args []string) {
+		drv := c.User.(target).GetMemoryDriver()
+		// process the arguments
+		r, args, err := regionPrefixArg(drv, args)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		err = cli.CheckArgc(args, []int{1, 2})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		width, err := widthArg(args[1:], 32)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		val, err := cli.UintArg(args[0], [2]uint{0, uint((1 << width) - 1)}, 16)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		shift := widthToShift[width]
+		align := uint((1 << shift) - 1)
+		// don't write outside of the requested range
+		if (r.addr|r.size)&align != 0 {
+			util.PutError(c.User, fmt.Errorf("address and length must be %d-bit aligned", width))
+			return
+		}
+		addr, n := r.addr, r.size
+		// read from the fill value, write to memory
+		const readSize = 1024
+		rd := newFillReader(val, n>>shift)
+		cs := &copyState{
+			rd:   rd,
+			wr:   newMemWriter(drv, addr, width),
+			size: readSize,
+		}
+		copyProgress(c, "filling memory", cs, rd.totalReads(readSize))
+	},
+}
+
+var helpMemCompare = []cli.Help{
+	{"<addr len/name> <file/addr>", "compare memory with a file or memory"},
+	{"  addr", "address (hex)"},
+	{"  len", "length (hex)"},
+	{"  name", "region name (string), see \"map\" command"},
+	{"  file", "filename (string)"},
+}
+
+var cmdCompare = cli.Leaf{
+	Descr: "compare memory with a file or memory",
+	F: func(c *cli.CLI, args []string) {
+		drv := c.User.(target).GetMemoryDriver()
+		// process the arguments
+		r, args, err := regionPrefixArg(drv, args)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		err = cli.CheckArgc(args, []int{1})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		addr, n := r.addr, r.size
+		// use 32-bit access if we are aligned, otherwise 8-bit
+		var width uint = 8
+		if (addr|n)&3 == 0 {
+			width = 32
+		}
+		// the expected values come from a file or another memory region
+		var expected util.Reader
+		if _, err := os.Stat(args[0]); err == nil {
+			fr, err := newFileReader(args[0], width)
+			if err != nil {
+				util.PutError(c.User, fmt.Errorf("unable to open %s (%s)", args[0], err))
+				return
+			}
+			defer fr.Close()
+			if uint(fr.size) < n {
+				util.PutError(c.User, fmt.Errorf("%s is shorter than the memory region", args[0]))
+				return
+			}
+			fr.setLimit(n)
+			expected = fr
+		} else {
+			maxAddr := uint((1 << drv.GetAddressSize()) - 1)
+			addr1, err := expr.UintArg(expr.GetContext(drv), args[0], [2]uint{0, maxAddr})
+			if err != nil {
+				util.PutError(c.User, err)
+				return
+			}
+			if (addr1 & 3) != 0 {
+				width = 8
+			}
+			expected = newMemReader(drv, addr1, n, width)
+		}
+		// read from memory, write to the comparator
+		const readSize = 1024
+		rd := newMemReader(drv, addr, n, width)
+		wr := newCmpWriter(expected, addr, width)
+		cs := &copyState{
+			rd:   rd,
+			wr:   wr,
+			size: readSize,
+		}
+		if !copyProgress(c, "comparing memory", cs, rd.totalReads(readSize)) {
+			return
+		}
+		c.User.Put(fmt.Sprintf("%s\n", wr.String(drv.GetAddressSize())))
+		if wr.count != 0 {
+			util.SetError(c.User, errors.New("compare failed"))
+		}
+	},
+}
+
 //-----------------------------------------------------------------------------
 // memory picture
 
@@ -287,7 +694,7 @@ var cmdPic = cli.Leaf{
 		// get the arguments
 		r, err := RegionArg(drv, args)
 		if err != nil {
-			c.User.Put(fmt.Sprintf("%s\n", err))
+			util.PutError(c.User, err)
 			return
 		}
 		// round down address to 32-bit byte boundary
@@ -309,16 +716,53 @@ var cmdPic = cli.Leaf{
 		wr.Close()
 		// report result
 		if !done {
-			c.User.Put("abort\n")
+			util.PutError(c.User, errors.New("abort"))
 			return
 		}
 		if cs.err != nil {
-			c.User.Put(fmt.Sprintf("error (%s)\n", cs.err))
+			util.PutError(c.User, fmt.Errorf("error (%s)", cs.err))
 			return
 		}
 	},
 }
 
+//-----------------------------------------------------------------------------
+// memory access mode
+
+var helpMemMode = []cli.Help{
+	{"[mode]", "set the memory access mode"},
+	{"<cr>", "display the memory access modes"},
+}
+
+var cmdMode = cli.Leaf{
+	Descr: "memory access mode",
+	F: func(c *cli.CLI, args []string) {
+		drv, ok := c.User.(target).GetMemoryDriver().(ModeDriver)
+		if !ok {
+			util.PutError(c.User, errors.New("memory driver has no access modes"))
+			return
+		}
+		err := cli.CheckArgc(args, []int{0, 1})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		if len(args) == 1 {
+			err := drv.SetMode(args[0])
+			if err != nil {
+				util.PutError(c.User, err)
+			}
+			return
+		}
+		s := [][]string{}
+		for _, m := range drv.GetModes() {
+			cur := []string{"", "*"}[util.BoolToInt(m.Name == drv.GetMode())]
+			s = append(s, []string{cur, m.Name, m.Descr})
+		}
+		c.User.Put(fmt.Sprintf("%s\n", cli.TableString(s, []int{0, 0, 0}, 1)))
+	},
+}
+
 //-----------------------------------------------------------------------------
 // memory region checksum
 
@@ -330,7 +774,7 @@ var cmdCheckSum = cli.Leaf{
 		// get the arguments
 		r, err := RegionArg(drv, args)
 		if err != nil {
-			c.User.Put(fmt.Sprintf("%s\n", err))
+			util.PutError(c.User, err)
 			return
 		}
 		// round down address to 32-bit byte boundary
@@ -356,11 +800,11 @@ var cmdCheckSum = cli.Leaf{
 
 		// report result
 		if !done {
-			c.User.Put("abort\n")
+			util.PutError(c.User, errors.New("abort"))
 			return
 		}
 		if cs.err != nil {
-			c.User.Put(fmt.Sprintf("error (%s)\n", cs.err))
+			util.PutError(c.User, fmt.Errorf("error (%s)", cs.err))
 			return
 		}
 		c.User.Put("done\n")
@@ -398,7 +842,7 @@ func cmdTest(c *cli.CLI, args []string, width uint) {
 	// get the arguments
 	r, err := RegionArg(drv, args)
 	if err != nil {
-		c.User.Put(fmt.Sprintf("%s\n", err))
+		util.PutError(c.User, err)
 		return
 	}
 	// round down address to 32-bit byte boundary
@@ -414,7 +858,7 @@ func cmdTest(c *cli.CLI, args []string, width uint) {
 	start := time.Now()
 	err = drv.WrMem(width, addr, wrbuf)
 	if err != nil {
-		c.User.Put(fmt.Sprintf("write error: %s\n", err))
+		util.PutError(c.User, fmt.Errorf("write error: %s", err))
 		return
 	}
 	delta := time.Now().Sub(start)
@@ -423,12 +867,16 @@ func cmdTest(c *cli.CLI, args []string, width uint) {
 	start = time.Now()
 	rdbuf, err := drv.RdMem(width, addr, nx)
 	if err != nil {
-		c.User.Put(fmt.Sprintf("read error: %s\n", err))
+		util.PutError(c.User, fmt.Errorf("read error: %s", err))
 		return
 	}
 	delta = time.Now().Sub(start)
 	c.User.Put(fmt.Sprintf("read %.2f KiB/sec\n", float64(n)/(1024.0*delta.Seconds())))
-	c.User.Put(fmt.Sprintf("read %s write\n", []string{"!=", "=="}[util.BoolToInt(cmpBuf(rdbuf, wrbuf))]))
+	same := cmpBuf(rdbuf, wrbuf)
+	if !same {
+		util.SetError(c.User, errors.New("read != write"))
+	}
+	c.User.Put(fmt.Sprintf("read %s write\n", []string{"!=", "=="}[util.BoolToInt(same)]))
 }
 
 var cmdTest8 = cli.Leaf{
@@ -459,6 +907,272 @@ var cmdTest64 = cli.Leaf{
 	},
 }
 
+//-----------------------------------------------------------------------------
+// memory snapshots
+
+var helpMemSnap = []cli.Help{
+	{"[<snapshot> <addr/name> [len]]", "take a snapshot of a memory region"},
+	{"  snapshot", "snapshot name (string)"},
+	{"  addr", "address (hex), default is 0"},
+	{"  name", "region name (string), see \"map\" command"},
+	{"  len", "length (hex), defaults to region size or 0x100"},
+	{"", "no arguments lists the snapshots"},
+}
+
+var cmdSnap = cli.Leaf{
+	Descr: "take a snapshot of a memory region",
+	F: func(c *cli.CLI, args []string) {
+		drv := c.User.(target).GetMemoryDriver()
+		if len(args) == 0 {
+			c.User.Put(fmt.Sprintf("%s\n", c.User.(snapTarget).GetSnapshots().list(drv.GetAddressSize())))
+			return
+		}
+		name, addr, n, err := fileRegionArg(drv, args)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		// read from memory, write to the snapshot
+		const readSize = 1024
+		snap := newSnapshot(name, addr, n)
+		rd := newMemReader(drv, snap.addr, snap.size, snapWidth)
+		cs := &copyState{
+			rd:   rd,
+			wr:   snap,
+			size: readSize,
+		}
+		if !copyProgress(c, "reading memory", cs, rd.totalReads(readSize)) {
+			return
+		}
+		c.User.(snapTarget).GetSnapshots().add(snap)
+	},
+}
+
+var helpMemDiff = []cli.Help{
+	{"<snapshot>", "compare a snapshot with memory"},
+	{"  snapshot", "snapshot name (string)"},
+}
+
+var cmdDiff = cli.Leaf{
+	Descr: "compare a snapshot with memory",
+	F: func(c *cli.CLI, args []string) {
+		drv := c.User.(target).GetMemoryDriver()
+		err := cli.CheckArgc(args, []int{1})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		snap, err := c.User.(snapTarget).GetSnapshots().lookup(args[0])
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		// read from memory, write to the diff
+		const readSize = 1024
+		rd := newMemReader(drv, snap.addr, snap.size, snapWidth)
+		wr := newDiffWriter(snap)
+		cs := &copyState{
+			rd:   rd,
+			wr:   wr,
+			size: readSize,
+		}
+		if !copyProgress(c, "reading memory", cs, rd.totalReads(readSize)) {
+			return
+		}
+		wr.display(c.User, drv.GetAddressSize())
+	},
+}
+
+var helpMemSnapToFile = []cli.Help{
+	{"<snapshot> <filename>", "write a snapshot to a file"},
+	{"  snapshot", "snapshot name (string)"},
+	{"  filename", "filename (string)"},
+}
+
+var cmdSnapToFile = cli.Leaf{
+	Descr: "write a snapshot to a file",
+	F: func(c *cli.CLI, args []string) {
+		err := cli.CheckArgc(args, []int{2})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		snap, err := c.User.(snapTarget).GetSnapshots().lookup(args[0])
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		wr, err := newFileWriter(args[1], snapWidth)
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("unable to open %s (%s)", args[1], err))
+			return
+		}
+		_, err = wr.Write(snap.data)
+		wr.Close()
+		if err != nil {
+			util.PutError(c.User, fmt.Errorf("error (%s)", err))
+			return
+		}
+		c.User.Put("done\n")
+	},
+}
+
+//-----------------------------------------------------------------------------
+// memory test suite
+
+// widthArg converts an optional argument to a data width.
+func widthArg(args []string, defWidth uint) (uint, error) {
+	if len(args) == 0 {
+		return defWidth, nil
+	}
+	width, err := cli.UintArg(args[0], [2]uint{8, 64}, 10)
+	if err != nil {
+		return 0, err
+	}
+	if _, ok := widthToShift[width]; !ok {
+		return 0, fmt.Errorf("%d-bit width is not supported", width)
+	}
+	return width, nil
+}
+
+// runMemTest sets up and runs a memory test over a region.
+func runMemTest(c *cli.CLI, args []string, name string, setup func(drv Driver, r *Region, args []string) (*memTest, error)) {
+	drv := c.User.(target).GetMemoryDriver()
+	r, args, err := regionPrefixArg(drv, args)
+	if err != nil {
+		util.PutError(c.User, err)
+		return
+	}
+	mt, err := setup(drv, r, args)
+	if err != nil {
+		util.PutError(c.User, err)
+		return
+	}
+	if mt.n == 0 {
+		c.User.Put("nothing to test\n")
+		return
+	}
+	progress := util.NewProgress(c.User, len(mt.steps))
+	c.User.Put(fmt.Sprintf("%s test (ctrl-d to abort): ", name))
+	progress.Update(0)
+	idx := 0
+	done := c.Loop(func() bool {
+		err = mt.run(idx)
+		idx++
+		progress.Update(idx)
+		return err != nil || idx == len(mt.steps)
+	}, cli.KeycodeCtrlD)
+	progress.Erase()
+	// report result
+	if !done {
+		util.PutError(c.User, errors.New("abort"))
+		return
+	}
+	if err != nil {
+		util.PutError(c.User, fmt.Errorf("error (%s)", err))
+		return
+	}
+	c.User.Put("done\n")
+	c.User.Put(fmt.Sprintf("%s\n", mt.fm.String(drv.GetAddressSize())))
+	if mt.fm.count != 0 {
+		util.SetError(c.User, fmt.Errorf("%s test failed (%d faults)", name, mt.fm.count))
+	}
+}
+
+var helpMemTestRegion = []cli.Help{
+	{"<addr len/name> [width]", "memory region"},
+	{"  addr", "address (hex)"},
+	{"  len", "length (hex)"},
+	{"  name", "region name (string), see \"map\" command"},
+	{"  width", "8, 16, 32 (default) or 64 bits"},
+}
+
+var helpMemTestRandom = []cli.Help{
+	{"<addr len/name> [seed]", "memory region"},
+	{"  addr", "address (hex)"},
+	{"  len", "length (hex)"},
+	{"  name", "region name (string), see \"map\" command"},
+	{"  seed", "random seed (decimal), default is time based"},
+}
+
+// regionTest returns a setup function for a region test with an optional width.
+func regionTest(fn func(mt *memTest)) func(drv Driver, r *Region, args []string) (*memTest, error) {
+	return func(drv Driver, r *Region, args []string) (*memTest, error) {
+		err := cli.CheckArgc(args, []int{0, 1})
+		if err != nil {
+			return nil, err
+		}
+		width, err := widthArg(args, 32)
+		if err != nil {
+			return nil, err
+		}
+		mt := newMemTest(drv, r.addr, r.size, width)
+		fn(mt)
+		return mt, nil
+	}
+}
+
+var cmdTestMarch = cli.Leaf{
+	Descr: "march c- test",
+	F: func(c *cli.CLI, args []string) {
+		runMemTest(c, args, "march c-", regionTest(func(mt *memTest) { mt.march() }))
+	},
+}
+
+var cmdTestWalk = cli.Leaf{
+	Descr: "walking 1s/0s data bus test",
+	F: func(c *cli.CLI, args []string) {
+		runMemTest(c, args, "data bus", regionTest(func(mt *memTest) { mt.walk() }))
+	},
+}
+
+var cmdTestAddrBus = cli.Leaf{
+	Descr: "address bus test",
+	F: func(c *cli.CLI, args []string) {
+		runMemTest(c, args, "address bus", regionTest(func(mt *memTest) { mt.addrBus() }))
+	},
+}
+
+var cmdTestAia = cli.Leaf{
+	Descr: "address in address test",
+	F: func(c *cli.CLI, args []string) {
+		runMemTest(c, args, "address in address", regionTest(func(mt *memTest) { mt.aia() }))
+	},
+}
+
+var cmdTestRandom = cli.Leaf{
+	Descr: "random data test",
+	F: func(c *cli.CLI, args []string) {
+		runMemTest(c, args, "random data", func(drv Driver, r *Region, args []string) (*memTest, error) {
+			err := cli.CheckArgc(args, []int{0, 1})
+			if err != nil {
+				return nil, err
+			}
+			seed := time.Now().UnixNano() & 0x7fffffff
+			if len(args) == 1 {
+				x, err := cli.IntArg(args[0], [2]int{0, 0x7fffffff}, 10)
+				if err != nil {
+					return nil, err
+				}
+				seed = int64(x)
+			}
+			c.User.Put(fmt.Sprintf("seed %d\n", seed))
+			mt := newMemTest(drv, r.addr, r.size, 32)
+			mt.random(seed)
+			return mt, nil
+		})
+	},
+}
+
+// testMenu memory test suite submenu items
+var testMenu = cli.Menu{
+	{"addr", cmdTestAddrBus, helpMemTestRegion},
+	{"aia", cmdTestAia, helpMemTestRegion},
+	{"march", cmdTestMarch, helpMemTestRegion},
+	{"rand", cmdTestRandom, helpMemTestRandom},
+	{"walk", cmdTestWalk, helpMemTestRegion},
+}
+
 //-----------------------------------------------------------------------------
 
 // Menu memory submenu items
@@ -479,9 +1193,18 @@ var Menu = cli.Menu{
 	{"wh", cmdWrite16, helpMemWrite},
 	{"ww", cmdWrite32, helpMemWrite},
 	{"wd", cmdWrite64, helpMemWrite},
+	{"<file", cmdFromFile, helpMemFromFile},
 	{">file", cmdToFile, helpMemToFile},
+	{">snap", cmdSnapToFile, helpMemSnapToFile},
+	{"cmp", cmdCompare, helpMemCompare},
+	{"diff", cmdDiff, helpMemDiff},
+	{"fill", cmdFill, helpMemFill},
+	{"find", cmdFind, helpMemFind},
 	{"md5", cmdCheckSum, helpMemRegion},
+	{"mode", cmdMode, helpMemMode},
 	{"pic", cmdPic, helpMemRegion},
+	{"snap", cmdSnap, helpMemSnap},
+	{"test", testMenu, "memory test suite"},
 }
 
 //-----------------------------------------------------------------------------
@@ -34,11 +34,29 @@ type Driver interface {
 	WrMem(width, addr uint, val []uint) error  // write width-bit memory buffer
 }
 
+// Mode is a memory access mode.
+type Mode struct {
+	Name  string // mode name
+	Descr string // description
+}
+
+// ModeDriver is implemented by memory drivers with access modes (E.g. privilege/virtual).
+type ModeDriver interface {
+	GetModes() []Mode          // get the access modes
+	GetMode() string           // get the current access mode
+	SetMode(name string) error // set the access mode
+}
+
 // target provides a method for getting the memory driver.
 type target interface {
 	GetMemoryDriver() Driver
 }
 
+// snapTarget provides a method for getting the memory snapshots.
+type snapTarget interface {
+	GetSnapshots() *Snapshots
+}
+
 //-----------------------------------------------------------------------------
 
 var widthToShift = map[uint]int{8: 0, 16: 1, 32: 2, 64: 3}
@@ -100,6 +118,40 @@ func (mr *memReader) Read(buf []uint) (int, error) {
 	return int(nread) >> mr.shift, mr.err
 }
 
+//-----------------------------------------------------------------------------
+// memory writer
+
+type memWriter struct {
+	drv   Driver // memory driver
+	addr  uint   // address to write to
+	width uint   // data has width-bit values
+	shift int    // shift for width-bits
+}
+
+func newMemWriter(drv Driver, addr, width uint) *memWriter {
+	shift := widthToShift[width]
+	// round down address to be width-bit aligned.
+	align := uint((1 << shift) - 1)
+	return &memWriter{
+		drv:   drv,
+		addr:  addr & ^align,
+		width: width,
+		shift: shift,
+	}
+}
+
+func (mw *memWriter) Write(buf []uint) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	err := mw.drv.WrMem(mw.width, mw.addr, buf)
+	if err != nil {
+		return 0, err
+	}
+	mw.addr += uint(len(buf)) << mw.shift
+	return len(buf), nil
+}
+
 //-----------------------------------------------------------------------------
 // memory picture
 
@@ -214,6 +266,14 @@ type memDisplay struct {
 	addr     uint     // address of memory buffer
 	width    uint     // data has width-bit values
 	shift    int      // shift for width-bits
+	json     bool     // output JSON objects
+}
+
+// memLine is a line of memory display data.
+type memLine struct {
+	Addr  uint   `json:"addr"`
+	Width uint   `json:"width"`
+	Data  []uint `json:"data"`
 }
 
 func newMemDisplay(ui cli.USER, addr, addrWidth, width uint) *memDisplay {
@@ -229,6 +289,7 @@ func newMemDisplay(ui cli.USER, addr, addrWidth, width uint) *memDisplay {
 		addr:     addr,
 		width:    width,
 		shift:    shift,
+		json:     util.IsJSON(ui),
 	}
 }
 
@@ -243,6 +304,12 @@ func (md *memDisplay) Write(buf []uint) (int, error) {
 	perLine := bytesPerLine >> md.shift
 	for i := 0; i < len(buf); i += perLine {
 		lineBuf := buf[i : i+perLine]
+		if md.json {
+			util.PutJSON(md.ui, &memLine{md.addr, md.width, lineBuf})
+			md.addr += bytesPerLine
+			md.addr &= md.addrMask
+			continue
+		}
 		// create the data string
 		xStr := make([]string, perLine)
 		for j := range xStr {
@@ -312,6 +379,7 @@ func (fw *fileWriter) Close() error {
 type fileReader struct {
 	f     *os.File
 	size  int64 // size of file in bytes
+	n     int64 // bytes remaining to be read
 	width uint  // data has width-bit values
 	shift int   // shift for width-bits
 }
@@ -327,38 +395,254 @@ func newFileReader(name string, width uint) (*fileReader, error) {
 		return nil, err
 	}
 	shift := widthToShift[width]
+	size := (info.Size() >> shift) << shift
 	return &fileReader{
 		f:     f,
-		size:  (info.Size() >> shift) << shift,
+		size:  size,
+		n:     size,
 		width: width,
 		shift: shift,
 	}, nil
 }
 
+// setLimit limits the number of bytes read from the file.
+func (fr *fileReader) setLimit(n uint) {
+	fr.n = int64((min(uint(fr.size), n) >> fr.shift) << fr.shift)
+}
+
+// totalReads returns the total number of calls to Read() required.
+func (fr *fileReader) totalReads(size uint) int {
+	bytesPerRead := size << fr.shift
+	return int((uint(fr.n) + bytesPerRead - 1) / bytesPerRead)
+}
+
 func (fr *fileReader) Read(buf []uint) (int, error) {
 	if len(buf) == 0 {
 		return 0, nil
 	}
-	fbuf := make([]byte, len(buf)<<fr.shift)
-	n, err := fr.f.Read(fbuf)
-	if err != nil && err != io.EOF {
-		return 0, err
+	if fr.n == 0 {
+		return 0, io.EOF
 	}
-	if n == 0 {
+	nread := min(uint(fr.n), uint(len(buf)<<fr.shift))
+	fbuf := make([]byte, nread)
+	n, err := io.ReadFull(fr.f, fbuf)
+	if err != nil && err != io.ErrUnexpectedEOF {
 		return 0, err
 	}
+	fr.n -= int64(n)
+	if err == io.ErrUnexpectedEOF {
+		// the file is shorter than we expected
+		fr.n = 0
+	}
 	// resize buffers to an integral number of width-bit values
 	buf = buf[0 : n>>fr.shift]
 	fbuf = fbuf[0 : (n>>fr.shift)<<fr.shift]
 	// convert the file buffer
 	util.ConvertToUint(fr.width, fbuf, buf)
-	return len(buf), err
+	if fr.n == 0 {
+		return len(buf), io.EOF
+	}
+	return len(buf), nil
 }
 
 func (fr *fileReader) Close() error {
 	return fr.f.Close()
 }
 
+//-----------------------------------------------------------------------------
+// compare writer
+
+// mismatch records a difference between expected and actual values.
+type mismatch struct {
+	addr     uint // address of value
+	expected uint // expected value
+	actual   uint // actual value
+}
+
+const maxMismatch = 8 // maximum number of recorded mismatches
+
+type cmpWriter struct {
+	rd       util.Reader // reader for the expected values
+	addr     uint        // address of the next value
+	width    uint        // data has width-bit values
+	shift    int         // shift for width-bits
+	count    uint        // total number of mismatched values
+	mismatch []mismatch  // the first mismatches
+}
+
+func newCmpWriter(rd util.Reader, addr, width uint) *cmpWriter {
+	shift := widthToShift[width]
+	// round down address to be width-bit aligned.
+	align := uint((1 << shift) - 1)
+	return &cmpWriter{
+		rd:    rd,
+		addr:  addr & ^align,
+		width: width,
+		shift: shift,
+	}
+}
+
+func (cw *cmpWriter) Write(buf []uint) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	// read the expected values
+	expected := make([]uint, len(buf))
+	n, err := cw.rd.Read(expected)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	if n != len(buf) {
+		return 0, fmt.Errorf("compare length mismatch at %x", cw.addr)
+	}
+	// compare the values
+	for i := range buf {
+		if buf[i] != expected[i] {
+			if len(cw.mismatch) < maxMismatch {
+				cw.mismatch = append(cw.mismatch, mismatch{cw.addr, expected[i], buf[i]})
+			}
+			cw.count++
+		}
+		cw.addr += 1 << cw.shift
+	}
+	return len(buf), nil
+}
+
+// String returns a report of the compare mismatches.
+func (cw *cmpWriter) String(addrWidth uint) string {
+	if cw.count == 0 {
+		return "no mismatches"
+	}
+	fmtStr := fmt.Sprintf("%s: expected %s actual %s", util.UintFormat(addrWidth), util.UintFormat(cw.width), util.UintFormat(cw.width))
+	s := []string{}
+	for _, m := range cw.mismatch {
+		s = append(s, fmt.Sprintf(fmtStr, m.addr, m.expected, m.actual))
+	}
+	if cw.count > uint(len(cw.mismatch)) {
+		s = append(s, "...")
+	}
+	s = append(s, fmt.Sprintf("%d mismatched %d-bit values", cw.count, cw.width))
+	return strings.Join(s, "\n")
+}
+
+//-----------------------------------------------------------------------------
+// find writer
+
+const maxMatch = 32 // maximum number of recorded pattern matches
+
+type findWriter struct {
+	addr    uint    // address of buf[0]
+	width   uint    // data has width-bit values
+	pattern []uint8 // pattern to search for
+	mask    []uint8 // pattern mask
+	align   uint    // alignment of pattern matches
+	start   uint    // start of the search range
+	end     uint    // end of the search range (exclusive)
+	buf     []uint8 // bytes carried over from the previous write
+	count   uint    // total number of pattern matches
+	match   []uint  // the first pattern match addresses
+}
+
+// newFindWriter returns a pattern finder for memory read from addr.
+// Only matches within the start..end range are reported.
+func newFindWriter(addr, width uint, pattern, mask []uint8, align, start, end uint) *findWriter {
+	return &findWriter{
+		addr:    addr,
+		width:   width,
+		pattern: pattern,
+		mask:    mask,
+		align:   align,
+		start:   start,
+		end:     end,
+	}
+}
+
+// isMatch returns true if the pattern matches the buffer.
+func (fw *findWriter) isMatch(buf []uint8) bool {
+	for i := range fw.pattern {
+		if buf[i]&fw.mask[i] != fw.pattern[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (fw *findWriter) Write(buf []uint) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	data := append(fw.buf, util.ConvertToUint8(fw.width, buf)...)
+	n := len(fw.pattern)
+	i := 0
+	for ; i+n <= len(data); i++ {
+		addr := fw.addr + uint(i)
+		if addr%fw.align != 0 || addr < fw.start || addr+uint(n) > fw.end {
+			continue
+		}
+		if fw.isMatch(data[i:]) {
+			if len(fw.match) < maxMatch {
+				fw.match = append(fw.match, addr)
+			}
+			fw.count++
+		}
+	}
+	// carry over the bytes we haven't been able to check
+	fw.buf = append([]uint8{}, data[i:]...)
+	fw.addr += uint(i)
+	return len(buf), nil
+}
+
+// String returns a report of the pattern matches.
+func (fw *findWriter) String(addrWidth uint) string {
+	s := []string{}
+	for _, addr := range fw.match {
+		s = append(s, fmt.Sprintf(util.UintFormat(addrWidth), addr))
+	}
+	if fw.count > uint(len(fw.match)) {
+		s = append(s, "...")
+	}
+	s = append(s, fmt.Sprintf("%d match(es)", fw.count))
+	return strings.Join(s, "\n")
+}
+
+//-----------------------------------------------------------------------------
+// fill reader
+
+type fillReader struct {
+	val uint // fill value
+	n   uint // number of values remaining
+}
+
+func newFillReader(val, n uint) *fillReader {
+	return &fillReader{
+		val: val,
+		n:   n,
+	}
+}
+
+// totalReads returns the total number of calls to Read() required.
+func (fr *fillReader) totalReads(size uint) int {
+	return int((fr.n + size - 1) / size)
+}
+
+func (fr *fillReader) Read(buf []uint) (int, error) {
+	if len(buf) == 0 {
+		return 0, nil
+	}
+	if fr.n == 0 {
+		return 0, io.EOF
+	}
+	n := min(fr.n, uint(len(buf)))
+	for i := 0; i < int(n); i++ {
+		buf[i] = fr.val
+	}
+	fr.n -= n
+	if fr.n == 0 {
+		return int(n), io.EOF
+	}
+	return int(n), nil
+}
+
 //-----------------------------------------------------------------------------
 // MD5 writer
 
@@ -0,0 +1,78 @@
+//-----------------------------------------------------------------------------
+/*
+
+Memory Test Helpers
+
+Memory is a little endian byte addressed memory that implements mem.Driver.
+It is used to test the users of target memory (E.g. semihosting, rtt, rtos).
+
+*/
+//-----------------------------------------------------------------------------
+
+package memtest
+
+import (
+	"github.com/deadsy/rvdbg/mem"
+)
+
+//-----------------------------------------------------------------------------
+
+// Memory is a little endian byte addressed memory.
+// Unwritten bytes read as zero.
+type Memory map[uint]byte
+
+// GetAddressSize returns the address size in bits.
+func (m Memory) GetAddressSize() uint {
+	return 32
+}
+
+// GetDefaultRegion returns a default memory region.
+func (m Memory) GetDefaultRegion() *mem.Region {
+	return mem.NewRegion("", 0, 0x100, nil)
+}
+
+// LookupSymbol returns a symbol region (always nil).
+func (m Memory) LookupSymbol(name string) *mem.Region {
+	return nil
+}
+
+// RdMem reads a width-bit memory buffer.
+func (m Memory) RdMem(width, addr, n uint) ([]uint, error) {
+	x := make([]uint, n)
+	for i := range x {
+		for j := uint(0); j < width>>3; j++ {
+			x[i] |= uint(m[addr+j]) << (8 * j)
+		}
+		addr += width >> 3
+	}
+	return x, nil
+}
+
+// WrMem writes a width-bit memory buffer.
+func (m Memory) WrMem(width, addr uint, val []uint) error {
+	for _, v := range val {
+		for j := uint(0); j < width>>3; j++ {
+			m[addr+j] = byte(v >> (8 * j))
+		}
+		addr += width >> 3
+	}
+	return nil
+}
+
+// RdBytes reads a byte buffer.
+func (m Memory) RdBytes(addr, n uint) []byte {
+	buf := make([]byte, n)
+	for i := range buf {
+		buf[i] = m[addr+uint(i)]
+	}
+	return buf
+}
+
+// WrBytes writes a byte buffer.
+func (m Memory) WrBytes(addr uint, buf []byte) {
+	for i := range buf {
+		m[addr+uint(i)] = buf[i]
+	}
+}
+
+//-----------------------------------------------------------------------------
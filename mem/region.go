@@ -16,6 +16,7 @@ import (
 	"strings"
 
 	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/expr"
 	"github.com/deadsy/rvdbg/util"
 )
 
@@ -80,6 +81,29 @@ func (r *Region) Overlaps(x *Region) bool {
 	return max(r.addr, x.addr) <= min(r.end, x.end)
 }
 
+// RegionInfo describes a memory region.
+type RegionInfo struct {
+	Name string `json:"name,omitempty"`
+	Addr uint   `json:"addr"`
+	End  uint   `json:"end"`
+	Size uint   `json:"size"`
+	Meta string `json:"meta,omitempty"`
+}
+
+// Info returns a description of the memory region.
+func (r *Region) Info() *RegionInfo {
+	ri := &RegionInfo{
+		Name: r.name,
+		Addr: r.addr,
+		End:  r.end,
+		Size: r.size,
+	}
+	if r.meta != nil {
+		ri.Meta = r.meta.String()
+	}
+	return ri
+}
+
 // ColString returns a 4 string description of the memory region.
 func (r *Region) ColString() []string {
 	fmtAddr := util.UintFormat(r.addrSize)
@@ -133,8 +157,9 @@ func RegionArg(drv RegionDriver, args []string) (*Region, error) {
 	}
 
 	// get the address
+	ctx := expr.GetContext(drv)
 	maxAddr := uint((1 << drv.GetAddressSize()) - 1)
-	addr, err := cli.UintArg(args[0], [2]uint{0, maxAddr}, 16)
+	addr, err := expr.UintArg(ctx, args[0], [2]uint{0, maxAddr})
 	if err != nil {
 		return nil, err
 	}
@@ -144,7 +169,7 @@ func RegionArg(drv RegionDriver, args []string) (*Region, error) {
 	}
 
 	// get the size
-	n, err := cli.UintArg(args[1], [2]uint{1, 0x100000000}, 16)
+	n, err := expr.UintArg(ctx, args[1], [2]uint{1, 0x100000000})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,177 @@
+//-----------------------------------------------------------------------------
+/*
+
+Memory Snapshots
+
+A snapshot is a copy of a memory region held in host memory.
+It can be compared against the current memory contents to find changes.
+
+*/
+//-----------------------------------------------------------------------------
+
+package mem
+
+import (
+	"fmt"
+	"sort"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+const snapWidth = 32                                // snapshots use 32-bit values
+const snapPerLine = bytesPerLine / (snapWidth >> 3) // values per display line
+const maxDiffRanges = 16                            // maximum number of displayed diff ranges
+
+type snapshot struct {
+	name string // snapshot name
+	addr uint   // base address
+	size uint   // size in bytes
+	data []uint // memory contents
+}
+
+func newSnapshot(name string, addr, size uint) *snapshot {
+	// round down address, round up size to be display line aligned.
+	align := uint(bytesPerLine - 1)
+	end := (addr + size + align) & ^align
+	addr &= ^align
+	return &snapshot{
+		name: name,
+		addr: addr,
+		size: end - addr,
+	}
+}
+
+// Write appends memory contents to the snapshot.
+func (s *snapshot) Write(buf []uint) (int, error) {
+	s.data = append(s.data, buf...)
+	return len(buf), nil
+}
+
+//-----------------------------------------------------------------------------
+
+// Snapshots stores the named snapshots. It is embedded in a target.
+type Snapshots struct {
+	snap map[string]*snapshot
+}
+
+// GetSnapshots returns the snapshot store.
+func (s *Snapshots) GetSnapshots() *Snapshots {
+	return s
+}
+
+// add adds a snapshot, replacing any snapshot with the same name.
+func (s *Snapshots) add(snap *snapshot) {
+	if s.snap == nil {
+		s.snap = map[string]*snapshot{}
+	}
+	s.snap[snap.name] = snap
+}
+
+// lookup returns a named snapshot.
+func (s *Snapshots) lookup(name string) (*snapshot, error) {
+	snap, ok := s.snap[name]
+	if !ok {
+		return nil, fmt.Errorf("no snapshot named \"%s\" (run \"snap\" for the names)", name)
+	}
+	return snap, nil
+}
+
+// list returns a string listing the snapshots.
+func (s *Snapshots) list(addrWidth uint) string {
+	if len(s.snap) == 0 {
+		return "no snapshots"
+	}
+	names := make([]string, 0, len(s.snap))
+	for k := range s.snap {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	fmtAddr := util.UintFormat(addrWidth)
+	tbl := [][]string{}
+	for _, k := range names {
+		x := s.snap[k]
+		addrStr := fmt.Sprintf("%s %s", fmt.Sprintf(fmtAddr, x.addr), fmt.Sprintf(fmtAddr, x.addr+x.size-1))
+		tbl = append(tbl, []string{x.name, addrStr, util.MemSize(x.size)})
+	}
+	return cli.TableString(tbl, []int{0, 0, 0}, 1)
+}
+
+//-----------------------------------------------------------------------------
+// diff writer
+
+// diffRange is a range of changed memory display lines.
+type diffRange struct {
+	idx int    // index of first value in the snapshot
+	new []uint // new values
+}
+
+type diffWriter struct {
+	snap   *snapshot    // snapshot being compared
+	idx    int          // index of the next value in the snapshot
+	ranges []*diffRange // changed ranges
+	count  int          // number of changed values
+}
+
+func newDiffWriter(snap *snapshot) *diffWriter {
+	return &diffWriter{
+		snap: snap,
+	}
+}
+
+func (dw *diffWriter) Write(buf []uint) (int, error) {
+	if len(buf)%snapPerLine != 0 {
+		return 0, fmt.Errorf("write buffer must be a multiple of %d values", snapPerLine)
+	}
+	for i := 0; i < len(buf); i += snapPerLine {
+		idx := dw.idx + i
+		old := dw.snap.data[idx : idx+snapPerLine]
+		line := buf[i : i+snapPerLine]
+		changed := false
+		for j := range line {
+			if line[j] != old[j] {
+				dw.count++
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		// extend the current range, or start a new one
+		n := len(dw.ranges)
+		if n != 0 && dw.ranges[n-1].idx+len(dw.ranges[n-1].new) == idx {
+			dw.ranges[n-1].new = append(dw.ranges[n-1].new, line...)
+		} else {
+			dw.ranges = append(dw.ranges, &diffRange{idx, append([]uint{}, line...)})
+		}
+	}
+	dw.idx += len(buf)
+	return len(buf), nil
+}
+
+// display displays the changed memory ranges.
+func (dw *diffWriter) display(ui cli.USER, addrWidth uint) {
+	if dw.count == 0 {
+		ui.Put("no changes\n")
+		return
+	}
+	fmtAddr := util.UintFormat(addrWidth)
+	for i, r := range dw.ranges {
+		if i == maxDiffRanges {
+			ui.Put(fmt.Sprintf("... (%d more ranges)\n", len(dw.ranges)-maxDiffRanges))
+			break
+		}
+		addr := dw.snap.addr + (uint(r.idx) << widthToShift[snapWidth])
+		end := addr + (uint(len(r.new)) << widthToShift[snapWidth]) - 1
+		ui.Put(fmt.Sprintf("%s %s\n", fmt.Sprintf(fmtAddr, addr), fmt.Sprintf(fmtAddr, end)))
+		ui.Put("old:\n")
+		newMemDisplay(ui, addr, addrWidth, snapWidth).Write(dw.snap.data[r.idx : r.idx+len(r.new)])
+		ui.Put("new:\n")
+		newMemDisplay(ui, addr, addrWidth, snapWidth).Write(r.new)
+	}
+	ui.Put(fmt.Sprintf("%d changed %d-bit values in %d range(s)\n", dw.count, snapWidth, len(dw.ranges)))
+}
+
+//-----------------------------------------------------------------------------
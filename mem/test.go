@@ -0,0 +1,377 @@
+//-----------------------------------------------------------------------------
+/*
+
+Memory Tests
+
+These tests are intended for qualifying RAM during board bring-up.
+All memory access is through the memory driver, so most tests are run on
+blocks of memory rather than single cells to keep the debug link busy.
+March C- needs the read/write operations applied cell by cell to detect
+coupling faults, so it is much slower than the other tests.
+
+*/
+//-----------------------------------------------------------------------------
+
+package mem
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+// fault map
+
+const maxFaults = 16       // maximum number of recorded faults
+const faultBlocks = 64     // number of blocks in the fault map
+const testBlockSize = 1024 // number of values in a test block
+
+type fault struct {
+	addr     uint // address of value
+	expected uint // expected value
+	actual   uint // actual value
+}
+
+type faultMap struct {
+	addr   uint    // base address of the tested region
+	n      uint    // size of the tested region in bytes
+	width  uint    // data has width-bit values
+	count  uint    // total number of faults
+	bits   uint    // failing data bits
+	fault  []fault // the first faults
+	blocks []uint  // number of faults in each block of the tested region
+}
+
+func newFaultMap(addr, n, width uint) *faultMap {
+	return &faultMap{
+		addr:   addr,
+		n:      n,
+		width:  width,
+		blocks: make([]uint, faultBlocks),
+	}
+}
+
+// blockSize returns the number of bytes for each block of the fault map.
+func (fm *faultMap) blockSize() uint {
+	return max((fm.n+faultBlocks-1)/faultBlocks, 1)
+}
+
+// add adds a fault to the fault map.
+func (fm *faultMap) add(addr, expected, actual uint) {
+	if len(fm.fault) < maxFaults {
+		fm.fault = append(fm.fault, fault{addr, expected, actual})
+	}
+	fm.count++
+	fm.bits |= expected ^ actual
+	idx := (addr - fm.addr) / fm.blockSize()
+	if idx < faultBlocks {
+		fm.blocks[idx]++
+	}
+}
+
+// check compares actual and expected buffers and records any faults.
+func (fm *faultMap) check(addr uint, expected, actual []uint) {
+	shift := widthToShift[fm.width]
+	for i := range expected {
+		if expected[i] != actual[i] {
+			fm.add(addr+(uint(i)<<shift), expected[i], actual[i])
+		}
+	}
+}
+
+// String returns a report of the memory faults.
+func (fm *faultMap) String(addrWidth uint) string {
+	if fm.count == 0 {
+		return "passed"
+	}
+	s := []string{}
+	fmtStr := fmt.Sprintf("%s: expected %s actual %s", util.UintFormat(addrWidth), util.UintFormat(fm.width), util.UintFormat(fm.width))
+	for _, f := range fm.fault {
+		s = append(s, fmt.Sprintf(fmtStr, f.addr, f.expected, f.actual))
+	}
+	if fm.count > uint(len(fm.fault)) {
+		s = append(s, "...")
+	}
+	s = append(s, fmt.Sprintf("failing bits: "+util.UintFormat(fm.width), fm.bits))
+	// the block map
+	m := make([]rune, faultBlocks)
+	for i, n := range fm.blocks {
+		m[i] = []rune{'.', 'X'}[util.BoolToInt(n != 0)]
+	}
+	s = append(s, fmt.Sprintf("fault map (%s per char): %s", util.MemSize(fm.blockSize()), string(m)))
+	s = append(s, fmt.Sprintf("failed: %d fault(s)", fm.count))
+	return strings.Join(s, "\n")
+}
+
+//-----------------------------------------------------------------------------
+
+// memTest is the state for a memory test.
+type memTest struct {
+	drv   Driver         // memory driver
+	addr  uint           // base address
+	n     uint           // number of width-bit values
+	width uint           // data has width-bit values
+	shift int            // shift for width-bits
+	mask  uint           // mask for width-bits
+	fm    *faultMap      // fault map
+	steps []func() error // test steps
+}
+
+func newMemTest(drv Driver, addr, size, width uint) *memTest {
+	shift := widthToShift[width]
+	// round down address, round up size to be width-bit aligned.
+	align := uint((1 << shift) - 1)
+	addr &= ^align
+	size = (size + align) & ^align
+	return &memTest{
+		drv:   drv,
+		addr:  addr,
+		n:     size >> shift,
+		width: width,
+		shift: shift,
+		mask:  uint((1 << width) - 1),
+		fm:    newFaultMap(addr, size, width),
+	}
+}
+
+// blockAddr returns the address and length of the i-th test block.
+func (mt *memTest) blockAddr(i uint) (uint, uint) {
+	ofs := i * testBlockSize
+	return mt.addr + (ofs << mt.shift), min(testBlockSize, mt.n-ofs)
+}
+
+// numBlocks returns the number of test blocks.
+func (mt *memTest) numBlocks() uint {
+	return (mt.n + testBlockSize - 1) / testBlockSize
+}
+
+// rdCheck reads memory and checks it against the expected values.
+func (mt *memTest) rdCheck(addr uint, expected []uint) error {
+	buf, err := mt.drv.RdMem(mt.width, addr, uint(len(expected)))
+	if err != nil {
+		return err
+	}
+	mt.fm.check(addr, expected, buf)
+	return nil
+}
+
+// run runs the i-th test step.
+func (mt *memTest) run(i int) error {
+	return mt.steps[i]()
+}
+
+//-----------------------------------------------------------------------------
+// March C-
+
+type marchOp struct {
+	rd  bool // read and check (or write)
+	val bool // all ones (or all zeroes)
+}
+
+type marchElement struct {
+	up  bool      // ascending (or descending) addresses
+	ops []marchOp // operations
+}
+
+var marchC = []marchElement{
+	{true, []marchOp{{false, false}}},
+	{true, []marchOp{{true, false}, {false, true}}},
+	{true, []marchOp{{true, true}, {false, false}}},
+	{false, []marchOp{{true, false}, {false, true}}},
+	{false, []marchOp{{true, true}, {false, false}}},
+	{true, []marchOp{{true, false}}},
+}
+
+// marchStep returns a test step running the march element operations on the cells of a block.
+// The operations are applied to each cell in turn, in the address order of the element.
+func (mt *memTest) marchStep(e *marchElement, blk uint) func() error {
+	return func() error {
+		addr, n := mt.blockAddr(blk)
+		for i := uint(0); i < n; i++ {
+			j := i
+			if !e.up {
+				j = n - i - 1
+			}
+			a := addr + (j << mt.shift)
+			for _, op := range e.ops {
+				val := []uint{0, mt.mask}[util.BoolToInt(op.val)]
+				if op.rd {
+					err := mt.rdCheck(a, []uint{val})
+					if err != nil {
+						return err
+					}
+				} else {
+					err := mt.drv.WrMem(mt.width, a, []uint{val})
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// march sets up a March C- test.
+func (mt *memTest) march() {
+	nblks := mt.numBlocks()
+	for i := range marchC {
+		e := &marchC[i]
+		for j := uint(0); j < nblks; j++ {
+			blk := j
+			if !e.up {
+				blk = nblks - j - 1
+			}
+			mt.steps = append(mt.steps, mt.marchStep(e, blk))
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// walking ones/zeroes on the data bus
+
+// walk sets up a walking ones and walking zeroes test at the base address.
+func (mt *memTest) walk() {
+	for i := uint(0); i < mt.width; i++ {
+		x := uint(1) << i
+		for _, val := range []uint{x, ^x & mt.mask} {
+			v := val
+			mt.steps = append(mt.steps, func() error {
+				err := mt.drv.WrMem(mt.width, mt.addr, []uint{v})
+				if err != nil {
+					return err
+				}
+				return mt.rdCheck(mt.addr, []uint{v})
+			})
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// address bus
+
+// addrBus sets up an address bus test.
+// Values at power-of-2 offsets are checked for aliasing.
+func (mt *memTest) addrBus() {
+	pattern := uint(0xaaaaaaaaaaaaaaaa) & mt.mask
+	anti := ^pattern & mt.mask
+	// the power-of-2 offsets within the region
+	ofs := []uint{0}
+	for i := uint(1); i < mt.n; i <<= 1 {
+		ofs = append(ofs, i)
+	}
+	addr := func(i uint) uint {
+		return mt.addr + (i << mt.shift)
+	}
+	wr := func(i, val uint) error {
+		return mt.drv.WrMem(mt.width, addr(i), []uint{val})
+	}
+	// write the pattern to all offsets
+	mt.steps = append(mt.steps, func() error {
+		for _, i := range ofs {
+			err := wr(i, pattern)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	// write the anti-pattern to each offset in turn and check the others
+	for k := range ofs {
+		x := ofs[k]
+		mt.steps = append(mt.steps, func() error {
+			err := wr(x, anti)
+			if err != nil {
+				return err
+			}
+			for _, i := range ofs {
+				val := []uint{pattern, anti}[util.BoolToInt(i == x)]
+				err := mt.rdCheck(addr(i), []uint{val})
+				if err != nil {
+					return err
+				}
+			}
+			return wr(x, pattern)
+		})
+	}
+}
+
+//-----------------------------------------------------------------------------
+// address in address
+
+// aiaBuf returns a buffer of address values (optionally inverted) for a block.
+func (mt *memTest) aiaBuf(addr, n uint, invert bool) []uint {
+	buf := make([]uint, n)
+	for i := range buf {
+		x := addr + (uint(i) << mt.shift)
+		if invert {
+			x = ^x
+		}
+		buf[i] = x & mt.mask
+	}
+	return buf
+}
+
+// aia sets up an address-in-address test.
+// Each value is written with its own address, then with the inverted address.
+func (mt *memTest) aia() {
+	nblks := mt.numBlocks()
+	for _, invert := range []bool{false, true} {
+		inv := invert
+		for i := uint(0); i < nblks; i++ {
+			blk := i
+			mt.steps = append(mt.steps, func() error {
+				addr, n := mt.blockAddr(blk)
+				return mt.drv.WrMem(mt.width, addr, mt.aiaBuf(addr, n, inv))
+			})
+		}
+		for i := uint(0); i < nblks; i++ {
+			blk := i
+			mt.steps = append(mt.steps, func() error {
+				addr, n := mt.blockAddr(blk)
+				return mt.rdCheck(addr, mt.aiaBuf(addr, n, inv))
+			})
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
+// random data
+
+// random sets up a random data test.
+// The region is written with a seeded random sequence, which is regenerated to check it.
+func (mt *memTest) random(seed int64) {
+	nblks := mt.numBlocks()
+	var wrRand, rdRand *rand.Rand
+	mt.steps = append(mt.steps, func() error {
+		wrRand = rand.New(rand.NewSource(seed))
+		rdRand = rand.New(rand.NewSource(seed))
+		return nil
+	})
+	seqBuf := func(r *rand.Rand, n uint) []uint {
+		buf := make([]uint, n)
+		for i := range buf {
+			buf[i] = uint(r.Uint64()) & mt.mask
+		}
+		return buf
+	}
+	for i := uint(0); i < nblks; i++ {
+		blk := i
+		mt.steps = append(mt.steps, func() error {
+			addr, n := mt.blockAddr(blk)
+			return mt.drv.WrMem(mt.width, addr, seqBuf(wrRand, n))
+		})
+	}
+	for i := uint(0); i < nblks; i++ {
+		blk := i
+		mt.steps = append(mt.steps, func() error {
+			addr, n := mt.blockAddr(blk)
+			return mt.rdCheck(addr, seqBuf(rdRand, n))
+		})
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,106 @@
+//-----------------------------------------------------------------------------
+/*
+
+Memory Test Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package mem
+
+import (
+	"fmt"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+
+// coupling is an idempotent coupling fault.
+// An up transition of the aggressor cell sets the victim cell to all ones.
+type coupling struct {
+	aggressor, victim uint
+}
+
+// testMem is a fake memory driver with an optional coupling fault.
+type testMem struct {
+	base  uint
+	width uint
+	cells []uint
+	cf    *coupling
+}
+
+func (m *testMem) GetAddressSize() uint             { return 32 }
+func (m *testMem) GetDefaultRegion() *Region        { return NewRegion("", m.base, 0x100, nil) }
+func (m *testMem) LookupSymbol(name string) *Region { return nil }
+
+func (m *testMem) index(width, addr uint) (uint, error) {
+	if width != m.width {
+		return 0, fmt.Errorf("bad width %d", width)
+	}
+	i := (addr - m.base) >> widthToShift[width]
+	if addr < m.base || i >= uint(len(m.cells)) {
+		return 0, fmt.Errorf("bad address 0x%x", addr)
+	}
+	return i, nil
+}
+
+func (m *testMem) RdMem(width, addr, n uint) ([]uint, error) {
+	i, err := m.index(width, addr)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]uint, n)
+	copy(buf, m.cells[i:i+n])
+	return buf, nil
+}
+
+func (m *testMem) WrMem(width, addr uint, val []uint) error {
+	i, err := m.index(width, addr)
+	if err != nil {
+		return err
+	}
+	for j, x := range val {
+		k := i + uint(j)
+		if m.cf != nil && k == m.cf.aggressor && m.cells[k] == 0 && x != 0 {
+			m.cells[m.cf.victim] = (1 << width) - 1
+		}
+		m.cells[k] = x
+	}
+	return nil
+}
+
+func runMarch(t *testing.T, drv *testMem, n uint) *faultMap {
+	mt := newMemTest(drv, drv.base, n<<widthToShift[drv.width], drv.width)
+	mt.march()
+	for i := range mt.steps {
+		err := mt.run(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return mt.fm
+}
+
+func Test_March(t *testing.T) {
+	const n = 64
+	// no faults
+	drv := &testMem{base: 0x1000, width: 32, cells: make([]uint, n)}
+	fm := runMarch(t, drv, n)
+	if fm.count != 0 {
+		t.Errorf("good memory has %d faults", fm.count)
+	}
+	// coupling faults between cells in the same test block
+	for _, cf := range []coupling{{3, 9}, {9, 3}} {
+		c := cf
+		drv := &testMem{base: 0x1000, width: 32, cells: make([]uint, n), cf: &c}
+		fm := runMarch(t, drv, n)
+		if fm.count == 0 {
+			t.Errorf("coupling fault %d->%d not detected", c.aggressor, c.victim)
+		}
+		if len(fm.fault) != 0 && fm.fault[0].addr != drv.base+c.victim*4 {
+			t.Errorf("coupling fault %d->%d reported at 0x%x", c.aggressor, c.victim, fm.fault[0].addr)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,158 @@
+//-----------------------------------------------------------------------------
+/*
+
+pprof Output
+
+Write a profile as a gzipped profile.proto message. Only the fields needed
+by "go tool pprof" are written:
+
+Profile: sample_type(1), sample(2), location(4), function(5),
+string_table(6), time_nanos(9), duration_nanos(10)
+Sample: location_id(1), value(2)
+Location: id(1), address(3), line(4)
+Line: function_id(1), line(2)
+Function: id(1), name(2), system_name(3), filename(4)
+ValueType: type(1), unit(2)
+
+*/
+//-----------------------------------------------------------------------------
+
+package profile
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+
+	"github.com/deadsy/rvdbg/elf"
+)
+
+//-----------------------------------------------------------------------------
+
+// pbuf is a protocol buffer encoder.
+type pbuf struct {
+	buf []byte
+}
+
+func (b *pbuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.buf = append(b.buf, byte(x)|0x80)
+		x >>= 7
+	}
+	b.buf = append(b.buf, byte(x))
+}
+
+// uint64 encodes a varint field.
+func (b *pbuf) uint64(tag int, x uint64) {
+	b.varint(uint64(tag) << 3)
+	b.varint(x)
+}
+
+// bytes encodes a length delimited field.
+func (b *pbuf) bytes(tag int, x []byte) {
+	b.varint(uint64(tag)<<3 | 2)
+	b.varint(uint64(len(x)))
+	b.buf = append(b.buf, x...)
+}
+
+// packed encodes a packed repeated varint field.
+func (b *pbuf) packed(tag int, x []uint64) {
+	p := &pbuf{}
+	for _, v := range x {
+		p.varint(v)
+	}
+	b.bytes(tag, p.buf)
+}
+
+//-----------------------------------------------------------------------------
+
+// stringTable is the profile string table.
+type stringTable struct {
+	index map[string]uint64
+	s     []string
+}
+
+func (t *stringTable) add(s string) uint64 {
+	if i, ok := t.index[s]; ok {
+		return i
+	}
+	i := uint64(len(t.s))
+	t.index[s] = i
+	t.s = append(t.s, s)
+	return i
+}
+
+// WritePprof writes the profile in the pprof format.
+func (p *Profile) WritePprof(w io.Writer, f *elf.File) error {
+	strs := &stringTable{index: map[string]uint64{}}
+	// string_table[0] must be ""
+	strs.add("")
+	pb := &pbuf{}
+
+	// sample_type
+	vt := &pbuf{}
+	vt.uint64(1, strs.add("samples"))
+	vt.uint64(2, strs.add("count"))
+	pb.bytes(1, vt.buf)
+
+	// one location per pc, in address order
+	pcs := make([]uint, 0, len(p.counts))
+	for pc := range p.counts {
+		pcs = append(pcs, pc)
+	}
+	sort.Slice(pcs, func(i, j int) bool { return pcs[i] < pcs[j] })
+
+	funcs := map[*elf.Symbol]uint64{}
+	fn := &pbuf{}
+	for i, pc := range pcs {
+		id := uint64(i + 1)
+		// sample
+		s := &pbuf{}
+		s.packed(1, []uint64{id})
+		s.packed(2, []uint64{uint64(p.counts[pc])})
+		pb.bytes(2, s.buf)
+		// location
+		loc := &pbuf{}
+		loc.uint64(1, id)
+		loc.uint64(3, uint64(pc))
+		var sym *elf.Symbol
+		if f != nil {
+			sym, _ = f.SymbolAt(pc)
+		}
+		if sym != nil {
+			fid, ok := funcs[sym]
+			file, line := f.LineAt(pc)
+			if !ok {
+				fid = uint64(len(funcs) + 1)
+				funcs[sym] = fid
+				x := &pbuf{}
+				x.uint64(1, fid)
+				x.uint64(2, strs.add(sym.Name))
+				x.uint64(3, strs.add(sym.Name))
+				x.uint64(4, strs.add(file))
+				fn.bytes(5, x.buf)
+			}
+			ln := &pbuf{}
+			ln.uint64(1, fid)
+			ln.uint64(2, uint64(line))
+			loc.bytes(4, ln.buf)
+		}
+		pb.bytes(4, loc.buf)
+	}
+	pb.buf = append(pb.buf, fn.buf...)
+
+	for _, s := range strs.s {
+		pb.bytes(6, []byte(s))
+	}
+	pb.uint64(9, uint64(p.Start.UnixNano()))
+	pb.uint64(10, uint64(p.Duration.Nanoseconds()))
+
+	zw := gzip.NewWriter(w)
+	_, err := zw.Write(pb.buf)
+	if err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+//-----------------------------------------------------------------------------
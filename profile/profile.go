@@ -0,0 +1,124 @@
+//-----------------------------------------------------------------------------
+/*
+
+PC Sample Profiles
+
+Collect program counter samples and aggregate them by symbol (when an ELF
+file is loaded) or by address bucket. The samples can also be written in the
+pprof format (gzipped profile.proto) for "go tool pprof".
+
+*/
+//-----------------------------------------------------------------------------
+
+package profile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/elf"
+)
+
+//-----------------------------------------------------------------------------
+
+const barWidth = 40 // histogram bar width in characters
+
+// Profile is a set of pc samples.
+type Profile struct {
+	counts   map[uint]uint // samples per pc
+	Total    uint          // total number of samples
+	Start    time.Time     // start of sampling
+	Duration time.Duration // sampling duration
+}
+
+// New returns an empty profile.
+func New() *Profile {
+	return &Profile{
+		counts: map[uint]uint{},
+		Start:  time.Now(),
+	}
+}
+
+// Add adds a pc sample.
+func (p *Profile) Add(pc uint) {
+	p.counts[pc]++
+	p.Total++
+}
+
+// Stop records the end of sampling.
+func (p *Profile) Stop() {
+	p.Duration = time.Since(p.Start)
+}
+
+//-----------------------------------------------------------------------------
+
+// Bucket is a histogram bucket.
+type Bucket struct {
+	Name  string `json:"name"`
+	Addr  uint   `json:"addr"` // symbol or bucket address
+	Count uint   `json:"count"`
+}
+
+// Histogram aggregates the samples by symbol, or by address bucket for pcs
+// without a symbol. The buckets are sorted by decreasing count.
+func (p *Profile) Histogram(f *elf.File, size uint) []*Bucket {
+	if size == 0 {
+		size = 1
+	}
+	buckets := map[string]*Bucket{}
+	for pc, n := range p.counts {
+		var s *elf.Symbol
+		if f != nil {
+			s, _ = f.SymbolAt(pc)
+		}
+		var b *Bucket
+		if s != nil {
+			b = &Bucket{Name: s.Name, Addr: s.Addr}
+		} else {
+			addr := pc - (pc % size)
+			b = &Bucket{Name: fmt.Sprintf("0x%x", addr), Addr: addr}
+		}
+		if x, ok := buckets[b.Name]; ok {
+			b = x
+		} else {
+			buckets[b.Name] = b
+		}
+		b.Count += n
+	}
+	h := make([]*Bucket, 0, len(buckets))
+	for _, b := range buckets {
+		h = append(h, b)
+	}
+	sort.Slice(h, func(i, j int) bool {
+		if h[i].Count != h[j].Count {
+			return h[i].Count > h[j].Count
+		}
+		return h[i].Addr < h[j].Addr
+	})
+	return h
+}
+
+// HistogramString returns a display string for the first n buckets of a histogram.
+func HistogramString(h []*Bucket, total uint, n int) string {
+	if total == 0 || len(h) == 0 {
+		return "no samples"
+	}
+	if n > len(h) {
+		n = len(h)
+	}
+	s := [][]string{}
+	for _, b := range h[:n] {
+		s = append(s, []string{
+			fmt.Sprintf("%d", b.Count),
+			fmt.Sprintf("%5.1f%%", 100*float64(b.Count)/float64(total)),
+			strings.Repeat("#", int((b.Count*barWidth+total-1)/total)),
+			b.Name,
+		})
+	}
+	return cli.TableString(s, []int{0, 0, barWidth, 0}, 1)
+}
+
+//-----------------------------------------------------------------------------
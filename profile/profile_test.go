@@ -0,0 +1,73 @@
+//-----------------------------------------------------------------------------
+/*
+
+PC Sample Profile Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+)
+
+//-----------------------------------------------------------------------------
+
+func Test_Histogram(t *testing.T) {
+	p := New()
+	for _, pc := range []uint{0x100, 0x104, 0x13c, 0x140, 0x200, 0x200, 0x200} {
+		p.Add(pc)
+	}
+	h := p.Histogram(nil, 0x40)
+	test := []Bucket{
+		{"0x100", 0x100, 3},
+		{"0x200", 0x200, 3},
+		{"0x140", 0x140, 1},
+	}
+	if len(h) != len(test) {
+		t.Fatalf("%d buckets", len(h))
+	}
+	for i, v := range test {
+		if *h[i] != v {
+			t.Errorf("bucket %d is %+v, expected %+v", i, *h[i], v)
+		}
+	}
+}
+
+func Test_Pprof(t *testing.T) {
+	p := New()
+	p.Add(0x80)
+	p.Add(0x300)
+	p.Stop()
+	buf := &bytes.Buffer{}
+	err := p.WritePprof(buf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sample_type {type: "samples", unit: "count"}
+	if !bytes.HasPrefix(x, []byte{0x0a, 0x04, 0x08, 0x01, 0x10, 0x02}) {
+		t.Errorf("bad sample_type % x", x[:6])
+	}
+	// sample {location_id: [1], value: [1]}
+	if !bytes.Contains(x, []byte{0x12, 0x06, 0x0a, 0x01, 0x01, 0x12, 0x01, 0x01}) {
+		t.Error("no sample for location 1")
+	}
+	// location {id: 2, address: 0x300}
+	if !bytes.Contains(x, []byte{0x22, 0x05, 0x08, 0x02, 0x18, 0x80, 0x06}) {
+		t.Error("no location 2")
+	}
+}
+
+//-----------------------------------------------------------------------------
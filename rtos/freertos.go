@@ -0,0 +1,379 @@
+//-----------------------------------------------------------------------------
+/*
+
+FreeRTOS Task Awareness
+
+Read the FreeRTOS task state from target memory. The kernel variables
+(pxCurrentTCB and the task lists) are found with the ELF symbols. The
+structure layouts are read from the DWARF information when available,
+otherwise the default layouts for a RISC-V port (all members XLEN bits)
+are used.
+
+TCB_t:
+
+StackType_t *pxTopOfStack;
+ListItem_t xStateListItem;
+ListItem_t xEventListItem;
+UBaseType_t uxPriority;
+StackType_t *pxStack;
+char pcTaskName[configMAX_TASK_NAME_LEN];
+
+ListItem_t: xItemValue, pxNext, pxPrevious, pvOwner, pxContainer
+List_t: uxNumberOfItems, pxIndex, xListEnd (MiniListItem_t)
+MiniListItem_t: xItemValue, pxNext, pxPrevious
+
+*/
+//-----------------------------------------------------------------------------
+
+package rtos
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/deadsy/rvdbg/elf"
+)
+
+//-----------------------------------------------------------------------------
+
+const maxTasks = 256       // sanity limit on the number of tasks in a list
+const stackFillByte = 0xa5 // tskSTACK_FILL_BYTE
+const stackScan = 64       // 32-bit words per memory read for the stack scan
+const maxStackScan = 1 << 20
+
+// Memory is target memory access.
+type Memory interface {
+	RdMem(width, addr, n uint) ([]uint, error) // read width-bit memory buffer
+}
+
+// Symbols is the symbol and type information of the firmware.
+type Symbols interface {
+	Lookup(name string) *elf.Symbol
+	StructFields(name string) (map[string]elf.Field, error)
+}
+
+//-----------------------------------------------------------------------------
+
+// TaskState is the scheduler state of a task.
+type TaskState int
+
+// Task states.
+const (
+	Running TaskState = iota
+	Ready
+	Blocked
+	Suspended
+	Deleted
+)
+
+var stateName = map[TaskState]string{
+	Running:   "running",
+	Ready:     "ready",
+	Blocked:   "blocked",
+	Suspended: "suspended",
+	Deleted:   "deleted",
+}
+
+func (s TaskState) String() string {
+	return stateName[s]
+}
+
+// MarshalText returns the task state name for JSON.
+func (s TaskState) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Task is a FreeRTOS task.
+type Task struct {
+	TCB        uint      `json:"tcb"`
+	Name       string    `json:"name"`
+	State      TaskState `json:"state"`
+	Priority   uint      `json:"priority"`
+	Stack      uint      `json:"stack"`      // lowest stack address (pxStack)
+	TopOfStack uint      `json:"topofstack"` // saved stack pointer (pxTopOfStack)
+	StackFree  uint      `json:"stackfree"`  // stack high water mark in bytes
+}
+
+//-----------------------------------------------------------------------------
+
+// layout is the member offsets of the FreeRTOS structures.
+type layout struct {
+	ptr uint // pointer size in bytes
+	// TCB_t
+	topOfStack, eventItem, priority, stack, name, nameLen uint
+	// ListItem_t
+	itemNext, itemOwner, itemContainer uint
+	// List_t
+	listEnd, listSize uint
+	// MiniListItem_t
+	endNext uint
+}
+
+// setField sets an offset (and size) from the DWARF struct members.
+func setField(fields map[string]elf.Field, name string, offset, size *uint) {
+	f, ok := fields[name]
+	if !ok {
+		return
+	}
+	*offset = f.Offset
+	if size != nil {
+		*size = f.Size
+	}
+}
+
+// newLayout returns the structure layouts for the firmware.
+func newLayout(syms Symbols, ptr uint) *layout {
+	l := &layout{
+		ptr:           ptr,
+		topOfStack:    0,
+		eventItem:     6 * ptr,
+		priority:      11 * ptr,
+		stack:         12 * ptr,
+		name:          13 * ptr,
+		nameLen:       16,
+		itemNext:      1 * ptr,
+		itemOwner:     3 * ptr,
+		itemContainer: 4 * ptr,
+		listEnd:       2 * ptr,
+		listSize:      5 * ptr,
+		endNext:       1 * ptr,
+	}
+	if f, err := syms.StructFields("tskTaskControlBlock"); err == nil {
+		setField(f, "pxTopOfStack", &l.topOfStack, nil)
+		setField(f, "xEventListItem", &l.eventItem, nil)
+		setField(f, "uxPriority", &l.priority, nil)
+		setField(f, "pxStack", &l.stack, nil)
+		setField(f, "pcTaskName", &l.name, &l.nameLen)
+	}
+	if f, err := syms.StructFields("xLIST_ITEM"); err == nil {
+		setField(f, "pxNext", &l.itemNext, nil)
+		setField(f, "pvOwner", &l.itemOwner, nil)
+		setField(f, "pxContainer", &l.itemContainer, nil)
+	}
+	if f, err := syms.StructFields("xLIST"); err == nil {
+		var size uint
+		setField(f, "xListEnd", &l.listEnd, &size)
+		if size != 0 {
+			l.listSize = l.listEnd + size
+		}
+	}
+	if f, err := syms.StructFields("xMINI_LIST_ITEM"); err == nil {
+		setField(f, "pxNext", &l.endNext, nil)
+	}
+	return l
+}
+
+//-----------------------------------------------------------------------------
+
+// reader reads the kernel state.
+type reader struct {
+	mem   Memory
+	syms  Symbols
+	l     *layout
+	tasks map[uint]*Task
+}
+
+// rdPtr reads a pointer from target memory.
+func (r *reader) rdPtr(addr uint) (uint, error) {
+	x, err := r.mem.RdMem(r.l.ptr*8, addr, 1)
+	if err != nil {
+		return 0, err
+	}
+	return x[0], nil
+}
+
+// symbol returns the address of a kernel symbol.
+func (r *reader) symbol(name string) (uint, error) {
+	s := r.syms.Lookup(name)
+	if s == nil {
+		return 0, fmt.Errorf("no \"%s\" symbol, is this a FreeRTOS firmware?", name)
+	}
+	return s.Addr, nil
+}
+
+// list adds the tasks in a list. A task already seen keeps its state.
+func (r *reader) list(addr uint, state TaskState) error {
+	end := addr + r.l.listEnd
+	item, err := r.rdPtr(end + r.l.endNext)
+	if err != nil {
+		return err
+	}
+	for n := 0; item != end; n++ {
+		if item == 0 || n >= maxTasks {
+			return fmt.Errorf("bad task list at 0x%x", addr)
+		}
+		tcb, err := r.rdPtr(item + r.l.itemOwner)
+		if err != nil {
+			return err
+		}
+		if _, ok := r.tasks[tcb]; !ok && tcb != 0 {
+			r.tasks[tcb] = &Task{TCB: tcb, State: state}
+		}
+		item, err = r.rdPtr(item + r.l.itemNext)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tcb reads the task control block.
+func (r *reader) tcb(t *Task) error {
+	var err error
+	t.TopOfStack, err = r.rdPtr(t.TCB + r.l.topOfStack)
+	if err != nil {
+		return err
+	}
+	t.Stack, err = r.rdPtr(t.TCB + r.l.stack)
+	if err != nil {
+		return err
+	}
+	x, err := r.mem.RdMem(r.l.ptr*8, t.TCB+r.l.priority, 1)
+	if err != nil {
+		return err
+	}
+	t.Priority = x[0]
+	x, err = r.mem.RdMem(8, t.TCB+r.l.name, r.l.nameLen)
+	if err != nil {
+		return err
+	}
+	name := make([]byte, len(x))
+	for i := range x {
+		name[i] = byte(x[i])
+	}
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+	t.Name = string(name)
+	if t.State == Suspended {
+		// waiting on an event without a timeout
+		container, err := r.rdPtr(t.TCB + r.l.eventItem + r.l.itemContainer)
+		if err != nil {
+			return err
+		}
+		if container != 0 {
+			t.State = Blocked
+		}
+	}
+	return nil
+}
+
+// stackFree returns the number of stack bytes that have never been used.
+func (r *reader) stackFree(t *Task) (uint, error) {
+	if t.Stack == 0 || t.TopOfStack <= t.Stack || t.TopOfStack-t.Stack > maxStackScan {
+		return 0, nil
+	}
+	free := uint(0)
+	addr := t.Stack
+	for addr < t.TopOfStack {
+		n := (t.TopOfStack - addr) >> 2
+		if n > stackScan {
+			n = stackScan
+		}
+		if n == 0 {
+			break
+		}
+		x, err := r.mem.RdMem(32, addr, n)
+		if err != nil {
+			return 0, err
+		}
+		for _, v := range x {
+			for i := 0; i < 4; i++ {
+				if byte(v>>(8*uint(i))) != stackFillByte {
+					return free, nil
+				}
+				free++
+			}
+		}
+		addr += n << 2
+	}
+	return free, nil
+}
+
+//-----------------------------------------------------------------------------
+
+// FreeRTOSTasks returns the FreeRTOS tasks sorted by TCB address.
+func FreeRTOSTasks(mem Memory, syms Symbols, xlen uint) ([]*Task, error) {
+	if syms == nil {
+		return nil, errors.New("no ELF file, see \"elf\"")
+	}
+	r := &reader{
+		mem:   mem,
+		syms:  syms,
+		l:     newLayout(syms, xlen>>3),
+		tasks: map[uint]*Task{},
+	}
+	// ready lists, one per priority
+	addr, err := r.symbol("pxReadyTasksLists")
+	if err != nil {
+		return nil, err
+	}
+	n := r.syms.Lookup("pxReadyTasksLists").Size / r.l.listSize
+	if n == 0 {
+		return nil, errors.New("unknown number of task priorities")
+	}
+	for i := uint(0); i < n; i++ {
+		err := r.list(addr+i*r.l.listSize, Ready)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the other lists
+	lists := []struct {
+		name  string
+		state TaskState
+	}{
+		{"xPendingReadyList", Ready},
+		{"xDelayedTaskList1", Blocked},
+		{"xDelayedTaskList2", Blocked},
+		{"xSuspendedTaskList", Suspended},
+		{"xTasksWaitingTermination", Deleted},
+	}
+	for _, v := range lists {
+		s := r.syms.Lookup(v.name)
+		if s == nil {
+			// optional with the kernel configuration
+			continue
+		}
+		err := r.list(s.Addr, v.state)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// the running task
+	addr, err = r.symbol("pxCurrentTCB")
+	if err != nil {
+		return nil, err
+	}
+	cur, err := r.rdPtr(addr)
+	if err != nil {
+		return nil, err
+	}
+	if cur != 0 {
+		if t, ok := r.tasks[cur]; ok {
+			t.State = Running
+		} else {
+			r.tasks[cur] = &Task{TCB: cur, State: Running}
+		}
+	}
+	tasks := []*Task{}
+	for _, t := range r.tasks {
+		err := r.tcb(t)
+		if err != nil {
+			return nil, err
+		}
+		t.StackFree, err = r.stackFree(t)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].TCB < tasks[j].TCB
+	})
+	return tasks, nil
+}
+
+//-----------------------------------------------------------------------------
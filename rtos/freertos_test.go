@@ -0,0 +1,99 @@
+//-----------------------------------------------------------------------------
+/*
+
+FreeRTOS Task Awareness Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package rtos
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/deadsy/rvdbg/elf"
+	"github.com/deadsy/rvdbg/mem/memtest"
+)
+
+//-----------------------------------------------------------------------------
+
+// testMemory is a memory with FreeRTOS data structure helpers.
+type testMemory struct {
+	memtest.Memory
+}
+
+// wr32 writes 32-bit values.
+func (m testMemory) wr32(addr uint, val ...uint) {
+	m.WrMem(32, addr, val)
+}
+
+// testSymbols are symbols without dwarf information.
+type testSymbols map[string]*elf.Symbol
+
+func (s testSymbols) Lookup(name string) *elf.Symbol {
+	return s[name]
+}
+
+func (s testSymbols) StructFields(name string) (map[string]elf.Field, error) {
+	return nil, errors.New("no dwarf information")
+}
+
+// list writes a list with one item.
+func (m testMemory) list(addr, item, tcb uint) {
+	end := addr + 8
+	m.wr32(addr, 1, end, 0xffffffff, item, item)
+	m.wr32(item, 0, end, end, tcb, addr)
+}
+
+// tcb writes a task control block with a stack.
+func (m testMemory) tcb(addr, prio, stack, top uint, name string) {
+	m.wr32(addr, top)
+	m.wr32(addr+44, prio, stack)
+	for i := range name {
+		m.Memory[addr+52+uint(i)] = name[i]
+	}
+	for a := stack; a < top; a++ {
+		m.Memory[a] = stackFillByte
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Tasks(t *testing.T) {
+	m := testMemory{memtest.Memory{}}
+	syms := testSymbols{
+		"pxCurrentTCB":      {Name: "pxCurrentTCB", Addr: 0x100, Size: 4},
+		"pxReadyTasksLists": {Name: "pxReadyTasksLists", Addr: 0x200, Size: 2 * 20},
+		"xDelayedTaskList1": {Name: "xDelayedTaskList1", Addr: 0x300, Size: 20},
+	}
+	// empty ready list for priority 0, "main" is ready at priority 1
+	m.wr32(0x200, 0, 0x208, 0xffffffff, 0x208, 0x208)
+	m.list(0x214, 0x1004, 0x1000)
+	m.tcb(0x1000, 1, 0x4000, 0x4100, "main")
+	// "idle" is blocked
+	m.list(0x300, 0x2004, 0x2000)
+	m.tcb(0x2000, 0, 0x5000, 0x5040, "idle")
+	m.wr32(0x5030, 0x12345678)
+	// "main" is running
+	m.wr32(0x100, 0x1000)
+
+	tasks, err := FreeRTOSTasks(m, syms, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("found %d tasks", len(tasks))
+	}
+	test := []Task{
+		{0x1000, "main", Running, 1, 0x4000, 0x4100, 0x100},
+		{0x2000, "idle", Blocked, 0, 0x5000, 0x5040, 0x30},
+	}
+	for i, v := range test {
+		if *tasks[i] != v {
+			t.Errorf("task %d is %+v, expected %+v", i, *tasks[i], v)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,390 @@
+//-----------------------------------------------------------------------------
+/*
+
+RTT Menu Items
+
+*/
+//-----------------------------------------------------------------------------
+
+package rtt
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/elf"
+	"github.com/deadsy/rvdbg/mem"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+// target provides the memory driver and the rtt state.
+type target interface {
+	GetMemoryDriver() mem.Driver
+	SetControlBlock(cb *ControlBlock)
+	GetControlBlock() *ControlBlock
+}
+
+const pollInterval = 10 * time.Millisecond
+const controlSymbol = "_SEGGER_RTT"
+
+// getControlBlock returns the located control block.
+func getControlBlock(c *cli.CLI) (*ControlBlock, error) {
+	cb := c.User.(target).GetControlBlock()
+	if cb == nil {
+		return nil, errors.New("no rtt control block, run \"rtt find\"")
+	}
+	return cb, nil
+}
+
+// channelArg returns a channel number argument (default 0).
+func channelArg(args []string, i int) (int, error) {
+	if len(args) <= i {
+		return 0, nil
+	}
+	return cli.IntArg(args[i], [2]int{0, maxBuffers - 1}, 10)
+}
+
+//-----------------------------------------------------------------------------
+
+var helpFind = []cli.Help{
+	{"<cr>", fmt.Sprintf("use the \"%s\" symbol of the ELF file (see \"elf\")", controlSymbol)},
+	{"<addr/name> [len]", "scan a memory region for the control block"},
+	{"  addr", "address (expression)"},
+	{"  name", "region name (string), see \"map\" command"},
+	{"  len", "length (hex), defaults to region size or 0x100"},
+}
+
+var cmdFind = cli.Leaf{
+	Descr: "find the rtt control block",
+	F: func(c *cli.CLI, args []string) {
+		drv := c.User.(target).GetMemoryDriver()
+		var cb *ControlBlock
+		if len(args) == 0 {
+			f := elf.Current()
+			if f == nil || f.Lookup(controlSymbol) == nil {
+				util.PutError(c.User, fmt.Errorf("no \"%s\" symbol, give a memory region to scan", controlSymbol))
+				return
+			}
+			var err error
+			cb, err = Open(drv, f.Lookup(controlSymbol).Addr)
+			if err != nil {
+				util.PutError(c.User, err)
+				return
+			}
+		} else {
+			r, err := mem.RegionArg(drv, args)
+			if err != nil {
+				util.PutError(c.User, err)
+				return
+			}
+			ri := r.Info()
+			cb, err = Find(drv, ri.Addr, ri.Size)
+			if err != nil {
+				util.PutError(c.User, err)
+				return
+			}
+		}
+		c.User.(target).SetControlBlock(cb)
+		c.User.Put(fmt.Sprintf("%s\n", cb))
+	},
+}
+
+var cmdInfo = cli.Leaf{
+	Descr: "display the rtt channels",
+	F: func(c *cli.CLI, args []string) {
+		cb, err := getControlBlock(c)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		err = cb.Refresh()
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		if util.IsJSON(c.User) {
+			util.PutJSON(c.User, cb)
+			return
+		}
+		c.User.Put(fmt.Sprintf("%s\n", cb))
+	},
+}
+
+//-----------------------------------------------------------------------------
+
+var helpConsole = []cli.Help{
+	{"[channel]", "up channel (decimal), default is 0"},
+}
+
+var cmdConsole = cli.Leaf{
+	Descr: "display the output of an up channel",
+	F: func(c *cli.CLI, args []string) {
+		err := cli.CheckArgc(args, []int{0, 1})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		cb, err := getControlBlock(c)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		ch, err := channelArg(args, 0)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		c.User.Put(fmt.Sprintf("rtt up%d console (ctrl-d to exit)\n", ch))
+		c.Loop(func() bool {
+			var buf []byte
+			buf, err = cb.Read(ch)
+			if err != nil {
+				return true
+			}
+			if len(buf) == 0 {
+				time.Sleep(pollInterval)
+				return false
+			}
+			c.User.Put(string(buf))
+			return false
+		}, cli.KeycodeCtrlD)
+		if err != nil {
+			util.PutError(c.User, err)
+		}
+	},
+}
+
+var helpWrite = []cli.Help{
+	{"<channel> <text>", "write a line of text to a down channel"},
+	{"  channel", "down channel (decimal)"},
+}
+
+var cmdWrite = cli.Leaf{
+	Descr: "write to a down channel",
+	F: func(c *cli.CLI, args []string) {
+		if len(args) < 2 {
+			util.PutError(c.User, errors.New("usage: write <channel> <text>"))
+			return
+		}
+		cb, err := getControlBlock(c)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		ch, err := channelArg(args, 0)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		buf := []byte(strings.Join(args[1:], " ") + "\n")
+		n, err := cb.Write(ch, buf)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		if n != len(buf) && cb.Down[ch].mode() == modeBlock {
+			// wait for the target to make space
+			c.User.Put(fmt.Sprintf("down%d buffer is full, waiting (ctrl-d to stop)\n", ch))
+			c.Loop(func() bool {
+				var k int
+				k, err = cb.Write(ch, buf[n:])
+				if err != nil {
+					return true
+				}
+				n += k
+				if k == 0 {
+					time.Sleep(pollInterval)
+				}
+				return n == len(buf)
+			}, cli.KeycodeCtrlD)
+			if err != nil {
+				util.PutError(c.User, err)
+				return
+			}
+		}
+		if n != len(buf) {
+			util.PutError(c.User, fmt.Errorf("down%d buffer is full, wrote %d of %d bytes", ch, n, len(buf)))
+		}
+	},
+}
+
+//-----------------------------------------------------------------------------
+
+var helpTCP = []cli.Help{
+	{"<port> [channel]", "connect an up/down channel pair to a tcp port"},
+	{"  port", "tcp port on localhost (decimal)"},
+	{"  channel", "channel (decimal), default is 0"},
+}
+
+// tcpBridge connects an rtt channel to a tcp client.
+type tcpBridge struct {
+	ln      net.Listener
+	conns   chan net.Conn
+	rx      chan []byte
+	done    chan struct{}
+	conn    net.Conn
+	pending []byte // received data not yet written to the down channel
+}
+
+func newTCPBridge(port int) (*tcpBridge, error) {
+	ln, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		return nil, err
+	}
+	b := &tcpBridge{
+		ln:    ln,
+		conns: make(chan net.Conn),
+		rx:    make(chan []byte, 16),
+		done:  make(chan struct{}),
+	}
+	// accept connections
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			select {
+			case b.conns <- conn:
+			case <-b.done:
+				conn.Close()
+				return
+			}
+		}
+	}()
+	return b, nil
+}
+
+// receive reads data from a client connection.
+func (b *tcpBridge) receive(conn net.Conn) {
+	for {
+		buf := make([]byte, 256)
+		n, err := conn.Read(buf)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		select {
+		case b.rx <- buf[:n]:
+		case <-b.done:
+			return
+		}
+	}
+}
+
+// poll moves data between the rtt channel and the tcp client.
+// It returns true if there was any data to move.
+func (b *tcpBridge) poll(cb *ControlBlock, ch int) (bool, error) {
+	busy := false
+	// new connection, only one client at a time
+	select {
+	case conn := <-b.conns:
+		if b.conn != nil {
+			b.conn.Close()
+		}
+		b.conn = conn
+		go b.receive(conn)
+	default:
+	}
+	// target to client
+	buf, err := cb.Read(ch)
+	if err != nil {
+		return false, err
+	}
+	if len(buf) != 0 {
+		busy = true
+		if b.conn != nil {
+			b.conn.Write(buf)
+		}
+	}
+	// client to target
+	if len(b.pending) == 0 {
+		select {
+		case b.pending = <-b.rx:
+		default:
+		}
+	}
+	if len(b.pending) != 0 && len(cb.Down) > ch {
+		n, err := cb.Write(ch, b.pending)
+		if err != nil {
+			return false, err
+		}
+		b.pending = b.pending[n:]
+		busy = busy || n != 0
+	}
+	return busy, nil
+}
+
+func (b *tcpBridge) close() {
+	close(b.done)
+	b.ln.Close()
+	if b.conn != nil {
+		b.conn.Close()
+	}
+}
+
+var cmdTCP = cli.Leaf{
+	Descr: "connect a channel to a tcp port",
+	F: func(c *cli.CLI, args []string) {
+		err := cli.CheckArgc(args, []int{1, 2})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		cb, err := getControlBlock(c)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		port, err := cli.IntArg(args[0], [2]int{1, 65535}, 10)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		ch, err := channelArg(args, 1)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		b, err := newTCPBridge(port)
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		defer b.close()
+		c.User.Put(fmt.Sprintf("rtt channel %d on localhost:%d (ctrl-d to exit)\n", ch, port))
+		c.Loop(func() bool {
+			var busy bool
+			busy, err = b.poll(cb, ch)
+			if err != nil {
+				return true
+			}
+			if !busy {
+				time.Sleep(pollInterval)
+			}
+			return false
+		}, cli.KeycodeCtrlD)
+		if err != nil {
+			util.PutError(c.User, err)
+		}
+	},
+}
+
+//-----------------------------------------------------------------------------
+
+// Menu is the rtt submenu.
+var Menu = cli.Menu{
+	{"console", cmdConsole, helpConsole},
+	{"find", cmdFind, helpFind},
+	{"info", cmdInfo},
+	{"tcp", cmdTCP, helpTCP},
+	{"write", cmdWrite, helpWrite},
+}
+
+//-----------------------------------------------------------------------------
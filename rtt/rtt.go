@@ -0,0 +1,374 @@
+//-----------------------------------------------------------------------------
+/*
+
+Real Time Transfer
+
+Segger RTT compatible ring buffers in target memory. The target writes to
+the up buffers and the host reads them. The host writes to the down buffers
+and the target reads them. The memory is accessed while the target is
+running, so this needs a core with memory access while running (E.g. system
+bus access).
+
+Control block:
+
+char acID[16]; // "SEGGER RTT"
+int MaxNumUpBuffers;
+int MaxNumDownBuffers;
+SEGGER_RTT_BUFFER_UP aUp[MaxNumUpBuffers];
+SEGGER_RTT_BUFFER_DOWN aDown[MaxNumDownBuffers];
+
+Buffer descriptor:
+
+const char *sName;
+char *pBuffer;
+unsigned SizeOfBuffer;
+unsigned WrOff;
+unsigned RdOff;
+unsigned Flags;
+
+Flags bits 1:0 are the mode for a full buffer. The host applies the mode
+to writes to a down buffer. skip: nothing is written if the data doesn't
+fit, trim: the data that fits is written, block: the data that fits is
+written and the caller writes the rest as the target makes space.
+
+*/
+//-----------------------------------------------------------------------------
+
+package rtt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/mem"
+)
+
+//-----------------------------------------------------------------------------
+
+// controlID is the control block identifier.
+var controlID = []byte("SEGGER RTT\x00")
+
+const idSize = 16             // bytes in acID
+const cbHeaderSize = 24       // acID + MaxNumUpBuffers + MaxNumDownBuffers
+const maxBuffers = 32         // sanity limit on the number of buffers
+const maxNameLength = 32      // maximum channel name length
+const scanSize = 1024         // bytes per memory read when scanning
+const maxBufferSize = 1 << 20 // sanity limit on the buffer size
+
+// buffer modes (Flags bits 1:0)
+const (
+	modeSkip  = 0
+	modeTrim  = 1
+	modeBlock = 2
+	modeMask  = 3
+)
+
+//-----------------------------------------------------------------------------
+
+// Channel is an RTT up (target to host) or down (host to target) channel.
+type Channel struct {
+	Up     bool   `json:"up"`
+	Index  int    `json:"index"`
+	Name   string `json:"name"`
+	Addr   uint   `json:"addr"`   // buffer descriptor address
+	Buffer uint   `json:"buffer"` // buffer address
+	Size   uint   `json:"size"`   // buffer size
+	WrOff  uint   `json:"wroff"`  // write offset
+	RdOff  uint   `json:"rdoff"`  // read offset
+	Flags  uint   `json:"flags"`
+}
+
+// ControlBlock is an RTT control block in target memory.
+type ControlBlock struct {
+	drv     mem.Driver
+	Addr    uint       `json:"addr"`
+	ptrSize uint       // target pointer size in bytes
+	Up      []*Channel `json:"up"`
+	Down    []*Channel `json:"down"`
+}
+
+// rdBytes reads a byte buffer from target memory.
+func rdBytes(drv mem.Driver, addr, n uint) ([]byte, error) {
+	x, err := drv.RdMem(8, addr, n)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	for i := range x {
+		buf[i] = byte(x[i])
+	}
+	return buf, nil
+}
+
+// wrBytes writes a byte buffer to target memory.
+func wrBytes(drv mem.Driver, addr uint, buf []byte) error {
+	x := make([]uint, len(buf))
+	for i := range buf {
+		x[i] = uint(buf[i])
+	}
+	return drv.WrMem(8, addr, x)
+}
+
+// rdString reads a zero terminated string from target memory.
+func rdString(drv mem.Driver, addr uint) (string, error) {
+	if addr == 0 {
+		return "", nil
+	}
+	buf, err := rdBytes(drv, addr, maxNameLength)
+	if err != nil {
+		return "", err
+	}
+	if i := bytes.IndexByte(buf, 0); i >= 0 {
+		buf = buf[:i]
+	}
+	return string(buf), nil
+}
+
+//-----------------------------------------------------------------------------
+
+// Open returns the control block at an address.
+func Open(drv mem.Driver, addr uint) (*ControlBlock, error) {
+	id, err := rdBytes(drv, addr, idSize)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(id, controlID) {
+		return nil, fmt.Errorf("no rtt control block at 0x%x", addr)
+	}
+	x, err := drv.RdMem(32, addr+idSize, 2)
+	if err != nil {
+		return nil, err
+	}
+	if x[0] > maxBuffers || x[1] > maxBuffers {
+		return nil, fmt.Errorf("bad number of rtt buffers (up %d, down %d)", x[0], x[1])
+	}
+	cb := &ControlBlock{
+		drv:     drv,
+		Addr:    addr,
+		ptrSize: drv.GetAddressSize() >> 3,
+	}
+	descSize := 2*cb.ptrSize + 16
+	a := addr + cbHeaderSize
+	for i := 0; i < int(x[0]); i++ {
+		cb.Up = append(cb.Up, &Channel{Up: true, Index: i, Addr: a})
+		a += descSize
+	}
+	for i := 0; i < int(x[1]); i++ {
+		cb.Down = append(cb.Down, &Channel{Index: i, Addr: a})
+		a += descSize
+	}
+	err = cb.Refresh()
+	if err != nil {
+		return nil, err
+	}
+	return cb, nil
+}
+
+// Find scans a memory range for the control block.
+func Find(drv mem.Driver, addr, size uint) (*ControlBlock, error) {
+	// the control block is 32-bit aligned
+	addr &= ^uint(3)
+	end := addr + size
+	for addr < end {
+		n := end - addr
+		if n > scanSize {
+			n = scanSize
+		}
+		n = (n + 3) & ^uint(3)
+		x, err := drv.RdMem(32, addr, n>>2)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 0, n)
+		for _, v := range x {
+			buf = append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+		}
+		for i := 0; i+4 <= len(buf); i += 4 {
+			// check the first word, Open checks the rest
+			if bytes.HasPrefix(buf[i:], controlID[:4]) {
+				cb, err := Open(drv, addr+uint(i))
+				if err == nil {
+					return cb, nil
+				}
+			}
+		}
+		addr += n
+	}
+	return nil, errors.New("rtt control block not found")
+}
+
+// Refresh reads the buffer descriptors.
+func (cb *ControlBlock) Refresh() error {
+	for _, ch := range append(cb.Up, cb.Down...) {
+		ptr, err := cb.drv.RdMem(cb.ptrSize*8, ch.Addr, 2)
+		if err != nil {
+			return err
+		}
+		x, err := cb.drv.RdMem(32, ch.Addr+2*cb.ptrSize, 4)
+		if err != nil {
+			return err
+		}
+		ch.Buffer, ch.Size, ch.WrOff, ch.RdOff, ch.Flags = ptr[1], x[0], x[1], x[2], x[3]
+		ch.Name, err = rdString(cb.drv, ptr[0])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// channel returns an up/down channel.
+func (cb *ControlBlock) channel(up bool, i int) (*Channel, error) {
+	chans := map[bool][]*Channel{true: cb.Up, false: cb.Down}[up]
+	kind := map[bool]string{true: "up", false: "down"}[up]
+	if i < 0 || i >= len(chans) {
+		return nil, fmt.Errorf("no %s channel %d", kind, i)
+	}
+	ch := chans[i]
+	if ch.Buffer == 0 || ch.Size == 0 || ch.Size > maxBufferSize {
+		return nil, fmt.Errorf("%s channel %d is not configured", kind, i)
+	}
+	return ch, nil
+}
+
+// offsets reads the buffer size, write offset, read offset and flags for a channel.
+func (cb *ControlBlock) offsets(ch *Channel) error {
+	x, err := cb.drv.RdMem(32, ch.Addr+2*cb.ptrSize, 4)
+	if err != nil {
+		return err
+	}
+	ch.Size, ch.WrOff, ch.RdOff, ch.Flags = x[0], x[1], x[2], x[3]
+	if ch.Size == 0 || ch.Size > maxBufferSize || ch.WrOff >= ch.Size || ch.RdOff >= ch.Size {
+		return fmt.Errorf("bad buffer state for channel %d (size %d, wroff %d, rdoff %d)", ch.Index, ch.Size, ch.WrOff, ch.RdOff)
+	}
+	return nil
+}
+
+// Read reads the available data from an up channel.
+func (cb *ControlBlock) Read(i int) ([]byte, error) {
+	ch, err := cb.channel(true, i)
+	if err != nil {
+		return nil, err
+	}
+	err = cb.offsets(ch)
+	if err != nil {
+		return nil, err
+	}
+	if ch.WrOff == ch.RdOff {
+		return nil, nil
+	}
+	var buf []byte
+	if ch.WrOff > ch.RdOff {
+		buf, err = rdBytes(cb.drv, ch.Buffer+ch.RdOff, ch.WrOff-ch.RdOff)
+	} else {
+		// wrapped
+		buf, err = rdBytes(cb.drv, ch.Buffer+ch.RdOff, ch.Size-ch.RdOff)
+		if err == nil && ch.WrOff != 0 {
+			var x []byte
+			x, err = rdBytes(cb.drv, ch.Buffer, ch.WrOff)
+			buf = append(buf, x...)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// update the read offset
+	ch.RdOff = ch.WrOff
+	err = cb.drv.WrMem(32, ch.Addr+2*cb.ptrSize+8, []uint{ch.RdOff})
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// mode returns the buffer mode of a channel.
+func (ch *Channel) mode() uint {
+	return ch.Flags & modeMask
+}
+
+// Write writes data to a down channel. It returns the number of bytes written.
+// A full buffer is handled as given by the channel mode.
+func (cb *ControlBlock) Write(i int, buf []byte) (int, error) {
+	ch, err := cb.channel(false, i)
+	if err != nil {
+		return 0, err
+	}
+	err = cb.offsets(ch)
+	if err != nil {
+		return 0, err
+	}
+	// one byte is left unused so a full buffer is distinguished from an empty buffer
+	free := (ch.RdOff + ch.Size - ch.WrOff - 1) % ch.Size
+	n := uint(len(buf))
+	if n > free {
+		if ch.mode() == modeSkip {
+			return 0, nil
+		}
+		n = free
+	}
+	if n == 0 {
+		return 0, nil
+	}
+	// write to the end of the buffer, then wrap
+	k := ch.Size - ch.WrOff
+	if k > n {
+		k = n
+	}
+	err = wrBytes(cb.drv, ch.Buffer+ch.WrOff, buf[:k])
+	if err != nil {
+		return 0, err
+	}
+	if n > k {
+		err = wrBytes(cb.drv, ch.Buffer, buf[k:n])
+		if err != nil {
+			return 0, err
+		}
+	}
+	// update the write offset
+	ch.WrOff = (ch.WrOff + n) % ch.Size
+	err = cb.drv.WrMem(32, ch.Addr+2*cb.ptrSize+4, []uint{ch.WrOff})
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+//-----------------------------------------------------------------------------
+
+// State stores the located control block. It is embedded in a target.
+type State struct {
+	cb *ControlBlock
+}
+
+// SetControlBlock sets the located control block.
+func (s *State) SetControlBlock(cb *ControlBlock) {
+	s.cb = cb
+}
+
+// GetControlBlock returns the located control block (nil == none).
+func (s *State) GetControlBlock() *ControlBlock {
+	return s.cb
+}
+
+//-----------------------------------------------------------------------------
+
+func (cb *ControlBlock) String() string {
+	s := [][]string{}
+	for _, ch := range append(cb.Up, cb.Down...) {
+		kind := map[bool]string{true: "up", false: "down"}[ch.Up]
+		s = append(s, []string{
+			fmt.Sprintf("%s%d", kind, ch.Index),
+			ch.Name,
+			fmt.Sprintf("0x%x", ch.Buffer),
+			fmt.Sprintf("size %d", ch.Size),
+			fmt.Sprintf("wr %d", ch.WrOff),
+			fmt.Sprintf("rd %d", ch.RdOff),
+			fmt.Sprintf("flags 0x%x", ch.Flags),
+		})
+	}
+	return fmt.Sprintf("control block 0x%x\n%s", cb.Addr, cli.TableString(s, []int{0, 0, 0, 0, 0, 0, 0}, 1))
+}
+
+//-----------------------------------------------------------------------------
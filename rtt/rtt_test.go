@@ -0,0 +1,178 @@
+//-----------------------------------------------------------------------------
+/*
+
+RTT Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package rtt
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/deadsy/rvdbg/mem/memtest"
+)
+
+//-----------------------------------------------------------------------------
+
+// testMem is a memory with rtt control block helpers.
+type testMem struct {
+	memtest.Memory
+}
+
+//-----------------------------------------------------------------------------
+
+const (
+	testCB       = 0x1000 // control block
+	testUpDesc   = testCB + cbHeaderSize
+	testDownDesc = testUpDesc + 24
+	testUpBuf    = 0x2000
+	testUpSize   = 16
+	testDownBuf  = 0x3000
+	testDownSize = 8
+)
+
+// newTestMem returns a memory with a control block for 1 up and 1 down channel.
+func newTestMem() testMem {
+	m := testMem{memtest.Memory{}}
+	m.WrBytes(testCB, controlID)
+	m.WrMem(32, testCB+idSize, []uint{1, 1})
+	m.WrBytes(0x1800, []byte("Terminal\x00"))
+	m.WrMem(32, testUpDesc, []uint{0x1800, testUpBuf, testUpSize, 0, 0, modeTrim})
+	m.WrMem(32, testDownDesc, []uint{0x1800, testDownBuf, testDownSize, 0, 0, modeSkip})
+	return m
+}
+
+// setOffsets sets the write and read offsets of a buffer descriptor.
+func (m testMem) setOffsets(desc, wr, rd uint) {
+	m.WrMem(32, desc+12, []uint{wr, rd})
+}
+
+// setMode sets the mode of a buffer descriptor.
+func (m testMem) setMode(desc, mode uint) {
+	m.WrMem(32, desc+20, []uint{mode})
+}
+
+func (m testMem) offsets(desc uint) (uint, uint) {
+	x, _ := m.RdMem(32, desc+12, 2)
+	return x[0], x[1]
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Find(t *testing.T) {
+	m := newTestMem()
+	cb, err := Find(m, testCB-0x100, 0x200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cb.Addr != testCB || len(cb.Up) != 1 || len(cb.Down) != 1 {
+		t.Fatalf("control block 0x%x up %d down %d", cb.Addr, len(cb.Up), len(cb.Down))
+	}
+	up := cb.Up[0]
+	if up.Name != "Terminal" || up.Buffer != testUpBuf || up.Size != testUpSize {
+		t.Errorf("up0 %+v", up)
+	}
+	_, err = Find(m, 0x4000, 0x100)
+	if err == nil {
+		t.Error("expected an error for a range without a control block")
+	}
+}
+
+func Test_Read(t *testing.T) {
+	m := newTestMem()
+	data := []byte("0123456789abcdef")
+	m.WrBytes(testUpBuf, data)
+	cb, err := Open(m, testCB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		wr, rd uint
+		s      string
+	}{
+		{0, 0, ""},                // empty
+		{5, 2, "234"},             // linear
+		{4, 12, "cdef0123"},       // wrapped
+		{0, 10, "abcdef"},         // wrapped to the start of the buffer
+		{4, 5, "56789abcdef0123"}, // full, one byte is unused
+	}
+	for _, v := range tests {
+		m.setOffsets(testUpDesc, v.wr, v.rd)
+		buf, err := cb.Read(0)
+		if err != nil {
+			t.Errorf("wr %d rd %d: %v", v.wr, v.rd, err)
+			continue
+		}
+		if string(buf) != v.s {
+			t.Errorf("wr %d rd %d: read %q, expected %q", v.wr, v.rd, buf, v.s)
+		}
+		if wr, rd := m.offsets(testUpDesc); wr != v.wr || rd != v.wr {
+			t.Errorf("wr %d rd %d: offsets are wr %d rd %d after the read", v.wr, v.rd, wr, rd)
+		}
+	}
+	// bad offsets
+	m.setOffsets(testUpDesc, testUpSize, 0)
+	_, err = cb.Read(0)
+	if err == nil {
+		t.Error("expected an error for a bad write offset")
+	}
+	// no channel
+	_, err = cb.Read(1)
+	if err == nil {
+		t.Error("expected an error for a missing channel")
+	}
+}
+
+func Test_Write(t *testing.T) {
+	tests := []struct {
+		mode   uint
+		wr, rd uint
+		s      string
+		n      int
+		buf    string // down buffer after the write
+		wrOff  uint
+	}{
+		// space for the data
+		{modeSkip, 0, 0, "abc", 3, "abc.....", 3},
+		{modeTrim, 0, 0, "abc", 3, "abc.....", 3},
+		// wrap
+		{modeTrim, 6, 6, "abcde", 5, "cde...ab", 3},
+		// not enough space
+		{modeSkip, 0, 0, "abcdefghij", 0, "........", 0},
+		{modeTrim, 0, 0, "abcdefghij", 7, "abcdefg.", 7},
+		{modeBlock, 0, 0, "abcdefghij", 7, "abcdefg.", 7},
+		{modeTrim, 2, 4, "abc", 1, "..a.....", 3},
+		// full
+		{modeTrim, 3, 4, "abc", 0, "........", 3},
+		{modeBlock, 7, 0, "abc", 0, "........", 7},
+	}
+	for _, v := range tests {
+		m := newTestMem()
+		m.WrBytes(testDownBuf, []byte("........"))
+		m.setMode(testDownDesc, v.mode)
+		m.setOffsets(testDownDesc, v.wr, v.rd)
+		cb, err := Open(m, testCB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, err := cb.Write(0, []byte(v.s))
+		if err != nil {
+			t.Errorf("%+v: %v", v, err)
+			continue
+		}
+		if n != v.n {
+			t.Errorf("%+v: wrote %d bytes", v, n)
+		}
+		if buf := m.RdBytes(testDownBuf, testDownSize); !bytes.Equal(buf, []byte(v.buf)) {
+			t.Errorf("%+v: down buffer %q", v, buf)
+		}
+		if wr, rd := m.offsets(testDownDesc); wr != v.wrOff || rd != v.rd {
+			t.Errorf("%+v: offsets are wr %d rd %d after the write", v, wr, rd)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
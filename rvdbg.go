@@ -0,0 +1,479 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Debugger Library
+
+This package provides the debugger functions without the CLI, so the
+targets can be driven by other Go programs (E.g. hardware-in-the-loop tests).
+
+	t, err := rvdbg.Open("gd32v", "jlink")
+	if err != nil {
+		...
+	}
+	defer t.Close()
+	err = t.Halt()
+	pc, err := t.RdReg("pc")
+
+*/
+//-----------------------------------------------------------------------------
+
+package rvdbg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/deadsy/rvdbg/cpu/riscv"
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/flash"
+	"github.com/deadsy/rvdbg/itf"
+	"github.com/deadsy/rvdbg/jtag"
+	"github.com/deadsy/rvdbg/mem"
+	"github.com/deadsy/rvdbg/soc"
+	"github.com/deadsy/rvdbg/target"
+	"github.com/deadsy/rvdbg/target/gd32v"
+	"github.com/deadsy/rvdbg/target/maixgo"
+	"github.com/deadsy/rvdbg/target/redv"
+	"github.com/deadsy/rvdbg/target/wap"
+)
+
+//-----------------------------------------------------------------------------
+
+func init() {
+	target.Add(&gd32v.Info)
+	target.Add(&wap.Info)
+	target.Add(&maixgo.Info)
+	target.Add(&redv.Info)
+}
+
+// NewTarget returns the target for the target name using a JTAG driver.
+func NewTarget(name string, drv jtag.Driver) (target.Target, error) {
+	switch name {
+	case "wap":
+		return wap.New(drv)
+	case "maixgo":
+		return maixgo.New(drv)
+	case "gd32v":
+		return gd32v.New(drv)
+	case "redv":
+		return redv.New(drv)
+	}
+	return nil, fmt.Errorf("target \"%s\" not found", name)
+}
+
+// SetAuthenticator sets the debug module authenticator for a target.
+// It is used when the target is opened, E.g. to unlock a locked part.
+func SetAuthenticator(targetName string, auth rv.Authenticator) error {
+	info := target.Lookup(targetName)
+	if info == nil {
+		return fmt.Errorf("target \"%s\" not found", targetName)
+	}
+	info.Auth = auth
+	return nil
+}
+
+//-----------------------------------------------------------------------------
+
+// Target is an open debug target.
+type Target struct {
+	tgt     target.Target // the target
+	drv     jtag.Driver   // jtag driver
+	ownsDrv bool          // the jtag driver is closed with the target
+}
+
+// Open opens a target using a debug interface.
+// An empty interface name selects the default interface for the target.
+func Open(targetName, interfaceName string) (*Target, error) {
+	info := target.Lookup(targetName)
+	if info == nil {
+		return nil, fmt.Errorf("target \"%s\" not found", targetName)
+	}
+	typ := info.DbgType
+	if interfaceName != "" {
+		x := itf.Lookup(interfaceName)
+		if x == nil {
+			return nil, fmt.Errorf("debug interface \"%s\" not found", interfaceName)
+		}
+		typ = x.Type
+	}
+	if typ == itf.TypeNone {
+		return nil, errors.New("no debug interface specified")
+	}
+	drv, err := itf.NewJtagDriver(typ, info.DbgSpeed)
+	if err != nil {
+		return nil, err
+	}
+	t, err := New(targetName, drv)
+	if err != nil {
+		drv.Close()
+		return nil, err
+	}
+	t.ownsDrv = true
+	return t, nil
+}
+
+// New returns a target using an existing JTAG driver (E.g. a simulation).
+// The driver is not closed with the target.
+func New(targetName string, drv jtag.Driver) (*Target, error) {
+	tgt, err := NewTarget(targetName, drv)
+	if err != nil {
+		return nil, err
+	}
+	return &Target{
+		tgt: tgt,
+		drv: drv,
+	}, nil
+}
+
+// Close closes the target.
+func (t *Target) Close() error {
+	t.tgt.Shutdown()
+	if t.ownsDrv {
+		return t.drv.Close()
+	}
+	return nil
+}
+
+// Target returns the underlying target (E.g. for use with the CLI).
+func (t *Target) Target() target.Target {
+	return t.tgt
+}
+
+//-----------------------------------------------------------------------------
+// target drivers
+
+type riscvTarget interface {
+	GetRiscvDebug() rv.Debug
+}
+
+type socTarget interface {
+	GetSoC() (*soc.Device, soc.Driver)
+}
+
+type memTarget interface {
+	GetMemoryDriver() mem.Driver
+}
+
+type flashTarget interface {
+	GetFlashDriver() flash.Driver
+}
+
+func (t *Target) debug() (rv.Debug, error) {
+	if x, ok := t.tgt.(riscvTarget); ok {
+		return x.GetRiscvDebug(), nil
+	}
+	return nil, errors.New("target has no risc-v debugger")
+}
+
+func (t *Target) soc() (*soc.Device, soc.Driver, error) {
+	if x, ok := t.tgt.(socTarget); ok {
+		dev, drv := x.GetSoC()
+		return dev, drv, nil
+	}
+	return nil, nil, errors.New("target has no soc device")
+}
+
+func (t *Target) memory() (mem.Driver, error) {
+	if x, ok := t.tgt.(memTarget); ok {
+		return x.GetMemoryDriver(), nil
+	}
+	return nil, errors.New("target has no memory driver")
+}
+
+func (t *Target) flash() (flash.Driver, error) {
+	if x, ok := t.tgt.(flashTarget); ok {
+		return x.GetFlashDriver(), nil
+	}
+	return nil, errors.New("target has no flash driver")
+}
+
+// context returns the register/symbol context for the current hart.
+func (t *Target) context() (*riscv.Context, error) {
+	dbg, err := t.debug()
+	if err != nil {
+		return nil, err
+	}
+	dev, _, err := t.soc()
+	if err != nil {
+		return nil, err
+	}
+	return riscv.NewContext(dbg, dev), nil
+}
+
+//-----------------------------------------------------------------------------
+// hart control
+
+// Harts returns the number of harts.
+func (t *Target) Harts() (int, error) {
+	dbg, err := t.debug()
+	if err != nil {
+		return 0, err
+	}
+	return dbg.GetHartCount(), nil
+}
+
+// Hart returns the information for the current hart.
+func (t *Target) Hart() (*rv.HartInfo, error) {
+	dbg, err := t.debug()
+	if err != nil {
+		return nil, err
+	}
+	return dbg.GetCurrentHart(), nil
+}
+
+// SelectHart sets the current hart.
+func (t *Target) SelectHart(id int) error {
+	dbg, err := t.debug()
+	if err != nil {
+		return err
+	}
+	_, err = dbg.SetCurrentHart(id)
+	return err
+}
+
+// Halt halts the current hart.
+func (t *Target) Halt() error {
+	dbg, err := t.debug()
+	if err != nil {
+		return err
+	}
+	return dbg.HaltHart()
+}
+
+// Resume resumes the current hart.
+func (t *Target) Resume() error {
+	dbg, err := t.debug()
+	if err != nil {
+		return err
+	}
+	return dbg.ResumeHart()
+}
+
+const dcsrStep = 1 << 2 // dcsr.step
+
+// Step single steps the current (halted) hart.
+func (t *Target) Step() error {
+	dbg, err := t.debug()
+	if err != nil {
+		return err
+	}
+	hi := dbg.GetCurrentHart()
+	if hi.State != rv.Halted {
+		return fmt.Errorf("hart%d is not halted", hi.ID)
+	}
+	dcsr, err := dbg.RdCSR(rv.DCSR, 0)
+	if err != nil {
+		return err
+	}
+	err = dbg.WrCSR(rv.DCSR, 0, dcsr|dcsrStep)
+	if err != nil {
+		return err
+	}
+	// the hart halts after executing a single instruction
+	err = dbg.ResumeHart()
+	if err != nil {
+		return err
+	}
+	err = dbg.HaltHart()
+	if err != nil {
+		return err
+	}
+	return dbg.WrCSR(rv.DCSR, 0, dcsr&^dcsrStep)
+}
+
+//-----------------------------------------------------------------------------
+// registers
+
+// RdReg reads a register of the current (halted) hart.
+// The name is a GPR (x0..x31 or abi name), FPR (f0..f31 or abi name), CSR or "pc".
+func (t *Target) RdReg(name string) (uint64, error) {
+	ctx, err := t.context()
+	if err != nil {
+		return 0, err
+	}
+	val, err := ctx.Register(name)
+	return uint64(val), err
+}
+
+// WrReg writes a register of the current (halted) hart.
+func (t *Target) WrReg(name string, val uint64) error {
+	ctx, err := t.context()
+	if err != nil {
+		return err
+	}
+	return ctx.SetRegister(name, uint(val))
+}
+
+// GPRs reads the general purpose registers of the current (halted) hart.
+func (t *Target) GPRs() ([]uint64, error) {
+	dbg, err := t.debug()
+	if err != nil {
+		return nil, err
+	}
+	hi := dbg.GetCurrentHart()
+	if hi.State != rv.Halted {
+		return nil, fmt.Errorf("hart%d is not halted", hi.ID)
+	}
+	reg := make([]uint64, hi.Nregs)
+	for i := range reg {
+		reg[i], err = dbg.RdGPR(uint(i), 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return reg, nil
+}
+
+//-----------------------------------------------------------------------------
+// memory
+
+// RdMem reads n width-bit values from memory.
+func (t *Target) RdMem(width, addr, n uint) ([]uint, error) {
+	drv, err := t.memory()
+	if err != nil {
+		return nil, err
+	}
+	return drv.RdMem(width, addr, n)
+}
+
+// WrMem writes width-bit values to memory.
+func (t *Target) WrMem(width, addr uint, buf []uint) error {
+	drv, err := t.memory()
+	if err != nil {
+		return err
+	}
+	return drv.WrMem(width, addr, buf)
+}
+
+//-----------------------------------------------------------------------------
+// soc registers
+
+// splitName splits a "peripheral.register" name.
+func splitName(name string) (string, string, error) {
+	x := strings.Split(name, ".")
+	if len(x) != 2 {
+		return "", "", fmt.Errorf("bad register name \"%s\", expected <peripheral>.<register>", name)
+	}
+	return x[0], x[1], nil
+}
+
+// Symbol returns the address of a peripheral or peripheral register (E.g. "GPIOA.CTL0").
+func (t *Target) Symbol(name string) (uint, error) {
+	ctx, err := t.context()
+	if err != nil {
+		return 0, err
+	}
+	return ctx.Symbol(name)
+}
+
+// RdSoC reads and decodes a peripheral register (E.g. "GPIOA.CTL0").
+func (t *Target) RdSoC(name string) (*soc.RegisterValue, error) {
+	pname, rname, err := splitName(name)
+	if err != nil {
+		return nil, err
+	}
+	dev, drv, err := t.soc()
+	if err != nil {
+		return nil, err
+	}
+	r, err := dev.GetPeripheralRegister(pname, rname)
+	if err != nil {
+		return nil, err
+	}
+	v := r.Value(drv, true)
+	if v.Error != "" {
+		return nil, errors.New(v.Error)
+	}
+	return v, nil
+}
+
+// WrSoC writes a peripheral register (E.g. "GPIOA.CTL0").
+func (t *Target) WrSoC(name string, val uint) error {
+	pname, rname, err := splitName(name)
+	if err != nil {
+		return err
+	}
+	dev, drv, err := t.soc()
+	if err != nil {
+		return err
+	}
+	return dev.WrPeripheralRegister(drv, pname, rname, val)
+}
+
+//-----------------------------------------------------------------------------
+// flash
+
+// FlashSectors returns the flash sectors.
+func (t *Target) FlashSectors() ([]*mem.RegionInfo, error) {
+	drv, err := t.flash()
+	if err != nil {
+		return nil, err
+	}
+	s := []*mem.RegionInfo{}
+	for _, r := range drv.GetSectors() {
+		s = append(s, r.Info())
+	}
+	return s, nil
+}
+
+// FlashErase erases the flash sectors overlapping a memory region.
+func (t *Target) FlashErase(addr, size uint) error {
+	drv, err := t.flash()
+	if err != nil {
+		return err
+	}
+	r := mem.NewRegion("", addr, size, nil)
+	for _, s := range drv.GetSectors() {
+		if s.Overlaps(r) {
+			err := drv.Erase(s)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// FlashEraseAll erases all of the flash.
+func (t *Target) FlashEraseAll() error {
+	drv, err := t.flash()
+	if err != nil {
+		return err
+	}
+	return drv.EraseAll()
+}
+
+// FlashProgram erases the flash sectors for a buffer and then writes it.
+func (t *Target) FlashProgram(addr uint, buf []byte) error {
+	if len(buf) == 0 {
+		return nil
+	}
+	drv, err := t.flash()
+	if err != nil {
+		return err
+	}
+	// check the buffer is within the flash sectors before erasing anything
+	end := addr + uint(len(buf))
+	for a := addr; a < end; {
+		next := a
+		for _, s := range drv.GetSectors() {
+			ri := s.Info()
+			if a >= ri.Addr && a <= ri.End {
+				next = ri.End + 1
+				break
+			}
+		}
+		if next == a {
+			return fmt.Errorf("0x%08x is not in a flash sector", a)
+		}
+		a = next
+	}
+	err = t.FlashErase(addr, uint(len(buf)))
+	if err != nil {
+		return err
+	}
+	return drv.Write(addr, buf)
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,57 @@
+//-----------------------------------------------------------------------------
+/*
+
+RISC-V Debugger Library Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package rvdbg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/deadsy/rvdbg/target"
+)
+
+//-----------------------------------------------------------------------------
+
+func Test_Open(t *testing.T) {
+	tests := []struct {
+		target, itf string
+		err         string
+	}{
+		{"bogus", "", "target \"bogus\" not found"},
+		{"bogus", "jlink", "target \"bogus\" not found"},
+		{"gd32v", "", "no debug interface specified"},
+		{"gd32v", "bogus", "debug interface \"bogus\" not found"},
+	}
+	for _, v := range tests {
+		_, err := Open(v.target, v.itf)
+		if err == nil || !strings.HasPrefix(err.Error(), v.err) {
+			t.Errorf("%q %q: error %v, expected %q", v.target, v.itf, err, v.err)
+		}
+	}
+	_, err := NewTarget("bogus", nil)
+	if err == nil {
+		t.Error("expected an error for an unknown target")
+	}
+}
+
+func Test_Targets(t *testing.T) {
+	for _, name := range []string{"gd32v", "maixgo", "redv", "wap"} {
+		if target.Lookup(name) == nil {
+			t.Errorf("target \"%s\" is not registered", name)
+		}
+	}
+}
+
+func Test_SetAuthenticator(t *testing.T) {
+	err := SetAuthenticator("bogus", nil)
+	if err == nil {
+		t.Error("expected an error for an unknown target")
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,430 @@
+//-----------------------------------------------------------------------------
+/*
+
+Semihosting
+
+Service semihosting requests from the target firmware using the host
+filesystem and console. The operation numbers and parameter blocks are
+common to ARM and RISC-V semihosting.
+
+The debugger CLI owns the host stdin, so console input for the firmware is
+queued with Input. A console read with no queued input returns ErrNoInput
+and the request is left pending.
+
+*/
+//-----------------------------------------------------------------------------
+
+package semihost
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+
+// Semihosting operations.
+const (
+	SysOpen         = 0x01
+	SysClose        = 0x02
+	SysWritec       = 0x03
+	SysWrite0       = 0x04
+	SysWrite        = 0x05
+	SysRead         = 0x06
+	SysClock        = 0x10
+	SysTime         = 0x11
+	SysErrno        = 0x13
+	SysExit         = 0x18
+	SysExitExtended = 0x20
+)
+
+var opName = map[uint]string{
+	SysOpen:         "SYS_OPEN",
+	SysClose:        "SYS_CLOSE",
+	SysWritec:       "SYS_WRITEC",
+	SysWrite0:       "SYS_WRITE0",
+	SysWrite:        "SYS_WRITE",
+	SysRead:         "SYS_READ",
+	SysClock:        "SYS_CLOCK",
+	SysTime:         "SYS_TIME",
+	SysErrno:        "SYS_ERRNO",
+	SysExit:         "SYS_EXIT",
+	SysExitExtended: "SYS_EXIT_EXTENDED",
+}
+
+// OpName returns the name of a semihosting operation.
+func OpName(op uint) string {
+	if name, ok := opName[op]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", op)
+}
+
+// adpStoppedApplicationExit is the SYS_EXIT reason for a normal exit.
+const adpStoppedApplicationExit = 0x20026
+
+// maxString is the maximum length of a string read from the target.
+const maxString = 4096
+
+// maxLength is the maximum length of a SYS_READ/SYS_WRITE buffer.
+const maxLength = 1 << 20
+
+// chunkSize is the size of the target memory accesses for a SYS_READ/SYS_WRITE buffer.
+const chunkSize = 1024
+
+// ErrNoInput is returned for a console read when there is no queued input.
+var ErrNoInput = errors.New("waiting for console input")
+
+// fopen modes for SYS_OPEN
+var openFlags = [12]int{
+	os.O_RDONLY, os.O_RDONLY, // r, rb
+	os.O_RDWR, os.O_RDWR, // r+, r+b
+	os.O_WRONLY | os.O_CREATE | os.O_TRUNC, os.O_WRONLY | os.O_CREATE | os.O_TRUNC, // w, wb
+	os.O_RDWR | os.O_CREATE | os.O_TRUNC, os.O_RDWR | os.O_CREATE | os.O_TRUNC, // w+, w+b
+	os.O_WRONLY | os.O_CREATE | os.O_APPEND, os.O_WRONLY | os.O_CREATE | os.O_APPEND, // a, ab
+	os.O_RDWR | os.O_CREATE | os.O_APPEND, os.O_RDWR | os.O_CREATE | os.O_APPEND, // a+, a+b
+}
+
+//-----------------------------------------------------------------------------
+
+// Memory is target memory access for semihosting.
+type Memory interface {
+	RdMem(width, addr, n uint) ([]uint, error) // read width-bit memory buffer
+	WrMem(width, addr uint, val []uint) error  // write width-bit memory buffer
+}
+
+// Host is the host side state for semihosting.
+type Host struct {
+	out    io.Writer         // console output
+	in     bytes.Buffer      // queued console input
+	files  map[uint]*os.File // open files (nil == console)
+	handle uint              // next file handle
+	errno  int               // errno of the last failed operation
+	start  time.Time         // start time for SYS_CLOCK
+	exited bool              // the firmware has exited
+	code   int               // firmware exit code
+}
+
+// NewHost returns the host side state for semihosting.
+func NewHost(out io.Writer) *Host {
+	return &Host{
+		out:    out,
+		files:  map[uint]*os.File{},
+		handle: 1,
+		start:  time.Now(),
+	}
+}
+
+// Input queues console input for the firmware.
+func (h *Host) Input(buf []byte) {
+	h.in.Write(buf)
+}
+
+// Exited returns true and the exit code if the firmware has exited.
+func (h *Host) Exited() (bool, int) {
+	return h.exited, h.code
+}
+
+// Close closes all open files.
+func (h *Host) Close() {
+	for k, f := range h.files {
+		if f != nil {
+			f.Close()
+		}
+		delete(h.files, k)
+	}
+}
+
+//-----------------------------------------------------------------------------
+
+// call is a semihosting call.
+type call struct {
+	mem  Memory
+	xlen uint
+	arg  uint // parameter/parameter block address
+}
+
+// word returns the n-th word of the parameter block.
+func (c *call) word(n uint) (uint, error) {
+	x, err := c.mem.RdMem(c.xlen, c.arg+n*(c.xlen>>3), 1)
+	if err != nil {
+		return 0, err
+	}
+	return x[0], nil
+}
+
+// words returns the first n words of the parameter block.
+func (c *call) words(n uint) ([]uint, error) {
+	return c.mem.RdMem(c.xlen, c.arg, n)
+}
+
+// rdBytes reads a byte buffer from target memory.
+func (c *call) rdBytes(addr, n uint) ([]byte, error) {
+	if n == 0 {
+		return nil, nil
+	}
+	x, err := c.mem.RdMem(8, addr, n)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	for i := range x {
+		buf[i] = byte(x[i])
+	}
+	return buf, nil
+}
+
+// wrBytes writes a byte buffer to target memory.
+func (c *call) wrBytes(addr uint, buf []byte) error {
+	if len(buf) == 0 {
+		return nil
+	}
+	x := make([]uint, len(buf))
+	for i := range buf {
+		x[i] = uint(buf[i])
+	}
+	return c.mem.WrMem(8, addr, x)
+}
+
+// rdString reads a zero terminated string from target memory.
+func (c *call) rdString(addr uint) (string, error) {
+	s := []byte{}
+	for len(s) < maxString {
+		buf, err := c.rdBytes(addr, 32)
+		if err != nil {
+			return "", err
+		}
+		if i := bytes.IndexByte(buf, 0); i >= 0 {
+			return string(append(s, buf[:i]...)), nil
+		}
+		s = append(s, buf...)
+		addr += 32
+	}
+	return "", errors.New("semihosting string is too long")
+}
+
+// minusOne is the -1 error return value for the xlen.
+func (c *call) minusOne() uint {
+	return uint((uint64(1) << c.xlen) - 1)
+}
+
+//-----------------------------------------------------------------------------
+
+// setErrno records the errno for a failed host operation.
+func (h *Host) setErrno(err error) {
+	if pe, ok := err.(*os.PathError); ok {
+		err = pe.Err
+	}
+	if errno, ok := err.(syscall.Errno); ok {
+		h.errno = int(errno)
+		return
+	}
+	h.errno = int(syscall.EIO)
+}
+
+func (h *Host) open(c *call) (uint, error) {
+	x, err := c.words(3)
+	if err != nil {
+		return 0, err
+	}
+	name, err := c.rdString(x[0])
+	if err != nil {
+		return 0, err
+	}
+	mode := x[1]
+	if mode >= uint(len(openFlags)) {
+		h.errno = int(syscall.EINVAL)
+		return c.minusOne(), nil
+	}
+	handle := h.handle
+	if name == ":tt" {
+		// console
+		h.files[handle] = nil
+	} else {
+		f, err := os.OpenFile(name, openFlags[mode], 0644)
+		if err != nil {
+			h.setErrno(err)
+			return c.minusOne(), nil
+		}
+		h.files[handle] = f
+	}
+	h.handle++
+	return handle, nil
+}
+
+func (h *Host) close(c *call) (uint, error) {
+	handle, err := c.word(0)
+	if err != nil {
+		return 0, err
+	}
+	f, ok := h.files[handle]
+	if !ok {
+		h.errno = int(syscall.EBADF)
+		return c.minusOne(), nil
+	}
+	delete(h.files, handle)
+	if f != nil {
+		err := f.Close()
+		if err != nil {
+			h.setErrno(err)
+			return c.minusOne(), nil
+		}
+	}
+	return 0, nil
+}
+
+func (h *Host) write(c *call) (uint, error) {
+	x, err := c.words(3)
+	if err != nil {
+		return 0, err
+	}
+	handle, addr, n := x[0], x[1], x[2]
+	f, ok := h.files[handle]
+	if !ok {
+		h.errno = int(syscall.EBADF)
+		return n, nil
+	}
+	if n > maxLength {
+		h.errno = int(syscall.EINVAL)
+		return n, nil
+	}
+	var w io.Writer = h.out
+	if f != nil {
+		w = f
+	}
+	done := uint(0)
+	for done < n {
+		k := n - done
+		if k > chunkSize {
+			k = chunkSize
+		}
+		buf, err := c.rdBytes(addr+done, k)
+		if err != nil {
+			return 0, err
+		}
+		m, err := w.Write(buf)
+		done += uint(m)
+		if err != nil {
+			h.setErrno(err)
+			break
+		}
+	}
+	// return the number of bytes not written
+	return n - done, nil
+}
+
+func (h *Host) read(c *call) (uint, error) {
+	x, err := c.words(3)
+	if err != nil {
+		return 0, err
+	}
+	handle, addr, n := x[0], x[1], x[2]
+	f, ok := h.files[handle]
+	if !ok {
+		h.errno = int(syscall.EBADF)
+		return c.minusOne(), nil
+	}
+	if n > maxLength {
+		h.errno = int(syscall.EINVAL)
+		return c.minusOne(), nil
+	}
+	var r io.Reader = &h.in
+	if f != nil {
+		r = f
+	} else if n != 0 && h.in.Len() == 0 {
+		return 0, ErrNoInput
+	}
+	buf := make([]byte, chunkSize)
+	done := uint(0)
+	for done < n {
+		k := n - done
+		if k > chunkSize {
+			k = chunkSize
+		}
+		m, err := r.Read(buf[:k])
+		werr := c.wrBytes(addr+done, buf[:m])
+		if werr != nil {
+			return 0, werr
+		}
+		done += uint(m)
+		if err != nil {
+			if err != io.EOF {
+				h.setErrno(err)
+			}
+			break
+		}
+		if uint(m) < k {
+			// short read, don't wait for more
+			break
+		}
+	}
+	// return the number of bytes not read
+	return n - done, nil
+}
+
+func (h *Host) exit(c *call, op uint) (uint, error) {
+	var reason, subcode uint
+	if op == SysExit && c.xlen == 32 {
+		// the parameter is the reason code
+		reason = c.arg
+	} else {
+		x, err := c.words(2)
+		if err != nil {
+			return 0, err
+		}
+		reason, subcode = x[0], x[1]
+	}
+	h.exited = true
+	h.code = 0
+	if reason != adpStoppedApplicationExit {
+		h.code = 1
+	} else if c.xlen == 64 || op == SysExitExtended {
+		h.code = int(int32(subcode))
+	}
+	return 0, nil
+}
+
+// Call services a semihosting request. It returns the result value for the firmware.
+func (h *Host) Call(mem Memory, xlen, op, arg uint) (uint, error) {
+	c := &call{mem, xlen, arg}
+	switch op {
+	case SysOpen:
+		return h.open(c)
+	case SysClose:
+		return h.close(c)
+	case SysWritec:
+		buf, err := c.rdBytes(arg, 1)
+		if err != nil {
+			return 0, err
+		}
+		h.out.Write(buf)
+		return 0, nil
+	case SysWrite0:
+		s, err := c.rdString(arg)
+		if err != nil {
+			return 0, err
+		}
+		io.WriteString(h.out, s)
+		return 0, nil
+	case SysWrite:
+		return h.write(c)
+	case SysRead:
+		return h.read(c)
+	case SysClock:
+		return uint(time.Since(h.start) / (10 * time.Millisecond)), nil
+	case SysTime:
+		return uint(time.Now().Unix()), nil
+	case SysErrno:
+		return uint(h.errno), nil
+	case SysExit, SysExitExtended:
+		return h.exit(c, op)
+	}
+	return 0, fmt.Errorf("unsupported semihosting operation %s", OpName(op))
+}
+
+//-----------------------------------------------------------------------------
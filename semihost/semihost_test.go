@@ -0,0 +1,160 @@
+//-----------------------------------------------------------------------------
+/*
+
+Semihosting Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package semihost
+
+import (
+	"bytes"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/deadsy/rvdbg/mem/memtest"
+)
+
+//-----------------------------------------------------------------------------
+
+// testMemory is a memory with string helpers.
+type testMemory struct {
+	memtest.Memory
+}
+
+// wrString writes a null terminated string.
+func (m testMemory) wrString(addr uint, s string) {
+	m.WrBytes(addr, append([]byte(s), 0))
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Console(t *testing.T) {
+	out := &bytes.Buffer{}
+	h := NewHost(out)
+	m := testMemory{memtest.Memory{}}
+	m.wrString(0x100, ":tt")
+
+	// open the console for writing
+	m.WrMem(32, 0x10, []uint{0x100, 4, 3})
+	fd, err := h.Call(m, 32, SysOpen, 0x10)
+	if err != nil || fd == 0xffffffff {
+		t.Fatalf("open failed %v", err)
+	}
+	// write
+	m.wrString(0x200, "hello ")
+	m.WrMem(32, 0x10, []uint{fd, 0x200, 6})
+	n, err := h.Call(m, 32, SysWrite, 0x10)
+	if err != nil || n != 0 {
+		t.Errorf("write returned %d %v", n, err)
+	}
+	// write0
+	m.wrString(0x300, "world\n")
+	h.Call(m, 32, SysWrite0, 0x300)
+	if out.String() != "hello world\n" {
+		t.Errorf("console output %q", out.String())
+	}
+	// read without console input
+	m.WrMem(32, 0x10, []uint{fd, 0x400, 8})
+	_, err = h.Call(m, 32, SysRead, 0x10)
+	if err != ErrNoInput {
+		t.Errorf("read without input returned %v", err)
+	}
+	// read
+	h.Input([]byte("input"))
+	n, err = h.Call(m, 32, SysRead, 0x10)
+	if err != nil || n != 3 {
+		t.Errorf("read returned %d %v", n, err)
+	}
+	x, _ := m.RdMem(8, 0x400, 5)
+	if string([]byte{byte(x[0]), byte(x[1]), byte(x[2]), byte(x[3]), byte(x[4])}) != "input" {
+		t.Errorf("read data %v", x)
+	}
+	// close a bad handle
+	m.WrMem(32, 0x10, []uint{99})
+	n, _ = h.Call(m, 32, SysClose, 0x10)
+	if n != 0xffffffff {
+		t.Errorf("close of a bad handle returned %d", n)
+	}
+}
+
+func Test_Length(t *testing.T) {
+	out := &bytes.Buffer{}
+	h := NewHost(out)
+	m := testMemory{memtest.Memory{}}
+	m.wrString(0x100, ":tt")
+	m.WrMem(32, 0x10, []uint{0x100, 4, 3})
+	fd, _ := h.Call(m, 32, SysOpen, 0x10)
+
+	// a write larger than a chunk
+	s := strings.Repeat("0123456789abcdef", (chunkSize*3)/16+1)
+	m.wrString(0x1000, s)
+	m.WrMem(32, 0x10, []uint{fd, 0x1000, uint(len(s))})
+	n, err := h.Call(m, 32, SysWrite, 0x10)
+	if err != nil || n != 0 || out.String() != s {
+		t.Errorf("chunked write returned %d %v, %d bytes written", n, err, out.Len())
+	}
+
+	// a read larger than a chunk
+	h.Input([]byte(s))
+	m.WrMem(32, 0x10, []uint{fd, 0x10000, uint(len(s)) + 2})
+	n, err = h.Call(m, 32, SysRead, 0x10)
+	if err != nil || n != 2 {
+		t.Errorf("chunked read returned %d %v", n, err)
+	}
+	x, _ := m.RdMem(8, 0x10000, uint(len(s)))
+	for i := range x {
+		if byte(x[i]) != s[i] {
+			t.Fatalf("chunked read data[%d] is 0x%02x", i, x[i])
+		}
+	}
+
+	// lengths that are too large
+	m.WrMem(32, 0x10, []uint{fd, 0x1000, maxLength + 1})
+	n, err = h.Call(m, 32, SysWrite, 0x10)
+	if err != nil || n != maxLength+1 {
+		t.Errorf("large write returned %d %v", n, err)
+	}
+	n, err = h.Call(m, 32, SysRead, 0x10)
+	if err != nil || n != 0xffffffff {
+		t.Errorf("large read returned %d %v", n, err)
+	}
+	errno, _ := h.Call(m, 32, SysErrno, 0)
+	if errno != uint(syscall.EINVAL) {
+		t.Errorf("errno is %d, expected EINVAL", errno)
+	}
+}
+
+func Test_Exit(t *testing.T) {
+	m := testMemory{memtest.Memory{}}
+	test := []struct {
+		xlen   uint
+		op     uint
+		reason uint
+		sub    uint
+		code   int
+	}{
+		{32, SysExit, adpStoppedApplicationExit, 0, 0},
+		{32, SysExit, 0x20024, 0, 1},
+		{32, SysExitExtended, adpStoppedApplicationExit, 3, 3},
+		{64, SysExit, adpStoppedApplicationExit, 7, 7},
+	}
+	for _, v := range test {
+		h := NewHost(&bytes.Buffer{})
+		arg := uint(0x10)
+		if v.xlen == 32 && v.op == SysExit {
+			arg = v.reason
+		} else {
+			m.WrMem(v.xlen, 0x10, []uint{v.reason, v.sub})
+		}
+		h.Call(m, v.xlen, v.op, arg)
+		exited, code := h.Exited()
+		if !exited || code != v.code {
+			t.Errorf("%+v: exited %t code %d", v, exited, code)
+		}
+	}
+}
+
+//-----------------------------------------------------------------------------
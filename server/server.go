@@ -0,0 +1,328 @@
+//-----------------------------------------------------------------------------
+/*
+
+Remote Control Server
+
+A JSON-RPC (version 1.0) server for sharing a debug target between several
+client programs. Each connection is served concurrently, but the requests
+are serialized so the JTAG operations of different clients don't interleave.
+Each connection has its own current hart, which is selected before each of
+its requests.
+
+The service name is "Debugger", E.g.
+
+	{"method": "Debugger.RdMem", "params": [{"width": 32, "addr": 536870912, "n": 4}], "id": 1}
+
+*/
+//-----------------------------------------------------------------------------
+
+package server
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"strings"
+	"sync"
+
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/mem"
+	"github.com/deadsy/rvdbg/soc"
+	"github.com/deadsy/rvdbg/util/log"
+)
+
+//-----------------------------------------------------------------------------
+// request arguments
+
+// Empty is the argument/reply for requests with no data.
+type Empty struct{}
+
+// HartArgs are the arguments for hart requests.
+type HartArgs struct {
+	ID int `json:"id"`
+}
+
+// RegArgs are the arguments for register requests.
+type RegArgs struct {
+	Name  string `json:"name"`
+	Value uint64 `json:"value"`
+}
+
+// MemArgs are the arguments for memory requests.
+type MemArgs struct {
+	Width uint   `json:"width"`
+	Addr  uint   `json:"addr"`
+	N     uint   `json:"n"`
+	Data  []uint `json:"data"`
+}
+
+// FlashArgs are the arguments for flash requests.
+type FlashArgs struct {
+	Addr uint   `json:"addr"`
+	Size uint   `json:"size"`
+	Data []byte `json:"data"`
+}
+
+//-----------------------------------------------------------------------------
+
+// Target is the debug target interface used by the server (E.g. *rvdbg.Target).
+type Target interface {
+	Halt() error
+	Resume() error
+	Step() error
+	Hart() (*rv.HartInfo, error)
+	SelectHart(id int) error
+	RdReg(name string) (uint64, error)
+	WrReg(name string, val uint64) error
+	GPRs() ([]uint64, error)
+	RdMem(width, addr, n uint) ([]uint, error)
+	WrMem(width, addr uint, buf []uint) error
+	RdSoC(name string) (*soc.RegisterValue, error)
+	WrSoC(name string, val uint) error
+	FlashSectors() ([]*mem.RegionInfo, error)
+	FlashErase(addr, size uint) error
+	FlashEraseAll() error
+	FlashProgram(addr uint, buf []byte) error
+}
+
+//-----------------------------------------------------------------------------
+
+// Debugger is the RPC service for a debug target connection.
+type Debugger struct {
+	mu   *sync.Mutex // serializes the target access (shared by all connections)
+	t    Target      // debug target
+	hart int         // current hart for this connection
+}
+
+// call selects the current hart for this connection and runs a target function.
+func (d *Debugger) call(f func() error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	hi, err := d.t.Hart()
+	if err == nil && hi.ID != d.hart {
+		err := d.t.SelectHart(d.hart)
+		if err != nil {
+			return err
+		}
+	}
+	return f()
+}
+
+// Halt halts the current hart.
+func (d *Debugger) Halt(args *Empty, reply *Empty) error {
+	return d.call(d.t.Halt)
+}
+
+// Resume resumes the current hart.
+func (d *Debugger) Resume(args *Empty, reply *Empty) error {
+	return d.call(d.t.Resume)
+}
+
+// Step single steps the current hart.
+func (d *Debugger) Step(args *Empty, reply *Empty) error {
+	return d.call(d.t.Step)
+}
+
+// Hart returns the information for the current hart.
+func (d *Debugger) Hart(args *Empty, reply *rv.HartInfo) error {
+	return d.call(func() error {
+		hi, err := d.t.Hart()
+		if err != nil {
+			return err
+		}
+		*reply = *hi
+		return nil
+	})
+}
+
+// SelectHart sets the current hart for this connection.
+func (d *Debugger) SelectHart(args *HartArgs, reply *Empty) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	err := d.t.SelectHart(args.ID)
+	if err != nil {
+		return err
+	}
+	d.hart = args.ID
+	return nil
+}
+
+// RdReg reads a register of the current hart.
+func (d *Debugger) RdReg(args *RegArgs, reply *uint64) error {
+	return d.call(func() error {
+		val, err := d.t.RdReg(args.Name)
+		*reply = val
+		return err
+	})
+}
+
+// WrReg writes a register of the current hart.
+func (d *Debugger) WrReg(args *RegArgs, reply *Empty) error {
+	return d.call(func() error {
+		return d.t.WrReg(args.Name, args.Value)
+	})
+}
+
+// GPRs reads the general purpose registers of the current hart.
+func (d *Debugger) GPRs(args *Empty, reply *[]uint64) error {
+	return d.call(func() error {
+		reg, err := d.t.GPRs()
+		*reply = reg
+		return err
+	})
+}
+
+// RdMem reads memory.
+func (d *Debugger) RdMem(args *MemArgs, reply *[]uint) error {
+	return d.call(func() error {
+		buf, err := d.t.RdMem(args.Width, args.Addr, args.N)
+		*reply = buf
+		return err
+	})
+}
+
+// WrMem writes memory.
+func (d *Debugger) WrMem(args *MemArgs, reply *Empty) error {
+	return d.call(func() error {
+		return d.t.WrMem(args.Width, args.Addr, args.Data)
+	})
+}
+
+// RdSoC reads and decodes a peripheral register.
+func (d *Debugger) RdSoC(args *RegArgs, reply *soc.RegisterValue) error {
+	return d.call(func() error {
+		v, err := d.t.RdSoC(args.Name)
+		if err != nil {
+			return err
+		}
+		*reply = *v
+		return nil
+	})
+}
+
+// WrSoC writes a peripheral register.
+func (d *Debugger) WrSoC(args *RegArgs, reply *Empty) error {
+	return d.call(func() error {
+		return d.t.WrSoC(args.Name, uint(args.Value))
+	})
+}
+
+// FlashSectors returns the flash sectors.
+func (d *Debugger) FlashSectors(args *Empty, reply *[]*mem.RegionInfo) error {
+	return d.call(func() error {
+		s, err := d.t.FlashSectors()
+		*reply = s
+		return err
+	})
+}
+
+// FlashErase erases the flash sectors overlapping a memory region.
+func (d *Debugger) FlashErase(args *FlashArgs, reply *Empty) error {
+	return d.call(func() error {
+		return d.t.FlashErase(args.Addr, args.Size)
+	})
+}
+
+// FlashEraseAll erases all of the flash.
+func (d *Debugger) FlashEraseAll(args *Empty, reply *Empty) error {
+	return d.call(d.t.FlashEraseAll)
+}
+
+// FlashProgram erases and writes a buffer to flash.
+func (d *Debugger) FlashProgram(args *FlashArgs, reply *Empty) error {
+	return d.call(func() error {
+		return d.t.FlashProgram(args.Addr, args.Data)
+	})
+}
+
+//-----------------------------------------------------------------------------
+
+// Server is a JSON-RPC server for a debug target.
+type Server struct {
+	mu   sync.Mutex    // serializes the target access
+	t    Target        // debug target
+	ln   net.Listener  // listener for client connections
+	done chan struct{} // closed when the server is closed
+}
+
+// New returns a JSON-RPC server for a debug target.
+func New(t Target) (*Server, error) {
+	// check the service can be registered
+	err := rpc.NewServer().Register(&Debugger{})
+	if err != nil {
+		return nil, err
+	}
+	return &Server{
+		t:    t,
+		done: make(chan struct{}),
+	}, nil
+}
+
+// ServeConn serves a single client connection (E.g. a pipe).
+// The current hart for the connection is initially hart 0.
+func (s *Server) ServeConn(conn io.ReadWriteCloser) {
+	d := &Debugger{mu: &s.mu, t: s.t}
+	srv := rpc.NewServer()
+	srv.Register(d)
+	srv.ServeCodec(jsonrpc.NewServerCodec(conn))
+}
+
+// SplitAddress splits a "network:address" string.
+// The network is "tcp" or "unix", and defaults to "tcp".
+func SplitAddress(s string) (string, string) {
+	x := strings.SplitN(s, ":", 2)
+	if len(x) == 2 && (x[0] == "tcp" || x[0] == "unix") {
+		return x[0], x[1]
+	}
+	return "tcp", s
+}
+
+// Listen listens for client connections on the network address.
+func (s *Server) Listen(network, address string) error {
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+	log.Info.Printf("json-rpc server listening on %s:%s", network, ln.Addr())
+	return nil
+}
+
+// Serve accepts and serves client connections until the server is closed.
+// It returns nil after a Close, otherwise the listener error.
+func (s *Server) Serve() error {
+	if s.ln == nil {
+		return errors.New("server is not listening")
+	}
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return nil
+			default:
+				return err
+			}
+		}
+		log.Info.Printf("json-rpc client %s connected", conn.RemoteAddr())
+		go s.ServeConn(conn)
+	}
+}
+
+// Close stops the server listening for client connections.
+func (s *Server) Close() error {
+	if s.ln == nil {
+		return nil
+	}
+	select {
+	case <-s.done:
+		return nil
+	default:
+		close(s.done)
+	}
+	return s.ln.Close()
+}
+
+//-----------------------------------------------------------------------------
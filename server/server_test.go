@@ -0,0 +1,215 @@
+//-----------------------------------------------------------------------------
+/*
+
+Remote Control Server Tests
+
+The server is tested with the redv target on a simulated debug module.
+
+*/
+//-----------------------------------------------------------------------------
+
+package server
+
+import (
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/deadsy/rvdbg"
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/cpu/riscv/sim"
+)
+
+//-----------------------------------------------------------------------------
+
+const ramAddr = 0x80000000
+
+// newTarget returns a 2 hart redv target on a simulated debug module.
+func newTarget(t *testing.T) (*rvdbg.Target, *sim.Target) {
+	drv := sim.New(&sim.Config{
+		IDCode:  0x20000913, // fe310
+		Version: 2,
+		Harts:   2,
+		MXLEN:   32,
+		MISA:    0x40101105, // rv32imacu
+	})
+	tgt, err := rvdbg.New("redv", drv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tgt, drv
+}
+
+// newClient returns a client connected to the server with a pipe.
+func newClient(s *Server) *rpc.Client {
+	sconn, cconn := net.Pipe()
+	go s.ServeConn(sconn)
+	return jsonrpc.NewClient(cconn)
+}
+
+//-----------------------------------------------------------------------------
+
+// testClient runs requests on the current hart of a client.
+func testClient(t *testing.T, c *rpc.Client, drv *sim.Target) {
+	var hi rv.HartInfo
+	err := c.Call("Debugger.Hart", &Empty{}, &hi)
+	if err != nil {
+		t.Fatalf("Hart: %v", err)
+	}
+	h := drv.Hart(hi.ID)
+	// hart control
+	err = c.Call("Debugger.Halt", &Empty{}, &Empty{})
+	if err != nil || !h.Halted {
+		t.Fatalf("Halt: hart%d halted %t %v", hi.ID, h.Halted, err)
+	}
+	// registers
+	err = c.Call("Debugger.WrReg", &RegArgs{Name: "a0", Value: 0xfeed}, &Empty{})
+	if err != nil || h.GPR[10] != 0xfeed {
+		t.Fatalf("WrReg: hart%d a0 0x%x %v, expected 0xfeed", hi.ID, h.GPR[10], err)
+	}
+	var val uint64
+	err = c.Call("Debugger.RdReg", &RegArgs{Name: "a0"}, &val)
+	if err != nil || val != 0xfeed {
+		t.Errorf("RdReg: 0x%x %v, expected 0xfeed", val, err)
+	}
+	// target errors are returned to the client
+	err = c.Call("Debugger.RdReg", &RegArgs{Name: "bogus"}, &val)
+	if err == nil {
+		t.Error("RdReg: expected an error for an unknown register")
+	}
+	// memory write and read back
+	data := []uint{0x12345678, 0xdeadbeef, 0}
+	err = c.Call("Debugger.WrMem", &MemArgs{Width: 32, Addr: ramAddr, Data: data}, &Empty{})
+	if err != nil {
+		t.Fatalf("WrMem: %v", err)
+	}
+	b := drv.RdMem(ramAddr, 4)
+	if !reflect.DeepEqual(b, []byte{0x78, 0x56, 0x34, 0x12}) {
+		t.Errorf("WrMem: memory is %x", b)
+	}
+	var buf []uint
+	err = c.Call("Debugger.RdMem", &MemArgs{Width: 32, Addr: ramAddr, N: 3}, &buf)
+	if err != nil {
+		t.Fatalf("RdMem: %v", err)
+	}
+	if !reflect.DeepEqual(buf, data) {
+		t.Errorf("RdMem: read %x, expected %x", buf, data)
+	}
+	err = c.Call("Debugger.RdMem", &MemArgs{Width: 8, Addr: ramAddr + 4, N: 2}, &buf)
+	if err != nil || !reflect.DeepEqual(buf, []uint{0xef, 0xbe}) {
+		t.Errorf("RdMem: read %x %v, expected [ef be]", buf, err)
+	}
+	// resume
+	err = c.Call("Debugger.Resume", &Empty{}, &Empty{})
+	if err != nil || h.Halted {
+		t.Errorf("Resume: hart%d halted %t %v", hi.ID, h.Halted, err)
+	}
+}
+
+func Test_ServeConn(t *testing.T) {
+	tgt, drv := newTarget(t)
+	s, err := New(tgt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(s)
+	defer c.Close()
+	testClient(t, c, drv)
+	err = c.Call("Debugger.SelectHart", &HartArgs{ID: 2}, &Empty{})
+	if err == nil {
+		t.Error("SelectHart: expected an error for hart2")
+	}
+}
+
+// Test_SelectHart checks each connection keeps its own current hart.
+func Test_SelectHart(t *testing.T) {
+	tgt, drv := newTarget(t)
+	s, err := New(tgt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := []*rpc.Client{newClient(s), newClient(s)}
+	for i := range c {
+		defer c[i].Close()
+	}
+	err = c[1].Call("Debugger.SelectHart", &HartArgs{ID: 1}, &Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range c {
+		err := c[i].Call("Debugger.Halt", &Empty{}, &Empty{})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// interleave register accesses from both clients
+	var wg sync.WaitGroup
+	for i := range c {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				x := uint64(id<<8 | j)
+				err := c[id].Call("Debugger.WrReg", &RegArgs{Name: "a0", Value: x}, &Empty{})
+				if err != nil {
+					t.Errorf("client%d WrReg: %v", id, err)
+					return
+				}
+				var val uint64
+				err = c[id].Call("Debugger.RdReg", &RegArgs{Name: "a0"}, &val)
+				if err != nil || val != x {
+					t.Errorf("client%d RdReg: 0x%x %v, expected 0x%x", id, val, err, x)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i := range c {
+		var hi rv.HartInfo
+		err := c[i].Call("Debugger.Hart", &Empty{}, &hi)
+		if err != nil || hi.ID != i {
+			t.Errorf("client%d Hart: hart%d %v, expected hart%d", i, hi.ID, err, i)
+		}
+		if drv.Hart(i).GPR[10] != uint64(i<<8|19) {
+			t.Errorf("hart%d a0 is 0x%x", i, drv.Hart(i).GPR[10])
+		}
+	}
+}
+
+func Test_Serve(t *testing.T) {
+	tgt, drv := newTarget(t)
+	s, err := New(tgt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Serve()
+	if err == nil {
+		t.Error("Serve: expected an error when not listening")
+	}
+	err = s.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		done <- s.Serve()
+	}()
+	c, err := jsonrpc.Dial("tcp", s.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testClient(t, c, drv)
+	c.Close()
+	// closing the server stops Serve without an error
+	s.Close()
+	err = <-done
+	if err != nil {
+		t.Errorf("Serve: %v after Close", err)
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -32,11 +32,25 @@ type target interface {
 
 //-----------------------------------------------------------------------------
 
+// mapEntry is a peripheral entry in the memory map.
+type mapEntry struct {
+	Name  string `json:"name"`
+	Addr  uint   `json:"addr"`
+	Size  uint   `json:"size"`
+	Descr string `json:"descr,omitempty"`
+}
+
 // CmdMap displays the memory map of the target.
 var CmdMap = cli.Leaf{
 	Descr: "display memory map",
 	F: func(c *cli.CLI, args []string) {
 		dev, drv := c.User.(target).GetSoC()
+		if util.IsJSON(c.User) {
+			for _, p := range dev.Peripherals {
+				util.PutJSON(c.User, &mapEntry{p.Name, p.Addr, p.Size, p.Descr})
+			}
+			return
+		}
 		addrFmt := util.UintFormat(drv.GetAddressSize())
 		s := make([][]string, len(dev.Peripherals))
 		for i, p := range dev.Peripherals {
@@ -69,7 +83,7 @@ var CmdRegs = cli.Leaf{
 
 		err := cli.CheckArgc(args, []int{1, 2})
 		if err != nil {
-			c.User.Put(fmt.Sprintf("%s\n", err))
+			util.PutError(c.User, err)
 			return
 		}
 
@@ -77,30 +91,30 @@ var CmdRegs = cli.Leaf{
 
 		p := dev.GetPeripheral(args[0])
 		if p == nil {
-			c.User.Put(fmt.Sprintf("no peripheral named \"%s\" (run \"map\" for the names)\n", args[0]))
+			util.PutError(c.User, fmt.Errorf("no peripheral named \"%s\" (run \"map\" for the names)", args[0]))
 			return
 		}
 
 		if len(p.Registers) == 0 {
-			c.User.Put(fmt.Sprintf("peripheral \"%s\" has no registers\n", args[0]))
+			util.PutError(c.User, fmt.Errorf("peripheral \"%s\" has no registers", args[0]))
 			return
 		}
 
 		if len(args) == 1 {
-			c.User.Put(fmt.Sprintf("%s\n", p.Display(drv, nil, false)))
+			p.Put(c.User, drv, nil, false)
 			return
 		}
 		if args[1] == "*" {
-			c.User.Put(fmt.Sprintf("%s\n", p.Display(drv, nil, true)))
+			p.Put(c.User, drv, nil, true)
 			return
 		}
 
 		r := p.GetRegister(args[1])
 		if r == nil {
-			c.User.Put(fmt.Sprintf("no register \"%s\" (run \"regs %s\" for the names) ", args[1], args[0]))
+			util.PutError(c.User, fmt.Errorf("no register \"%s\" (run \"regs %s\" for the names)", args[1], args[0]))
 			return
 		}
-		c.User.Put(fmt.Sprintf("%s\n", p.Display(drv, r, true)))
+		p.Put(c.User, drv, r, true)
 
 	},
 }
@@ -64,6 +64,38 @@ func (dev *Device) GetPeripheralRegister(pname, rname string) (*Register, error)
 	return r, nil
 }
 
+// GetPeripheralAt returns the peripheral containing an address.
+func (dev *Device) GetPeripheralAt(addr uint) *Peripheral {
+	if dev == nil {
+		return nil
+	}
+	for i := range dev.Peripherals {
+		p := &dev.Peripherals[i]
+		if addr >= p.Addr && addr-p.Addr < p.Size {
+			return p
+		}
+	}
+	return nil
+}
+
+// Symbol returns a symbol string for an address (E.g. "GPIOA.CTL0" or "GPIOA+0x6").
+// It returns an empty string if the address is not within a peripheral.
+func (dev *Device) Symbol(addr uint) string {
+	p := dev.GetPeripheralAt(addr)
+	if p == nil {
+		return ""
+	}
+	for i := range p.Registers {
+		if p.Addr+p.Registers[i].Offset == addr {
+			return fmt.Sprintf("%s.%s", p.Name, p.Registers[i].Name)
+		}
+	}
+	if addr == p.Addr {
+		return p.Name
+	}
+	return fmt.Sprintf("%s+0x%x", p.Name, addr-p.Addr)
+}
+
 // WrPeripheralRegister writes a register within a peripheral.
 func (dev *Device) WrPeripheralRegister(drv Driver, pname, rname string, val uint) error {
 	r, err := dev.GetPeripheralRegister(pname, rname)
@@ -57,42 +57,70 @@ func (a FieldSet) Less(i, j int) bool {
 
 //-----------------------------------------------------------------------------
 
-// Display returns display strings for a bit field.
-func (f *Field) Display(val uint) []string {
+// FieldValue is the decoded value of a bit field.
+type FieldValue struct {
+	Name    string `json:"name"`
+	Msb     uint   `json:"msb"`
+	Lsb     uint   `json:"lsb"`
+	Value   uint   `json:"value"`
+	ValName string `json:"valname,omitempty"`
+	Changed bool   `json:"changed"`
+	Descr   string `json:"descr,omitempty"`
+}
+
+// Value decodes the value of a bit field from a register value.
+func (f *Field) Value(val uint) FieldValue {
 	// get the field
 	val = util.Bits(val, f.Msb, f.Lsb)
-	// has the value changed?
-	changed := ""
-	if val != f.cacheVal && f.cacheValid {
-		changed = " *"
+	fv := FieldValue{
+		Name:  f.Name,
+		Msb:   f.Msb,
+		Lsb:   f.Lsb,
+		Value: val,
+		Descr: f.Descr,
 	}
+	// has the value changed?
+	fv.Changed = val != f.cacheVal && f.cacheValid
 	f.cacheVal = val
 	f.cacheValid = true
-
-	// field name
-	var nameStr string
-	if f.Msb == f.Lsb {
-		nameStr = fmt.Sprintf("  %s[%d]", f.Name, f.Lsb)
-	} else {
-		nameStr = fmt.Sprintf("  %s[%d:%d]", f.Name, f.Msb, f.Lsb)
-	}
 	// value name
-	valName := ""
 	if f.Fmt != nil {
-		valName = f.Fmt(val)
+		fv.ValName = f.Fmt(val)
 	} else if f.Enums != nil {
 		if s, ok := f.Enums[val]; ok {
-			valName = s
+			fv.ValName = s
 		}
 	}
+	return fv
+}
+
+// Display returns display strings for a bit field.
+func (f *Field) Display(val uint) []string {
+	fv := f.Value(val)
+	return fv.Display()
+}
+
+// Display returns display strings for a bit field value.
+func (fv *FieldValue) Display() []string {
+	changed := ""
+	if fv.Changed {
+		changed = " *"
+	}
+	// field name
+	var nameStr string
+	if fv.Msb == fv.Lsb {
+		nameStr = fmt.Sprintf("  %s[%d]", fv.Name, fv.Lsb)
+	} else {
+		nameStr = fmt.Sprintf("  %s[%d:%d]", fv.Name, fv.Msb, fv.Lsb)
+	}
 	// value string
 	var valStr string
-	if val < 10 {
-		valStr = fmt.Sprintf(": %d %s%s", val, valName, changed)
+	if fv.Value < 10 {
+		valStr = fmt.Sprintf(": %d %s%s", fv.Value, fv.ValName, changed)
 	} else {
-		valStr = fmt.Sprintf(": 0x%x %s%s", val, valName, changed)
+		valStr = fmt.Sprintf(": 0x%x %s%s", fv.Value, fv.ValName, changed)
 	}
-	return []string{nameStr, valStr, "", f.Descr}
+	return []string{nameStr, valStr, "", fv.Descr}
 }
 
 //-----------------------------------------------------------------------------
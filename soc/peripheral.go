@@ -9,9 +9,11 @@ Peripherals
 package soc
 
 import (
+	"fmt"
 	"strings"
 
 	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/util"
 )
 
 //-----------------------------------------------------------------------------
@@ -73,24 +75,45 @@ func (p *Peripheral) RemoveRegister(name string) {
 	}
 }
 
-// Display returns a string for the decoded registers of the peripheral.
-func (p *Peripheral) Display(drv Driver, r *Register, fields bool) string {
-	s := [][]string{}
+// Values returns the decoded register values of the peripheral.
+// If r is nil all registers are decoded.
+func (p *Peripheral) Values(drv Driver, r *Register, fields bool) []*RegisterValue {
+	v := []*RegisterValue{}
 	if r != nil {
 		// decode a single register
 		if !r.ignore && r.regSize(drv) != 0 {
-			s = append(s, r.Display(drv, fields)...)
+			v = append(v, r.Value(drv, fields))
 		}
 	} else {
 		// decode all registers
 		for i := range p.Registers {
 			r := &p.Registers[i]
 			if !r.ignore && r.regSize(drv) != 0 {
-				s = append(s, r.Display(drv, fields)...)
+				v = append(v, r.Value(drv, fields))
 			}
 		}
 	}
+	return v
+}
+
+// Display returns a string for the decoded registers of the peripheral.
+func (p *Peripheral) Display(drv Driver, r *Register, fields bool) string {
+	s := [][]string{}
+	for _, v := range p.Values(drv, r, fields) {
+		s = append(s, v.Display(drv.GetAddressSize())...)
+	}
 	return cli.TableString(s, []int{0, 0, 0, 0}, 1)
 }
 
+// Put outputs the decoded registers of the peripheral to the user.
+func (p *Peripheral) Put(ui cli.USER, drv Driver, r *Register, fields bool) {
+	if util.IsJSON(ui) {
+		for _, v := range p.Values(drv, r, fields) {
+			util.PutJSON(ui, v)
+		}
+		return
+	}
+	ui.Put(fmt.Sprintf("%s\n", p.Display(drv, r, fields)))
+}
+
 //-----------------------------------------------------------------------------
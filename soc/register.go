@@ -79,45 +79,90 @@ func (r *Register) regAddr(drv Driver, idx uint) uint {
 	return r.parent.Addr + r.Offset + (idx * (r.regSize(drv) >> 3))
 }
 
+// RegisterValue is the decoded value of a register.
+type RegisterValue struct {
+	Peripheral string       `json:"peripheral"`
+	Name       string       `json:"name"`
+	Addr       uint         `json:"addr"`
+	Size       uint         `json:"size"`
+	Value      uint         `json:"value"`
+	Changed    bool         `json:"changed"`
+	Descr      string       `json:"descr,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	Fields     []FieldValue `json:"fields,omitempty"`
+	width      uint         // register size used for the value string
+}
+
+// Value reads and decodes the value of a register.
+func (r *Register) Value(drv Driver, fields bool) *RegisterValue {
+	rv := &RegisterValue{
+		Peripheral: r.parent.Name,
+		Name:       r.Name,
+		Addr:       r.regAddr(drv, 0),
+		Size:       r.regSize(drv),
+		Descr:      r.Descr,
+		width:      r.Size,
+	}
+
+	// read the value
+	val, err := drv.Rd(r.Size, rv.Addr)
+	if err != nil {
+		rv.Error = err.Error()
+		return rv
+	}
+	rv.Value = val
+
+	// has the value changed?
+	rv.Changed = val != r.cacheVal && r.cacheValid
+	r.cacheVal = val
+	r.cacheValid = true
+
+	// add field decodes
+	if fields {
+		for i := range r.Fields {
+			rv.Fields = append(rv.Fields, r.Fields[i].Value(val))
+		}
+	}
+	return rv
+}
+
 // Display returns strings for the decode of a register.
 func (r *Register) Display(drv Driver, fields bool) [][]string {
+	return r.Value(drv, fields).Display(drv.GetAddressSize())
+}
+
+// Display returns strings for the decode of a register value.
+func (rv *RegisterValue) Display(addrSize uint) [][]string {
 
 	// address string
-	addr := r.regAddr(drv, 0)
-	fmtStr := fmt.Sprintf(": %s[%%d:0]", util.UintFormat(drv.GetAddressSize()))
-	addrStr := fmt.Sprintf(fmtStr, addr, r.regSize(drv)-1)
+	fmtStr := fmt.Sprintf(": %s[%%d:0]", util.UintFormat(addrSize))
+	addrStr := fmt.Sprintf(fmtStr, rv.Addr, rv.Size-1)
 
-	// read the value
-	val, err := drv.Rd(r.Size, addr)
-	if err != nil {
-		return [][]string{{r.Name, addrStr, "?", util.RedString(err.Error())}}
+	if rv.Error != "" {
+		return [][]string{{rv.Name, addrStr, "?", util.RedString(rv.Error)}}
 	}
 
 	// has the value changed?
 	changed := ""
-	if val != r.cacheVal && r.cacheValid {
+	if rv.Changed {
 		changed = " *"
 	}
-	r.cacheVal = val
-	r.cacheValid = true
 
 	// value string
 	var valStr string
-	if val == 0 {
+	if rv.Value == 0 {
 		valStr = fmt.Sprintf("= 0%s", changed)
 	} else {
-		fmtStr := fmt.Sprintf("= 0x%%0%dx%%s", r.Size>>2)
-		valStr = fmt.Sprintf(fmtStr, val, changed)
+		fmtStr := fmt.Sprintf("= 0x%%0%dx%%s", rv.width>>2)
+		valStr = fmt.Sprintf(fmtStr, rv.Value, changed)
 	}
 
 	s := [][]string{}
-	s = append(s, []string{r.Name, addrStr, valStr, r.Descr})
+	s = append(s, []string{rv.Name, addrStr, valStr, rv.Descr})
 
 	// add field decodes
-	if fields && len(r.Fields) != 0 {
-		for i := range r.Fields {
-			s = append(s, r.Fields[i].Display(val))
-		}
+	for i := range rv.Fields {
+		s = append(s, rv.Fields[i].Display())
 	}
 
 	return s
@@ -133,4 +178,17 @@ func (r *Register) Rd(drv Driver, idx uint) (uint, error) {
 	return drv.Rd(r.regSize(drv), r.regAddr(drv, idx))
 }
 
+// Poll reads the register and returns the value and the prior cached value.
+// The prior value is only valid if the returned flag is true.
+func (r *Register) Poll(drv Driver) (uint, uint, bool, error) {
+	val, err := r.Rd(drv, 0)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	old, valid := r.cacheVal, r.cacheValid
+	r.cacheVal = val
+	r.cacheValid = true
+	return val, old, valid, nil
+}
+
 //-----------------------------------------------------------------------------
@@ -0,0 +1,164 @@
+//-----------------------------------------------------------------------------
+/*
+
+Watch memory locations and peripheral registers.
+
+The target is polled at a regular interval and changed values are displayed.
+
+*/
+//-----------------------------------------------------------------------------
+
+package soc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/expr"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+const defWatchInterval = 500 // default polling interval (milliseconds)
+
+// watch is the state for a watched location.
+type watch struct {
+	drv   Driver    // soc driver
+	name  string    // display name
+	addr  uint      // address of value
+	width uint      // data has width-bit values
+	reg   *Register // peripheral register (or nil for a memory location)
+	val   uint      // cached value for memory locations
+	valid bool      // is the cached value valid?
+}
+
+// newWatch returns a watch for a "peripheral.register" or address argument.
+func newWatch(ctx expr.Context, dev *Device, drv Driver, arg string) (*watch, error) {
+	w := &watch{
+		drv:   drv,
+		name:  arg,
+		width: 32,
+	}
+	if x := strings.Split(arg, "."); len(x) == 2 {
+		r, err := dev.GetPeripheralRegister(x[0], x[1])
+		if err != nil {
+			return nil, err
+		}
+		w.reg = r
+		w.addr = r.regAddr(drv, 0)
+		w.width = r.regSize(drv)
+		return w, nil
+	}
+	maxAddr := uint((1 << drv.GetAddressSize()) - 1)
+	addr, err := expr.UintArg(ctx, arg, [2]uint{0, maxAddr})
+	if err != nil {
+		return nil, err
+	}
+	// round down address to 32-bit byte boundary
+	w.addr = addr & ^uint(3)
+	w.name = fmt.Sprintf(util.UintFormat(drv.GetAddressSize()), w.addr)
+	return w, nil
+}
+
+// poll reads the watched value and returns the value and the prior value.
+// The prior value is only valid if the returned flag is true.
+func (w *watch) poll() (uint, uint, bool, error) {
+	if w.reg != nil {
+		return w.reg.Poll(w.drv)
+	}
+	val, err := w.drv.Rd(w.width, w.addr)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	old, valid := w.val, w.valid
+	w.val = val
+	w.valid = true
+	return val, old, valid, nil
+}
+
+// changeString returns a string for a changed value.
+func (w *watch) changeString(old, val uint) string {
+	fmtVal := util.UintFormat(w.width)
+	s := []string{}
+	s = append(s, fmt.Sprintf("%s: %s -> %s", w.name, util.RedString(fmt.Sprintf(fmtVal, old)), util.GreenString(fmt.Sprintf(fmtVal, val))))
+	// show the changed fields
+	if w.reg != nil {
+		for _, f := range w.reg.Fields {
+			x0 := util.Bits(old, f.Msb, f.Lsb)
+			x1 := util.Bits(val, f.Msb, f.Lsb)
+			if x0 != x1 {
+				s = append(s, fmt.Sprintf("  %s: %s -> %s", f.Name, util.RedString(fmt.Sprintf("0x%x", x0)), util.GreenString(fmt.Sprintf("0x%x", x1))))
+			}
+		}
+	}
+	return strings.Join(s, "\n")
+}
+
+//-----------------------------------------------------------------------------
+
+// WatchHelp is help information for the "watch" command.
+var WatchHelp = []cli.Help{
+	{"<addr/peripheral.register> [interval]", "watch a value for changes"},
+	{"  addr", "address (expression), 32-bit value"},
+	{"  peripheral.register", "register name, see \"regs\" command"},
+	{"  interval", fmt.Sprintf("polling interval in ms (decimal), default is %d", defWatchInterval)},
+}
+
+// CmdWatch polls a memory location or peripheral register and displays changed values.
+var CmdWatch = cli.Leaf{
+	Descr: "watch a value for changes",
+	F: func(c *cli.CLI, args []string) {
+		err := cli.CheckArgc(args, []int{1, 2})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		dev, drv := c.User.(target).GetSoC()
+		w, err := newWatch(expr.GetContext(c.User), dev, drv, args[0])
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		interval := defWatchInterval
+		if len(args) == 2 {
+			interval, err = cli.IntArg(args[1], [2]int{10, 60000}, 10)
+			if err != nil {
+				util.PutError(c.User, err)
+				return
+			}
+		}
+		// initial value
+		val, _, _, err := w.poll()
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		fmtStr := fmt.Sprintf("%%s: %s (ctrl-d to exit)\n", util.UintFormat(w.width))
+		c.User.Put(fmt.Sprintf(fmtStr, w.name, val))
+		// poll for changes
+		start := time.Now()
+		next := start
+		c.Loop(func() bool {
+			if time.Now().Before(next) {
+				time.Sleep(10 * time.Millisecond)
+				return false
+			}
+			next = next.Add(time.Duration(interval) * time.Millisecond)
+			val, old, valid, err := w.poll()
+			if err != nil {
+				util.PutError(c.User, err)
+				return true
+			}
+			if valid && val != old {
+				t := time.Now().Sub(start).Seconds()
+				c.User.Put(fmt.Sprintf("[%8.3fs] %s\n", t, w.changeString(old, val)))
+			}
+			return false
+		}, cli.KeycodeCtrlD)
+	},
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,218 @@
+//-----------------------------------------------------------------------------
+/*
+
+Batch Mode
+
+Run commands from the command line or a script file without a terminal.
+Commands are dispatched through the same menu tree as the interactive CLI.
+
+Script syntax:
+
+Multiple commands on a line are separated with ";".
+"#" at the start of a command or after whitespace, and outside of quotes,
+starts a comment that runs to the end of the line.
+"sleep <seconds>" pauses execution (fractional seconds are allowed).
+"set -e" stops execution on the first command error (the default).
+"set +e" continues execution after a command error.
+
+*/
+//-----------------------------------------------------------------------------
+
+package target
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+// lookupLeaf finds the leaf function for a command within a menu tree.
+// It returns the leaf and the remaining arguments.
+func lookupLeaf(menu []cli.MenuItem, args []string) (*cli.Leaf, []string, error) {
+	path := []string{}
+	for len(args) != 0 {
+		var item cli.MenuItem
+		for _, x := range menu {
+			if name, ok := x[0].(string); ok && name == args[0] {
+				item = x
+				break
+			}
+		}
+		path = append(path, args[0])
+		if item == nil {
+			return nil, nil, fmt.Errorf("unknown command \"%s\"", strings.Join(path, " "))
+		}
+		args = args[1:]
+		switch v := item[1].(type) {
+		case cli.Leaf:
+			return &v, args, nil
+		case cli.Menu:
+			menu = v
+		case []cli.MenuItem:
+			menu = v
+		default:
+			return nil, nil, fmt.Errorf("bad menu item \"%s\"", strings.Join(path, " "))
+		}
+	}
+	return nil, nil, fmt.Errorf("incomplete command \"%s\"", strings.Join(path, " "))
+}
+
+//-----------------------------------------------------------------------------
+
+// stripComment removes a comment from a line.
+func stripComment(line string) string {
+	var quote rune
+	prev := ' '
+	for i, c := range line {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (prev == ';' || unicode.IsSpace(prev)):
+			return line[:i]
+		}
+		prev = c
+	}
+	return line
+}
+
+//-----------------------------------------------------------------------------
+
+// Batch is the state for running non-interactive commands.
+type Batch struct {
+	c           *cli.CLI        // cli with the target as the user
+	tgt         Target          // target
+	stopOnError bool            // stop on the first command error
+	errors      int             // number of command errors
+	exit        *util.ExitError // exit code error (E.g. from semihosting)
+}
+
+// NewBatch returns the state for running non-interactive commands.
+func NewBatch(c *cli.CLI, tgt Target) *Batch {
+	return &Batch{
+		c:           c,
+		tgt:         tgt,
+		stopOnError: true,
+	}
+}
+
+// builtin runs the batch mode builtin commands.
+// It returns true if the command was a builtin.
+func (b *Batch) builtin(args []string) (bool, error) {
+	switch args[0] {
+	case "sleep":
+		if len(args) != 2 {
+			return true, errors.New("usage: sleep <seconds>")
+		}
+		t, err := strconv.ParseFloat(args[1], 64)
+		if err != nil || t < 0 {
+			return true, fmt.Errorf("bad sleep time \"%s\"", args[1])
+		}
+		time.Sleep(time.Duration(t * float64(time.Second)))
+		return true, nil
+	case "set":
+		if len(args) != 2 || (args[1] != "-e" && args[1] != "+e") {
+			return true, errors.New("usage: set -e|+e")
+		}
+		b.stopOnError = args[1] == "-e"
+		return true, nil
+	}
+	return false, nil
+}
+
+// command runs a single command.
+func (b *Batch) command(args []string) error {
+	ok, err := b.builtin(args)
+	if ok {
+		return err
+	}
+	leaf, args, err := lookupLeaf(b.tgt.GetMenuRoot(), args)
+	if err != nil {
+		return err
+	}
+	// clear any stale error, run the command, and check for a new error
+	b.tgt.GetError()
+	leaf.F(b.c, args)
+	return b.tgt.GetError()
+}
+
+// Line runs the commands on a line.
+// It returns an error if execution should stop.
+func (b *Batch) Line(line string) error {
+	for _, cmd := range strings.Split(stripComment(line), ";") {
+		args := strings.Fields(cmd)
+		if len(args) == 0 {
+			continue
+		}
+		if !b.c.Running() {
+			// the exit command was run
+			return nil
+		}
+		err := b.command(args)
+		if err != nil {
+			b.errors++
+			if ee, ok := err.(*util.ExitError); ok {
+				// keep the exit code for the caller
+				b.exit = ee
+				if b.stopOnError {
+					return ee
+				}
+				continue
+			}
+			if b.stopOnError {
+				return fmt.Errorf("\"%s\": %s", strings.Join(args, " "), err)
+			}
+		}
+	}
+	return nil
+}
+
+// Script runs the commands from a script file.
+// It returns an error if execution should stop.
+func (b *Batch) Script(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	n := 0
+	for scanner.Scan() {
+		n++
+		err := b.Line(scanner.Text())
+		if _, ok := err.(*util.ExitError); ok {
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("%s:%d: %s", name, n, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// Status returns an error if any command failed.
+// An exit code error is returned in preference to the error count.
+func (b *Batch) Status() error {
+	if b.exit != nil {
+		return b.exit
+	}
+	if b.errors != 0 {
+		return fmt.Errorf("%d command(s) failed", b.errors)
+	}
+	return nil
+}
+
+//-----------------------------------------------------------------------------
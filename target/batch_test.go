@@ -0,0 +1,187 @@
+//-----------------------------------------------------------------------------
+/*
+
+Batch Mode Tests
+
+*/
+//-----------------------------------------------------------------------------
+
+package target
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/util"
+)
+
+//-----------------------------------------------------------------------------
+
+// testTarget records the commands that were run.
+type testTarget struct {
+	Errors
+	Output
+	cmds []string
+}
+
+func (t *testTarget) GetPrompt() string { return "test> " }
+func (t *testTarget) Shutdown()         {}
+func (t *testTarget) Put(s string)      {}
+
+func (t *testTarget) GetMenuRoot() []cli.MenuItem {
+	return []cli.MenuItem{
+		{"ok", cli.Leaf{F: func(c *cli.CLI, args []string) {
+			t.cmds = append(t.cmds, strings.Join(append([]string{"ok"}, args...), " "))
+		}}},
+		{"fail", cli.Leaf{F: func(c *cli.CLI, args []string) {
+			t.cmds = append(t.cmds, "fail")
+			util.PutError(c.User, errors.New("failed"))
+		}}},
+		{"sub", cli.Menu{
+			{"cmd", cli.Leaf{F: func(c *cli.CLI, args []string) {
+				t.cmds = append(t.cmds, "sub cmd")
+			}}},
+		}},
+		{"exit", CmdExit},
+	}
+}
+
+func newTestBatch() (*Batch, *testTarget) {
+	t := &testTarget{}
+	return NewBatch(cli.NewCLI(t), t), t
+}
+
+//-----------------------------------------------------------------------------
+
+func Test_Line(t *testing.T) {
+	tests := []struct {
+		line string
+		cmds []string
+		err  bool
+	}{
+		{"", nil, false},
+		{"ok", []string{"ok"}, false},
+		{"ok a; ok b ;; ok c", []string{"ok a", "ok b", "ok c"}, false},
+		{"sub cmd", []string{"sub cmd"}, false},
+		// comments
+		{"# comment", nil, false},
+		{"  # comment; ok a", nil, false},
+		{"ok a # comment; ok b", []string{"ok a"}, false},
+		{"ok a;# comment", []string{"ok a"}, false},
+		{"ok a#b", []string{"ok a#b"}, false},
+		{"ok 1#2 # comment", []string{"ok 1#2"}, false},
+		{"ok \"a # b\" # comment", []string{"ok \"a # b\""}, false},
+		{"ok 'a #b'", []string{"ok 'a #b'"}, false},
+		// builtins
+		{"sleep 0; ok", []string{"ok"}, false},
+		{"sleep", nil, true},
+		{"sleep x", nil, true},
+		{"set -x", nil, true},
+		// bad commands
+		{"bad", nil, true},
+		{"sub", nil, true},
+		{"sub bad", nil, true},
+		// exit stops the remaining commands
+		{"ok a; exit; ok b", []string{"ok a"}, false},
+	}
+	for _, v := range tests {
+		b, tgt := newTestBatch()
+		err := b.Line(v.line)
+		if (err != nil) != v.err {
+			t.Errorf("%q: error %v", v.line, err)
+		}
+		if strings.Join(tgt.cmds, ",") != strings.Join(v.cmds, ",") {
+			t.Errorf("%q: ran %q, expected %q", v.line, tgt.cmds, v.cmds)
+		}
+	}
+}
+
+func Test_StopOnError(t *testing.T) {
+	// stop on the first error
+	b, tgt := newTestBatch()
+	err := b.Line("ok a; fail; ok b")
+	if err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("set -e: error %v", err)
+	}
+	if strings.Join(tgt.cmds, ",") != "ok a,fail" {
+		t.Errorf("set -e: ran %q", tgt.cmds)
+	}
+	if b.Status() == nil {
+		t.Error("set -e: expected a status error")
+	}
+	// continue after an error
+	b, tgt = newTestBatch()
+	err = b.Line("set +e; ok a; fail; bad; ok b")
+	if err != nil {
+		t.Errorf("set +e: error %v", err)
+	}
+	if strings.Join(tgt.cmds, ",") != "ok a,fail,ok b" {
+		t.Errorf("set +e: ran %q", tgt.cmds)
+	}
+	err = b.Status()
+	if err == nil || err.Error() != "2 command(s) failed" {
+		t.Errorf("set +e: status %v", err)
+	}
+	// a successful command does not report a stale error
+	b, tgt = newTestBatch()
+	tgt.SetError(errors.New("stale"))
+	err = b.Line("ok")
+	if err != nil || b.Status() != nil {
+		t.Errorf("stale error: error %v status %v", err, b.Status())
+	}
+	// exit code errors are returned to the caller
+	ee := &util.ExitError{Code: 3}
+	tgt = &testTarget{}
+	b = NewBatch(cli.NewCLI(tgt), &exitTarget{testTarget: tgt, err: ee})
+	err = b.Line("ok; ok")
+	if err != ee || b.Status() != ee {
+		t.Errorf("exit code: error %v status %v", err, b.Status())
+	}
+	if len(tgt.cmds) != 1 {
+		t.Errorf("exit code: ran %q", tgt.cmds)
+	}
+}
+
+func Test_ExitStatus(t *testing.T) {
+	for _, v := range []struct{ code, status int }{
+		{0, 0}, {1, 1}, {3, 3}, {255, 255}, {256, 1}, {257, 1}, {512, 1}, {-1, 255},
+	} {
+		status := (&util.ExitError{Code: v.code}).Status()
+		if status != v.status {
+			t.Errorf("code %d: status %d, expected %d", v.code, status, v.status)
+		}
+	}
+}
+
+// exitTarget reports an exit code error for every command.
+type exitTarget struct {
+	*testTarget
+	err error
+}
+
+func (t *exitTarget) GetError() error {
+	t.testTarget.GetError()
+	return t.err
+}
+
+func Test_Script(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "test.rvdbg")
+	err := os.WriteFile(name, []byte("# test script\nok a\n\nfail\nok b\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, tgt := newTestBatch()
+	err = b.Script(name)
+	if err == nil || !strings.HasPrefix(err.Error(), name+":4:") {
+		t.Errorf("error %v", err)
+	}
+	if strings.Join(tgt.cmds, ",") != "ok a,fail" {
+		t.Errorf("ran %q", tgt.cmds)
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -20,11 +20,14 @@ import (
 	"github.com/deadsy/rvdbg/cpu/riscv"
 	"github.com/deadsy/rvdbg/cpu/riscv/rv"
 	"github.com/deadsy/rvdbg/cpu/riscv/rv13"
+	"github.com/deadsy/rvdbg/elf"
+	"github.com/deadsy/rvdbg/expr"
 	"github.com/deadsy/rvdbg/flash"
 	"github.com/deadsy/rvdbg/gpio"
 	"github.com/deadsy/rvdbg/itf"
 	"github.com/deadsy/rvdbg/jtag"
 	"github.com/deadsy/rvdbg/mem"
+	"github.com/deadsy/rvdbg/rtt"
 	"github.com/deadsy/rvdbg/soc"
 	"github.com/deadsy/rvdbg/target"
 )
@@ -44,23 +47,39 @@ var Info = target.Info{
 
 // menuRoot is the root menu.
 var menuRoot = cli.Menu{
+	{"break", riscv.CmdBreak, riscv.BreakHelp},
+	{"bt", riscv.CmdBacktrace, riscv.BacktraceHelp},
 	{"cpu", riscv.Menu, "cpu functions"},
 	{"csr", riscv.CmdCSR, riscv.CsrHelp},
 	{"da", riscv.CmdDisassemble, riscv.DisassembleHelp},
 	{"dbg", rv13.Menu, "debugger functions"},
+	{"elf", elf.CmdElf, elf.ElfHelp},
 	{"exit", target.CmdExit},
 	{"flash", flash.Menu, "flash functions"},
+	{"format", target.CmdFormat, target.FormatHelp},
 	{"gpio", gpio.Menu, "gpio functions"},
-	{"gpr", riscv.CmdGpr},
-	{"halt", riscv.CmdHalt},
+	{"gpr", riscv.CmdGpr, riscv.GprHelp},
+	{"halt", riscv.CmdHalt, riscv.HaltHelp},
 	{"hart", riscv.CmdHart, riscv.HartHelp},
 	{"help", target.CmdHelp},
 	{"history", target.CmdHistory, cli.HistoryHelp},
 	{"jtag", jtag.Menu, "jtag functions"},
 	{"map", soc.CmdMap},
 	{"mem", mem.Menu, "memory functions"},
+	{"perf", riscv.CmdPerf, riscv.PerfHelp},
+	{"pmp", riscv.CmdPmp, riscv.PmpHelp},
+	{"profile", riscv.CmdProfile, riscv.ProfileHelp},
 	{"regs", soc.CmdRegs, soc.RegsHelp},
-	{"resume", riscv.CmdResume},
+	{"reset", riscv.CmdReset, riscv.ResetHelp},
+	{"resume", riscv.CmdResume, riscv.ResumeHelp},
+	{"rtt", rtt.Menu, "rtt functions"},
+	{"semihosting", riscv.CmdSemihosting, riscv.SemihostingHelp},
+	{"threads", riscv.CmdThreads, riscv.ThreadsHelp},
+	{"trap", riscv.CmdTrap, riscv.TrapHelp},
+	{"trig", riscv.CmdTrigger},
+	{"vregs", riscv.CmdVregs, riscv.VregsHelp},
+	{"vtop", riscv.CmdVtop, riscv.VtopHelp},
+	{"watch", soc.CmdWatch, soc.WatchHelp},
 }
 
 //-----------------------------------------------------------------------------
@@ -165,6 +184,11 @@ var gpioNames = map[string]string{
 
 // Target is the application structure for the target.
 type Target struct {
+	target.Errors
+	target.Output
+	mem.Snapshots
+	rtt.State
+	riscv.CmdState
 	jtagDevice  *jtag.Device
 	rvDebug     rv.Debug
 	socDevice   *soc.Device
@@ -208,7 +232,7 @@ func New(jtagDriver jtag.Driver) (target.Target, error) {
 		return nil, err
 	}
 
-	rvDebug, err := riscv.NewDebug(jtagDevice)
+	rvDebug, err := riscv.NewDebug(jtagDevice, Info.Auth)
 	if err != nil {
 		return nil, err
 	}
@@ -268,6 +292,11 @@ func (t *Target) GetFlashDriver() flash.Driver {
 	return t.flashDriver
 }
 
+// GetExprContext returns the context for expression evaluation.
+func (t *Target) GetExprContext() expr.Context {
+	return t.memDriver.GetExprContext()
+}
+
 // GetRiscvDebug returns a RISC-V debug driver for this target.
 func (t *Target) GetRiscvDebug() rv.Debug {
 	return t.rvDebug
@@ -11,7 +11,9 @@ This code implements the mem.Driver interface.
 package redv
 
 import (
+	"github.com/deadsy/rvdbg/cpu/riscv"
 	"github.com/deadsy/rvdbg/cpu/riscv/rv"
+	"github.com/deadsy/rvdbg/expr"
 	"github.com/deadsy/rvdbg/mem"
 	"github.com/deadsy/rvdbg/soc"
 )
@@ -19,14 +21,16 @@ import (
 //-----------------------------------------------------------------------------
 
 type memDriver struct {
+	riscv.MemModes
 	dbg rv.Debug
 	dev *soc.Device
 }
 
 func newMemDriver(dbg rv.Debug, dev *soc.Device) *memDriver {
 	return &memDriver{
-		dbg: dbg,
-		dev: dev,
+		MemModes: riscv.NewMemModes(dbg),
+		dbg:      dbg,
+		dev:      dev,
 	}
 }
 
@@ -54,6 +58,11 @@ func (m *memDriver) RdMem(width, addr, n uint) ([]uint, error) {
 	return m.dbg.RdMem(width, addr, n)
 }
 
+// GetExprContext returns the context for expression evaluation.
+func (m *memDriver) GetExprContext() expr.Context {
+	return riscv.NewContext(m.dbg, m.dev)
+}
+
 // WrMem wirtes n x width-bit values to memory.
 func (m *memDriver) WrMem(width, addr uint, val []uint) error {
 	return m.dbg.WrMem(width, addr, val)
@@ -20,9 +20,12 @@ import (
 	"github.com/deadsy/rvdbg/cpu/riscv"
 	"github.com/deadsy/rvdbg/cpu/riscv/rv"
 	"github.com/deadsy/rvdbg/cpu/riscv/rv13"
+	"github.com/deadsy/rvdbg/elf"
+	"github.com/deadsy/rvdbg/expr"
 	"github.com/deadsy/rvdbg/itf"
 	"github.com/deadsy/rvdbg/jtag"
 	"github.com/deadsy/rvdbg/mem"
+	"github.com/deadsy/rvdbg/rtt"
 	"github.com/deadsy/rvdbg/soc"
 	"github.com/deadsy/rvdbg/target"
 )
@@ -43,27 +46,48 @@ var Info = target.Info{
 
 // menuRoot is the root menu.
 var menuRoot = cli.Menu{
+	{"break", riscv.CmdBreak, riscv.BreakHelp},
+	{"bt", riscv.CmdBacktrace, riscv.BacktraceHelp},
 	{"cpu", riscv.Menu, "cpu functions"},
 	{"csr", riscv.CmdCSR, riscv.CsrHelp},
 	{"da", riscv.CmdDisassemble, riscv.DisassembleHelp},
 	{"dbg", rv13.Menu, "debugger functions"},
+	{"elf", elf.CmdElf, elf.ElfHelp},
 	{"exit", target.CmdExit},
-	{"gpr", riscv.CmdGpr},
-	{"halt", riscv.CmdHalt},
+	{"format", target.CmdFormat, target.FormatHelp},
+	{"gpr", riscv.CmdGpr, riscv.GprHelp},
+	{"halt", riscv.CmdHalt, riscv.HaltHelp},
 	{"hart", riscv.CmdHart, riscv.HartHelp},
 	{"help", target.CmdHelp},
 	{"history", target.CmdHistory, cli.HistoryHelp},
 	{"jtag", jtag.Menu, "jtag functions"},
 	{"map", soc.CmdMap},
 	{"mem", mem.Menu, "memory functions"},
+	{"perf", riscv.CmdPerf, riscv.PerfHelp},
+	{"pmp", riscv.CmdPmp, riscv.PmpHelp},
+	{"profile", riscv.CmdProfile, riscv.ProfileHelp},
 	{"regs", soc.CmdRegs, soc.RegsHelp},
-	{"resume", riscv.CmdResume},
+	{"reset", riscv.CmdReset, riscv.ResetHelp},
+	{"resume", riscv.CmdResume, riscv.ResumeHelp},
+	{"rtt", rtt.Menu, "rtt functions"},
+	{"semihosting", riscv.CmdSemihosting, riscv.SemihostingHelp},
+	{"threads", riscv.CmdThreads, riscv.ThreadsHelp},
+	{"trap", riscv.CmdTrap, riscv.TrapHelp},
+	{"trig", riscv.CmdTrigger},
+	{"vregs", riscv.CmdVregs, riscv.VregsHelp},
+	{"vtop", riscv.CmdVtop, riscv.VtopHelp},
+	{"watch", soc.CmdWatch, soc.WatchHelp},
 }
 
 //-----------------------------------------------------------------------------
 
 // Target is the application structure for the target.
 type Target struct {
+	target.Errors
+	target.Output
+	mem.Snapshots
+	rtt.State
+	riscv.CmdState
 	jtagDevice *jtag.Device
 	rvDebug    rv.Debug
 	socDevice  *soc.Device
@@ -104,7 +128,7 @@ func New(jtagDriver jtag.Driver) (target.Target, error) {
 	}
 
 	// create the CPU debug interface
-	rvDebug, err := riscv.NewDebug(jtagDevice)
+	rvDebug, err := riscv.NewDebug(jtagDevice, Info.Auth)
 	if err != nil {
 		return nil, err
 	}
@@ -151,6 +175,11 @@ func (t *Target) GetMemoryDriver() mem.Driver {
 	return t.memDriver
 }
 
+// GetExprContext returns the context for expression evaluation.
+func (t *Target) GetExprContext() expr.Context {
+	return t.memDriver.GetExprContext()
+}
+
 // GetRiscvDebug returns a RISC-V debug driver for this target.
 func (t *Target) GetRiscvDebug() rv.Debug {
 	return t.rvDebug
@@ -9,10 +9,13 @@ Common target functions.
 package target
 
 import (
+	"fmt"
 	"sort"
 
 	cli "github.com/deadsy/go-cli"
+	"github.com/deadsy/rvdbg/cpu/riscv/rv"
 	"github.com/deadsy/rvdbg/itf"
+	"github.com/deadsy/rvdbg/util"
 )
 
 //-----------------------------------------------------------------------------
@@ -22,16 +25,55 @@ type Target interface {
 	GetMenuRoot() []cli.MenuItem
 	Shutdown()
 	Put(s string)
+	SetError(err error)
+	GetError() error
+	SetFormat(f util.Format)
+	GetFormat() util.Format
+}
+
+// Errors records the first command error. It is embedded in a target.
+type Errors struct {
+	err error
+}
+
+// SetError records a command error.
+func (e *Errors) SetError(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// GetError returns and clears the recorded command error.
+func (e *Errors) GetError() error {
+	err := e.err
+	e.err = nil
+	return err
+}
+
+// Output stores the output format for command results. It is embedded in a target.
+type Output struct {
+	format util.Format
+}
+
+// SetFormat sets the output format.
+func (o *Output) SetFormat(f util.Format) {
+	o.format = f
+}
+
+// GetFormat returns the output format.
+func (o *Output) GetFormat() util.Format {
+	return o.format
 }
 
 // Info provides general target information.
 type Info struct {
-	Name     string   // short name for target (command line)
-	Descr    string   // description of target
-	DbgType  itf.Type // default debugger type
-	DbgMode  itf.Mode // debugger interface mode
-	DbgSpeed int      // debugger clock speed
-	Volts    int      // target voltage
+	Name     string           // short name for target (command line)
+	Descr    string           // description of target
+	DbgType  itf.Type         // default debugger type
+	DbgMode  itf.Mode         // debugger interface mode
+	DbgSpeed int              // debugger clock speed
+	Volts    int              // target voltage
+	Auth     rv.Authenticator // debug module authentication (nil == none)
 }
 
 //-----------------------------------------------------------------------------
@@ -83,6 +125,37 @@ var CmdHistory = cli.Leaf{
 	},
 }
 
+// FormatHelp is help information for the "format" command.
+var FormatHelp = []cli.Help{
+	{"[text|json]", "set the output format"},
+	{"<cr>", "display the output format"},
+}
+
+// CmdFormat sets the output format for command results.
+var CmdFormat = cli.Leaf{
+	Descr: "command output format",
+	F: func(c *cli.CLI, args []string) {
+		t := c.User.(Target)
+		err := cli.CheckArgc(args, []int{0, 1})
+		if err != nil {
+			util.PutError(c.User, err)
+			return
+		}
+		if len(args) == 0 {
+			c.User.Put(fmt.Sprintf("%s\n", t.GetFormat()))
+			return
+		}
+		switch args[0] {
+		case "text":
+			t.SetFormat(util.FormatText)
+		case "json":
+			t.SetFormat(util.FormatJSON)
+		default:
+			util.PutError(c.User, fmt.Errorf("unknown format \"%s\"", args[0]))
+		}
+	},
+}
+
 // CmdExit exits the CLI.
 var CmdExit = cli.Leaf{
 	Descr: "exit application",
@@ -37,6 +37,7 @@ var Info = target.Info{
 // menuRoot is the root menu.
 var menuRoot = cli.Menu{
 	{"exit", target.CmdExit},
+	{"format", target.CmdFormat, target.FormatHelp},
 	{"help", target.CmdHelp},
 	{"history", target.CmdHistory, cli.HistoryHelp},
 	{"jtag", jtag.Menu, "jtag functions"},
@@ -46,6 +47,8 @@ var menuRoot = cli.Menu{
 
 // Target is the application structure for the target.
 type Target struct {
+	target.Errors
+	target.Output
 	jtagDriver jtag.Driver
 	jtagChain  *jtag.Chain
 	jtagDevice *jtag.Device
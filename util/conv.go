@@ -178,23 +178,19 @@ func ConvertToUint8(width uint, buf []uint) []uint8 {
 func ConvertToUint(width uint, in []uint8, out []uint) {
 	switch width {
 	case 64:
-		out := make([]uint, len(in)>>3)
-		for i := range out {
+		for i := 0; i < len(in)>>3; i++ {
 			out[i] = uint(binary.LittleEndian.Uint64(in[i<<3:]))
 		}
 	case 32:
-		out := make([]uint, len(in)>>2)
-		for i := range out {
+		for i := 0; i < len(in)>>2; i++ {
 			out[i] = uint(binary.LittleEndian.Uint32(in[i<<2:]))
 		}
 	case 16:
-		out := make([]uint, len(in)>>1)
-		for i := range out {
+		for i := 0; i < len(in)>>1; i++ {
 			out[i] = uint(binary.LittleEndian.Uint16(in[i<<1:]))
 		}
 	case 8:
-		out := make([]uint, len(in))
-		for i := range out {
+		for i := range in {
 			out[i] = uint(in[i])
 		}
 	default:
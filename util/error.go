@@ -0,0 +1,65 @@
+//-----------------------------------------------------------------------------
+/*
+
+Command Error Reporting
+
+CLI leaf functions don't return errors, so errors are reported to the user.
+If the user records errors (E.g. for non-interactive operation) they are
+passed along so the caller can see that a command failed.
+
+*/
+//-----------------------------------------------------------------------------
+
+package util
+
+import (
+	"fmt"
+
+	"github.com/deadsy/go-cli"
+)
+
+//-----------------------------------------------------------------------------
+
+// ErrorUser is a user interface that records command errors.
+type ErrorUser interface {
+	SetError(err error) // record a command error
+}
+
+// SetError records a command error without displaying it.
+func SetError(ui cli.USER, err error) {
+	if eu, ok := ui.(ErrorUser); ok {
+		eu.SetError(err)
+	}
+}
+
+// ExitError is a command error with a process exit code (E.g. a firmware exit code).
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("firmware exit code %d", e.Code)
+}
+
+// Status returns the process exit status for the exit code.
+// A nonzero code with a zero low byte (E.g. 256) maps to 1, so the exit is not a success.
+func (e *ExitError) Status() int {
+	if e.Code != 0 && e.Code&0xff == 0 {
+		return 1
+	}
+	return e.Code & 0xff
+}
+
+// PutError displays and records a command error.
+func PutError(ui cli.USER, err error) {
+	SetError(ui, err)
+	if IsJSON(ui) {
+		PutJSON(ui, struct {
+			Error string `json:"error"`
+		}{err.Error()})
+		return
+	}
+	ui.Put(fmt.Sprintf("%s\n", err))
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,69 @@
+//-----------------------------------------------------------------------------
+/*
+
+Output Format
+
+Commands normally produce human readable text. For use by other programs
+the user can select JSON output, in which case each result is output as a
+single line JSON object.
+
+*/
+//-----------------------------------------------------------------------------
+
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/deadsy/go-cli"
+)
+
+//-----------------------------------------------------------------------------
+
+// Format is the output format for command results.
+type Format int
+
+// Format values.
+const (
+	FormatText Format = iota // human readable text
+	FormatJSON               // one JSON object per result
+)
+
+var formatName = map[Format]string{
+	FormatText: "text",
+	FormatJSON: "json",
+}
+
+func (f Format) String() string {
+	if name, ok := formatName[f]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// FormatUser is a user interface with a selectable output format.
+type FormatUser interface {
+	GetFormat() Format // get the output format
+}
+
+// IsJSON returns true if the user has selected JSON output.
+func IsJSON(ui cli.USER) bool {
+	if fu, ok := ui.(FormatUser); ok {
+		return fu.GetFormat() == FormatJSON
+	}
+	return false
+}
+
+// PutJSON outputs a value as a single line JSON object.
+func PutJSON(ui cli.USER, v interface{}) {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		buf, _ = json.Marshal(struct {
+			Error string `json:"error"`
+		}{err.Error()})
+	}
+	ui.Put(fmt.Sprintf("%s\n", buf))
+}
+
+//-----------------------------------------------------------------------------